@@ -1,5 +1,12 @@
 import AsyncStorage from "@react-native-async-storage/async-storage";
-import React, { createContext, useState, ReactNode, useEffect } from "react";
+import React, { createContext, useState, ReactNode, useEffect, useRef } from "react";
+
+import {
+  ApiError,
+  RefreshTokenResponse,
+  logout as logoutRequest,
+  refreshToken as refreshTokenRequest,
+} from "../requests/userRequests";
 
 /**
  * User data structure
@@ -11,6 +18,7 @@ export interface User {
   firstName: string;
   lastName: string;
   token: string;
+  refreshToken: string;
 }
 
 /**
@@ -21,6 +29,7 @@ interface UserContextType {
   setUser: (user: User | null) => void;
   isLoading: boolean;
   logout: () => void;
+  withAuthToken: <T>(request: (authToken: string) => Promise<T>) => Promise<T>;
 }
 
 /**
@@ -43,6 +52,11 @@ export const UserProvider = ({ children }: UserProviderProps) => {
   const [user, setUser] = useState<User | null>(null);
   const [isLoading, setIsLoading] = useState<boolean>(true);
 
+  // The latest user and the refresh in progress, read by requests that started before a re-render
+  const userRef = useRef<User | null>(null);
+  const refreshRef = useRef<Promise<RefreshTokenResponse> | null>(null);
+  userRef.current = user;
+
   // Load saved user on initial mount
   useEffect(() => {
     const loadUser = async () => {
@@ -79,10 +93,72 @@ export const UserProvider = ({ children }: UserProviderProps) => {
   }, [user]);
 
   /**
-   * Logs out the current user
+   * Runs an authenticated request with the current access token. Access tokens are short-lived, so
+   * when the request is rejected with 401 the token pair is refreshed once and the request retried.
+   * A session whose refresh token is no longer accepted is signed out.
+   * @param request - Request to run with an access token
+   * @returns The result of the request
+   * @throws {ApiError} If the request fails
+   */
+  const withAuthToken = async <T,>(request: (authToken: string) => Promise<T>): Promise<T> => {
+    const currentUser = userRef.current;
+    if (!currentUser) {
+      throw new ApiError("Not logged in", 401);
+    }
+
+    try {
+      return await request(currentUser.token);
+    } catch (error) {
+      if (!(error instanceof ApiError) || error.statusCode !== 401) {
+        throw error;
+      }
+    }
+
+    // Refresh tokens are single-use, so concurrent requests share one refresh
+    if (!refreshRef.current) {
+      refreshRef.current = refreshTokenRequest(currentUser.refreshToken).finally(() => {
+        refreshRef.current = null;
+      });
+    }
+
+    let tokens: RefreshTokenResponse;
+    try {
+      tokens = await refreshRef.current;
+    } catch (error) {
+      if (error instanceof ApiError && error.statusCode === 401) {
+        setUser(null);
+      }
+      throw error;
+    }
+
+    // The rotated pair replaces the old one, which the server no longer accepts, and is persisted
+    const refreshedUser = {
+      ...currentUser,
+      token: tokens.token,
+      refreshToken: tokens.refresh_token,
+    };
+    if (userRef.current?.refreshToken === currentUser.refreshToken) {
+      userRef.current = refreshedUser;
+      setUser(refreshedUser);
+    }
+    return await request(tokens.token);
+  };
+
+  /**
+   * Logs out the current user, revoking the session's tokens on the server
    */
-  // eslint-disable-next-line @typescript-eslint/require-await
   const logout = async () => {
+    if (user) {
+      try {
+        // Read when the request runs, refreshing the access token replaces the refresh token
+        await withAuthToken((authToken) =>
+          logoutRequest(authToken, userRef.current?.refreshToken ?? user.refreshToken),
+        );
+      } catch (error) {
+        // The local session is cleared regardless, the tokens will expire on their own
+        console.error("Failed to revoke session:", error);
+      }
+    }
     setUser(null);
   };
 
@@ -92,6 +168,7 @@ export const UserProvider = ({ children }: UserProviderProps) => {
     setUser,
     isLoading,
     logout,
+    withAuthToken,
   };
 
   return <UserContext.Provider value={value}>{children}</UserContext.Provider>;
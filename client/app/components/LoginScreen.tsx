@@ -77,6 +77,7 @@ export default function LoginScreen({ navigation }: LoginScreenProps) {
         firstName: res.user_info.first_name,
         lastName: res.user_info.last_name,
         token: res.token,
+        refreshToken: res.refresh_token,
       });
 
       navigation.navigate("UserLearnings");
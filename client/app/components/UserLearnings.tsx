@@ -35,7 +35,7 @@ interface LearningSection {
  * organized by categories
  */
 export default function UserLearnings() {
-  const { user, logout } = useUser();
+  const { user, logout, withAuthToken } = useUser();
   const [learningCategories, setLearningCategories] = useState<string[]>([]);
   const [learnings, setLearnings] = useState<LearningSection[]>([]);
   const [newItems, setNewItems] = useState<Record<string, string>>({});
@@ -115,7 +115,7 @@ export default function UserLearnings() {
 
     try {
       setIsSubmitting((prev) => ({ ...prev, [category]: true }));
-      await createLearning(user.token, title, category);
+      await withAuthToken((authToken) => createLearning(authToken, title, category));
 
       // Refresh the learning items
       await fetchLearningItems();
@@ -149,7 +149,7 @@ export default function UserLearnings() {
         onPress: () => {
           void (async () => {
             try {
-              await deleteLearning(user.token, id);
+              await withAuthToken((authToken) => deleteLearning(authToken, id));
 
               // Update local state to reflect the deletion
               setLearnings((prev) =>
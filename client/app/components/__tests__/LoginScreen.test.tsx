@@ -13,6 +13,7 @@ const mockUserContext = {
   setUser: mockSetUser,
   isLoading: false,
   logout: jest.fn(),
+  withAuthToken: jest.fn(),
 };
 
 // Mock the login API call
@@ -90,12 +91,14 @@ describe("LoginScreen", () => {
   it("submits form with correct credentials and navigates on success", async () => {
     (login as jest.Mock).mockResolvedValueOnce({
       token: "test-token",
+      refresh_token: "test-refresh-token",
       user_info: {
         id: 1,
         email: "test@example.com",
         username: "testuser",
         first_name: "John",
         last_name: "Doe",
+        role: "user",
       },
     });
 
@@ -126,6 +129,7 @@ describe("LoginScreen", () => {
         firstName: "John",
         lastName: "Doe",
         token: "test-token",
+        refreshToken: "test-refresh-token",
       });
       expect(mockNavigation.navigate).toHaveBeenCalledWith("UserLearnings");
     });
@@ -0,0 +1,114 @@
+import AsyncStorage from "@react-native-async-storage/async-storage";
+import { render, waitFor, act } from "@testing-library/react-native";
+import React from "react";
+
+import { UserProvider, useUser } from "../../common/UserContext";
+import { ApiError, refreshToken } from "../../requests/userRequests";
+
+jest.mock("../../requests/userRequests", () => {
+  const actual = jest.requireActual<typeof import("../../requests/userRequests")>(
+    "../../requests/userRequests",
+  );
+  return { ...actual, refreshToken: jest.fn(), logout: jest.fn() };
+});
+
+const savedUser = {
+  id: 1,
+  email: "test@example.com",
+  username: "testuser",
+  firstName: "John",
+  lastName: "Doe",
+  token: "expired_token",
+  refreshToken: "valid_refresh_token",
+};
+
+/**
+ * Renders the provider with a saved session and returns the context once it has loaded
+ */
+async function renderWithSavedUser() {
+  (AsyncStorage.getItem as jest.Mock).mockResolvedValueOnce(JSON.stringify(savedUser));
+
+  let context: ReturnType<typeof useUser> | undefined;
+  const Consumer = () => {
+    context = useUser();
+    return null;
+  };
+  render(
+    <UserProvider>
+      <Consumer />
+    </UserProvider>,
+  );
+
+  await waitFor(() => {
+    expect(context?.user).not.toBeNull();
+  });
+  return () => context as ReturnType<typeof useUser>;
+}
+
+describe("UserContext", () => {
+  beforeEach(() => {
+    jest.clearAllMocks();
+  });
+
+  it("refreshes an expired access token once and retries the request", async () => {
+    (refreshToken as jest.Mock).mockResolvedValueOnce({
+      token: "new_token",
+      refresh_token: "new_refresh_token",
+    });
+    const request = jest
+      .fn()
+      .mockRejectedValueOnce(new ApiError("Authentication token has expired", 401))
+      .mockResolvedValueOnce("result");
+
+    const getContext = await renderWithSavedUser();
+    let result: unknown;
+    await act(async () => {
+      result = await getContext().withAuthToken(request);
+    });
+
+    expect(result).toBe("result");
+    expect(refreshToken).toHaveBeenCalledWith("valid_refresh_token");
+    expect(request).toHaveBeenNthCalledWith(1, "expired_token");
+    expect(request).toHaveBeenNthCalledWith(2, "new_token");
+
+    // The rotated pair is kept and persisted for the next requests
+    await waitFor(() => {
+      expect(getContext().user?.refreshToken).toBe("new_refresh_token");
+      expect(AsyncStorage.setItem).toHaveBeenLastCalledWith(
+        "@SoftwareSlayer:user",
+        JSON.stringify({ ...savedUser, token: "new_token", refreshToken: "new_refresh_token" }),
+      );
+    });
+  });
+
+  it("signs out when the refresh token is no longer accepted", async () => {
+    (refreshToken as jest.Mock).mockRejectedValueOnce(
+      new ApiError("Invalid or expired refresh token", 401),
+    );
+    const request = jest
+      .fn()
+      .mockRejectedValue(new ApiError("Authentication token has expired", 401));
+
+    const getContext = await renderWithSavedUser();
+    await act(async () => {
+      await expect(getContext().withAuthToken(request)).rejects.toThrow(
+        "Invalid or expired refresh token",
+      );
+    });
+
+    expect(request).toHaveBeenCalledTimes(1);
+    expect(getContext().user).toBeNull();
+  });
+
+  it("doesn't refresh for other errors", async () => {
+    const request = jest.fn().mockRejectedValueOnce(new ApiError("Invalid title", 400));
+
+    const getContext = await renderWithSavedUser();
+    await act(async () => {
+      await expect(getContext().withAuthToken(request)).rejects.toThrow("Invalid title");
+    });
+
+    expect(refreshToken).not.toHaveBeenCalled();
+    expect(getContext().user?.token).toBe("expired_token");
+  });
+});
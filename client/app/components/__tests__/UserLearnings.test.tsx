@@ -25,10 +25,16 @@ const mockUserContext = {
     firstName: "John",
     lastName: "Doe",
     token: "valid_token",
+    refreshToken: "valid_refresh_token",
   },
   setUser: jest.fn(),
   isLoading: false,
   logout: jest.fn(),
+  withAuthToken: jest
+    .fn()
+    .mockImplementation((request: (authToken: string) => Promise<unknown>) =>
+      request("valid_token"),
+    ),
 };
 
 describe("UserLearnings", () => {
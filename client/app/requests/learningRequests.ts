@@ -1,5 +1,5 @@
 import { apiRequests } from "./apiRequests";
-import { getErrorMessageFromResponse } from "./requestUtils";
+import { authHeader, getErrorMessageFromResponse } from "./requestUtils";
 import { ApiError } from "./userRequests";
 
 /**
@@ -11,6 +11,24 @@ export interface LearningItem {
   category: string;
 }
 
+/**
+ * Paginated list response envelope
+ */
+interface Page<T> {
+  items: T[];
+  next_cursor?: string;
+  total: number;
+}
+
+/**
+ * Learning category data structure
+ */
+export interface LearningCategory {
+  id: number;
+  name: string;
+  custom: boolean;
+}
+
 /**
  * Creates a new learning item
  * @param authToken - User's authentication token
@@ -21,7 +39,7 @@ export interface LearningItem {
 async function createLearning(authToken: string, title: string, category: string): Promise<void> {
   const response = await apiRequests.postRequest(
     "/learning",
-    { Authorization: authToken },
+    authHeader(authToken),
     { title, category },
   );
 
@@ -38,7 +56,7 @@ async function createLearning(authToken: string, title: string, category: string
  * @throws {ApiError} If the request fails
  */
 async function deleteLearning(authToken: string, id: number): Promise<void> {
-  const response = await apiRequests.deleteRequest(`/learning/${id}`, { Authorization: authToken });
+  const response = await apiRequests.deleteRequest(`/learning/${id}`, authHeader(authToken));
 
   if (!response.ok) {
     const errorMessage = await getErrorMessageFromResponse(
@@ -50,23 +68,33 @@ async function deleteLearning(authToken: string, id: number): Promise<void> {
 }
 
 /**
- * Retrieves learning items for a specific user
+ * Retrieves all learning items for a specific user, following pagination cursors
  * @param userId - ID of the user
  * @returns Array of learning items
  * @throws {ApiError} If the request fails
  */
 async function getLearnings(userId: number): Promise<LearningItem[]> {
-  const response = await apiRequests.getRequest(`/learning/${userId}`);
+  const learnings: LearningItem[] = [];
+  let cursor: string | undefined;
 
-  if (!response.ok) {
-    const errorMessage = await getErrorMessageFromResponse(
-      response,
-      "Failed to get learning items",
-    );
-    throw new ApiError(errorMessage, response.status);
-  }
+  do {
+    const query = cursor ? `?limit=100&cursor=${encodeURIComponent(cursor)}` : "?limit=100";
+    const response = await apiRequests.getRequest(`/learning/${userId}${query}`);
+
+    if (!response.ok) {
+      const errorMessage = await getErrorMessageFromResponse(
+        response,
+        "Failed to get learning items",
+      );
+      throw new ApiError(errorMessage, response.status);
+    }
+
+    const page = (await response.json()) as Page<LearningItem>;
+    learnings.push(...page.items);
+    cursor = page.next_cursor;
+  } while (cursor);
 
-  return response.json() as Promise<LearningItem[]>;
+  return learnings;
 }
 
 /**
@@ -85,7 +113,8 @@ async function getLearningCategories(): Promise<string[]> {
     throw new ApiError(errorMessage, response.status);
   }
 
-  return response.json() as Promise<string[]>;
+  const categories = (await response.json()) as LearningCategory[];
+  return categories.map((category) => category.name);
 }
 
 export { createLearning, deleteLearning, getLearnings, getLearningCategories };
@@ -16,4 +16,8 @@ async function getErrorMessageFromResponse(
   return errorMessage;
 }
 
-export { getErrorMessageFromResponse };
+function authHeader(authToken: string): Record<string, string> {
+  return { Authorization: `Bearer ${authToken}` };
+}
+
+export { authHeader, getErrorMessageFromResponse };
@@ -1,5 +1,5 @@
 import { apiRequests } from "./apiRequests";
-import { getErrorMessageFromResponse } from "./requestUtils";
+import { authHeader, getErrorMessageFromResponse } from "./requestUtils";
 
 /**
  * User data interface returned from login endpoint
@@ -11,8 +11,18 @@ export interface UserResponse {
     username: string;
     first_name: string;
     last_name: string;
+    role: "user" | "moderator" | "admin";
   };
   token: string;
+  refresh_token: string;
+}
+
+/**
+ * Token pair returned from the token refresh endpoint
+ */
+export interface RefreshTokenResponse {
+  token: string;
+  refresh_token: string;
 }
 
 /**
@@ -79,4 +89,43 @@ async function login(identifier: string, password: string): Promise<UserResponse
   return response.json() as Promise<UserResponse>;
 }
 
-export { createUser, login };
+/**
+ * Exchanges a refresh token for a new access token and refresh token
+ * The old refresh token can no longer be used afterwards
+ * @param refreshToken - Refresh token returned by login or a previous refresh
+ * @returns The new token pair
+ * @throws {ApiError} If the request fails
+ */
+async function refreshToken(refreshToken: string): Promise<RefreshTokenResponse> {
+  const response = await apiRequests.postRequest("/token/refresh", undefined, {
+    refresh_token: refreshToken,
+  });
+
+  if (!response.ok) {
+    const errorMessage = await getErrorMessageFromResponse(response, "Failed to refresh token");
+    throw new ApiError(errorMessage, response.status);
+  }
+
+  return response.json() as Promise<RefreshTokenResponse>;
+}
+
+/**
+ * Revokes the access token and refresh token of the current session
+ * @param authToken - Access token to revoke
+ * @param refreshToken - Refresh token issued with the access token
+ * @throws {ApiError} If the request fails
+ */
+async function logout(authToken: string, refreshToken: string): Promise<void> {
+  const response = await apiRequests.postRequest(
+    "/logout",
+    authHeader(authToken),
+    { refresh_token: refreshToken },
+  );
+
+  if (!response.ok) {
+    const errorMessage = await getErrorMessageFromResponse(response, "Failed to log out");
+    throw new ApiError(errorMessage, response.status);
+  }
+}
+
+export { createUser, login, logout, refreshToken };
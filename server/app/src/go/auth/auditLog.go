@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"software-slayer/db"
+)
+
+const (
+	AuditLoginLockout  = "login_lockout"
+	AuditAccountUnlock = "account_unlock"
+)
+
+// AuditEvent is a security relevant event, kept so admins can find out what happened to an account
+type AuditEvent struct {
+	// Action is what happened, one of the Audit constants
+	Action string
+	// ActorID is the user who caused the event, 0 if the server did
+	ActorID int
+	// Subject is what the event happened to, such as a login attempt key
+	Subject   string
+	Detail    string
+	CreatedAt time.Time
+}
+
+// AuditLog records audit events
+type AuditLog interface {
+	Record(ctx context.Context, event AuditEvent) error
+}
+
+// InMemoryAuditLog keeps every event it is given, for tests
+type InMemoryAuditLog struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+func NewInMemoryAuditLog() *InMemoryAuditLog {
+	return &InMemoryAuditLog{}
+}
+
+func (l *InMemoryAuditLog) Record(ctx context.Context, event AuditEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events = append(l.events, event)
+	return nil
+}
+
+// Events returns a copy of the events recorded so far
+func (l *InMemoryAuditLog) Events() []AuditEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]AuditEvent(nil), l.events...)
+}
+
+type MySQLAuditLog struct {
+	db *db.Database
+}
+
+func NewMySQLAuditLog(db *db.Database) *MySQLAuditLog {
+	return &MySQLAuditLog{db: db}
+}
+
+func (l *MySQLAuditLog) Record(ctx context.Context, event AuditEvent) error {
+	var actorId *int
+	if event.ActorID != 0 {
+		actorId = &event.ActorID
+	}
+
+	_, err := l.db.ExecContext(ctx, "INSERT INTO audit_events (action, actor_id, subject, detail, created_at) VALUES (?, ?, ?, ?, ?)",
+		event.Action, actorId, event.Subject, event.Detail, event.CreatedAt.UTC())
+	return err
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+
+	"software-slayer/utils"
+)
+
+var keyring *Keyring
+
+// @Summary Get signing keys
+// @Description Get the public keys tokens are signed with as a JSON Web Key Set, so other services can verify tokens
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} JWKSet
+// @Router /.well-known/jwks.json [get]
+func getJWKS(w http.ResponseWriter, r *http.Request) {
+	// Keys are rotated rarely, but a cached set must not outlive a reload for long
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	utils.RespondWithJSON(w, http.StatusOK, keyring.JWKS())
+}
+
+// InitAuthRest initializes the auth REST endpoints
+func InitAuthRest(_keyring *Keyring) {
+	keyring = _keyring
+
+	http.HandleFunc("GET /.well-known/jwks.json", getJWKS)
+
+	log.Println("Auth REST endpoints initialized")
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PersonalAccessToken describes a personal access token, the token itself is never stored
+type PersonalAccessToken struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// TokenConfig holds the values access tokens are issued with and validated against
+type TokenConfig struct {
+	Issuer   string
+	Audience string
+	Lifetime time.Duration
+	// Leeway is the clock skew tolerated when checking exp, nbf and iat
+	Leeway time.Duration
+}
+
+// Claims are the registered claims (RFC 7519) carried by access tokens
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	// IssuedAt has a fractional part, so tokens issued right after RevokeAllTokens are told apart from those it revoked
+	IssuedAt float64 `json:"iat"`
+	ID       string  `json:"jti"`
+	// Role is the role of the user when the token was issued
+	Role Role `json:"role,omitempty"`
+	// EmailVerified is whether the user had verified their email when the token was issued
+	EmailVerified bool `json:"email_verified,omitempty"`
+	// Scope is a space separated list of the scopes granted to the token, if it is restricted
+	Scope string `json:"scope,omitempty"`
+}
+
+// Valid always succeeds, claims are validated by TokenServiceImpl so that leeway and configuration apply
+func (c Claims) Valid() error {
+	return nil
+}
+
+// Audience is the aud claim, which may be a single string or an array of strings
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+func (a Audience) Contains(audience string) bool {
+	for _, value := range a {
+		if value == audience {
+			return true
+		}
+	}
+	return false
+}
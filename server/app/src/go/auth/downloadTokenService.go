@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var ErrInvalidDownloadToken = errors.New("invalid download token")
+
+type DownloadTokenService interface {
+	GenerateDownloadToken(userId int, resource string) (string, time.Time, error)
+	ParseDownloadToken(token string) (int, string, error)
+}
+
+// DownloadTokenServiceImpl issues download links as JWTs signed with the keyring, so a file can be fetched without an
+// Authorization header while the link is valid. The config's audience must differ from the access token audience.
+type DownloadTokenServiceImpl struct {
+	config  TokenConfig
+	keyring *Keyring
+}
+
+func NewDownloadTokenService(config TokenConfig, keyring *Keyring) *DownloadTokenServiceImpl {
+	return &DownloadTokenServiceImpl{config: config, keyring: keyring}
+}
+
+// downloadClaims are the claims carried by download tokens
+type downloadClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	// Resource is the file the token lets its holder download
+	Resource string `json:"resource"`
+}
+
+// Valid always succeeds, claims are validated by DownloadTokenServiceImpl so that leeway and configuration apply
+func (c downloadClaims) Valid() error {
+	return nil
+}
+
+/*
+ * Generate a token letting whoever holds it download one of a user's files
+ * @param userId: the id of the user the file belongs to
+ * @param resource: the id of the file
+ * @return string: the signed token
+ * @return time.Time: when the token expires
+ * @return error: an error if the token could not be signed
+ */
+func (s *DownloadTokenServiceImpl) GenerateDownloadToken(userId int, resource string) (string, time.Time, error) {
+	key := s.keyring.Current()
+	now := time.Now()
+	expiresAt := now.Add(s.config.Lifetime).Truncate(time.Second)
+	token := jwt.NewWithClaims(key.Method, downloadClaims{
+		Issuer:    s.config.Issuer,
+		Subject:   strconv.Itoa(userId),
+		Audience:  Audience{s.config.Audience},
+		ExpiresAt: expiresAt.Unix(),
+		IssuedAt:  now.Unix(),
+		Resource:  resource,
+	})
+	token.Header["kid"] = key.ID
+
+	signed, err := token.SignedString(key.signKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+/*
+ * Verify a download token's signature and validate its claims
+ * @param tokenString: the token
+ * @return int: the id of the user the file belongs to
+ * @return string: the id of the file
+ * @return error: ErrInvalidDownloadToken or ErrTokenExpired
+ */
+func (s *DownloadTokenServiceImpl) ParseDownloadToken(tokenString string) (int, string, error) {
+	var claims downloadClaims
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	if _, err := parser.ParseWithClaims(tokenString, &claims, s.keyring.verifyKey); err != nil {
+		return -1, "", ErrInvalidDownloadToken
+	}
+
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil || claims.Resource == "" || claims.ExpiresAt == 0 {
+		return -1, "", ErrInvalidDownloadToken
+	}
+	if claims.Issuer != s.config.Issuer || !claims.Audience.Contains(s.config.Audience) {
+		return -1, "", ErrInvalidDownloadToken
+	}
+	if time.Now().After(time.Unix(claims.ExpiresAt, 0).Add(s.config.Leeway)) {
+		return -1, "", ErrTokenExpired
+	}
+
+	return userId, claims.Resource, nil
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+)
+
+// EmailVerificationPolicy is what users may do before they have verified their email
+type EmailVerificationPolicy string
+
+const (
+	// VerificationOptional lets unverified users do everything verified users can
+	VerificationOptional EmailVerificationPolicy = "optional"
+	// VerificationReadOnly lets unverified users log in and read, but not make changes
+	VerificationReadOnly EmailVerificationPolicy = "read_only"
+	// VerificationRequired stops unverified users from logging in
+	VerificationRequired EmailVerificationPolicy = "required"
+)
+
+var ErrUnknownVerificationPolicy = errors.New("unknown email verification policy")
+
+var emailVerificationPolicy = VerificationOptional
+
+// SetEmailVerificationPolicy sets the policy applied to unverified users, VerificationOptional by default
+func SetEmailVerificationPolicy(policy EmailVerificationPolicy) {
+	emailVerificationPolicy = policy
+}
+
+// ParseEmailVerificationPolicy returns the policy with the given name
+func ParseEmailVerificationPolicy(name string) (EmailVerificationPolicy, error) {
+	switch policy := EmailVerificationPolicy(name); policy {
+	case VerificationOptional, VerificationReadOnly, VerificationRequired:
+		return policy, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownVerificationPolicy, name)
+}
+
+// CanLogIn reports whether a user may log in under the email verification policy
+func CanLogIn(emailVerified bool) bool {
+	return emailVerified || emailVerificationPolicy != VerificationRequired
+}
+
+// CanWrite reports whether a principal may make changes under the email verification policy
+func CanWrite(principal Principal) bool {
+	return principal.EmailVerified || emailVerificationPolicy == VerificationOptional
+}
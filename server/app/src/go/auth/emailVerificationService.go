@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var ErrInvalidVerificationToken = errors.New("invalid email verification token")
+
+type EmailVerificationService interface {
+	GenerateVerificationToken(userId int, email string) (string, error)
+	ParseVerificationToken(token string) (int, string, error)
+}
+
+// EmailVerificationServiceImpl issues verification tokens as JWTs signed with the keyring, so nothing has to be stored.
+// The config's audience must differ from the access token audience, so the two kinds of token can't be swapped.
+type EmailVerificationServiceImpl struct {
+	config  TokenConfig
+	keyring *Keyring
+}
+
+func NewEmailVerificationService(config TokenConfig, keyring *Keyring) *EmailVerificationServiceImpl {
+	return &EmailVerificationServiceImpl{config: config, keyring: keyring}
+}
+
+// verificationClaims are the claims carried by email verification tokens
+type verificationClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	// Email is the address being verified, so a link stops working once the user changes their email
+	Email string `json:"email"`
+}
+
+// Valid always succeeds, claims are validated by EmailVerificationServiceImpl so that leeway and configuration apply
+func (c verificationClaims) Valid() error {
+	return nil
+}
+
+/*
+ * Generate a token proving that whoever holds it can read a user's email
+ * @param userId: the id of the user
+ * @param email: the email the token is sent to
+ * @return string: the signed token
+ * @return error: an error if the token could not be signed
+ */
+func (s *EmailVerificationServiceImpl) GenerateVerificationToken(userId int, email string) (string, error) {
+	key := s.keyring.Current()
+	now := time.Now()
+	token := jwt.NewWithClaims(key.Method, verificationClaims{
+		Issuer:    s.config.Issuer,
+		Subject:   strconv.Itoa(userId),
+		Audience:  Audience{s.config.Audience},
+		ExpiresAt: now.Add(s.config.Lifetime).Unix(),
+		IssuedAt:  now.Unix(),
+		Email:     email,
+	})
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+/*
+ * Verify a verification token's signature and validate its claims
+ * @param tokenString: the token
+ * @return int: the id of the user the token was issued to
+ * @return string: the email the token was sent to
+ * @return error: ErrInvalidVerificationToken or ErrTokenExpired
+ */
+func (s *EmailVerificationServiceImpl) ParseVerificationToken(tokenString string) (int, string, error) {
+	var claims verificationClaims
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	if _, err := parser.ParseWithClaims(tokenString, &claims, s.keyring.verifyKey); err != nil {
+		return -1, "", ErrInvalidVerificationToken
+	}
+
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil || claims.Email == "" || claims.ExpiresAt == 0 {
+		return -1, "", ErrInvalidVerificationToken
+	}
+	if claims.Issuer != s.config.Issuer || !claims.Audience.Contains(s.config.Audience) {
+		return -1, "", ErrInvalidVerificationToken
+	}
+	if time.Now().After(time.Unix(claims.ExpiresAt, 0).Add(s.config.Leeway)) {
+		return -1, "", ErrTokenExpired
+	}
+
+	return userId, claims.Email, nil
+}
@@ -1,14 +1,54 @@
 package auth
 
 import (
+	"fmt"
+	"sync"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
+var bcryptCost = bcrypt.DefaultCost
+
+// dummyHash is compared against for logins to unknown accounts, created with the current cost when first needed
+var dummyHash struct {
+	sync.Mutex
+	cost int
+	hash []byte
+}
+
+// SetBcryptCost sets the cost new password hashes are created with, bcrypt.DefaultCost by default
+func SetBcryptCost(cost int) error {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cost)
+	}
+	bcryptCost = cost
+	return nil
+}
+
 func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
 	return string(hash), err
 }
 
 func ValidatePassword(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-}
\ No newline at end of file
+}
+
+// NeedsRehash reports whether a hash was created with another cost or algorithm than new hashes, and should be replaced on the next login
+func NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != bcryptCost
+}
+
+// CompareDummyPassword takes as long as ValidatePassword on a new hash, so logins to unknown accounts can't be told apart by their timing
+func CompareDummyPassword(password string) {
+	dummyHash.Lock()
+	if dummyHash.hash == nil || dummyHash.cost != bcryptCost {
+		dummyHash.hash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcryptCost)
+		dummyHash.cost = bcryptCost
+	}
+	hash := dummyHash.hash
+	dummyHash.Unlock()
+
+	bcrypt.CompareHashAndPassword(hash, []byte(password))
+}
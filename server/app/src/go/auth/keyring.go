@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// keyFileMethods maps the extension of a key file to the signing method of the key it contains
+var keyFileMethods = map[string]jwt.SigningMethod{
+	".hs256": jwt.SigningMethodHS256,
+	".rs256": jwt.SigningMethodRS256,
+	".eddsa": jwt.SigningMethodEdDSA,
+}
+
+// currentKeyFile is the name of the file in the key directory holding the kid of the signing key
+const currentKeyFile = "current"
+
+// SigningKey is a key in the keyring, identified in token headers by its kid
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func NewHMACKey(id string, secret []byte) *SigningKey {
+	return &SigningKey{ID: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+func NewRSAKey(id string, key *rsa.PrivateKey) *SigningKey {
+	return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, signKey: key, verifyKey: &key.PublicKey}
+}
+
+func NewEdDSAKey(id string, key ed25519.PrivateKey) *SigningKey {
+	return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, signKey: key, verifyKey: key.Public()}
+}
+
+/*
+ * Keyring holds the keys tokens are signed and verified with
+ * New tokens are signed with the current key, and tokens signed with any key in the ring are accepted,
+ * so keys can be rotated by adding a new key, making it current, and removing the old key once every
+ * token signed with it has expired.
+ */
+type Keyring struct {
+	mu      sync.RWMutex
+	dir     string
+	keys    map[string]*SigningKey
+	current *SigningKey
+}
+
+// NewKeyring creates a keyring signing with current and also verifying with the other keys
+func NewKeyring(current *SigningKey, others ...*SigningKey) *Keyring {
+	keys := map[string]*SigningKey{current.ID: current}
+	for _, key := range others {
+		keys[key.ID] = key
+	}
+	return &Keyring{keys: keys, current: current}
+}
+
+/*
+ * Load a keyring from a directory
+ * Each key is a file named <kid>.hs256 holding a secret, or <kid>.rs256 or <kid>.eddsa holding a PEM encoded
+ * private key. The file named current holds the kid of the key new tokens are signed with.
+ * @param dir: the key directory
+ * @return *Keyring: the loaded keyring
+ * @return error: an error if a key could not be read or the current key is missing
+ */
+func LoadKeyring(dir string) (*Keyring, error) {
+	keyring := &Keyring{dir: dir}
+	if err := keyring.Reload(); err != nil {
+		return nil, err
+	}
+	return keyring, nil
+}
+
+/*
+ * Reload the keys from the keyring's directory
+ * If loading fails, the keyring keeps the keys it had
+ * @return error: an error if a key could not be read or the current key is missing
+ */
+func (k *Keyring) Reload() error {
+	if k.dir == "" {
+		return errors.New("keyring was not loaded from a directory")
+	}
+
+	entries, err := os.ReadDir(k.dir)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*SigningKey)
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == currentKeyFile {
+			continue
+		}
+
+		key, err := readKeyFile(filepath.Join(k.dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read key %s: %w", entry.Name(), err)
+		}
+		if key != nil {
+			keys[key.ID] = key
+		}
+	}
+
+	currentId, err := os.ReadFile(filepath.Join(k.dir, currentKeyFile))
+	if err != nil {
+		return fmt.Errorf("failed to read current key id: %w", err)
+	}
+	current, ok := keys[strings.TrimSpace(string(currentId))]
+	if !ok {
+		return fmt.Errorf("current key %q not found", strings.TrimSpace(string(currentId)))
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys = keys
+	k.current = current
+	log.Printf("Loaded %d signing keys, signing with %s (%s)", len(keys), current.ID, current.Method.Alg())
+	return nil
+}
+
+// Current returns the key new tokens are signed with
+func (k *Keyring) Current() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.current
+}
+
+// Key returns the key with the given kid
+func (k *Keyring) Key(id string) (*SigningKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.keys[id]
+	return key, ok
+}
+
+/*
+ * Look up the key a token was signed with, for jwt.Parser
+ * The signing method is pinned to the method of the key named by the token's kid, so a token cannot
+ * pick its own algorithm (e.g. none, or HS256 with a public key as the secret)
+ * @param token: the parsed, unverified token
+ * @return interface{}: the key to verify the signature with
+ * @return error: an error if the kid is unknown or the token uses another signing method
+ */
+func (k *Keyring) verifyKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.Key(kid)
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.verifyKey, nil
+}
+
+/*
+ * Build the JSON Web Key Set of the keyring's public keys
+ * HMAC secrets are never published, so only RS256 and EdDSA keys are included
+ * @return JWKSet: the public keys, sorted by kid
+ */
+func (k *Keyring) JWKS() JWKSet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range k.keys {
+		jwk := JWK{Kid: key.ID, Alg: key.Method.Alg(), Use: "sig"}
+		switch verifyKey := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(verifyKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(verifyKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(verifyKey)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+	return set
+}
+
+// readKeyFile reads a key file, returning nil for files that are not keys
+func readKeyFile(path string) (*SigningKey, error) {
+	extension := filepath.Ext(path)
+	method, ok := keyFileMethods[extension]
+	if !ok {
+		log.Printf("Ignoring file %s in key directory", path)
+		return nil, nil
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	id := strings.TrimSuffix(filepath.Base(path), extension)
+
+	switch method {
+	case jwt.SigningMethodRS256:
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(contents)
+		if err != nil {
+			return nil, err
+		}
+		return NewRSAKey(id, key), nil
+	case jwt.SigningMethodEdDSA:
+		key, err := jwt.ParseEdPrivateKeyFromPEM(contents)
+		if err != nil {
+			return nil, err
+		}
+		return NewEdDSAKey(id, key.(ed25519.PrivateKey)), nil
+	default:
+		secret := []byte(strings.TrimSpace(string(contents)))
+		if len(secret) == 0 {
+			return nil, errors.New("empty secret")
+		}
+		return NewHMACKey(id, secret), nil
+	}
+}
@@ -0,0 +1,335 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"software-slayer/db"
+)
+
+// LoginAttempts are the recent failed logins for a login attempt key
+type LoginAttempts struct {
+	Failures    int
+	LastFailure time.Time
+	// LockedUntil is when a lockout ends, zero if the key is not locked out
+	LockedUntil time.Time
+}
+
+// LoginAttemptStore tracks failed logins by key, see AccountKey, IdentifierKey and IPKey
+type LoginAttemptStore interface {
+	Get(ctx context.Context, key string) (LoginAttempts, error)
+	// RecordFailure counts a failed login, starting the count over if the last failure was before resetBefore
+	RecordFailure(ctx context.Context, key string, now time.Time, resetBefore time.Time) (LoginAttempts, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	// Prune removes keys that have not failed since before and are not locked out
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// ThrottleConfig decides how failed logins for a key are slowed down
+type ThrottleConfig struct {
+	// FreeAttempts is how many failures are allowed before logins are delayed
+	FreeAttempts int
+	// BaseDelay is the delay after the first failure past FreeAttempts, it doubles with each further failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutThreshold is how many failures lock the key out for LockoutDuration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// ResetAfter is how long without a failure it takes for failures to be forgotten
+	ResetAfter time.Duration
+}
+
+// delay returns how long to wait after the last of a number of failures
+func (c ThrottleConfig) delay(failures int) time.Duration {
+	if failures <= c.FreeAttempts {
+		return 0
+	}
+
+	delay := c.BaseDelay
+	for i := c.FreeAttempts + 1; i < failures && delay < c.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, c.MaxDelay)
+}
+
+// AccountKey is the login attempt key of an existing account, whether the user logs in with their email or username
+func AccountKey(userId int) string {
+	return "user:" + strconv.Itoa(userId)
+}
+
+// IdentifierKey is the login attempt key of an email or username that doesn't belong to an account
+func IdentifierKey(identifier string) string {
+	return "identifier:" + strings.ToLower(identifier)
+}
+
+// IPKey is the login attempt key of a client address
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+/*
+ * LoginThrottle slows down password guessing by delaying logins after repeated failures, with exponential
+ * backoff, and locking out keys that keep failing. Accounts and client addresses are tracked separately,
+ * so an attacker can neither try many passwords for one account nor one password for many accounts.
+ */
+type LoginThrottle struct {
+	store    LoginAttemptStore
+	auditLog AuditLog
+	account  ThrottleConfig
+	ip       ThrottleConfig
+}
+
+func NewLoginThrottle(store LoginAttemptStore, auditLog AuditLog, account ThrottleConfig, ip ThrottleConfig) *LoginThrottle {
+	return &LoginThrottle{store: store, auditLog: auditLog, account: account, ip: ip}
+}
+
+/*
+ * Check whether a login may be attempted, before the password is compared
+ * @param ctx: the context
+ * @param accountKey: the AccountKey or IdentifierKey being logged in to
+ * @param ip: the client address
+ * @return time.Duration: how long the client has to wait, 0 if the login may be attempted
+ * @return error: an error if the attempts could not be read
+ */
+func (t *LoginThrottle) Check(ctx context.Context, accountKey string, ip string) (time.Duration, error) {
+	now := time.Now()
+
+	accountWait, err := t.wait(ctx, accountKey, t.account, now)
+	if err != nil {
+		return 0, err
+	}
+	ipWait, err := t.wait(ctx, IPKey(ip), t.ip, now)
+	if err != nil {
+		return 0, err
+	}
+	return max(accountWait, ipWait), nil
+}
+
+/*
+ * Record a failed login for the account and client address, locking out whichever reached its lockout threshold
+ * @param ctx: the context
+ * @param accountKey: the AccountKey or IdentifierKey being logged in to
+ * @param ip: the client address
+ * @return error: an error if the failure could not be recorded
+ */
+func (t *LoginThrottle) RecordFailure(ctx context.Context, accountKey string, ip string) error {
+	now := time.Now()
+
+	if err := t.recordFailure(ctx, accountKey, t.account, now); err != nil {
+		return err
+	}
+	return t.recordFailure(ctx, IPKey(ip), t.ip, now)
+}
+
+// RecordSuccess forgets the failed logins of an account, the client address is not reset so one known password can't be used to keep guessing others
+func (t *LoginThrottle) RecordSuccess(ctx context.Context, accountKey string) error {
+	return t.store.Reset(ctx, accountKey)
+}
+
+/*
+ * Lift a lockout and forget the failed logins of an account
+ * @param ctx: the context
+ * @param userId: the id of the user whose account is unlocked
+ * @param actorId: the id of the admin unlocking the account
+ * @return error: an error if the account could not be unlocked
+ */
+func (t *LoginThrottle) Unlock(ctx context.Context, userId int, actorId int) error {
+	if err := t.store.Reset(ctx, AccountKey(userId)); err != nil {
+		return err
+	}
+
+	return t.auditLog.Record(ctx, AuditEvent{
+		Action:    AuditAccountUnlock,
+		ActorID:   actorId,
+		Subject:   AccountKey(userId),
+		CreatedAt: time.Now(),
+	})
+}
+
+// wait returns how long a key has to wait before its next login
+func (t *LoginThrottle) wait(ctx context.Context, key string, config ThrottleConfig, now time.Time) (time.Duration, error) {
+	attempts, err := t.store.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	until := attempts.LastFailure.Add(config.delay(attempts.Failures))
+	if attempts.LockedUntil.After(until) {
+		until = attempts.LockedUntil
+	}
+	if !until.After(now) {
+		return 0, nil
+	}
+	return until.Sub(now), nil
+}
+
+func (t *LoginThrottle) recordFailure(ctx context.Context, key string, config ThrottleConfig, now time.Time) error {
+	attempts, err := t.store.RecordFailure(ctx, key, now, now.Add(-config.ResetAfter))
+	if err != nil {
+		return err
+	}
+
+	// Every failure from the threshold on extends the lockout, so it only ends once the failures stop
+	if attempts.Failures < config.LockoutThreshold {
+		return nil
+	}
+	if err := t.store.Lock(ctx, key, now.Add(config.LockoutDuration)); err != nil {
+		return err
+	}
+
+	log.Printf("Locked out %s for %s after %d failed logins", key, config.LockoutDuration, attempts.Failures)
+	return t.auditLog.Record(ctx, AuditEvent{
+		Action:    AuditLoginLockout,
+		Subject:   key,
+		Detail:    fmt.Sprintf("%d failed logins, locked for %s", attempts.Failures, config.LockoutDuration),
+		CreatedAt: now,
+	})
+}
+
+/*
+ * Periodically prune forgotten login attempts until the context is cancelled
+ * @param ctx: the context that stops pruning when cancelled
+ * @param store: the login attempt store to prune
+ * @param interval: the time between prunes
+ * @param retention: how long after the last failure attempts are kept, at least the longest ResetAfter
+ */
+func PruneLoginAttempts(ctx context.Context, store LoginAttemptStore, interval time.Duration, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			pruneCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			if err := store.Prune(pruneCtx, now.Add(-retention)); err != nil {
+				log.Printf("Failed to prune login attempts: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+type InMemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]LoginAttempts
+}
+
+func NewInMemoryLoginAttemptStore() *InMemoryLoginAttemptStore {
+	return &InMemoryLoginAttemptStore{attempts: make(map[string]LoginAttempts)}
+}
+
+func (s *InMemoryLoginAttemptStore) Get(ctx context.Context, key string) (LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.attempts[key], nil
+}
+
+func (s *InMemoryLoginAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, resetBefore time.Time) (LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := s.attempts[key]
+	if attempts.LastFailure.Before(resetBefore) {
+		attempts.Failures = 0
+	}
+	attempts.Failures++
+	attempts.LastFailure = now
+	s.attempts[key] = attempts
+	return attempts, nil
+}
+
+func (s *InMemoryLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := s.attempts[key]
+	attempts.LockedUntil = until
+	s.attempts[key] = attempts
+	return nil
+}
+
+func (s *InMemoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+func (s *InMemoryLoginAttemptStore) Prune(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, attempts := range s.attempts {
+		if attempts.LastFailure.Before(before) && attempts.LockedUntil.Before(before) {
+			delete(s.attempts, key)
+		}
+	}
+	return nil
+}
+
+type MySQLLoginAttemptStore struct {
+	db *db.Database
+}
+
+func NewMySQLLoginAttemptStore(db *db.Database) *MySQLLoginAttemptStore {
+	return &MySQLLoginAttemptStore{db: db}
+}
+
+func (s *MySQLLoginAttemptStore) Get(ctx context.Context, key string) (LoginAttempts, error) {
+	var attempts LoginAttempts
+	var lockedUntil sql.NullTime
+	err := s.db.QueryRowContext(ctx, "SELECT failures, last_failure_at, locked_until FROM login_attempts WHERE attempt_key = ?",
+		key).Scan(&attempts.Failures, &attempts.LastFailure, &lockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return LoginAttempts{}, nil
+	}
+	attempts.LockedUntil = lockedUntil.Time
+	return attempts, err
+}
+
+func (s *MySQLLoginAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, resetBefore time.Time) (LoginAttempts, error) {
+	var attempts LoginAttempts
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		// The count is incremented in a single statement, so concurrent failures are all counted
+		if _, err := tx.ExecContext(ctx, `INSERT INTO login_attempts (attempt_key, failures, last_failure_at) VALUES (?, 1, ?)
+			ON DUPLICATE KEY UPDATE failures = IF(last_failure_at < ?, 1, failures + 1), last_failure_at = VALUES(last_failure_at)`,
+			key, now.UTC(), resetBefore.UTC()); err != nil {
+			return err
+		}
+
+		var lockedUntil sql.NullTime
+		err := tx.QueryRowContext(ctx, "SELECT failures, last_failure_at, locked_until FROM login_attempts WHERE attempt_key = ?",
+			key).Scan(&attempts.Failures, &attempts.LastFailure, &lockedUntil)
+		attempts.LockedUntil = lockedUntil.Time
+		return err
+	})
+	return attempts, err
+}
+
+func (s *MySQLLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE login_attempts SET locked_until = ? WHERE attempt_key = ?", until.UTC(), key)
+	return err
+}
+
+func (s *MySQLLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE attempt_key = ?", key)
+	return err
+}
+
+func (s *MySQLLoginAttemptStore) Prune(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)",
+		before.UTC(), before.UTC())
+	return err
+}
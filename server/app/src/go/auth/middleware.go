@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"software-slayer/utils"
+)
+
+var ErrAuthorizationScheme = errors.New("authorization header must use the Bearer scheme")
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID        int
+	Role          Role
+	EmailVerified bool
+	// Scopes restrict what the credentials may be used for, nil means they are not restricted
+	Scopes    []string
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+// Access is the authentication a route requires
+type Access int
+
+const (
+	// Public routes ignore credentials
+	Public Access = iota
+	// OptionalAuthentication routes identify callers that send credentials, but reject invalid ones
+	OptionalAuthentication
+	// Authenticated routes reject callers without valid credentials
+	Authenticated
+)
+
+// Route declares a handler along with the authentication it requires
+type Route struct {
+	Pattern string
+	Access  Access
+	// Scope is the scope restricted credentials need, routes without one can only be used with unrestricted credentials
+	Scope string
+	// AllowUnverified routes can make changes for users who haven't verified their email, see CanWrite
+	AllowUnverified bool
+	Handler         http.HandlerFunc
+}
+
+type principalKey struct{}
+
+/*
+ * Register routes on the default mux, wrapping each handler with the authentication it requires
+ * @param tokenService: the token service credentials are validated with
+ * @param routes: the routes to register
+ */
+func RegisterRoutes(tokenService TokenService, routes []Route) {
+	for _, route := range routes {
+		handler := route.Handler
+		if route.Access != Public {
+			if !route.AllowUnverified {
+				handler = RequireVerifiedEmail(handler)
+			}
+			handler = RequireScope(route.Scope, handler)
+		}
+		http.HandleFunc(route.Pattern, Middleware(tokenService, route.Access, handler))
+	}
+}
+
+/*
+ * Middleware authenticates the Authorization: Bearer <token> header and stores the principal in the request context
+ * @param tokenService: the token service credentials are validated with
+ * @param access: the authentication the handler requires
+ * @param next: the handler
+ * @return http.HandlerFunc: the wrapped handler
+ */
+func Middleware(tokenService TokenService, access Access, next http.HandlerFunc) http.HandlerFunc {
+	if access == Public {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" && access == OptionalAuthentication {
+			next(w, r)
+			return
+		}
+
+		token, err := ParseBearerToken(header)
+		if err == nil {
+			var principal Principal
+			principal, err = tokenService.AuthorizeUser(token)
+			if err == nil {
+				next(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		utils.RespondWithError(w, http.StatusUnauthorized, TokenErrorMessage(err))
+	}
+}
+
+/*
+ * RequireScope rejects restricted credentials that were not granted a scope, anonymous requests are passed through
+ * @param scope: the scope the handler requires, or "" to only allow unrestricted credentials
+ * @param next: the handler
+ * @return http.HandlerFunc: the wrapped handler
+ */
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := PrincipalFromContext(r.Context())
+		if ok && !principal.HasScope(scope) {
+			if scope == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+				utils.RespondWithError(w, http.StatusForbidden, "This endpoint can't be used with a personal access token")
+				return
+			}
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+			utils.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("Token is missing the %s scope", scope))
+			return
+		}
+		next(w, r)
+	}
+}
+
+/*
+ * RequireVerifiedEmail rejects changes by principals that the email verification policy only allows to read,
+ * GET and HEAD requests and anonymous requests are passed through
+ * @param next: the handler
+ * @return http.HandlerFunc: the wrapped handler
+ */
+func RequireVerifiedEmail(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := PrincipalFromContext(r.Context())
+		if ok && r.Method != http.MethodGet && r.Method != http.MethodHead && !CanWrite(principal) {
+			utils.RespondWithError(w, http.StatusForbidden, "Verify your email address to make changes")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// HasScope reports whether the principal's credentials were granted a scope
+func (p Principal) HasScope(scope string) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, granted := range p.Scopes {
+		if scope != "" && granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * Extract the token from an Authorization header using the Bearer scheme (RFC 6750)
+ * @param header: the Authorization header
+ * @return string: the token
+ * @return error: ErrTokenMissing or ErrAuthorizationScheme
+ */
+func ParseBearerToken(header string) (string, error) {
+	if header == "" {
+		return "", ErrTokenMissing
+	}
+
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", ErrAuthorizationScheme
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", ErrTokenMissing
+	}
+	return token, nil
+}
+
+// WithPrincipal returns a copy of the context carrying the principal
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal of an authenticated request
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// UserIDFromContext returns the id of the authenticated user, or 0 for anonymous requests
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	principal, ok := PrincipalFromContext(ctx)
+	return principal.UserID, ok
+}
+
+// ScopesFromContext returns the scopes granted to the credentials of the request
+func ScopesFromContext(ctx context.Context) []string {
+	principal, _ := PrincipalFromContext(ctx)
+	return principal.Scopes
+}
+
+// TokenIDFromContext returns the id of the token the request was authenticated with
+func TokenIDFromContext(ctx context.Context) (string, bool) {
+	principal, ok := PrincipalFromContext(ctx)
+	return principal.TokenID, ok
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxPasswordBytes is the most bcrypt hashes, longer passwords are rejected rather than silently truncated
+const maxPasswordBytes = 72
+
+// WeakPasswordError is returned by a PasswordPolicy for a password it rejects, the reason can be shown to the user
+type WeakPasswordError struct {
+	Reason string
+}
+
+func (e *WeakPasswordError) Error() string {
+	return e.Reason
+}
+
+func weakPassword(format string, args ...any) error {
+	return &WeakPasswordError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// PasswordPolicy decides which passwords users may choose
+type PasswordPolicy interface {
+	// Check returns a WeakPasswordError if the password is rejected, identifiers are the username and email of the user choosing it
+	Check(password string, identifiers ...string) error
+}
+
+// PasswordPolicies is a policy that rejects any password one of its policies rejects
+type PasswordPolicies []PasswordPolicy
+
+func (p PasswordPolicies) Check(password string, identifiers ...string) error {
+	for _, policy := range p {
+		if err := policy.Check(password, identifiers...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LengthPolicy limits the number of characters in a password
+type LengthPolicy struct {
+	Min int
+	Max int
+}
+
+func (p LengthPolicy) Check(password string, identifiers ...string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.Min {
+		return weakPassword("must be at least %d characters", p.Min)
+	}
+	if length > p.Max || len(password) > maxPasswordBytes {
+		return weakPassword("must be at most %d characters", p.Max)
+	}
+	return nil
+}
+
+// CharacterClassPolicy requires a password to mix lowercase letters, uppercase letters, digits and symbols
+type CharacterClassPolicy struct {
+	MinClasses int
+}
+
+func (p CharacterClassPolicy) Check(password string, identifiers ...string) error {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	if lower+upper+digit+symbol < p.MinClasses {
+		return weakPassword("must contain at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses)
+	}
+	return nil
+}
+
+// EntropyPolicy requires a minimum estimated entropy, so long passwords don't need symbols and short ones need a mix
+type EntropyPolicy struct {
+	MinBits float64
+}
+
+func (p EntropyPolicy) Check(password string, identifiers ...string) error {
+	if PasswordEntropy(password) < p.MinBits {
+		return weakPassword("is too easy to guess, use a longer password or mix in other kinds of characters")
+	}
+	return nil
+}
+
+/*
+ * Estimate the entropy of a password from its length and the kinds of characters in it
+ * This is an upper bound, it assumes every character was picked at random from the alphabets used
+ * @param password: the password
+ * @return float64: the estimated entropy in bits
+ */
+func PasswordEntropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r > unicode.MaxASCII:
+			other = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	alphabet := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			alphabet += class.size
+		}
+	}
+	if alphabet == 0 {
+		return 0
+	}
+	return float64(utf8.RuneCountInString(password)) * math.Log2(float64(alphabet))
+}
+
+// PersonalInfoPolicy rejects passwords containing the user's username or email
+type PersonalInfoPolicy struct{}
+
+func (p PersonalInfoPolicy) Check(password string, identifiers ...string) error {
+	password = strings.ToLower(password)
+	for _, identifier := range identifiers {
+		identifier = strings.ToLower(identifier)
+		// The part of an email before the @ is often the name users would put in their password
+		local, _, _ := strings.Cut(identifier, "@")
+
+		for _, part := range []string{identifier, local} {
+			// Very short names would reject too many passwords by chance
+			if len(part) >= 3 && strings.Contains(password, part) {
+				return weakPassword("must not contain your username or email")
+			}
+		}
+	}
+	return nil
+}
+
+// BreachedPasswordPolicy rejects passwords that appear in a list of leaked passwords
+type BreachedPasswordPolicy struct {
+	passwords map[string]struct{}
+}
+
+// NewBreachedPasswordPolicy creates a policy rejecting the given passwords, compared case insensitively
+func NewBreachedPasswordPolicy(passwords []string) *BreachedPasswordPolicy {
+	policy := &BreachedPasswordPolicy{passwords: make(map[string]struct{}, len(passwords))}
+	for _, password := range passwords {
+		policy.passwords[strings.ToLower(password)] = struct{}{}
+	}
+	return policy
+}
+
+/*
+ * Load a list of breached passwords from a file
+ * @param path: the path of the file, with one password per line
+ * @return *BreachedPasswordPolicy: a policy rejecting the passwords in the file
+ * @return error: an error if the file could not be read
+ */
+func LoadBreachedPasswordPolicy(path string) (*BreachedPasswordPolicy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var passwords []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if password := strings.TrimRight(scanner.Text(), "\r"); password != "" {
+			passwords = append(passwords, password)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewBreachedPasswordPolicy(passwords), nil
+}
+
+func (p *BreachedPasswordPolicy) Check(password string, identifiers ...string) error {
+	if _, ok := p.passwords[strings.ToLower(password)]; ok {
+		return weakPassword("has appeared in a data breach, choose a different password")
+	}
+	return nil
+}
+
+// Len returns the number of passwords in the list
+func (p *BreachedPasswordPolicy) Len() int {
+	return len(p.passwords)
+}
+
+var passwordPolicy PasswordPolicy = LengthPolicy{Min: 8, Max: 64}
+
+// SetPasswordPolicy sets the policy new passwords are checked against, passwords of 8 to 64 characters by default
+func SetPasswordPolicy(policy PasswordPolicy) {
+	passwordPolicy = policy
+}
+
+/*
+ * Check a new password against the password policy
+ * @param password: the password the user chose
+ * @param identifiers: the username and email of the user, when known
+ * @return error: a WeakPasswordError if the policy rejects the password
+ */
+func CheckPassword(password string, identifiers ...string) error {
+	return passwordPolicy.Check(password, identifiers...)
+}
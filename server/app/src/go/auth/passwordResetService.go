@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"software-slayer/db"
+)
+
+var ErrInvalidResetToken = errors.New("invalid password reset token")
+
+type PasswordResetService interface {
+	IssueResetToken(ctx context.Context, userId int) (string, error)
+	LookupResetToken(ctx context.Context, token string) (int, error)
+	ConsumeResetToken(ctx context.Context, token string) (int, error)
+}
+
+type PasswordResetServiceImpl struct {
+	db            *db.Database
+	tokenLifetime time.Duration
+}
+
+func NewPasswordResetService(db *db.Database, tokenLifetime time.Duration) *PasswordResetServiceImpl {
+	return &PasswordResetServiceImpl{db: db, tokenLifetime: tokenLifetime}
+}
+
+/*
+ * Issue a password reset token, invalidating any earlier token of the user
+ * @param ctx: the context
+ * @param userId: the id of the user whose password can be reset with the token
+ * @return string: the reset token, which is only stored as a hash
+ * @return error: an error if the token could not be stored
+ */
+func (s *PasswordResetServiceImpl) IssueResetToken(ctx context.Context, userId int) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND used_at IS NULL", userId); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, "INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES (?, ?, ?)",
+			userId, HashToken(token), time.Now().Add(s.tokenLifetime).UTC())
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+/*
+ * Find the user a password reset token belongs to without using it up, so the new password can be checked first
+ * @param ctx: the context
+ * @param token: the reset token
+ * @return int: the id of the user whose password may be reset
+ * @return error: ErrInvalidResetToken if the token is unknown, used or expired, or a database error
+ */
+func (s *PasswordResetServiceImpl) LookupResetToken(ctx context.Context, token string) (int, error) {
+	var userId int
+	var expiresAt time.Time
+	err := s.db.QueryRowContext(ctx,
+		"SELECT user_id, expires_at FROM password_reset_tokens WHERE token_hash = ? AND used_at IS NULL",
+		HashToken(token)).Scan(&userId, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return -1, ErrInvalidResetToken
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	if time.Now().After(expiresAt) {
+		return -1, ErrInvalidResetToken
+	}
+	return userId, nil
+}
+
+/*
+ * Use up a password reset token, each token can only be consumed once
+ * @param ctx: the context
+ * @param token: the reset token
+ * @return int: the id of the user whose password may be reset
+ * @return error: ErrInvalidResetToken if the token is unknown, used or expired, or a database error
+ */
+func (s *PasswordResetServiceImpl) ConsumeResetToken(ctx context.Context, token string) (int, error) {
+	var userId int
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		var id int
+		var expiresAt time.Time
+		err := tx.QueryRowContext(ctx,
+			"SELECT id, user_id, expires_at FROM password_reset_tokens WHERE token_hash = ? AND used_at IS NULL FOR UPDATE",
+			HashToken(token)).Scan(&id, &userId, &expiresAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if time.Now().After(expiresAt) {
+			return ErrInvalidResetToken
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = ?", id)
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return userId, nil
+}
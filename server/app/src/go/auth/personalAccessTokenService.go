@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"software-slayer/db"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token, so they can be told apart from JWTs
+const PersonalAccessTokenPrefix = "ssp_"
+
+const (
+	ScopeReadLearnings  = "read:learnings"
+	ScopeWriteLearnings = "write:learnings"
+	ScopeReadUsers      = "read:users"
+)
+
+// Scopes are the scopes personal access tokens can be granted
+var Scopes = []string{ScopeReadLearnings, ScopeWriteLearnings, ScopeReadUsers}
+
+var ErrInvalidPersonalAccessToken = errors.New("personal access token is invalid")
+var ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+
+type PersonalAccessTokenService interface {
+	CreateToken(ctx context.Context, userId int, name string, scopes []string, expiresAt *time.Time) (PersonalAccessToken, string, error)
+	GetTokens(ctx context.Context, userId int) ([]PersonalAccessToken, error)
+	RevokeToken(ctx context.Context, userId int, id int) error
+	RevokeAllTokens(ctx context.Context, userId int) error
+	AuthenticateToken(ctx context.Context, token string) (Principal, error)
+}
+
+type PersonalAccessTokenServiceImpl struct {
+	db *db.Database
+}
+
+func NewPersonalAccessTokenService(db *db.Database) *PersonalAccessTokenServiceImpl {
+	return &PersonalAccessTokenServiceImpl{db: db}
+}
+
+/*
+ * Create a personal access token
+ * @param ctx: the context
+ * @param userId: the id of the user the token acts as
+ * @param name: a name to recognize the token by
+ * @param scopes: the scopes granted to the token
+ * @param expiresAt: when the token expires, or nil if it never does
+ * @return PersonalAccessToken: the token's details
+ * @return string: the token, which is only stored as a hash and can't be retrieved again
+ * @return error: an error if the token could not be stored
+ */
+func (s *PersonalAccessTokenServiceImpl) CreateToken(ctx context.Context, userId int, name string, scopes []string, expiresAt *time.Time) (PersonalAccessToken, string, error) {
+	secret, err := randomToken()
+	if err != nil {
+		return PersonalAccessToken{}, "", err
+	}
+	token := PersonalAccessTokenPrefix + secret
+
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	if expiresAt != nil {
+		utc := expiresAt.UTC()
+		expiresAt = &utc
+	}
+
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userId, name, HashToken(token), strings.Join(scopes, " "), createdAt, expiresAt)
+	if err != nil {
+		return PersonalAccessToken{}, "", err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return PersonalAccessToken{}, "", err
+	}
+
+	return PersonalAccessToken{
+		ID:        int(id),
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: createdAt,
+		ExpiresAt: expiresAt,
+	}, token, nil
+}
+
+// GetTokens returns the personal access tokens of a user that have not been revoked
+func (s *PersonalAccessTokenServiceImpl) GetTokens(ctx context.Context, userId int) ([]PersonalAccessToken, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, name, scopes, created_at, expires_at, last_used_at FROM personal_access_tokens WHERE user_id = ? AND revoked_at IS NULL ORDER BY id",
+		userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]PersonalAccessToken, 0)
+	for rows.Next() {
+		var token PersonalAccessToken
+		var scopes string
+		var expiresAt, lastUsedAt sql.NullTime
+		if err := rows.Scan(&token.ID, &token.Name, &scopes, &token.CreatedAt, &expiresAt, &lastUsedAt); err != nil {
+			return nil, err
+		}
+		token.Scopes = strings.Fields(scopes)
+		token.ExpiresAt = nullTimePtr(expiresAt)
+		token.LastUsedAt = nullTimePtr(lastUsedAt)
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+/*
+ * Revoke a personal access token
+ * @param ctx: the context
+ * @param userId: the id of the user the token must belong to
+ * @param id: the id of the token
+ * @return error: ErrPersonalAccessTokenNotFound, or a database error
+ */
+func (s *PersonalAccessTokenServiceImpl) RevokeToken(ctx context.Context, userId int, id int) error {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE personal_access_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		id, userId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrPersonalAccessTokenNotFound
+	}
+	return nil
+}
+
+/*
+ * Revoke every personal access token of a user
+ * @param ctx: the context
+ * @param userId: the id of the user
+ * @return error: an error if the tokens could not be revoked
+ */
+func (s *PersonalAccessTokenServiceImpl) RevokeAllTokens(ctx context.Context, userId int) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE personal_access_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL", userId)
+	return err
+}
+
+/*
+ * Authenticate a request made with a personal access token and record that the token was used
+ * @param ctx: the context
+ * @param token: the personal access token
+ * @return Principal: the user the token acts as, restricted to the token's scopes
+ * @return error: ErrInvalidPersonalAccessToken, ErrTokenExpired, or a database error
+ */
+func (s *PersonalAccessTokenServiceImpl) AuthenticateToken(ctx context.Context, token string) (Principal, error) {
+	var id, userId int
+	var scopes, role string
+	var expiresAt sql.NullTime
+	var disabled, emailVerified bool
+	err := s.db.QueryRowContext(ctx, `SELECT p.id, p.user_id, p.scopes, p.expires_at, u.role, u.disabled_at IS NOT NULL, u.email_verified_at IS NOT NULL
+		FROM personal_access_tokens p JOIN users u ON u.id = p.user_id
+		WHERE p.token_hash = ? AND p.revoked_at IS NULL`,
+		HashToken(token)).Scan(&id, &userId, &scopes, &expiresAt, &role, &disabled, &emailVerified)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Principal{}, ErrInvalidPersonalAccessToken
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+
+	if disabled {
+		return Principal{}, ErrInvalidPersonalAccessToken
+	}
+	if expiresAt.Valid && time.Now().After(expiresAt.Time) {
+		return Principal{}, ErrTokenExpired
+	}
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE personal_access_tokens SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?", id); err != nil {
+		return Principal{}, err
+	}
+
+	return Principal{
+		UserID:        userId,
+		Role:          ParseRole(role),
+		EmailVerified: emailVerified,
+		Scopes:        strings.Fields(scopes),
+		ExpiresAt:     expiresAt.Time,
+	}, nil
+}
+
+// IsPersonalAccessToken reports whether a bearer token is a personal access token rather than a JWT
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// IsScope reports whether a scope can be granted to personal access tokens
+func IsScope(scope string) bool {
+	for _, valid := range Scopes {
+		if scope == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,78 @@
+package auth
+
+// Role is the role of a user, stored on the users table and embedded in access tokens
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Action is an operation a principal may be allowed to perform
+type Action string
+
+const (
+	ActionUpdateLearning Action = "learning:update"
+	ActionDeleteLearning Action = "learning:delete"
+	ActionManageCategory Action = "category:manage"
+	ActionListAccounts   Action = "account:list"
+	ActionDisableAccount Action = "account:disable"
+	ActionDeleteAccount  Action = "account:delete"
+	ActionUnlockAccount  Action = "account:unlock"
+	// ActionViewPrivate lets moderators and admins see private profiles and learning items, to moderate them
+	ActionViewPrivate Action = "content:view_private"
+)
+
+// Resource is what an action is performed on, an OwnerID of 0 means it has no owner
+type Resource struct {
+	OwnerID int
+}
+
+// ownerActions are the actions a principal may always perform on resources they own
+var ownerActions = map[Action]bool{
+	ActionUpdateLearning: true,
+	ActionDeleteLearning: true,
+	ActionManageCategory: true,
+}
+
+// rolePermissions are the actions a role may perform on any resource
+var rolePermissions = map[Role]map[Action]bool{
+	RoleUser: {},
+	RoleModerator: {
+		ActionDeleteLearning: true,
+		ActionViewPrivate:    true,
+	},
+	RoleAdmin: {
+		ActionUpdateLearning: true,
+		ActionDeleteLearning: true,
+		ActionListAccounts:   true,
+		ActionDisableAccount: true,
+		ActionDeleteAccount:  true,
+		ActionUnlockAccount:  true,
+		ActionViewPrivate:    true,
+	},
+}
+
+/*
+ * Check whether a principal may perform an action on a resource
+ * @param principal: the authenticated caller
+ * @param action: the action to perform
+ * @param resource: the resource the action is performed on
+ * @return bool: true if the action is allowed
+ */
+func Can(principal Principal, action Action, resource Resource) bool {
+	if ownerActions[action] && resource.OwnerID != 0 && resource.OwnerID == principal.UserID {
+		return true
+	}
+	return rolePermissions[principal.Role][action]
+}
+
+// ParseRole returns the role with the given name, unknown names are the least privileged role
+func ParseRole(name string) Role {
+	role := Role(name)
+	if _, ok := rolePermissions[role]; !ok {
+		return RoleUser
+	}
+	return role
+}
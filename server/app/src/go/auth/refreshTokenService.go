@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"software-slayer/db"
+)
+
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+type RefreshTokenService interface {
+	IssueRefreshToken(ctx context.Context, userId int) (string, error)
+	RotateRefreshToken(ctx context.Context, refreshToken string) (int, string, error)
+	RevokeRefreshToken(ctx context.Context, userId int, refreshToken string) error
+	RevokeAllRefreshTokens(ctx context.Context, userId int) error
+}
+
+type RefreshTokenServiceImpl struct {
+	db            *db.Database
+	tokenLifetime time.Duration
+}
+
+func NewRefreshTokenService(db *db.Database, tokenLifetime time.Duration) *RefreshTokenServiceImpl {
+	return &RefreshTokenServiceImpl{db: db, tokenLifetime: tokenLifetime}
+}
+
+/*
+ * Issue a refresh token that starts a new token family
+ * @param ctx: the context
+ * @param userId: the id of the user the token belongs to
+ * @return string: the refresh token, which is only stored as a hash
+ * @return error: an error if the token could not be stored
+ */
+func (s *RefreshTokenServiceImpl) IssueRefreshToken(ctx context.Context, userId int) (string, error) {
+	family, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	var token string
+	err = s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		token, err = s.insertRefreshToken(ctx, tx, userId, family)
+		return err
+	})
+	return token, err
+}
+
+/*
+ * Exchange a refresh token for a new one in the same family
+ * Each refresh token can only be used once. Presenting one that was already rotated means it
+ * has leaked, so every token in its family is revoked and the user has to log in again.
+ * @param ctx: the context
+ * @param refreshToken: the refresh token to rotate
+ * @return int: the id of the user the token belongs to
+ * @return string: the new refresh token
+ * @return error: ErrInvalidRefreshToken, ErrRefreshTokenReused, or a database error
+ */
+func (s *RefreshTokenServiceImpl) RotateRefreshToken(ctx context.Context, refreshToken string) (int, string, error) {
+	var userId int
+	var newToken string
+	reused := false
+
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		var id int
+		var family string
+		var expiresAt time.Time
+		var revokedAt sql.NullTime
+		err := tx.QueryRowContext(ctx,
+			"SELECT id, user_id, family_id, expires_at, revoked_at FROM refresh_tokens WHERE token_hash = ? FOR UPDATE",
+			HashToken(refreshToken)).Scan(&id, &userId, &family, &expiresAt, &revokedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if revokedAt.Valid {
+			// The revocation has to be committed, so reuse is reported once the transaction ends
+			reused = true
+			_, err = tx.ExecContext(ctx,
+				"UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = ? AND revoked_at IS NULL", family)
+			return err
+		}
+
+		if time.Now().After(expiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		if _, err = tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = ?", id); err != nil {
+			return err
+		}
+
+		newToken, err = s.insertRefreshToken(ctx, tx, userId, family)
+		return err
+	})
+	if err != nil {
+		return -1, "", err
+	}
+
+	if reused {
+		log.Printf("Refresh token reuse detected for user %d, revoked token family", userId)
+		return -1, "", ErrRefreshTokenReused
+	}
+
+	return userId, newToken, nil
+}
+
+/*
+ * Revoke a refresh token along with every other token in its family
+ * @param ctx: the context
+ * @param userId: the id of the user the token must belong to
+ * @param refreshToken: the refresh token to revoke
+ * @return error: an error if the tokens could not be revoked
+ */
+func (s *RefreshTokenServiceImpl) RevokeRefreshToken(ctx context.Context, userId int, refreshToken string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE revoked_at IS NULL AND family_id = (SELECT family_id FROM (SELECT family_id FROM refresh_tokens WHERE token_hash = ? AND user_id = ?) AS t)`,
+		HashToken(refreshToken), userId)
+	return err
+}
+
+// RevokeAllRefreshTokens revokes every refresh token of a user
+func (s *RefreshTokenServiceImpl) RevokeAllRefreshTokens(ctx context.Context, userId int) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL", userId)
+	return err
+}
+
+func (s *RefreshTokenServiceImpl) insertRefreshToken(ctx context.Context, tx *sql.Tx, userId int, family string) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)",
+		userId, family, HashToken(token), time.Now().Add(s.tokenLifetime).UTC())
+	return token, err
+}
+
+// HashToken returns the hex encoded SHA-256 hash of an opaque token for storage
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
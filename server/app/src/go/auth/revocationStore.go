@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"software-slayer/db"
+)
+
+// RevocationStore records access tokens that were revoked before they expired
+type RevocationStore interface {
+	// Revoke revokes a single token until it expires
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeUser revokes every token of a user issued at or before issuedBefore, until expiresAt
+	RevokeUser(ctx context.Context, userId int, issuedBefore time.Time, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string, userId int, issuedAt time.Time) (bool, error)
+	// Prune removes entries for tokens that have expired anyway
+	Prune(ctx context.Context, now time.Time) error
+}
+
+/*
+ * Periodically prune expired entries from a revocation store until the context is cancelled
+ * @param ctx: the context that stops pruning when cancelled
+ * @param store: the revocation store to prune
+ * @param interval: the time between prunes
+ */
+func PruneRevocations(ctx context.Context, store RevocationStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			pruneCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			if err := store.Prune(pruneCtx, now); err != nil {
+				log.Printf("Failed to prune revoked tokens: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+type userRevocation struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+type InMemoryRevocationStore struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+	users  map[int]userRevocation
+}
+
+func NewInMemoryRevocationStore() *InMemoryRevocationStore {
+	return &InMemoryRevocationStore{tokens: make(map[string]time.Time), users: make(map[int]userRevocation)}
+}
+
+func (s *InMemoryRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[jti] = expiresAt
+	return nil
+}
+
+func (s *InMemoryRevocationStore) RevokeUser(ctx context.Context, userId int, issuedBefore time.Time, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[userId] = userRevocation{issuedBefore: issuedBefore, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *InMemoryRevocationStore) IsRevoked(ctx context.Context, jti string, userId int, issuedAt time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.tokens[jti]; ok {
+		return true, nil
+	}
+	revocation, ok := s.users[userId]
+	return ok && !issuedAt.After(revocation.issuedBefore), nil
+}
+
+func (s *InMemoryRevocationStore) Prune(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for jti, expiresAt := range s.tokens {
+		if now.After(expiresAt) {
+			delete(s.tokens, jti)
+		}
+	}
+	for userId, revocation := range s.users {
+		if now.After(revocation.expiresAt) {
+			delete(s.users, userId)
+		}
+	}
+	return nil
+}
+
+type MySQLRevocationStore struct {
+	db *db.Database
+}
+
+func NewMySQLRevocationStore(db *db.Database) *MySQLRevocationStore {
+	return &MySQLRevocationStore{db: db}
+}
+
+func (s *MySQLRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, "INSERT IGNORE INTO revoked_tokens (jti, expires_at) VALUES (?, ?)", jti, expiresAt.UTC())
+	return err
+}
+
+func (s *MySQLRevocationStore) RevokeUser(ctx context.Context, userId int, issuedBefore time.Time, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO user_token_revocations (user_id, issued_before, expires_at) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE issued_before = VALUES(issued_before), expires_at = VALUES(expires_at)`,
+		userId, issuedBefore.UTC(), expiresAt.UTC())
+	return err
+}
+
+func (s *MySQLRevocationStore) IsRevoked(ctx context.Context, jti string, userId int, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?)
+		OR EXISTS (SELECT 1 FROM user_token_revocations WHERE user_id = ? AND issued_before >= ?)`,
+		jti, userId, issuedAt.UTC()).Scan(&revoked)
+	return revoked, err
+}
+
+func (s *MySQLRevocationStore) Prune(ctx context.Context, now time.Time) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at < ?", now.UTC()); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, "DELETE FROM user_token_revocations WHERE expires_at < ?", now.UTC())
+	return err
+}
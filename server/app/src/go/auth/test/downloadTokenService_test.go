@@ -0,0 +1,65 @@
+package auth_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"software-slayer/auth"
+)
+
+func downloadConfig(lifetime time.Duration) auth.TokenConfig {
+	return auth.TokenConfig{Issuer: "test-issuer", Audience: "test-download", Lifetime: lifetime}
+}
+
+func TestDownloadToken(t *testing.T) {
+	keyring := auth.NewKeyring(auth.NewHMACKey("test", []byte("secret")))
+	service := auth.NewDownloadTokenService(downloadConfig(time.Hour), keyring)
+
+	token, expiresAt, err := service.GenerateDownloadToken(3, "abc123")
+	if err != nil {
+		t.Fatalf("GenerateDownloadToken() returned error: %v", err)
+	}
+	if until := time.Until(expiresAt); until <= 59*time.Minute || until > time.Hour {
+		t.Errorf("GenerateDownloadToken() expires in %v, expected an hour", until)
+	}
+
+	userId, resource, err := service.ParseDownloadToken(token)
+	if err != nil || userId != 3 || resource != "abc123" {
+		t.Errorf("ParseDownloadToken() = %d, %q, %v", userId, resource, err)
+	}
+}
+
+func TestDownloadTokenRejected(t *testing.T) {
+	keyring := auth.NewKeyring(auth.NewHMACKey("test", []byte("secret")))
+	service := auth.NewDownloadTokenService(downloadConfig(time.Hour), keyring)
+	expired := auth.NewDownloadTokenService(downloadConfig(-time.Hour), keyring)
+	verification := auth.NewEmailVerificationService(verificationConfig(time.Hour), keyring)
+	tokenService := auth.NewTokenService(tokenConfig(time.Hour), keyring, auth.NewInMemoryRevocationStore(), nil)
+
+	expiredToken, _, _ := expired.GenerateDownloadToken(3, "abc123")
+	verificationToken, _ := verification.GenerateVerificationToken(3, "user@example.com")
+	accessToken, _ := tokenService.GenerateToken(3, auth.RoleUser, false)
+	downloadToken, _, _ := service.GenerateDownloadToken(3, "abc123")
+
+	tests := []struct {
+		token    string
+		expected error
+	}{
+		{expiredToken, auth.ErrTokenExpired},
+		{verificationToken, auth.ErrInvalidDownloadToken},
+		{accessToken, auth.ErrInvalidDownloadToken},
+		{"not a token", auth.ErrInvalidDownloadToken},
+	}
+
+	for _, test := range tests {
+		if _, _, err := service.ParseDownloadToken(test.token); !errors.Is(err, test.expected) {
+			t.Errorf("ParseDownloadToken(%q) returned %v, expected %v", test.token, err, test.expected)
+		}
+	}
+
+	// Download tokens can't be used as access tokens either
+	if _, err := tokenService.AuthorizeUser(downloadToken); err == nil {
+		t.Error("AuthorizeUser() accepted a download token")
+	}
+}
@@ -0,0 +1,59 @@
+package auth_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"software-slayer/auth"
+)
+
+func verificationConfig(lifetime time.Duration) auth.TokenConfig {
+	return auth.TokenConfig{Issuer: "test-issuer", Audience: "test-verification", Lifetime: lifetime}
+}
+
+func TestVerificationToken(t *testing.T) {
+	keyring := auth.NewKeyring(auth.NewHMACKey("test", []byte("secret")))
+	service := auth.NewEmailVerificationService(verificationConfig(time.Hour), keyring)
+
+	token, err := service.GenerateVerificationToken(3, "user@example.com")
+	if err != nil {
+		t.Fatalf("GenerateVerificationToken() returned error: %v", err)
+	}
+
+	userId, email, err := service.ParseVerificationToken(token)
+	if err != nil || userId != 3 || email != "user@example.com" {
+		t.Errorf("ParseVerificationToken() = %d, %q, %v", userId, email, err)
+	}
+}
+
+func TestVerificationTokenRejected(t *testing.T) {
+	keyring := auth.NewKeyring(auth.NewHMACKey("test", []byte("secret")))
+	service := auth.NewEmailVerificationService(verificationConfig(time.Hour), keyring)
+	expired := auth.NewEmailVerificationService(verificationConfig(-time.Hour), keyring)
+	tokenService := auth.NewTokenService(tokenConfig(time.Hour), keyring, auth.NewInMemoryRevocationStore(), nil)
+
+	expiredToken, _ := expired.GenerateVerificationToken(3, "user@example.com")
+	accessToken, _ := tokenService.GenerateToken(3, auth.RoleUser, false)
+	verificationToken, _ := service.GenerateVerificationToken(3, "user@example.com")
+
+	tests := []struct {
+		token    string
+		expected error
+	}{
+		{expiredToken, auth.ErrTokenExpired},
+		{accessToken, auth.ErrInvalidVerificationToken},
+		{"not a token", auth.ErrInvalidVerificationToken},
+	}
+
+	for _, test := range tests {
+		if _, _, err := service.ParseVerificationToken(test.token); !errors.Is(err, test.expected) {
+			t.Errorf("ParseVerificationToken(%q) returned %v, expected %v", test.token, err, test.expected)
+		}
+	}
+
+	// Verification tokens can't be used as access tokens either
+	if _, err := tokenService.AuthorizeUser(verificationToken); err == nil {
+		t.Error("AuthorizeUser() accepted a verification token")
+	}
+}
@@ -23,3 +23,27 @@ func FuzzHashAndValidatePassword(f *testing.F) {
 		}
 	})
 }
+
+func TestNeedsRehash(t *testing.T) {
+	if err := auth.SetBcryptCost(3); err == nil {
+		t.Error("SetBcryptCost(3) accepted a cost below the bcrypt minimum")
+	}
+
+	if err := auth.SetBcryptCost(5); err != nil {
+		t.Fatalf("SetBcryptCost(5) returned error: %v", err)
+	}
+	defer auth.SetBcryptCost(10)
+
+	current, _ := auth.HashPassword("password")
+	if auth.NeedsRehash(current) {
+		t.Error("NeedsRehash() = true for a hash with the current cost")
+	}
+
+	auth.SetBcryptCost(6)
+	if !auth.NeedsRehash(current) {
+		t.Error("NeedsRehash() = false for a hash with an outdated cost")
+	}
+	if !auth.NeedsRehash("5f4dcc3b5aa765d61d8327deb882cf99") {
+		t.Error("NeedsRehash() = false for a hash of another algorithm")
+	}
+}
@@ -0,0 +1,169 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"software-slayer/auth"
+)
+
+func writeKeyFile(t *testing.T, dir, name string, contents []byte) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), contents, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func pemEncode(t *testing.T, key interface{}) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// keyDir writes an HS256, an RS256 and an EdDSA key to a temporary directory
+func keyDir(t *testing.T, current string) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeKeyFile(t, dir, "hmac.hs256", []byte("secret\n"))
+	writeKeyFile(t, dir, "rsa.rs256", pemEncode(t, rsaKey))
+	writeKeyFile(t, dir, "ed.eddsa", pemEncode(t, edKey))
+	writeKeyFile(t, dir, "README", []byte("not a key"))
+	writeKeyFile(t, dir, "current", []byte(current+"\n"))
+	return dir
+}
+
+func TestLoadKeyringSignsWithEachAlgorithm(t *testing.T) {
+	for _, kid := range []string{"hmac", "rsa", "ed"} {
+		keyring, err := auth.LoadKeyring(keyDir(t, kid))
+		if err != nil {
+			t.Fatalf("LoadKeyring() returned error: %v", err)
+		}
+		if keyring.Current().ID != kid {
+			t.Errorf("expected current key %s, got %s", kid, keyring.Current().ID)
+		}
+
+		tokenService := auth.NewTokenService(tokenConfig(time.Hour), keyring, auth.NewInMemoryRevocationStore(), nil)
+		token, err := tokenService.GenerateToken(1, auth.RoleUser, true)
+		if err != nil {
+			t.Fatalf("%s: GenerateToken() returned error: %v", kid, err)
+		}
+		if principal, err := tokenService.AuthorizeUser(token); err != nil || principal.UserID != 1 {
+			t.Errorf("%s: AuthorizeUser() = %+v, %v", kid, principal, err)
+		}
+	}
+}
+
+func TestLoadKeyringMissingCurrentKey(t *testing.T) {
+	if _, err := auth.LoadKeyring(keyDir(t, "missing")); err == nil {
+		t.Error("LoadKeyring() did not return error for unknown current key")
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	dir := keyDir(t, "hmac")
+	keyring, err := auth.LoadKeyring(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenService := auth.NewTokenService(tokenConfig(time.Hour), keyring, auth.NewInMemoryRevocationStore(), nil)
+	oldToken, _ := tokenService.GenerateToken(1, auth.RoleUser, true)
+
+	// Rotate to a new current key, the old key still verifies
+	writeKeyFile(t, dir, "current", []byte("ed"))
+	if err := keyring.Reload(); err != nil {
+		t.Fatalf("Reload() returned error: %v", err)
+	}
+	if keyring.Current().ID != "ed" {
+		t.Errorf("expected current key ed, got %s", keyring.Current().ID)
+	}
+	if _, err := tokenService.AuthorizeUser(oldToken); err != nil {
+		t.Errorf("AuthorizeUser() rejected token signed with previous key: %v", err)
+	}
+
+	// Retire the old key
+	os.Remove(filepath.Join(dir, "hmac.hs256"))
+	if err := keyring.Reload(); err != nil {
+		t.Fatalf("Reload() returned error: %v", err)
+	}
+	if _, err := tokenService.AuthorizeUser(oldToken); err == nil {
+		t.Error("AuthorizeUser() accepted token signed with retired key")
+	}
+
+	// A failed reload keeps the loaded keys
+	writeKeyFile(t, dir, "current", []byte("hmac"))
+	if err := keyring.Reload(); err == nil {
+		t.Error("Reload() did not return error for retired current key")
+	}
+	if keyring.Current().ID != "ed" {
+		t.Errorf("failed reload changed current key to %s", keyring.Current().ID)
+	}
+}
+
+func TestAuthorizeUserRejectsTokenSignedWithOtherKey(t *testing.T) {
+	signer := auth.NewTokenService(tokenConfig(time.Hour), auth.NewKeyring(auth.NewHMACKey("test", []byte("other secret"))), auth.NewInMemoryRevocationStore(), nil)
+	verifier := auth.NewTokenService(tokenConfig(time.Hour), auth.NewKeyring(auth.NewHMACKey("test", []byte("secret"))), auth.NewInMemoryRevocationStore(), nil)
+
+	token, _ := signer.GenerateToken(1, auth.RoleUser, true)
+	if _, err := verifier.AuthorizeUser(token); err == nil {
+		t.Error("AuthorizeUser() accepted token signed with a different secret")
+	}
+}
+
+func TestGetJWKS(t *testing.T) {
+	keyring, err := auth.LoadKeyring(keyDir(t, "hmac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.InitAuthRest(keyring)
+	ts := httptest.NewServer(http.DefaultServeMux)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/.well-known/jwks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var set auth.JWKSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		t.Fatal(err)
+	}
+
+	// The HMAC secret must never be published
+	if len(set.Keys) != 2 {
+		t.Fatalf("expected 2 public keys, got %+v", set.Keys)
+	}
+	if set.Keys[0].Kid != "ed" || set.Keys[0].Kty != "OKP" || set.Keys[0].Crv != "Ed25519" || set.Keys[0].X == "" {
+		t.Errorf("unexpected EdDSA key: %+v", set.Keys[0])
+	}
+	if set.Keys[1].Kid != "rsa" || set.Keys[1].Kty != "RSA" || set.Keys[1].Alg != "RS256" || set.Keys[1].N == "" || set.Keys[1].E != "AQAB" {
+		t.Errorf("unexpected RSA key: %+v", set.Keys[1])
+	}
+}
@@ -0,0 +1,242 @@
+package auth_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"software-slayer/auth"
+	"software-slayer/db"
+)
+
+var accountThrottleConfig = auth.ThrottleConfig{
+	FreeAttempts:     2,
+	BaseDelay:        time.Minute,
+	MaxDelay:         2 * time.Minute,
+	LockoutThreshold: 5,
+	LockoutDuration:  time.Hour,
+	ResetAfter:       time.Hour,
+}
+
+var ipThrottleConfig = auth.ThrottleConfig{
+	FreeAttempts:     3,
+	BaseDelay:        time.Minute,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 4,
+	LockoutDuration:  time.Hour,
+	ResetAfter:       time.Hour,
+}
+
+// expectWait checks that the wait returned by Check is within a second of expected, as time passes during the test
+func expectWait(t *testing.T, throttle *auth.LoginThrottle, accountKey string, ip string, expected time.Duration) {
+	t.Helper()
+
+	wait, err := throttle.Check(context.Background(), accountKey, ip)
+	if err != nil {
+		t.Fatalf("Check() returned error: %v", err)
+	}
+	if wait > expected || wait < expected-time.Second {
+		t.Errorf("Check(%q, %q) = %v, expected %v", accountKey, ip, wait, expected)
+	}
+}
+
+func TestLoginThrottleBackoff(t *testing.T) {
+	ctx := context.Background()
+	auditLog := auth.NewInMemoryAuditLog()
+	throttle := auth.NewLoginThrottle(auth.NewInMemoryLoginAttemptStore(), auditLog, accountThrottleConfig, ipThrottleConfig)
+	account := auth.AccountKey(1)
+
+	expected := []time.Duration{0, 0, time.Minute, 2 * time.Minute, time.Hour}
+	for i, wait := range expected {
+		// A new address for each attempt, so only the account is throttled
+		ip := fmt.Sprintf("10.0.0.%d", i+1)
+		if err := throttle.RecordFailure(ctx, account, ip); err != nil {
+			t.Fatalf("RecordFailure() returned error: %v", err)
+		}
+		expectWait(t, throttle, account, "10.0.1.1", wait)
+	}
+
+	events := auditLog.Events()
+	if len(events) != 1 || events[0].Action != auth.AuditLoginLockout || events[0].Subject != account {
+		t.Fatalf("expected one lockout event for %s, got %+v", account, events)
+	}
+
+	// Other accounts are unaffected
+	expectWait(t, throttle, auth.AccountKey(2), "10.0.1.1", 0)
+
+	if err := throttle.Unlock(ctx, 1, 4); err != nil {
+		t.Fatalf("Unlock() returned error: %v", err)
+	}
+	expectWait(t, throttle, account, "10.0.1.1", 0)
+
+	events = auditLog.Events()
+	if len(events) != 2 || events[1].Action != auth.AuditAccountUnlock || events[1].ActorID != 4 || events[1].Subject != account {
+		t.Errorf("expected an unlock event by user 4, got %+v", events)
+	}
+}
+
+func TestLoginThrottleIP(t *testing.T) {
+	ctx := context.Background()
+	auditLog := auth.NewInMemoryAuditLog()
+	throttle := auth.NewLoginThrottle(auth.NewInMemoryLoginAttemptStore(), auditLog, accountThrottleConfig, ipThrottleConfig)
+
+	// One failure for each of several identifiers from the same address
+	identifiers := []string{"a", "b", "c", "d"}
+	for _, identifier := range identifiers {
+		if err := throttle.RecordFailure(ctx, auth.IdentifierKey(identifier), "10.0.0.1"); err != nil {
+			t.Fatalf("RecordFailure() returned error: %v", err)
+		}
+	}
+
+	expectWait(t, throttle, auth.IdentifierKey("e"), "10.0.0.1", time.Hour)
+	expectWait(t, throttle, auth.IdentifierKey("e"), "10.0.0.2", 0)
+
+	events := auditLog.Events()
+	if len(events) != 1 || events[0].Subject != auth.IPKey("10.0.0.1") {
+		t.Errorf("expected one lockout event for the address, got %+v", events)
+	}
+}
+
+func TestLoginThrottleRecordSuccess(t *testing.T) {
+	ctx := context.Background()
+	throttle := auth.NewLoginThrottle(auth.NewInMemoryLoginAttemptStore(), auth.NewInMemoryAuditLog(), accountThrottleConfig, ipThrottleConfig)
+	account := auth.AccountKey(1)
+
+	for i := 0; i < 3; i++ {
+		throttle.RecordFailure(ctx, account, "10.0.0.1")
+	}
+	expectWait(t, throttle, account, "10.0.0.2", time.Minute)
+
+	if err := throttle.RecordSuccess(ctx, account); err != nil {
+		t.Fatalf("RecordSuccess() returned error: %v", err)
+	}
+	expectWait(t, throttle, account, "10.0.0.2", 0)
+}
+
+func TestIdentifierKey(t *testing.T) {
+	if auth.IdentifierKey("Test@Example.com") != auth.IdentifierKey("test@example.com") {
+		t.Error("IdentifierKey() is case sensitive")
+	}
+	if auth.IdentifierKey("1") == auth.AccountKey(1) {
+		t.Error("IdentifierKey() collides with AccountKey()")
+	}
+}
+
+func TestInMemoryLoginAttemptStore(t *testing.T) {
+	ctx := context.Background()
+	store := auth.NewInMemoryLoginAttemptStore()
+	now := time.Now()
+
+	store.RecordFailure(ctx, "key", now.Add(-2*time.Hour), now.Add(-3*time.Hour))
+	attempts, _ := store.RecordFailure(ctx, "key", now.Add(-time.Hour), now.Add(-3*time.Hour))
+	if attempts.Failures != 2 {
+		t.Errorf("expected 2 failures, got %d", attempts.Failures)
+	}
+
+	// A failure after the reset window starts the count over
+	attempts, _ = store.RecordFailure(ctx, "key", now, now.Add(-30*time.Minute))
+	if attempts.Failures != 1 {
+		t.Errorf("expected failures to reset, got %d", attempts.Failures)
+	}
+
+	store.RecordFailure(ctx, "locked", now.Add(-2*time.Hour), now)
+	store.Lock(ctx, "locked", now.Add(time.Hour))
+	store.RecordFailure(ctx, "old", now.Add(-2*time.Hour), now)
+
+	store.Prune(ctx, now.Add(-time.Hour))
+	if attempts, _ := store.Get(ctx, "old"); attempts.Failures != 0 {
+		t.Error("Prune() did not remove old attempts")
+	}
+	if attempts, _ := store.Get(ctx, "locked"); attempts.Failures != 1 {
+		t.Error("Prune() removed attempts that are still locked out")
+	}
+	if attempts, _ := store.Get(ctx, "key"); attempts.Failures != 1 {
+		t.Error("Prune() removed recent attempts")
+	}
+}
+
+func TestMySQLLoginAttemptStore(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	store := auth.NewMySQLLoginAttemptStore(db.NewDB(database))
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	resetBefore := now.Add(-time.Hour)
+
+	mock.ExpectQuery("SELECT failures, last_failure_at, locked_until FROM login_attempts").
+		WithArgs("user:1").
+		WillReturnRows(sqlmock.NewRows([]string{"failures", "last_failure_at", "locked_until"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO login_attempts").
+		WithArgs("user:1", now, resetBefore).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("SELECT failures, last_failure_at, locked_until FROM login_attempts").
+		WithArgs("user:1").
+		WillReturnRows(sqlmock.NewRows([]string{"failures", "last_failure_at", "locked_until"}).AddRow(3, now, nil))
+	mock.ExpectCommit()
+	mock.ExpectExec("UPDATE login_attempts SET locked_until").
+		WithArgs(now.Add(time.Hour), "user:1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM login_attempts WHERE attempt_key").
+		WithArgs("user:1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM login_attempts WHERE last_failure_at").
+		WithArgs(resetBefore, resetBefore).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	attempts, err := store.Get(ctx, "user:1")
+	if err != nil || attempts.Failures != 0 {
+		t.Errorf("Get() = %+v, %v, expected no failures", attempts, err)
+	}
+	attempts, err = store.RecordFailure(ctx, "user:1", now, resetBefore)
+	if err != nil || attempts.Failures != 3 || !attempts.LastFailure.Equal(now) || !attempts.LockedUntil.IsZero() {
+		t.Errorf("RecordFailure() = %+v, %v, expected 3 failures", attempts, err)
+	}
+	if err := store.Lock(ctx, "user:1", now.Add(time.Hour)); err != nil {
+		t.Errorf("Lock() returned error: %v", err)
+	}
+	if err := store.Reset(ctx, "user:1"); err != nil {
+		t.Errorf("Reset() returned error: %v", err)
+	}
+	if err := store.Prune(ctx, resetBefore); err != nil {
+		t.Errorf("Prune() returned error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMySQLAuditLog(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	auditLog := auth.NewMySQLAuditLog(db.NewDB(database))
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// Events caused by the server have no actor
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs(auth.AuditLoginLockout, nil, "ip:10.0.0.1", "locked", now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs(auth.AuditAccountUnlock, 4, "user:1", "", now).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	if err := auditLog.Record(ctx, auth.AuditEvent{Action: auth.AuditLoginLockout, Subject: "ip:10.0.0.1", Detail: "locked", CreatedAt: now}); err != nil {
+		t.Errorf("Record() returned error: %v", err)
+	}
+	if err := auditLog.Record(ctx, auth.AuditEvent{Action: auth.AuditAccountUnlock, ActorID: 4, Subject: "user:1", CreatedAt: now}); err != nil {
+		t.Errorf("Record() returned error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,159 @@
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"software-slayer/auth"
+)
+
+func TestParseBearerToken(t *testing.T) {
+	tests := []struct {
+		header   string
+		token    string
+		expected error
+	}{
+		{"Bearer abc.def.ghi", "abc.def.ghi", nil},
+		{"bearer abc.def.ghi", "abc.def.ghi", nil},
+		{"Bearer   abc.def.ghi ", "abc.def.ghi", nil},
+		{"", "", auth.ErrTokenMissing},
+		{"Bearer ", "", auth.ErrTokenMissing},
+		{"abc.def.ghi", "", auth.ErrAuthorizationScheme},
+		{"Basic dXNlcjpwYXNz", "", auth.ErrAuthorizationScheme},
+	}
+
+	for _, test := range tests {
+		token, err := auth.ParseBearerToken(test.header)
+		if token != test.token || !errors.Is(err, test.expected) {
+			t.Errorf("ParseBearerToken(%q) = %q, %v, expected %q, %v", test.header, token, err, test.token, test.expected)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tokenService := auth.NewTokenService(tokenConfig(time.Hour), auth.NewKeyring(auth.NewHMACKey("test", []byte("secret"))), auth.NewInMemoryRevocationStore(), nil)
+	token, _ := tokenService.GenerateToken(7, auth.RoleUser, true)
+
+	// The handler responds with the status given by the principal it finds
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		userId, _ := auth.UserIDFromContext(r.Context())
+		tokenId, _ := auth.TokenIDFromContext(r.Context())
+		switch {
+		case !ok:
+			w.WriteHeader(http.StatusNoContent)
+		case userId == 7 && tokenId == principal.TokenID && tokenId != "":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+
+	tests := []struct {
+		access   auth.Access
+		header   string
+		expected int
+	}{
+		{auth.Authenticated, "Bearer " + token, http.StatusOK},
+		{auth.Authenticated, "", http.StatusUnauthorized},
+		{auth.Authenticated, token, http.StatusUnauthorized},
+		{auth.Authenticated, "Bearer invalid", http.StatusUnauthorized},
+		{auth.OptionalAuthentication, "Bearer " + token, http.StatusOK},
+		{auth.OptionalAuthentication, "", http.StatusNoContent},
+		{auth.OptionalAuthentication, "Bearer invalid", http.StatusUnauthorized},
+		{auth.Public, "Bearer " + token, http.StatusNoContent},
+		{auth.Public, "Bearer invalid", http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+		w := httptest.NewRecorder()
+
+		auth.Middleware(tokenService, test.access, handler)(w, r)
+
+		if w.Code != test.expected {
+			t.Errorf("access %d, header %q: expected %d, got %d", test.access, test.header, test.expected, w.Code)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("access %d, header %q: missing WWW-Authenticate header", test.access, test.header)
+		}
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	restricted := auth.Principal{UserID: 1, Scopes: []string{auth.ScopeReadLearnings}}
+
+	tests := []struct {
+		principal *auth.Principal
+		scope     string
+		expected  int
+	}{
+		{&restricted, auth.ScopeReadLearnings, http.StatusOK},
+		{&restricted, auth.ScopeWriteLearnings, http.StatusForbidden},
+		{&restricted, "", http.StatusForbidden},
+		{&auth.Principal{UserID: 1}, auth.ScopeWriteLearnings, http.StatusOK},
+		{&auth.Principal{UserID: 1}, "", http.StatusOK},
+		{nil, auth.ScopeReadLearnings, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.principal != nil {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), *test.principal))
+		}
+		w := httptest.NewRecorder()
+
+		auth.RequireScope(test.scope, handler)(w, r)
+
+		if w.Code != test.expected {
+			t.Errorf("principal %+v, scope %q: expected %d, got %d", test.principal, test.scope, test.expected, w.Code)
+		}
+		if w.Code == http.StatusForbidden && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("principal %+v, scope %q: missing WWW-Authenticate header", test.principal, test.scope)
+		}
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	defer auth.SetEmailVerificationPolicy(auth.VerificationOptional)
+	auth.SetEmailVerificationPolicy(auth.VerificationReadOnly)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	tests := []struct {
+		principal *auth.Principal
+		method    string
+		expected  int
+	}{
+		{&auth.Principal{UserID: 1, EmailVerified: true}, "POST", http.StatusOK},
+		{&auth.Principal{UserID: 1}, "POST", http.StatusForbidden},
+		{&auth.Principal{UserID: 1}, "DELETE", http.StatusForbidden},
+		{&auth.Principal{UserID: 1}, "GET", http.StatusOK},
+		{nil, "POST", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/", nil)
+		if test.principal != nil {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), *test.principal))
+		}
+		w := httptest.NewRecorder()
+
+		auth.RequireVerifiedEmail(handler)(w, r)
+
+		if w.Code != test.expected {
+			t.Errorf("principal %+v, method %s: expected %d, got %d", test.principal, test.method, test.expected, w.Code)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package auth_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"software-slayer/auth"
+)
+
+func TestPasswordPolicies(t *testing.T) {
+	policy := auth.PasswordPolicies{
+		auth.LengthPolicy{Min: 8, Max: 64},
+		auth.CharacterClassPolicy{MinClasses: 3},
+		auth.PersonalInfoPolicy{},
+		auth.NewBreachedPasswordPolicy([]string{"Password123!"}),
+	}
+
+	tests := []struct {
+		password string
+		valid    bool
+	}{
+		{"Tr0ub4dor&3", true},
+		{"Sh0rt!", false},
+		{strings.Repeat("Aa1!", 17), false},
+		{"alllowercase", false},
+		{"lowerUPPER123", true},
+		{"password123!", false},
+		{"Jdoe-Secret1", false},
+		{"Secret1-Example", true},
+		{"Jo!1Jo!1Jo!1", true},
+	}
+
+	for _, test := range tests {
+		err := policy.Check(test.password, "jdoe", "john@example.com")
+		if test.valid && err != nil {
+			t.Errorf("Check(%q) rejected a valid password: %v", test.password, err)
+		}
+		var weak *auth.WeakPasswordError
+		if !test.valid && !errors.As(err, &weak) {
+			t.Errorf("Check(%q) = %v, expected a WeakPasswordError", test.password, err)
+		}
+	}
+}
+
+func TestPersonalInfoPolicy(t *testing.T) {
+	policy := auth.PersonalInfoPolicy{}
+
+	if err := policy.Check("my-john-password", "jdoe", "john@example.com"); err == nil {
+		t.Error("Check() accepted a password containing the name in the email")
+	}
+	if err := policy.Check("JDOE2024!", "jdoe", "john@example.com"); err == nil {
+		t.Error("Check() accepted a password containing the username in another case")
+	}
+	// Names shorter than 3 characters are ignored, or they would reject too many passwords
+	if err := policy.Check("jo-password", "jo", "jo@example.com"); err != nil {
+		t.Errorf("Check() rejected a password for containing a short name: %v", err)
+	}
+}
+
+func TestEntropyPolicy(t *testing.T) {
+	policy := auth.EntropyPolicy{MinBits: 50}
+
+	if err := policy.Check("abcdefgh"); err == nil {
+		t.Errorf("Check() accepted a password with %.1f bits of entropy", auth.PasswordEntropy("abcdefgh"))
+	}
+	if err := policy.Check("correct horse battery staple"); err != nil {
+		t.Errorf("Check() rejected a long passphrase: %v", err)
+	}
+	if auth.PasswordEntropy("") != 0 {
+		t.Error("PasswordEntropy() of an empty password is not 0")
+	}
+}
+
+func TestLoadBreachedPasswordPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte("123456\r\nqwertyuiop\n\nletmein\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := auth.LoadBreachedPasswordPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadBreachedPasswordPolicy() returned error: %v", err)
+	}
+	if policy.Len() != 3 {
+		t.Errorf("expected 3 passwords, got %d", policy.Len())
+	}
+	if err := policy.Check("QwertyUIOP"); err == nil {
+		t.Error("Check() accepted a breached password in another case")
+	}
+	if err := policy.Check("not-breached"); err != nil {
+		t.Errorf("Check() rejected a password that is not in the list: %v", err)
+	}
+
+	if _, err := auth.LoadBreachedPasswordPolicy(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("LoadBreachedPasswordPolicy() did not fail for a missing file")
+	}
+}
@@ -0,0 +1,113 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"software-slayer/auth"
+	"software-slayer/db"
+)
+
+var resetTokenColumns = []string{"id", "user_id", "expires_at"}
+
+func setupPasswordResets(t *testing.T) (sqlmock.Sqlmock, *auth.PasswordResetServiceImpl) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	return mock, auth.NewPasswordResetService(db.NewDB(database), time.Hour)
+}
+
+func TestIssueResetToken(t *testing.T) {
+	mock, service := setupPasswordResets(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE user_id = \\? AND used_at IS NULL").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO password_reset_tokens").
+		WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	token, err := service.IssueResetToken(context.Background(), 1)
+	if err != nil {
+		t.Errorf("IssueResetToken() returned error: %v", err)
+	}
+	if token == "" {
+		t.Error("IssueResetToken() returned an empty token")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestConsumeResetToken(t *testing.T) {
+	mock, service := setupPasswordResets(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, user_id, expires_at FROM password_reset_tokens WHERE token_hash = \\? AND used_at IS NULL FOR UPDATE").
+		WithArgs(auth.HashToken("reset_token")).
+		WillReturnRows(sqlmock.NewRows(resetTokenColumns).AddRow(3, 1, time.Now().Add(time.Hour)))
+	mock.ExpectExec("UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = \\?").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	userId, err := service.ConsumeResetToken(context.Background(), "reset_token")
+	if err != nil || userId != 1 {
+		t.Errorf("ConsumeResetToken() = %d, %v", userId, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestConsumeResetTokenRejected(t *testing.T) {
+	tests := map[string]*sqlmock.Rows{
+		"unknown or used": sqlmock.NewRows(resetTokenColumns),
+		"expired":         sqlmock.NewRows(resetTokenColumns).AddRow(3, 1, time.Now().Add(-time.Minute)),
+	}
+
+	for name, rows := range tests {
+		mock, service := setupPasswordResets(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, user_id, expires_at FROM password_reset_tokens").WillReturnRows(rows)
+		mock.ExpectRollback()
+
+		if _, err := service.ConsumeResetToken(context.Background(), "reset_token"); !errors.Is(err, auth.ErrInvalidResetToken) {
+			t.Errorf("%s: ConsumeResetToken() returned %v, expected ErrInvalidResetToken", name, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: there were unfulfilled expectations: %s", name, err)
+		}
+	}
+}
+
+func TestLookupResetToken(t *testing.T) {
+	mock, service := setupPasswordResets(t)
+
+	mock.ExpectQuery("SELECT user_id, expires_at FROM password_reset_tokens WHERE token_hash = \\? AND used_at IS NULL").
+		WithArgs(auth.HashToken("reset_token")).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "expires_at"}).AddRow(1, time.Now().Add(time.Hour)))
+	mock.ExpectQuery("SELECT user_id, expires_at FROM password_reset_tokens").
+		WithArgs(auth.HashToken("expired_token")).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "expires_at"}).AddRow(1, time.Now().Add(-time.Minute)))
+
+	userId, err := service.LookupResetToken(context.Background(), "reset_token")
+	if err != nil || userId != 1 {
+		t.Errorf("LookupResetToken() = %d, %v, expected 1", userId, err)
+	}
+	if _, err := service.LookupResetToken(context.Background(), "expired_token"); !errors.Is(err, auth.ErrInvalidResetToken) {
+		t.Errorf("expected ErrInvalidResetToken for an expired token, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
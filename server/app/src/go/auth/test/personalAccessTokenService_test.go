@@ -0,0 +1,172 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"software-slayer/auth"
+	"software-slayer/db"
+)
+
+var personalAccessTokenColumns = []string{"id", "user_id", "scopes", "expires_at", "role", "disabled", "email_verified"}
+
+func setupPersonalAccessTokens(t *testing.T) (sqlmock.Sqlmock, *auth.PersonalAccessTokenServiceImpl) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	return mock, auth.NewPersonalAccessTokenService(db.NewDB(database))
+}
+
+func TestCreatePersonalAccessToken(t *testing.T) {
+	mock, service := setupPersonalAccessTokens(t)
+
+	mock.ExpectExec("INSERT INTO personal_access_tokens").
+		WithArgs(1, "ci", sqlmock.AnyArg(), "read:learnings write:learnings", sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+
+	details, token, err := service.CreateToken(context.Background(), 1, "ci", []string{auth.ScopeReadLearnings, auth.ScopeWriteLearnings}, nil)
+	if err != nil {
+		t.Fatalf("CreateToken() returned error: %v", err)
+	}
+	if !strings.HasPrefix(token, auth.PersonalAccessTokenPrefix) || !auth.IsPersonalAccessToken(token) {
+		t.Errorf("CreateToken() returned token without prefix: %s", token)
+	}
+	if details.ID != 5 || details.Name != "ci" || len(details.Scopes) != 2 || details.ExpiresAt != nil {
+		t.Errorf("CreateToken() returned unexpected details: %+v", details)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetPersonalAccessTokens(t *testing.T) {
+	mock, service := setupPersonalAccessTokens(t)
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT id, name, scopes, created_at, expires_at, last_used_at FROM personal_access_tokens WHERE user_id = \\? AND revoked_at IS NULL").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "scopes", "created_at", "expires_at", "last_used_at"}).
+			AddRow(1, "ci", "read:learnings", now, nil, now).
+			AddRow(2, "backup", "read:learnings read:users", now, now.Add(time.Hour), nil))
+
+	tokens, err := service.GetTokens(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetTokens() returned error: %v", err)
+	}
+	if len(tokens) != 2 {
+		t.Fatalf("expected 2 tokens, got %+v", tokens)
+	}
+	if tokens[0].LastUsedAt == nil || tokens[0].ExpiresAt != nil || len(tokens[1].Scopes) != 2 || tokens[1].LastUsedAt != nil {
+		t.Errorf("GetTokens() returned unexpected tokens: %+v", tokens)
+	}
+}
+
+func TestRevokePersonalAccessToken(t *testing.T) {
+	mock, service := setupPersonalAccessTokens(t)
+
+	mock.ExpectExec("UPDATE personal_access_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = \\? AND user_id = \\?").
+		WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE personal_access_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = \\? AND user_id = \\?").
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := service.RevokeToken(context.Background(), 1, 1); err != nil {
+		t.Errorf("RevokeToken() returned error: %v", err)
+	}
+	if err := service.RevokeToken(context.Background(), 2, 1); !errors.Is(err, auth.ErrPersonalAccessTokenNotFound) {
+		t.Errorf("RevokeToken() returned %v for another user's token, expected ErrPersonalAccessTokenNotFound", err)
+	}
+}
+
+func TestRevokeAllPersonalAccessTokens(t *testing.T) {
+	mock, service := setupPersonalAccessTokens(t)
+
+	mock.ExpectExec("UPDATE personal_access_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = \\? AND revoked_at IS NULL").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	if err := service.RevokeAllTokens(context.Background(), 1); err != nil {
+		t.Errorf("RevokeAllTokens() returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAuthenticatePersonalAccessToken(t *testing.T) {
+	mock, service := setupPersonalAccessTokens(t)
+
+	mock.ExpectQuery("SELECT p.id, p.user_id, p.scopes, p.expires_at, u.role").
+		WithArgs(auth.HashToken("ssp_token")).
+		WillReturnRows(sqlmock.NewRows(personalAccessTokenColumns).AddRow(3, 1, "read:learnings", nil, "admin", false, true))
+	mock.ExpectExec("UPDATE personal_access_tokens SET last_used_at = CURRENT_TIMESTAMP WHERE id = \\?").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	principal, err := service.AuthenticateToken(context.Background(), "ssp_token")
+	if err != nil {
+		t.Fatalf("AuthenticateToken() returned error: %v", err)
+	}
+	if principal.UserID != 1 || principal.Role != auth.RoleAdmin || !principal.EmailVerified || !principal.HasScope(auth.ScopeReadLearnings) || principal.HasScope(auth.ScopeWriteLearnings) {
+		t.Errorf("AuthenticateToken() returned unexpected principal: %+v", principal)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAuthenticatePersonalAccessTokenRejected(t *testing.T) {
+	tests := []struct {
+		rows     *sqlmock.Rows
+		expected error
+	}{
+		{sqlmock.NewRows(personalAccessTokenColumns), auth.ErrInvalidPersonalAccessToken},
+		{sqlmock.NewRows(personalAccessTokenColumns).AddRow(3, 1, "read:learnings", nil, "user", true, true), auth.ErrInvalidPersonalAccessToken},
+		{sqlmock.NewRows(personalAccessTokenColumns).AddRow(3, 1, "read:learnings", time.Now().Add(-time.Hour), "user", false, true), auth.ErrTokenExpired},
+	}
+
+	for _, test := range tests {
+		mock, service := setupPersonalAccessTokens(t)
+		mock.ExpectQuery("SELECT p.id, p.user_id").WillReturnRows(test.rows)
+
+		if _, err := service.AuthenticateToken(context.Background(), "ssp_token"); !errors.Is(err, test.expected) {
+			t.Errorf("AuthenticateToken() returned %v, expected %v", err, test.expected)
+		}
+	}
+}
+
+func TestAuthorizeUserPersonalAccessToken(t *testing.T) {
+	mock, service := setupPersonalAccessTokens(t)
+	keyring := auth.NewKeyring(auth.NewHMACKey("test", []byte("secret")))
+
+	mock.ExpectQuery("SELECT p.id, p.user_id").
+		WillReturnRows(sqlmock.NewRows(personalAccessTokenColumns).AddRow(3, 1, "read:users", nil, "user", false, true))
+	mock.ExpectExec("UPDATE personal_access_tokens SET last_used_at").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	tokenService := auth.NewTokenService(tokenConfig(time.Hour), keyring, auth.NewInMemoryRevocationStore(), service)
+	principal, err := tokenService.AuthorizeUser("ssp_token")
+	if err != nil || principal.UserID != 1 || !principal.HasScope(auth.ScopeReadUsers) {
+		t.Errorf("AuthorizeUser() = %+v, %v", principal, err)
+	}
+
+	// JWTs are still accepted, and are not restricted to any scope
+	token, _ := tokenService.GenerateToken(1, auth.RoleUser, true)
+	principal, err = tokenService.AuthorizeUser(token)
+	if err != nil || principal.Scopes != nil || !principal.HasScope(auth.ScopeWriteLearnings) {
+		t.Errorf("AuthorizeUser() = %+v, %v for JWT", principal, err)
+	}
+
+	// Without a personal access token service only JWTs are accepted
+	jwtOnly := auth.NewTokenService(tokenConfig(time.Hour), keyring, auth.NewInMemoryRevocationStore(), nil)
+	if _, err := jwtOnly.AuthorizeUser("ssp_token"); !errors.Is(err, auth.ErrInvalidPersonalAccessToken) {
+		t.Errorf("AuthorizeUser() returned %v, expected ErrInvalidPersonalAccessToken", err)
+	}
+}
@@ -0,0 +1,117 @@
+package auth_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"software-slayer/auth"
+)
+
+func TestCan(t *testing.T) {
+	user := auth.Principal{UserID: 1, Role: auth.RoleUser}
+	moderator := auth.Principal{UserID: 2, Role: auth.RoleModerator}
+	admin := auth.Principal{UserID: 3, Role: auth.RoleAdmin}
+
+	tests := []struct {
+		principal auth.Principal
+		action    auth.Action
+		resource  auth.Resource
+		expected  bool
+	}{
+		{user, auth.ActionUpdateLearning, auth.Resource{OwnerID: 1}, true},
+		{user, auth.ActionDeleteLearning, auth.Resource{OwnerID: 1}, true},
+		{user, auth.ActionDeleteLearning, auth.Resource{OwnerID: 2}, false},
+		{user, auth.ActionManageCategory, auth.Resource{OwnerID: 1}, true},
+		{user, auth.ActionManageCategory, auth.Resource{}, false},
+		{user, auth.ActionListAccounts, auth.Resource{}, false},
+		{user, auth.ActionDeleteAccount, auth.Resource{OwnerID: 1}, false},
+		{moderator, auth.ActionDeleteLearning, auth.Resource{OwnerID: 1}, true},
+		{moderator, auth.ActionUpdateLearning, auth.Resource{OwnerID: 1}, false},
+		{moderator, auth.ActionDisableAccount, auth.Resource{OwnerID: 1}, false},
+		{admin, auth.ActionDeleteLearning, auth.Resource{OwnerID: 1}, true},
+		{admin, auth.ActionUpdateLearning, auth.Resource{OwnerID: 1}, true},
+		{admin, auth.ActionManageCategory, auth.Resource{OwnerID: 1}, false},
+		{admin, auth.ActionListAccounts, auth.Resource{}, true},
+		{admin, auth.ActionDisableAccount, auth.Resource{OwnerID: 1}, true},
+		{admin, auth.ActionDeleteAccount, auth.Resource{OwnerID: 1}, true},
+		{auth.Principal{UserID: 1, Role: "root"}, auth.ActionListAccounts, auth.Resource{}, false},
+	}
+
+	for _, test := range tests {
+		if allowed := auth.Can(test.principal, test.action, test.resource); allowed != test.expected {
+			t.Errorf("Can(%+v, %s, %+v) = %t, expected %t", test.principal, test.action, test.resource, allowed, test.expected)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	tests := map[string]auth.Role{
+		"user":      auth.RoleUser,
+		"moderator": auth.RoleModerator,
+		"admin":     auth.RoleAdmin,
+		"":          auth.RoleUser,
+		"root":      auth.RoleUser,
+	}
+
+	for name, expected := range tests {
+		if role := auth.ParseRole(name); role != expected {
+			t.Errorf("ParseRole(%q) = %q, expected %q", name, role, expected)
+		}
+	}
+}
+
+func TestAuthorizeUserRole(t *testing.T) {
+	tokenService := auth.NewTokenService(tokenConfig(time.Hour), auth.NewKeyring(auth.NewHMACKey("test", []byte("secret"))), auth.NewInMemoryRevocationStore(), nil)
+
+	for _, role := range []auth.Role{auth.RoleUser, auth.RoleModerator, auth.RoleAdmin} {
+		token, _ := tokenService.GenerateToken(1, role, true)
+		principal, err := tokenService.AuthorizeUser(token)
+		if err != nil || principal.Role != role {
+			t.Errorf("AuthorizeUser() = %+v, %v, expected role %s", principal, err, role)
+		}
+	}
+}
+
+func TestEmailVerificationPolicy(t *testing.T) {
+	defer auth.SetEmailVerificationPolicy(auth.VerificationOptional)
+
+	verified := auth.Principal{UserID: 1, EmailVerified: true}
+	unverified := auth.Principal{UserID: 2}
+
+	tests := []struct {
+		policy             auth.EmailVerificationPolicy
+		unverifiedCanLogIn bool
+		unverifiedCanWrite bool
+	}{
+		{auth.VerificationOptional, true, true},
+		{auth.VerificationReadOnly, true, false},
+		{auth.VerificationRequired, false, false},
+	}
+
+	for _, test := range tests {
+		auth.SetEmailVerificationPolicy(test.policy)
+
+		if !auth.CanLogIn(true) || !auth.CanWrite(verified) {
+			t.Errorf("policy %s: verified user was restricted", test.policy)
+		}
+		if canLogIn := auth.CanLogIn(false); canLogIn != test.unverifiedCanLogIn {
+			t.Errorf("policy %s: CanLogIn(false) = %t, expected %t", test.policy, canLogIn, test.unverifiedCanLogIn)
+		}
+		if canWrite := auth.CanWrite(unverified); canWrite != test.unverifiedCanWrite {
+			t.Errorf("policy %s: CanWrite(unverified) = %t, expected %t", test.policy, canWrite, test.unverifiedCanWrite)
+		}
+	}
+}
+
+func TestParseEmailVerificationPolicy(t *testing.T) {
+	for _, name := range []string{"optional", "read_only", "required"} {
+		if policy, err := auth.ParseEmailVerificationPolicy(name); err != nil || string(policy) != name {
+			t.Errorf("ParseEmailVerificationPolicy(%q) = %q, %v", name, policy, err)
+		}
+	}
+
+	if _, err := auth.ParseEmailVerificationPolicy("sometimes"); !errors.Is(err, auth.ErrUnknownVerificationPolicy) {
+		t.Errorf("ParseEmailVerificationPolicy() returned %v for an unknown policy", err)
+	}
+}
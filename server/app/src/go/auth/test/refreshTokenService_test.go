@@ -0,0 +1,123 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"software-slayer/auth"
+	"software-slayer/db"
+)
+
+var refreshTokenColumns = []string{"id", "user_id", "family_id", "expires_at", "revoked_at"}
+
+func setupRefreshTokens(t *testing.T) (sqlmock.Sqlmock, *auth.RefreshTokenServiceImpl) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	return mock, auth.NewRefreshTokenService(db.NewDB(database), time.Hour)
+}
+
+func TestIssueRefreshToken(t *testing.T) {
+	mock, service := setupRefreshTokens(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	token, err := service.IssueRefreshToken(context.Background(), 1)
+	if err != nil {
+		t.Errorf("IssueRefreshToken() returned error: %v", err)
+	}
+	if token == "" {
+		t.Error("IssueRefreshToken() returned an empty token")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	mock, service := setupRefreshTokens(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, user_id, family_id, expires_at, revoked_at FROM refresh_tokens").
+		WithArgs(auth.HashToken("old_token")).
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns).AddRow(7, 1, "family", time.Now().Add(time.Hour), nil))
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = ?").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(1, "family", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(8, 1))
+	mock.ExpectCommit()
+
+	userId, token, err := service.RotateRefreshToken(context.Background(), "old_token")
+	if err != nil {
+		t.Errorf("RotateRefreshToken() returned error: %v", err)
+	}
+	if userId != 1 {
+		t.Errorf("RotateRefreshToken() returned wrong user id: %d", userId)
+	}
+	if token == "" || token == "old_token" {
+		t.Errorf("RotateRefreshToken() did not return a new token: %q", token)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRotateRefreshTokenReuseRevokesFamily(t *testing.T) {
+	mock, service := setupRefreshTokens(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, user_id, family_id, expires_at, revoked_at FROM refresh_tokens").
+		WithArgs(auth.HashToken("old_token")).
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns).AddRow(7, 1, "family", time.Now().Add(time.Hour), time.Now()))
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = ?").
+		WithArgs("family").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// The family revocation must be committed, not rolled back
+	mock.ExpectCommit()
+
+	_, _, err := service.RotateRefreshToken(context.Background(), "old_token")
+	if !errors.Is(err, auth.ErrRefreshTokenReused) {
+		t.Errorf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRotateRefreshTokenInvalid(t *testing.T) {
+	mock, service := setupRefreshTokens(t)
+
+	// Unknown token
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, user_id, family_id, expires_at, revoked_at FROM refresh_tokens").
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns))
+	mock.ExpectRollback()
+
+	// Expired token
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, user_id, family_id, expires_at, revoked_at FROM refresh_tokens").
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns).AddRow(7, 1, "family", time.Now().Add(-time.Minute), nil))
+	mock.ExpectRollback()
+
+	for _, token := range []string{"unknown_token", "expired_token"} {
+		_, _, err := service.RotateRefreshToken(context.Background(), token)
+		if !errors.Is(err, auth.ErrInvalidRefreshToken) {
+			t.Errorf("%s: expected ErrInvalidRefreshToken, got %v", token, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
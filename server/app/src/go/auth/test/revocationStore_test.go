@@ -0,0 +1,97 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"software-slayer/auth"
+	"software-slayer/db"
+)
+
+func TestInMemoryRevocationStore(t *testing.T) {
+	ctx := context.Background()
+	store := auth.NewInMemoryRevocationStore()
+	now := time.Now().Truncate(time.Second)
+
+	store.Revoke(ctx, "revoked", now.Add(time.Minute))
+	store.RevokeUser(ctx, 2, now, now.Add(time.Hour))
+
+	tests := []struct {
+		jti      string
+		userId   int
+		issuedAt time.Time
+		expected bool
+	}{
+		{"revoked", 1, now, true},
+		{"other", 1, now, false},
+		{"other", 2, now, true},
+		{"other", 2, now.Add(-time.Minute), true},
+		{"other", 2, now.Add(time.Second), false},
+	}
+
+	for _, test := range tests {
+		revoked, err := store.IsRevoked(ctx, test.jti, test.userId, test.issuedAt)
+		if err != nil {
+			t.Errorf("IsRevoked() returned error: %v", err)
+		}
+		if revoked != test.expected {
+			t.Errorf("IsRevoked(%q, %d, %v) = %v, expected %v", test.jti, test.userId, test.issuedAt, revoked, test.expected)
+		}
+	}
+
+	// Entries are only pruned once the tokens they cover have expired
+	store.Prune(ctx, now.Add(2*time.Minute))
+	if revoked, _ := store.IsRevoked(ctx, "revoked", 1, now); revoked {
+		t.Error("Prune() did not remove expired token revocation")
+	}
+	if revoked, _ := store.IsRevoked(ctx, "other", 2, now); !revoked {
+		t.Error("Prune() removed user revocation that has not expired")
+	}
+}
+
+func TestMySQLRevocationStore(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	store := auth.NewMySQLRevocationStore(db.NewDB(database))
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	mock.ExpectExec("INSERT IGNORE INTO revoked_tokens").
+		WithArgs("jti", now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO user_token_revocations").
+		WithArgs(1, now, now.Add(time.Hour)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("jti", 1, now).
+		WillReturnRows(sqlmock.NewRows([]string{"revoked"}).AddRow(true))
+	mock.ExpectExec("DELETE FROM revoked_tokens WHERE expires_at < ?").
+		WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM user_token_revocations WHERE expires_at < ?").
+		WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := store.Revoke(ctx, "jti", now); err != nil {
+		t.Errorf("Revoke() returned error: %v", err)
+	}
+	if err := store.RevokeUser(ctx, 1, now, now.Add(time.Hour)); err != nil {
+		t.Errorf("RevokeUser() returned error: %v", err)
+	}
+	revoked, err := store.IsRevoked(ctx, "jti", 1, now)
+	if err != nil || !revoked {
+		t.Errorf("IsRevoked() = %v, %v, expected true", revoked, err)
+	}
+	if err := store.Prune(ctx, now); err != nil {
+		t.Errorf("Prune() returned error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
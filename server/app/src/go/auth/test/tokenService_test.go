@@ -1,12 +1,20 @@
 package auth_test
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt"
+
 	"software-slayer/auth"
 )
 
+func tokenConfig(lifetime time.Duration) auth.TokenConfig {
+	return auth.TokenConfig{Issuer: "test-issuer", Audience: "test-audience", Lifetime: lifetime}
+}
+
 func FuzzGenerateTokenAndAuthorizeUser(f *testing.F) {
 	durationSeeds := []int64{int64(time.Hour), int64(time.Minute), int64(3 * time.Second)}
 	secretSeeds := []string{"s", "secret", "superlongsecretthatkeepsgettinglongerandlonger", "&*(%$#)@)(#*%)}:>?][]|"}
@@ -21,28 +29,28 @@ func FuzzGenerateTokenAndAuthorizeUser(f *testing.F) {
 	}
 
 	f.Fuzz(func(t *testing.T, duration int64, jwtSecret string, userId int) {
-		tokenService := auth.NewTokenService(time.Duration(duration), []byte(jwtSecret))
+		tokenService := auth.NewTokenService(tokenConfig(time.Duration(duration)), auth.NewKeyring(auth.NewHMACKey("test", []byte(jwtSecret))), auth.NewInMemoryRevocationStore(), nil)
 
-		token, err := tokenService.GenerateToken(userId)
+		token, err := tokenService.GenerateToken(userId, auth.RoleUser, true)
 		if err != nil {
 			t.Errorf("GenerateToken() returned error: %v", err)
 		}
 
-		id, err := tokenService.AuthorizeUser(token)
+		principal, err := tokenService.AuthorizeUser(token)
 		if err != nil {
 			t.Errorf("AuthorizeUser() returned error: %v", err)
 		}
 
-		if id != userId {
-			t.Errorf("AuthorizeUser() returned wrong user id: %d", id)
+		if principal.UserID != userId || !principal.EmailVerified {
+			t.Errorf("AuthorizeUser() returned wrong user id: %d", principal.UserID)
 		}
 	})
 }
 
 func TestAuthorizeUserExpiredToken(t *testing.T) {
-	tokenService := auth.NewTokenService(time.Second, []byte("secret"))
+	tokenService := auth.NewTokenService(tokenConfig(time.Second), auth.NewKeyring(auth.NewHMACKey("test", []byte("secret"))), auth.NewInMemoryRevocationStore(), nil)
 
-	token, err := tokenService.GenerateToken(1)
+	token, err := tokenService.GenerateToken(1, auth.RoleUser, true)
 	if err != nil {
 		t.Errorf("GenerateToken() returned error: %v", err)
 	}
@@ -55,7 +63,159 @@ func TestAuthorizeUserExpiredToken(t *testing.T) {
 	time.Sleep(time.Second * 2)
 
 	_, err = tokenService.AuthorizeUser(token)
-	if err == nil {
-		t.Errorf("AuthorizeUser() did not return error for expired token")
+	if !errors.Is(err, auth.ErrTokenExpired) {
+		t.Errorf("AuthorizeUser() returned %v for expired token, expected ErrTokenExpired", err)
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	tokenService := auth.NewTokenService(tokenConfig(time.Hour), auth.NewKeyring(auth.NewHMACKey("test", []byte("secret"))), auth.NewInMemoryRevocationStore(), nil)
+
+	token, _ := tokenService.GenerateToken(1, auth.RoleUser, true)
+	otherToken, _ := tokenService.GenerateToken(1, auth.RoleUser, true)
+
+	principal, err := tokenService.AuthorizeUser(token)
+	if err != nil {
+		t.Fatalf("AuthorizeUser() returned error: %v", err)
+	}
+	if principal.TokenID == "" || principal.ExpiresAt.IsZero() {
+		t.Errorf("AuthorizeUser() returned incomplete principal: %+v", principal)
+	}
+
+	if err := tokenService.RevokeToken(context.Background(), principal); err != nil {
+		t.Errorf("RevokeToken() returned error: %v", err)
+	}
+
+	if _, err := tokenService.AuthorizeUser(token); err == nil {
+		t.Errorf("AuthorizeUser() did not return error for revoked token")
+	}
+
+	if _, err := tokenService.AuthorizeUser(otherToken); err != nil {
+		t.Errorf("AuthorizeUser() returned error for token that was not revoked: %v", err)
+	}
+}
+
+func TestRevokeAllTokens(t *testing.T) {
+	tokenService := auth.NewTokenService(tokenConfig(time.Hour), auth.NewKeyring(auth.NewHMACKey("test", []byte("secret"))), auth.NewInMemoryRevocationStore(), nil)
+
+	token, _ := tokenService.GenerateToken(1, auth.RoleUser, true)
+	otherUserToken, _ := tokenService.GenerateToken(2, auth.RoleUser, true)
+
+	if err := tokenService.RevokeAllTokens(context.Background(), 1); err != nil {
+		t.Errorf("RevokeAllTokens() returned error: %v", err)
+	}
+
+	if _, err := tokenService.AuthorizeUser(token); err == nil {
+		t.Errorf("AuthorizeUser() did not return error for revoked token")
+	}
+
+	if _, err := tokenService.AuthorizeUser(otherUserToken); err != nil {
+		t.Errorf("AuthorizeUser() returned error for another user's token: %v", err)
+	}
+}
+
+func TestRevokeAllTokens_LaterTokensAccepted(t *testing.T) {
+	tokenService := auth.NewTokenService(tokenConfig(time.Hour), auth.NewKeyring(auth.NewHMACKey("test", []byte("secret"))), auth.NewInMemoryRevocationStore(), nil)
+
+	if err := tokenService.RevokeAllTokens(context.Background(), 1); err != nil {
+		t.Fatalf("RevokeAllTokens() returned error: %v", err)
+	}
+
+	// Logging in again right away issues a token within the same second as the revocation
+	time.Sleep(time.Millisecond)
+	token, _ := tokenService.GenerateToken(1, auth.RoleUser, true)
+	if _, err := tokenService.AuthorizeUser(token); err != nil {
+		t.Errorf("AuthorizeUser() returned error for a token issued after the revocation: %v", err)
+	}
+}
+
+// signToken signs claims with the "test" HMAC key used by tokenConfig services
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = "test"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss": "test-issuer",
+		"sub": "1",
+		"aud": "test-audience",
+		"exp": now.Add(time.Hour).Unix(),
+		"iat": now.Unix(),
+		"jti": "token-id",
+	}
+}
+
+func TestAuthorizeUserErrors(t *testing.T) {
+	secret := []byte("secret")
+	tokenService := auth.NewTokenService(tokenConfig(time.Hour), auth.NewKeyring(auth.NewHMACKey("test", secret)), auth.NewInMemoryRevocationStore(), nil)
+
+	withClaim := func(name string, value interface{}) jwt.MapClaims {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := map[string]struct {
+		token    string
+		expected error
+	}{
+		"missing":           {"", auth.ErrTokenMissing},
+		"not a jwt":         {"not.a.jwt", auth.ErrTokenMalformed},
+		"wrong secret":      {signToken(t, jwt.SigningMethodHS256, []byte("other"), validClaims()), auth.ErrTokenSignature},
+		"wrong algorithm":   {signToken(t, jwt.SigningMethodHS512, secret, validClaims()), auth.ErrTokenSignature},
+		"unsigned":          {signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims()), auth.ErrTokenSignature},
+		"subject not an id": {signToken(t, jwt.SigningMethodHS256, secret, withClaim("sub", "admin")), auth.ErrTokenMalformed},
+		"missing subject":   {signToken(t, jwt.SigningMethodHS256, secret, withClaim("sub", nil)), auth.ErrTokenMalformed},
+		"subject type":      {signToken(t, jwt.SigningMethodHS256, secret, withClaim("sub", 1)), auth.ErrTokenMalformed},
+		"missing jti":       {signToken(t, jwt.SigningMethodHS256, secret, withClaim("jti", nil)), auth.ErrTokenMalformed},
+		"expired":           {signToken(t, jwt.SigningMethodHS256, secret, withClaim("exp", time.Now().Add(-time.Minute).Unix())), auth.ErrTokenExpired},
+		"not yet valid":     {signToken(t, jwt.SigningMethodHS256, secret, withClaim("nbf", time.Now().Add(time.Minute).Unix())), auth.ErrTokenInvalidClaims},
+		"wrong issuer":      {signToken(t, jwt.SigningMethodHS256, secret, withClaim("iss", "someone-else")), auth.ErrTokenInvalidClaims},
+		"wrong audience":    {signToken(t, jwt.SigningMethodHS256, secret, withClaim("aud", []string{"other-api"})), auth.ErrTokenInvalidClaims},
+	}
+
+	for name, test := range tests {
+		_, err := tokenService.AuthorizeUser(test.token)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected %v, got %v", name, test.expected, err)
+		}
+	}
+
+	// An audience list containing this service is accepted
+	token := signToken(t, jwt.SigningMethodHS256, secret, withClaim("aud", []string{"other-api", "test-audience"}))
+	if principal, err := tokenService.AuthorizeUser(token); err != nil || principal.UserID != 1 {
+		t.Errorf("AuthorizeUser() = %+v, %v for token with audience list", principal, err)
+	}
+}
+
+func TestAuthorizeUserLeeway(t *testing.T) {
+	secret := []byte("secret")
+	config := tokenConfig(time.Hour)
+	config.Leeway = time.Minute
+	tokenService := auth.NewTokenService(config, auth.NewKeyring(auth.NewHMACKey("test", secret)), auth.NewInMemoryRevocationStore(), nil)
+
+	claims := validClaims()
+	claims["exp"] = time.Now().Add(-30 * time.Second).Unix()
+	claims["nbf"] = time.Now().Add(30 * time.Second).Unix()
+
+	if _, err := tokenService.AuthorizeUser(signToken(t, jwt.SigningMethodHS256, secret, claims)); err != nil {
+		t.Errorf("AuthorizeUser() returned error for token within leeway: %v", err)
+	}
+
+	claims["exp"] = time.Now().Add(-2 * time.Minute).Unix()
+	if _, err := tokenService.AuthorizeUser(signToken(t, jwt.SigningMethodHS256, secret, claims)); !errors.Is(err, auth.ErrTokenExpired) {
+		t.Errorf("AuthorizeUser() returned %v for token expired beyond leeway", err)
 	}
 }
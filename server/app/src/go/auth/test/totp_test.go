@@ -0,0 +1,103 @@
+package auth_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"software-slayer/auth"
+)
+
+// rfcSecret is the SHA1 seed of the RFC 6238 test vectors
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	// The last 6 digits of the RFC 6238 appendix B SHA1 test vectors
+	tests := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, expected := range tests {
+		code, err := auth.TOTPCode(rfcSecret, auth.TOTPStep(time.Unix(unix, 0)))
+		if err != nil || code != expected {
+			t.Errorf("TOTPCode() at %d = %q, %v, expected %q", unix, code, err, expected)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := auth.TOTPStep(now)
+
+	tests := []struct {
+		step     int64
+		expected bool
+	}{
+		{step, true},
+		{step - 1, true},
+		{step + 1, true},
+		{step - 2, false},
+		{step + 2, false},
+	}
+
+	for _, test := range tests {
+		code, _ := auth.TOTPCode(rfcSecret, test.step)
+		matched, ok := auth.MatchTOTP(rfcSecret, code, now)
+		if ok != test.expected || (ok && matched != test.step) {
+			t.Errorf("MatchTOTP() for step %d = %d, %t, expected %t", test.step-step, matched, ok, test.expected)
+		}
+	}
+
+	if _, ok := auth.MatchTOTP(rfcSecret, "", now); ok {
+		t.Error("MatchTOTP() accepted an empty code")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() returned error: %v", err)
+	}
+	if _, err := auth.TOTPCode(secret, 1); err != nil {
+		t.Errorf("TOTPCode() rejected generated secret %q: %v", secret, err)
+	}
+
+	uri := auth.TOTPURI("Software Slayer", "user@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Software%20Slayer:user@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("TOTPURI() = %q", uri)
+	}
+}
+
+func TestSecretCipher(t *testing.T) {
+	cipher, err := auth.NewSecretCipher([]byte("key"))
+	if err != nil {
+		t.Fatalf("NewSecretCipher() returned error: %v", err)
+	}
+
+	ciphertext, err := cipher.Encrypt("secret")
+	if err != nil {
+		t.Fatalf("Encrypt() returned error: %v", err)
+	}
+	if strings.Contains(ciphertext, "secret") {
+		t.Errorf("Encrypt() returned plaintext %q", ciphertext)
+	}
+
+	plaintext, err := cipher.Decrypt(ciphertext)
+	if err != nil || plaintext != "secret" {
+		t.Errorf("Decrypt() = %q, %v", plaintext, err)
+	}
+
+	other, _ := auth.NewSecretCipher([]byte("other key"))
+	if _, err := other.Decrypt(ciphertext); err != auth.ErrInvalidCiphertext {
+		t.Errorf("Decrypt() with another key returned %v", err)
+	}
+
+	if _, err := auth.NewSecretCipher(nil); err == nil {
+		t.Error("NewSecretCipher() accepted an empty key")
+	}
+}
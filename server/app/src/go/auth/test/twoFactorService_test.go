@@ -0,0 +1,227 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"software-slayer/auth"
+	"software-slayer/db"
+)
+
+func setupTwoFactor(t *testing.T) (sqlmock.Sqlmock, *auth.TwoFactorServiceImpl, *auth.SecretCipher) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	cipher, _ := auth.NewSecretCipher([]byte("key"))
+	keyring := auth.NewKeyring(auth.NewHMACKey("test", []byte("secret")))
+	config := auth.TokenConfig{Issuer: "test-issuer", Audience: "test-2fa", Lifetime: time.Minute}
+	return mock, auth.NewTwoFactorService(db.NewDB(database), cipher, "Test", config, keyring), cipher
+}
+
+func currentCode(secret string) string {
+	code, _ := auth.TOTPCode(secret, auth.TOTPStep(time.Now()))
+	return code
+}
+
+func TestBeginEnrollment(t *testing.T) {
+	mock, service, _ := setupTwoFactor(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT confirmed_at IS NOT NULL FROM totp_secrets WHERE user_id = \\? FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"confirmed"}))
+	mock.ExpectExec("INSERT INTO totp_secrets").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	enrollment, err := service.BeginEnrollment(context.Background(), 1, "user@example.com")
+	if err != nil {
+		t.Fatalf("BeginEnrollment() returned error: %v", err)
+	}
+	if enrollment.Secret == "" || enrollment.URI == "" {
+		t.Errorf("BeginEnrollment() returned %+v", enrollment)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestBeginEnrollmentAlreadyEnabled(t *testing.T) {
+	mock, service, _ := setupTwoFactor(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT confirmed_at IS NOT NULL FROM totp_secrets").
+		WillReturnRows(sqlmock.NewRows([]string{"confirmed"}).AddRow(true))
+	mock.ExpectRollback()
+
+	if _, err := service.BeginEnrollment(context.Background(), 1, "user@example.com"); !errors.Is(err, auth.ErrTwoFactorAlreadyEnabled) {
+		t.Errorf("BeginEnrollment() returned %v, expected ErrTwoFactorAlreadyEnabled", err)
+	}
+}
+
+func TestConfirmEnrollment(t *testing.T) {
+	mock, service, cipher := setupTwoFactor(t)
+	secret, _ := auth.GenerateTOTPSecret()
+	ciphertext, _ := cipher.Encrypt(secret)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT secret_ciphertext, confirmed_at IS NOT NULL FROM totp_secrets WHERE user_id = \\? FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"secret_ciphertext", "confirmed"}).AddRow(ciphertext, false))
+	mock.ExpectExec("UPDATE totp_secrets SET confirmed_at = CURRENT_TIMESTAMP, last_used_step = \\? WHERE user_id = \\?").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM recovery_codes WHERE user_id = \\?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	for i := 0; i < auth.RecoveryCodeCount; i++ {
+		mock.ExpectExec("INSERT INTO recovery_codes").WithArgs(1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	codes, err := service.ConfirmEnrollment(context.Background(), 1, currentCode(secret))
+	if err != nil {
+		t.Fatalf("ConfirmEnrollment() returned error: %v", err)
+	}
+	if len(codes) != auth.RecoveryCodeCount || len(codes[0]) != 11 {
+		t.Errorf("ConfirmEnrollment() returned recovery codes %v", codes)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestConfirmEnrollmentInvalidCode(t *testing.T) {
+	mock, service, cipher := setupTwoFactor(t)
+	secret, _ := auth.GenerateTOTPSecret()
+	ciphertext, _ := cipher.Encrypt(secret)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT secret_ciphertext, confirmed_at IS NOT NULL FROM totp_secrets").
+		WillReturnRows(sqlmock.NewRows([]string{"secret_ciphertext", "confirmed"}).AddRow(ciphertext, false))
+	mock.ExpectRollback()
+
+	if _, err := service.ConfirmEnrollment(context.Background(), 1, "abcdef"); !errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+		t.Errorf("ConfirmEnrollment() returned %v, expected ErrInvalidTwoFactorCode", err)
+	}
+}
+
+func TestVerifyCode(t *testing.T) {
+	mock, service, cipher := setupTwoFactor(t)
+	secret, _ := auth.GenerateTOTPSecret()
+	ciphertext, _ := cipher.Encrypt(secret)
+	step := auth.TOTPStep(time.Now())
+
+	// A fresh code is accepted and recorded
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT secret_ciphertext, last_used_step FROM totp_secrets WHERE user_id = \\? AND confirmed_at IS NOT NULL FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"secret_ciphertext", "last_used_step"}).AddRow(ciphertext, step-5))
+	mock.ExpectExec("UPDATE totp_secrets SET last_used_step = \\? WHERE user_id = \\?").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := service.VerifyCode(context.Background(), 1, currentCode(secret)); err != nil {
+		t.Errorf("VerifyCode() returned error: %v", err)
+	}
+
+	// The same code can't be replayed
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT secret_ciphertext, last_used_step FROM totp_secrets").
+		WillReturnRows(sqlmock.NewRows([]string{"secret_ciphertext", "last_used_step"}).AddRow(ciphertext, step))
+	mock.ExpectRollback()
+
+	if err := service.VerifyCode(context.Background(), 1, currentCode(secret)); !errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+		t.Errorf("VerifyCode() returned %v for a replayed code", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestVerifyRecoveryCode(t *testing.T) {
+	mock, service, cipher := setupTwoFactor(t)
+	secret, _ := auth.GenerateTOTPSecret()
+	ciphertext, _ := cipher.Encrypt(secret)
+
+	tests := []struct {
+		used     int64
+		expected error
+	}{
+		{1, nil},
+		{0, auth.ErrInvalidTwoFactorCode},
+	}
+
+	for _, test := range tests {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT secret_ciphertext, last_used_step FROM totp_secrets").
+			WillReturnRows(sqlmock.NewRows([]string{"secret_ciphertext", "last_used_step"}).AddRow(ciphertext, 0))
+		// Recovery codes are matched case and dash insensitively
+		mock.ExpectExec("UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = \\? AND code_hash = \\? AND used_at IS NULL").
+			WithArgs(1, auth.HashToken("abcdefghij")).
+			WillReturnResult(sqlmock.NewResult(0, test.used))
+		if test.expected == nil {
+			mock.ExpectCommit()
+		} else {
+			mock.ExpectRollback()
+		}
+
+		if err := service.VerifyCode(context.Background(), 1, "ABCDE-FGHIJ"); !errors.Is(err, test.expected) {
+			t.Errorf("VerifyCode() returned %v, expected %v", err, test.expected)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	_, service, _ := setupTwoFactor(t)
+
+	challenge, err := service.IssueChallenge(4)
+	if err != nil {
+		t.Fatalf("IssueChallenge() returned error: %v", err)
+	}
+	if userId, err := service.ParseChallenge(challenge); err != nil || userId != 4 {
+		t.Errorf("ParseChallenge() = %d, %v", userId, err)
+	}
+
+	// Access tokens signed with the same key are not challenges
+	tokenService := auth.NewTokenService(tokenConfig(time.Hour), auth.NewKeyring(auth.NewHMACKey("test", []byte("secret"))), auth.NewInMemoryRevocationStore(), nil)
+	accessToken, _ := tokenService.GenerateToken(4, auth.RoleUser, true)
+	if _, err := service.ParseChallenge(accessToken); !errors.Is(err, auth.ErrInvalidChallengeToken) {
+		t.Errorf("ParseChallenge() returned %v for an access token", err)
+	}
+}
+
+func TestConsumeChallenge(t *testing.T) {
+	mock, service, _ := setupTwoFactor(t)
+	challenge, _ := service.IssueChallenge(4)
+
+	// Only the first use inserts the jti
+	mock.ExpectExec("INSERT IGNORE INTO revoked_tokens \\(jti, expires_at\\) VALUES \\(\\?, \\?\\)").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT IGNORE INTO revoked_tokens").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := service.ConsumeChallenge(context.Background(), challenge); err != nil {
+		t.Errorf("ConsumeChallenge() returned error: %v", err)
+	}
+	if err := service.ConsumeChallenge(context.Background(), challenge); !errors.Is(err, auth.ErrChallengeUsed) {
+		t.Errorf("ConsumeChallenge() returned %v for a used challenge", err)
+	}
+	if err := service.ConsumeChallenge(context.Background(), "not a token"); !errors.Is(err, auth.ErrInvalidChallengeToken) {
+		t.Errorf("ConsumeChallenge() returned %v for an invalid token", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,53 @@
+package auth_test
+
+import (
+	"testing"
+
+	"software-slayer/auth"
+)
+
+func TestCanView(t *testing.T) {
+	anonymous := auth.Principal{}
+	user := auth.Principal{UserID: 1, Role: auth.RoleUser}
+	moderator := auth.Principal{UserID: 2, Role: auth.RoleModerator}
+	admin := auth.Principal{UserID: 3, Role: auth.RoleAdmin}
+	owned := auth.Resource{OwnerID: 1}
+	other := auth.Resource{OwnerID: 4}
+
+	tests := []struct {
+		principal  auth.Principal
+		visibility auth.Visibility
+		resource   auth.Resource
+		expected   bool
+	}{
+		{anonymous, auth.VisibilityPublic, other, true},
+		{anonymous, auth.VisibilityOrganization, other, false},
+		{anonymous, auth.VisibilityPrivate, other, false},
+		{user, auth.VisibilityOrganization, other, true},
+		{user, auth.VisibilityPrivate, other, false},
+		{user, auth.VisibilityPrivate, owned, true},
+		{moderator, auth.VisibilityPrivate, other, true},
+		{admin, auth.VisibilityPrivate, other, true},
+	}
+
+	for _, test := range tests {
+		if visible := auth.CanView(test.principal, test.visibility, test.resource); visible != test.expected {
+			t.Errorf("CanView(%+v, %s, %+v) = %t, expected %t", test.principal, test.visibility, test.resource, visible, test.expected)
+		}
+	}
+
+	if visible := auth.VisibleTo(user, owned); visible != nil {
+		t.Errorf("expected owners to see every visibility, got %v", visible)
+	}
+}
+
+func TestParseVisibility(t *testing.T) {
+	for _, name := range []string{"public", "organization", "private"} {
+		if visibility, err := auth.ParseVisibility(name); err != nil || string(visibility) != name {
+			t.Errorf("ParseVisibility(%q) = %q, %v", name, visibility, err)
+		}
+	}
+	if _, err := auth.ParseVisibility("friends"); err == nil {
+		t.Error("expected an error for an unknown visibility")
+	}
+}
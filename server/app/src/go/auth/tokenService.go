@@ -1,51 +1,215 @@
 package auth
 
 import (
+	"context"
 	"errors"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 )
 
+var ErrTokenMissing = errors.New("token is missing")
+var ErrTokenMalformed = errors.New("token is malformed")
+var ErrTokenSignature = errors.New("token signature is invalid")
+var ErrTokenExpired = errors.New("token has expired")
+var ErrTokenInvalidClaims = errors.New("token is not valid for this service")
+var ErrTokenRevoked = errors.New("token has been revoked")
+
 type TokenService interface {
-	AuthorizeUser(tokenString string) (int, error)
-	GenerateToken(id int) (string, error)
+	AuthorizeUser(tokenString string) (Principal, error)
+	GenerateToken(id int, role Role, emailVerified bool) (string, error)
+	RevokeToken(ctx context.Context, principal Principal) error
+	RevokeAllTokens(ctx context.Context, id int) error
 }
 
 type TokenServiceImpl struct {
-	jwtSecret     []byte
-	tokenLifetime time.Duration
+	config               TokenConfig
+	keyring              *Keyring
+	revocationStore      RevocationStore
+	personalAccessTokens PersonalAccessTokenService
 }
 
-func NewTokenService(tokenLifetime time.Duration, jwtSecret []byte) *TokenServiceImpl {
-	return &TokenServiceImpl{tokenLifetime: tokenLifetime, jwtSecret: jwtSecret}
+// NewTokenService creates a token service, personalAccessTokens may be nil to only accept JWTs
+func NewTokenService(config TokenConfig, keyring *Keyring, revocationStore RevocationStore, personalAccessTokens PersonalAccessTokenService) *TokenServiceImpl {
+	return &TokenServiceImpl{config: config, keyring: keyring, revocationStore: revocationStore, personalAccessTokens: personalAccessTokens}
 }
 
-func (s *TokenServiceImpl) AuthorizeUser(tokenString string) (int, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return s.jwtSecret, nil
-	})
-	if err != nil || !token.Valid {
-		return -1, errors.New("invalid token")
+/*
+ * Authorize a user by their access token or personal access token
+ * @param tokenString: the access token or personal access token
+ * @return Principal: the user the token was issued to
+ * @return error: ErrTokenMissing, ErrTokenMalformed, ErrTokenSignature, ErrTokenExpired, ErrTokenInvalidClaims,
+ * ErrTokenRevoked or ErrInvalidPersonalAccessToken
+ */
+func (s *TokenServiceImpl) AuthorizeUser(tokenString string) (Principal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if IsPersonalAccessToken(tokenString) {
+		if s.personalAccessTokens == nil {
+			return Principal{}, ErrInvalidPersonalAccessToken
+		}
+		return s.personalAccessTokens.AuthenticateToken(ctx, tokenString)
+	}
+
+	claims, userId, err := s.parseToken(tokenString)
+	if err != nil {
+		return Principal{}, err
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return -1, errors.New("invalid claims")
+	revoked, err := s.revocationStore.IsRevoked(ctx, claims.ID, userId, numericDate(claims.IssuedAt))
+	if err != nil {
+		return Principal{}, errors.New("failed to check token revocation")
+	}
+	if revoked {
+		return Principal{}, ErrTokenRevoked
 	}
 
-	userId, ok := claims["user_id"]
-	if !ok {
-		return -1, errors.New("invalid user id")
+	// Tokens without a scope claim are not restricted
+	var scopes []string
+	if claims.Scope != "" {
+		scopes = strings.Fields(claims.Scope)
 	}
 
-	return int(userId.(float64)), nil
+	return Principal{
+		UserID:        userId,
+		Role:          ParseRole(string(claims.Role)),
+		EmailVerified: claims.EmailVerified,
+		Scopes:        scopes,
+		TokenID:       claims.ID,
+		ExpiresAt:     time.Unix(claims.ExpiresAt, 0),
+	}, nil
 }
 
-func (s *TokenServiceImpl) GenerateToken(id int) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": id,
-		"exp":     time.Now().Add(s.tokenLifetime).Unix(),
+/*
+ * Generate an access token for a user
+ * @param id: the id of the user
+ * @param role: the role of the user, checked by Can for the lifetime of the token
+ * @param emailVerified: whether the user has verified their email, checked against the email verification policy
+ * @return string: the signed token
+ * @return error: an error if the token could not be signed
+ */
+func (s *TokenServiceImpl) GenerateToken(id int, role Role, emailVerified bool) (string, error) {
+	jti, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	key := s.keyring.Current()
+	now := time.Now()
+	token := jwt.NewWithClaims(key.Method, Claims{
+		Issuer:        s.config.Issuer,
+		Subject:       strconv.Itoa(id),
+		Audience:      Audience{s.config.Audience},
+		ExpiresAt:     now.Add(s.config.Lifetime).Unix(),
+		NotBefore:     now.Unix(),
+		IssuedAt:      float64(now.UnixMicro()) / 1e6,
+		ID:            jti,
+		Role:          role,
+		EmailVerified: emailVerified,
 	})
-	return token.SignedString(s.jwtSecret)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+/*
+ * Revoke the token a principal was authenticated with, so it can no longer be used even though it has not expired
+ * @param ctx: the context
+ * @param principal: the principal returned by AuthorizeUser
+ * @return error: an error if the token could not be revoked
+ */
+func (s *TokenServiceImpl) RevokeToken(ctx context.Context, principal Principal) error {
+	return s.revocationStore.Revoke(ctx, principal.TokenID, principal.ExpiresAt)
+}
+
+/*
+ * Revoke every token issued to a user up to now
+ * @param ctx: the context
+ * @param id: the id of the user
+ * @return error: an error if the tokens could not be revoked
+ */
+func (s *TokenServiceImpl) RevokeAllTokens(ctx context.Context, id int) error {
+	// Issue times are compared with microsecond precision, so tokens issued later this second are still accepted
+	now := time.Now().Truncate(time.Microsecond)
+	return s.revocationStore.RevokeUser(ctx, id, now, now.Add(s.config.Lifetime+s.config.Leeway))
+}
+
+/*
+ * Verify a token's signature and validate its claims
+ * The signing method is pinned by the keyring, see Keyring.verifyKey
+ * @param tokenString: the token
+ * @return Claims: the token's claims
+ * @return int: the id of the user the token was issued to
+ * @return error: ErrTokenMissing, ErrTokenMalformed, ErrTokenSignature, ErrTokenExpired or ErrTokenInvalidClaims
+ */
+func (s *TokenServiceImpl) parseToken(tokenString string) (Claims, int, error) {
+	var claims Claims
+	if tokenString == "" {
+		return claims, -1, ErrTokenMissing
+	}
+
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(tokenString, &claims, s.keyring.verifyKey)
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorMalformed != 0 {
+			return claims, -1, ErrTokenMalformed
+		}
+		return claims, -1, ErrTokenSignature
+	}
+
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil || claims.ID == "" || claims.ExpiresAt == 0 || claims.IssuedAt == 0 {
+		return claims, -1, ErrTokenMalformed
+	}
+
+	now := time.Now()
+	leeway := s.config.Leeway
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(leeway)) {
+		return claims, -1, ErrTokenExpired
+	}
+	if now.Add(leeway).Before(time.Unix(claims.NotBefore, 0)) || now.Add(leeway).Before(numericDate(claims.IssuedAt)) {
+		return claims, -1, ErrTokenInvalidClaims
+	}
+	if claims.Issuer != s.config.Issuer || !claims.Audience.Contains(s.config.Audience) {
+		return claims, -1, ErrTokenInvalidClaims
+	}
+
+	return claims, userId, nil
+}
+
+// numericDate converts a JWT NumericDate, which may have a fractional part, to a time with microsecond precision
+func numericDate(seconds float64) time.Time {
+	return time.UnixMicro(int64(math.Round(seconds * 1e6)))
+}
+
+/*
+ * Describe why a token was rejected, for 401 responses
+ * @param err: the error returned by AuthorizeUser
+ * @return string: the message to respond with
+ */
+func TokenErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrTokenExpired):
+		return "Authentication token has expired"
+	case errors.Is(err, ErrTokenRevoked):
+		return "Authentication token has been revoked"
+	case errors.Is(err, ErrTokenSignature):
+		return "Authentication token signature is invalid"
+	case errors.Is(err, ErrTokenMalformed):
+		return "Authentication token is malformed"
+	case errors.Is(err, ErrTokenInvalidClaims):
+		return "Authentication token was not issued for this service"
+	case errors.Is(err, ErrTokenMissing):
+		return "Missing authentication token"
+	case errors.Is(err, ErrAuthorizationScheme):
+		return "Authorization header must use the Bearer scheme"
+	case errors.Is(err, ErrInvalidPersonalAccessToken):
+		return "Personal access token is invalid or has been revoked"
+	default:
+		return "Invalid or missing authentication token"
+	}
 }
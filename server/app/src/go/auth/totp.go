@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// totpPeriod is the time step of TOTP codes, RFC 6238 recommends 30 seconds
+	totpPeriod = 30 * time.Second
+	// totpDigits is the length of TOTP codes
+	totpDigits = 6
+	// totpSkew is how many time steps before or after the current one are accepted, for clock drift
+	totpSkew = 1
+	// totpSecretSize is the length of TOTP secrets in bytes, the HMAC-SHA1 block size suggested by RFC 4226
+	totpSecretSize = 20
+)
+
+var ErrInvalidCiphertext = errors.New("ciphertext could not be decrypted")
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a random base32 encoded TOTP secret
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, totpSecretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return secretEncoding.EncodeToString(buf), nil
+}
+
+/*
+ * Build the otpauth URI authenticator apps read from enrollment QR codes
+ * @param issuer: the name of the service shown in the app
+ * @param account: the name of the account shown in the app
+ * @param secret: the base32 encoded secret
+ * @return string: the otpauth URI
+ */
+func TOTPURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(int(totpPeriod.Seconds())))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+/*
+ * Compute the TOTP code of a secret for a time step, as defined by RFC 6238
+ * @param secret: the base32 encoded secret
+ * @param step: the number of periods since the Unix epoch
+ * @return string: the zero padded code
+ * @return error: an error if the secret is not valid base32
+ */
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := secretEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// TOTPStep returns the time step a time falls in
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod.Seconds())
+}
+
+/*
+ * Find the time step a TOTP code is valid for, allowing for clock drift
+ * @param secret: the base32 encoded secret
+ * @param code: the code entered by the user
+ * @param now: the current time
+ * @return int64: the time step the code matched, so callers can reject codes that were already used
+ * @return bool: whether the code is valid
+ */
+func MatchTOTP(secret, code string, now time.Time) (int64, bool) {
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// SecretCipher encrypts secrets that have to be stored in a form they can be read back from, such as TOTP secrets
+type SecretCipher struct {
+	aead cipher.AEAD
+}
+
+/*
+ * Create a cipher using AES-256-GCM
+ * @param key: the encryption key, hashed with SHA-256 so a key of any length can be used
+ * @return *SecretCipher: the cipher
+ * @return error: an error if the key is empty
+ */
+func NewSecretCipher(key []byte) (*SecretCipher, error) {
+	if len(key) == 0 {
+		return nil, errors.New("encryption key must not be empty")
+	}
+
+	hashed := sha256.Sum256(key)
+	block, err := aes.NewCipher(hashed[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretCipher{aead: aead}, nil
+}
+
+// Encrypt encrypts a secret with a random nonce, returning the base64 encoded nonce and ciphertext
+func (c *SecretCipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a secret returned by Encrypt
+func (c *SecretCipher) Decrypt(ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,382 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"software-slayer/db"
+)
+
+// RecoveryCodeCount is how many recovery codes are issued at a time
+const RecoveryCodeCount = 10
+
+var ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+var ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+var ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+var ErrInvalidChallengeToken = errors.New("invalid two-factor challenge token")
+var ErrChallengeUsed = errors.New("two-factor challenge token has already been used")
+
+type TwoFactorService interface {
+	BeginEnrollment(ctx context.Context, userId int, account string) (TOTPEnrollment, error)
+	ConfirmEnrollment(ctx context.Context, userId int, code string) ([]string, error)
+	IsEnabled(ctx context.Context, userId int) (bool, error)
+	VerifyCode(ctx context.Context, userId int, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userId int) ([]string, error)
+	Disable(ctx context.Context, userId int) error
+	IssueChallenge(userId int) (string, error)
+	ParseChallenge(token string) (int, error)
+	ConsumeChallenge(ctx context.Context, token string) error
+}
+
+// TOTPEnrollment is what an authenticator app needs to start generating codes for an account
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// TwoFactorServiceImpl stores TOTP secrets encrypted with a SecretCipher, and recovery codes as hashes.
+// Challenge tokens are JWTs signed with the keyring, the config's audience must differ from the access token audience.
+// They are single-use, used challenges are recorded in the revoked_tokens table until they expire.
+type TwoFactorServiceImpl struct {
+	db              *db.Database
+	cipher          *SecretCipher
+	issuer          string
+	challengeConfig TokenConfig
+	keyring         *Keyring
+}
+
+func NewTwoFactorService(db *db.Database, cipher *SecretCipher, issuer string, challengeConfig TokenConfig, keyring *Keyring) *TwoFactorServiceImpl {
+	return &TwoFactorServiceImpl{db: db, cipher: cipher, issuer: issuer, challengeConfig: challengeConfig, keyring: keyring}
+}
+
+// challengeClaims are the claims carried by two-factor challenge tokens
+type challengeClaims struct {
+	ID        string   `json:"jti"`
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+}
+
+// Valid always succeeds, claims are validated by TwoFactorServiceImpl so that leeway and configuration apply
+func (c challengeClaims) Valid() error {
+	return nil
+}
+
+/*
+ * Start enrolling a user in TOTP, replacing any enrollment that was never confirmed
+ * @param ctx: the context
+ * @param userId: the id of the user
+ * @param account: the account name shown in authenticator apps
+ * @return TOTPEnrollment: the new secret and its otpauth URI
+ * @return error: ErrTwoFactorAlreadyEnabled, or an error if the secret could not be stored
+ */
+func (s *TwoFactorServiceImpl) BeginEnrollment(ctx context.Context, userId int, account string) (TOTPEnrollment, error) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	ciphertext, err := s.cipher.Encrypt(secret)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	err = s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		var confirmed bool
+		err := tx.QueryRowContext(ctx, "SELECT confirmed_at IS NOT NULL FROM totp_secrets WHERE user_id = ? FOR UPDATE", userId).Scan(&confirmed)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if confirmed {
+			return ErrTwoFactorAlreadyEnabled
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO totp_secrets (user_id, secret_ciphertext) VALUES (?, ?)
+			ON DUPLICATE KEY UPDATE secret_ciphertext = VALUES(secret_ciphertext), last_used_step = 0`, userId, ciphertext)
+		return err
+	})
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	return TOTPEnrollment{Secret: secret, URI: TOTPURI(s.issuer, account, secret)}, nil
+}
+
+/*
+ * Finish enrolling a user with the first code from their authenticator app
+ * @param ctx: the context
+ * @param userId: the id of the user
+ * @param code: the TOTP code
+ * @return []string: the recovery codes, which are only stored as hashes
+ * @return error: ErrTwoFactorNotEnabled if enrollment wasn't started, ErrTwoFactorAlreadyEnabled, ErrInvalidTwoFactorCode, or a database error
+ */
+func (s *TwoFactorServiceImpl) ConfirmEnrollment(ctx context.Context, userId int, code string) ([]string, error) {
+	var recoveryCodes []string
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		var ciphertext string
+		var confirmed bool
+		err := tx.QueryRowContext(ctx, "SELECT secret_ciphertext, confirmed_at IS NOT NULL FROM totp_secrets WHERE user_id = ? FOR UPDATE",
+			userId).Scan(&ciphertext, &confirmed)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTwoFactorNotEnabled
+		}
+		if err != nil {
+			return err
+		}
+		if confirmed {
+			return ErrTwoFactorAlreadyEnabled
+		}
+
+		secret, err := s.cipher.Decrypt(ciphertext)
+		if err != nil {
+			return err
+		}
+		step, ok := MatchTOTP(secret, code, time.Now())
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE totp_secrets SET confirmed_at = CURRENT_TIMESTAMP, last_used_step = ? WHERE user_id = ?",
+			step, userId); err != nil {
+			return err
+		}
+
+		recoveryCodes, err = replaceRecoveryCodes(ctx, tx, userId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+// IsEnabled reports whether a user has confirmed a TOTP enrollment
+func (s *TwoFactorServiceImpl) IsEnabled(ctx context.Context, userId int) (bool, error) {
+	var confirmed bool
+	err := s.db.QueryRowContext(ctx, "SELECT confirmed_at IS NOT NULL FROM totp_secrets WHERE user_id = ?", userId).Scan(&confirmed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return confirmed, err
+}
+
+/*
+ * Check a TOTP code or recovery code of an enrolled user
+ * Each TOTP code is only accepted once, and each recovery code is used up
+ * @param ctx: the context
+ * @param userId: the id of the user
+ * @param code: a TOTP code or a recovery code
+ * @return error: ErrTwoFactorNotEnabled, ErrInvalidTwoFactorCode, or a database error
+ */
+func (s *TwoFactorServiceImpl) VerifyCode(ctx context.Context, userId int, code string) error {
+	return s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		var ciphertext string
+		var lastUsedStep int64
+		err := tx.QueryRowContext(ctx,
+			"SELECT secret_ciphertext, last_used_step FROM totp_secrets WHERE user_id = ? AND confirmed_at IS NOT NULL FOR UPDATE",
+			userId).Scan(&ciphertext, &lastUsedStep)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTwoFactorNotEnabled
+		}
+		if err != nil {
+			return err
+		}
+
+		secret, err := s.cipher.Decrypt(ciphertext)
+		if err != nil {
+			return err
+		}
+
+		// A code for a step at or before the last one used could have been observed, so it's treated as invalid
+		if step, ok := MatchTOTP(secret, code, time.Now()); ok {
+			if step <= lastUsedStep {
+				return ErrInvalidTwoFactorCode
+			}
+			_, err := tx.ExecContext(ctx, "UPDATE totp_secrets SET last_used_step = ? WHERE user_id = ?", step, userId)
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx,
+			"UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+			userId, HashToken(normalizeRecoveryCode(code)))
+		if err != nil {
+			return err
+		}
+		if used, err := result.RowsAffected(); err != nil || used != 1 {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	})
+}
+
+/*
+ * Replace an enrolled user's recovery codes with new ones
+ * @param ctx: the context
+ * @param userId: the id of the user
+ * @return []string: the new recovery codes
+ * @return error: ErrTwoFactorNotEnabled, or a database error
+ */
+func (s *TwoFactorServiceImpl) RegenerateRecoveryCodes(ctx context.Context, userId int) ([]string, error) {
+	var recoveryCodes []string
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		var confirmed bool
+		err := tx.QueryRowContext(ctx, "SELECT confirmed_at IS NOT NULL FROM totp_secrets WHERE user_id = ? FOR UPDATE", userId).Scan(&confirmed)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !confirmed) {
+			return ErrTwoFactorNotEnabled
+		}
+		if err != nil {
+			return err
+		}
+
+		recoveryCodes, err = replaceRecoveryCodes(ctx, tx, userId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+// Disable removes a user's TOTP secret and recovery codes
+func (s *TwoFactorServiceImpl) Disable(ctx context.Context, userId int) error {
+	return s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userId); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "DELETE FROM totp_secrets WHERE user_id = ?", userId)
+		return err
+	})
+}
+
+/*
+ * Issue a challenge token proving that a user has entered their password, to be exchanged along with a code for an access token
+ * @param userId: the id of the user
+ * @return string: the signed challenge token
+ * @return error: an error if the token could not be signed
+ */
+func (s *TwoFactorServiceImpl) IssueChallenge(userId int) (string, error) {
+	jti, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	key := s.keyring.Current()
+	now := time.Now()
+	token := jwt.NewWithClaims(key.Method, challengeClaims{
+		ID:        jti,
+		Issuer:    s.challengeConfig.Issuer,
+		Subject:   strconv.Itoa(userId),
+		Audience:  Audience{s.challengeConfig.Audience},
+		ExpiresAt: now.Add(s.challengeConfig.Lifetime).Unix(),
+		IssuedAt:  now.Unix(),
+	})
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+/*
+ * Verify a challenge token's signature and validate its claims
+ * @param tokenString: the challenge token
+ * @return int: the id of the user the challenge was issued to
+ * @return error: ErrInvalidChallengeToken or ErrTokenExpired
+ */
+func (s *TwoFactorServiceImpl) ParseChallenge(tokenString string) (int, error) {
+	claims, err := s.parseChallenge(tokenString)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(claims.Subject)
+}
+
+/*
+ * Use up a challenge token so it can't be exchanged again, whether or not the code sent with it is valid
+ * @param ctx: the context
+ * @param tokenString: the challenge token
+ * @return error: ErrChallengeUsed if the challenge was already used, ErrInvalidChallengeToken or ErrTokenExpired
+ */
+func (s *TwoFactorServiceImpl) ConsumeChallenge(ctx context.Context, tokenString string) error {
+	claims, err := s.parseChallenge(tokenString)
+	if err != nil {
+		return err
+	}
+
+	// The jti is the key of revoked_tokens, so only the first request to use a challenge inserts a row
+	result, err := s.db.ExecContext(ctx, "INSERT IGNORE INTO revoked_tokens (jti, expires_at) VALUES (?, ?)",
+		claims.ID, time.Unix(claims.ExpiresAt, 0).Add(s.challengeConfig.Leeway).UTC())
+	if err != nil {
+		return err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return ErrChallengeUsed
+	}
+	return nil
+}
+
+// parseChallenge verifies a challenge token and returns its claims
+func (s *TwoFactorServiceImpl) parseChallenge(tokenString string) (challengeClaims, error) {
+	var claims challengeClaims
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	if _, err := parser.ParseWithClaims(tokenString, &claims, s.keyring.verifyKey); err != nil {
+		return claims, ErrInvalidChallengeToken
+	}
+
+	if _, err := strconv.Atoi(claims.Subject); err != nil || claims.ID == "" || claims.ExpiresAt == 0 {
+		return claims, ErrInvalidChallengeToken
+	}
+	if claims.Issuer != s.challengeConfig.Issuer || !claims.Audience.Contains(s.challengeConfig.Audience) {
+		return claims, ErrInvalidChallengeToken
+	}
+	if time.Now().After(time.Unix(claims.ExpiresAt, 0).Add(s.challengeConfig.Leeway)) {
+		return claims, ErrTokenExpired
+	}
+
+	return claims, nil
+}
+
+// replaceRecoveryCodes deletes a user's recovery codes and stores the hashes of new ones
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userId int) ([]string, error) {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userId); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)",
+			userId, HashToken(normalizeRecoveryCode(code))); err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+	return codes, nil
+}
+
+// randomRecoveryCode returns a code of two groups of five base32 characters, such as abcde-fghij
+func randomRecoveryCode() (string, error) {
+	buf := make([]byte, 7)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(secretEncoding.EncodeToString(buf))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalizeRecoveryCode lets users enter recovery codes without the dash or in upper case
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
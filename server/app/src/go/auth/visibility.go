@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Visibility is who may see a user's profile, learning list or learning item
+type Visibility string
+
+const (
+	// VisibilityPublic is visible to everyone, including anonymous callers
+	VisibilityPublic Visibility = "public"
+	// VisibilityOrganization is visible to everyone signed in to this server
+	VisibilityOrganization Visibility = "organization"
+	// VisibilityPrivate is only visible to the owner, and to moderators and admins
+	VisibilityPrivate Visibility = "private"
+)
+
+// ParseVisibility returns the visibility with the given name
+func ParseVisibility(name string) (Visibility, error) {
+	switch visibility := Visibility(name); visibility {
+	case VisibilityPublic, VisibilityOrganization, VisibilityPrivate:
+		return visibility, nil
+	}
+	return "", fmt.Errorf("unknown visibility: %q", name)
+}
+
+/*
+ * Get the visibilities of resources a principal may see
+ * @param principal: the caller, the zero Principal for anonymous callers
+ * @param resource: the resource being viewed
+ * @return []Visibility: the visible visibilities, nil if the principal may see the resource whatever its visibility
+ */
+func VisibleTo(principal Principal, resource Resource) []Visibility {
+	if (resource.OwnerID != 0 && resource.OwnerID == principal.UserID) || rolePermissions[principal.Role][ActionViewPrivate] {
+		return nil
+	}
+	if principal.UserID == 0 {
+		return []Visibility{VisibilityPublic}
+	}
+	return []Visibility{VisibilityPublic, VisibilityOrganization}
+}
+
+/*
+ * Check whether a principal may see a resource
+ * @param principal: the caller, the zero Principal for anonymous callers
+ * @param visibility: the visibility of the resource
+ * @param resource: the resource being viewed
+ * @return bool: true if the resource is visible to the principal
+ */
+func CanView(principal Principal, visibility Visibility, resource Resource) bool {
+	visible := VisibleTo(principal, resource)
+	return visible == nil || slices.Contains(visible, visibility)
+}
@@ -7,6 +7,61 @@ import (
 var MAX_DB_OPEN_RETRIES = 5
 
 const (
-	TOKEN_LIFETIME          = time.Hour * 24
-	JWT_SECRET_FILE_ENV_VAR = "JWT_SECRET_FILE"
-)
\ No newline at end of file
+	TOKEN_LIFETIME          = time.Minute * 15
+	TOKEN_LEEWAY            = time.Second * 30
+	TOKEN_ISSUER            = "software-slayer"
+	TOKEN_AUDIENCE          = "software-slayer-api"
+	REFRESH_TOKEN_LIFETIME  = time.Hour * 24 * 30
+	REVOCATION_PRUNE_PERIOD = time.Hour
+	JWT_KEYS_DIR_ENV_VAR    = "JWT_KEYS_DIR"
+
+	PASSWORD_RESET_TOKEN_LIFETIME = time.Hour
+	PASSWORD_RESET_URL_ENV_VAR    = "PASSWORD_RESET_URL"
+	DEFAULT_PASSWORD_RESET_URL    = "softwareslayer://reset-password"
+	DEFAULT_SMTP_PORT             = "587"
+	DEFAULT_MAIL_DIR              = "/tmp/software-slayer-mail"
+
+	EMAIL_VERIFICATION_TOKEN_LIFETIME  = time.Hour * 24
+	EMAIL_VERIFICATION_AUDIENCE        = "software-slayer-email-verification"
+	EMAIL_VERIFICATION_RESEND_INTERVAL = time.Minute * 5
+	EMAIL_VERIFICATION_URL_ENV_VAR     = "EMAIL_VERIFICATION_URL"
+	DEFAULT_EMAIL_VERIFICATION_URL     = "http://localhost:8080/user/verify"
+	EMAIL_VERIFICATION_POLICY_ENV_VAR  = "EMAIL_VERIFICATION_POLICY"
+	DEFAULT_EMAIL_VERIFICATION_POLICY  = "optional"
+
+	TWO_FACTOR_ISSUER                = "Software Slayer"
+	TWO_FACTOR_AUDIENCE              = "software-slayer-2fa"
+	TWO_FACTOR_CHALLENGE_LIFETIME    = time.Minute * 5
+	TOTP_ENCRYPTION_KEY_FILE_ENV_VAR = "TOTP_ENCRYPTION_KEY_FILE"
+
+	LOGIN_ACCOUNT_FREE_ATTEMPTS     = 3
+	LOGIN_ACCOUNT_LOCKOUT_THRESHOLD = 10
+	LOGIN_IP_FREE_ATTEMPTS          = 10
+	LOGIN_IP_LOCKOUT_THRESHOLD      = 50
+	LOGIN_BACKOFF_BASE_DELAY        = time.Second
+	LOGIN_BACKOFF_MAX_DELAY         = time.Minute
+	LOGIN_LOCKOUT_DURATION          = time.Minute * 15
+	LOGIN_ATTEMPT_RESET_AFTER       = time.Hour
+	LOGIN_ATTEMPT_PRUNE_PERIOD      = time.Hour
+
+	PASSWORD_MIN_LENGTH                    = 8
+	PASSWORD_MAX_LENGTH                    = 64
+	PASSWORD_MIN_ENTROPY_BITS_ENV_VAR      = "PASSWORD_MIN_ENTROPY_BITS"
+	DEFAULT_PASSWORD_MIN_ENTROPY_BITS      = 40
+	PASSWORD_MIN_CHARACTER_CLASSES_ENV_VAR = "PASSWORD_MIN_CHARACTER_CLASSES"
+	DEFAULT_PASSWORD_MIN_CHARACTER_CLASSES = 0
+	BREACHED_PASSWORDS_FILE_ENV_VAR        = "BREACHED_PASSWORDS_FILE"
+	BCRYPT_COST_ENV_VAR                    = "BCRYPT_COST"
+	DEFAULT_BCRYPT_COST                    = 10
+
+	ACCOUNT_DELETION_GRACE_PERIOD_ENV_VAR = "ACCOUNT_DELETION_GRACE_PERIOD"
+	DEFAULT_ACCOUNT_DELETION_GRACE_PERIOD = time.Hour * 24 * 14
+	ACCOUNT_DELETION_CHECK_PERIOD         = time.Hour
+
+	EXPORT_DIR_ENV_VAR            = "EXPORT_DIR"
+	DEFAULT_EXPORT_DIR            = "/tmp/software-slayer-exports"
+	EXPORT_DOWNLOAD_AUDIENCE      = "software-slayer-export-download"
+	EXPORT_DOWNLOAD_LINK_LIFETIME = time.Minute * 15
+	EXPORT_RETENTION              = time.Hour * 24 * 7
+	EXPORT_PRUNE_PERIOD           = time.Hour
+)
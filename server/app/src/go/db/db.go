@@ -108,3 +108,25 @@ func (db *Database) QueryContext(ctx context.Context, query string, args ...any)
 func (db *Database) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
 	return db.conn.QueryRowContext(ctx, query, args...)
 }
+
+// BeginTx starts a transaction, with a context
+func (db *Database) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return db.conn.BeginTx(ctx, opts)
+}
+
+// WithTx runs fn inside a transaction, committing if fn succeeds and rolling back otherwise
+func (db *Database) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("Failed to roll back transaction: %v", rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,140 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// table is one part of a user's data, written to the archive as <name>.json and <name>.csv
+type table struct {
+	name    string
+	records any
+	header  []string
+	rows    [][]string
+}
+
+/*
+ * Write a user's data to a zip archive with a JSON and a CSV file for each part of it
+ * @param w: where the archive is written
+ * @param data: the user's data
+ * @return error: an error if the archive could not be written
+ */
+func WriteArchive(w io.Writer, data UserData) error {
+	archive := zip.NewWriter(w)
+	for _, table := range tables(data) {
+		if err := writeJSON(archive, table.name+".json", table.records); err != nil {
+			return err
+		}
+		if err := writeCSV(archive, table.name+".csv", table.header, table.rows); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// tables splits a user's data into the parts written to the archive
+func tables(data UserData) []table {
+	profile := data.Profile
+	tables := []table{{
+		name:    "profile",
+		records: profile,
+		header: []string{"id", "username", "email", "first_name", "last_name", "role", "disabled_at", "email_verified_at",
+			"verification_sent_at", "pending_email", "delete_after", "profile_visibility", "learnings_visibility", "two_factor_enabled_at"},
+		rows: [][]string{{strconv.Itoa(profile.ID), profile.Username, profile.Email, profile.FirstName, profile.LastName, profile.Role,
+			formatTime(profile.DisabledAt), formatTime(profile.EmailVerifiedAt), formatTime(profile.VerificationSentAt),
+			formatString(profile.PendingEmail), formatTime(profile.DeleteAfter), profile.ProfileVisibility, profile.LearningsVisibility,
+			formatTime(profile.TwoFactorEnabledAt)}},
+	}}
+
+	learnings := table{name: "learnings", records: data.Learnings,
+		header: []string{"id", "title", "category", "status", "visibility", "tags", "created_at", "started_at", "completed_at"}}
+	for _, learning := range data.Learnings {
+		learnings.rows = append(learnings.rows, []string{strconv.Itoa(learning.ID), learning.Title, learning.Category, learning.Status,
+			formatString(learning.Visibility), strings.Join(learning.Tags, ","), formatTime(&learning.CreatedAt),
+			formatTime(learning.StartedAt), formatTime(learning.CompletedAt)})
+	}
+
+	categories := table{name: "categories", records: data.Categories, header: []string{"id", "name", "position"}}
+	for _, category := range data.Categories {
+		categories.rows = append(categories.rows, []string{strconv.Itoa(category.ID), category.Name, strconv.Itoa(category.Position)})
+	}
+
+	tags := table{name: "tags", records: data.Tags, header: []string{"id", "name"}}
+	for _, tag := range data.Tags {
+		tags.rows = append(tags.rows, []string{strconv.Itoa(tag.ID), tag.Name})
+	}
+
+	sessions := table{name: "sessions", records: data.Sessions, header: []string{"id", "family_id", "created_at", "expires_at", "revoked_at"}}
+	for _, session := range data.Sessions {
+		sessions.rows = append(sessions.rows, []string{strconv.Itoa(session.ID), session.FamilyID, formatTime(&session.CreatedAt),
+			formatTime(&session.ExpiresAt), formatTime(session.RevokedAt)})
+	}
+
+	accessTokens := table{name: "personal_access_tokens", records: data.PersonalAccessTokens,
+		header: []string{"id", "name", "scopes", "created_at", "expires_at", "last_used_at", "revoked_at"}}
+	for _, token := range data.PersonalAccessTokens {
+		accessTokens.rows = append(accessTokens.rows, []string{strconv.Itoa(token.ID), token.Name, token.Scopes,
+			formatTime(&token.CreatedAt), formatTime(token.ExpiresAt), formatTime(token.LastUsedAt), formatTime(token.RevokedAt)})
+	}
+
+	auditEvents := table{name: "audit_events", records: data.AuditEvents,
+		header: []string{"id", "action", "actor_id", "subject", "detail", "created_at"}}
+	for _, event := range data.AuditEvents {
+		actorId := ""
+		if event.ActorID != nil {
+			actorId = strconv.Itoa(*event.ActorID)
+		}
+		auditEvents.rows = append(auditEvents.rows, []string{strconv.Itoa(event.ID), event.Action, actorId, event.Subject,
+			event.Detail, formatTime(&event.CreatedAt)})
+	}
+
+	return append(tables, learnings, categories, tags, sessions, accessTokens, auditEvents)
+}
+
+func writeJSON(archive *zip.Writer, name string, records any) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+func writeCSV(archive *zip.Writer, name string, header []string, rows [][]string) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// formatTime formats a timestamp as RFC 3339 in UTC, or returns an empty string if it is not set
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// formatString returns the string, or an empty string if it is not set
+func formatString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,38 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"software-slayer/learnings"
+)
+
+// csvListEncoder writes learning items with the columns of the learnings.csv of a data export, which POST /learning/import reads back
+type csvListEncoder struct {
+	writer *csv.Writer
+}
+
+func newCSVListEncoder(w io.Writer, list ListInfo) (ListEncoder, error) {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"id", "title", "category", "status", "visibility", "tags", "created_at", "started_at", "completed_at"})
+	return &csvListEncoder{writer: writer}, err
+}
+
+func (e *csvListEncoder) Encode(learning learnings.GetLearningResponse) error {
+	err := e.writer.Write([]string{strconv.Itoa(learning.ID), learning.Title, learning.Category, learning.Status,
+		formatVisibility(learning.Visibility), strings.Join(learning.Tags, ","), formatTime(&learning.CreatedAt),
+		formatTime(learning.StartedAt), formatTime(learning.CompletedAt)})
+	if err != nil {
+		return err
+	}
+	// Rows are flushed as they are written, so the list is streamed rather than held in the writer's buffer
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvListEncoder) Close() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
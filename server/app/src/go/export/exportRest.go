@@ -0,0 +1,254 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"software-slayer/auth"
+	"software-slayer/utils"
+)
+
+// exportJobTimeout is how long an export may run, a job still unfinished after it was interrupted and has failed
+const exportJobTimeout = 5 * time.Minute
+
+var exportService ExportService
+var exportStore Store
+var downloadTokenService auth.DownloadTokenService
+var tokenService auth.TokenService
+
+// startExportMu makes checking for an export in progress and starting one atomic
+var startExportMu sync.Mutex
+
+// exportRetention is how long after an export is requested its archive can be downloaded
+var exportRetention time.Duration
+
+// @Summary Export the current user's data
+// @Description Start an export of everything stored about the current user: their profile, learning items, categories, tags, sessions, personal access tokens and audit events. The export runs in the background, poll its status to get a download link for the zip of JSON and CSV files once it completes.
+// @Tags Users
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 202 {object} ExportJobResponse "Export started"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 409 {object} utils.ErrorResponse "An export is already in progress"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/me/export [post]
+func createExport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	startExportMu.Lock()
+	defer startExportMu.Unlock()
+
+	jobs, err := exportStore.GetJobsByUserId(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to start export")
+		return
+	}
+	for _, job := range jobs {
+		if !jobStatus(job).Finished() {
+			utils.RespondWithError(w, http.StatusConflict, "An export is already in progress")
+			return
+		}
+	}
+
+	job, err := exportStore.CreateJob(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to start export")
+		return
+	}
+
+	go runExport(job)
+
+	log.Printf("Started export %s of user ID %d", job.ID, userId)
+	utils.RespondWithJSON(w, http.StatusAccepted, jobResponse(job))
+}
+
+// @Summary Get the status of an export
+// @Description Get the status of one of the current user's exports. Once it has completed, the response has a link to download the archive, which expires after a short time; get the status again for a new one.
+// @Tags Users
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Export ID"
+// @Success 200 {object} ExportJobResponse
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 404 {object} utils.ErrorResponse "Export not found"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/me/export/{id} [get]
+func getExport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	job, err := exportStore.GetJob(ctx, r.PathValue("id"))
+	if errors.Is(err, ErrJobNotFound) || (err == nil && job.UserID != userId) {
+		utils.RespondWithError(w, http.StatusNotFound, "Export not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve export")
+		return
+	}
+
+	response := jobResponse(job)
+	if response.Status == StatusCompleted {
+		token, expiresAt, err := downloadTokenService.GenerateDownloadToken(userId, job.ID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create download link")
+			return
+		}
+		response.DownloadURL = fmt.Sprintf("/user/me/export/%s/download?token=%s", job.ID, url.QueryEscape(token))
+		response.DownloadExpiresAt = &expiresAt
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// @Summary Download an export
+// @Description Download the zip archive of a completed export, with the link from its status. The link is the only credential needed, so it can be opened in a browser.
+// @Tags Users
+// @Produce application/zip
+// @Param id path string true "Export ID"
+// @Param token query string true "Download token"
+// @Success 200 {file} file "Export archive"
+// @Failure 403 {object} utils.ErrorResponse "Invalid or expired download link"
+// @Failure 404 {object} utils.ErrorResponse "Export not found"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/me/export/{id}/download [get]
+func downloadExport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, id, err := downloadTokenService.ParseDownloadToken(r.URL.Query().Get("token"))
+	if err != nil || id != r.PathValue("id") {
+		utils.RespondWithError(w, http.StatusForbidden, "Invalid or expired download link")
+		return
+	}
+
+	job, err := exportStore.GetJob(ctx, id)
+	if errors.Is(err, ErrJobNotFound) || (err == nil && (job.UserID != userId || job.Status != StatusCompleted)) {
+		utils.RespondWithError(w, http.StatusNotFound, "Export not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve export")
+		return
+	}
+
+	artifact, err := exportStore.OpenArtifact(ctx, id)
+	if errors.Is(err, ErrJobNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, "Export not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve export")
+		return
+	}
+	defer artifact.Close()
+
+	log.Printf("User ID %d downloaded export %s", userId, id)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="software-slayer-export-%s.zip"`,
+		job.CreatedAt.Format("2006-01-02")))
+	http.ServeContent(w, r, "", *job.CompletedAt, artifact)
+}
+
+/*
+ * runExport collects a user's data and stores its archive, recording the outcome in the job
+ * It runs after the request that started it has returned, so it has its own context
+ * @param job: the pending job
+ */
+func runExport(job Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), exportJobTimeout)
+	defer cancel()
+
+	job.Status = StatusRunning
+	if err := exportStore.UpdateJob(ctx, job); err != nil {
+		log.Printf("Failed to start export %s: %v", job.ID, err)
+		return
+	}
+
+	job.Status = StatusCompleted
+	if err := writeExport(ctx, job); err != nil {
+		log.Printf("Export %s of user ID %d failed: %v", job.ID, job.UserID, err)
+		job.Status = StatusFailed
+	}
+
+	completedAt := time.Now().UTC().Truncate(time.Second)
+	job.CompletedAt = &completedAt
+	if err := exportStore.UpdateJob(ctx, job); err != nil {
+		log.Printf("Failed to finish export %s: %v", job.ID, err)
+		return
+	}
+	log.Printf("Export %s of user ID %d %s", job.ID, job.UserID, job.Status)
+}
+
+// writeExport collects the data of a job's user and writes it to the job's archive
+func writeExport(ctx context.Context, job Job) error {
+	data, err := exportService.CollectUserData(ctx, job.UserID)
+	if err != nil {
+		return err
+	}
+
+	return exportStore.WriteArtifact(ctx, job.ID, func(w io.Writer) error {
+		return WriteArchive(w, data)
+	})
+}
+
+// jobStatus returns a job with its real status, an unfinished job that outlived exportJobTimeout having been interrupted
+func jobStatus(job Job) Job {
+	if !job.Finished() && time.Since(job.CreatedAt) > exportJobTimeout {
+		job.Status = StatusFailed
+	}
+	return job
+}
+
+// jobResponse converts a job to the response describing it, without a download link
+func jobResponse(job Job) ExportJobResponse {
+	job = jobStatus(job)
+	response := ExportJobResponse{
+		ID:          job.ID,
+		Status:      job.Status,
+		CreatedAt:   job.CreatedAt,
+		CompletedAt: job.CompletedAt,
+	}
+	if job.Status == StatusCompleted {
+		expiresAt := job.CreatedAt.Add(exportRetention)
+		response.ExpiresAt = &expiresAt
+	}
+	return response
+}
+
+/*
+ * InitExportRest initializes the data export REST endpoints
+ * @param _exportService: collects the data of a user
+ * @param _exportStore: stores export jobs and archives
+ * @param _downloadTokenService: signs the download links of archives
+ * @param _tokenService: authenticates requests
+ * @param retention: how long archives are kept, which PruneExports must be run with
+ */
+func InitExportRest(_exportService ExportService, _exportStore Store, _downloadTokenService auth.DownloadTokenService,
+	_tokenService auth.TokenService, retention time.Duration) {
+	exportService = _exportService
+	exportStore = _exportStore
+	downloadTokenService = _downloadTokenService
+	tokenService = _tokenService
+	exportRetention = retention
+
+	auth.RegisterRoutes(tokenService, []auth.Route{
+		{Pattern: "POST /user/me/export", Access: auth.Authenticated, AllowUnverified: true, Handler: createExport},
+		{Pattern: "GET /user/me/export/{id}", Access: auth.Authenticated, AllowUnverified: true, Handler: getExport},
+		{Pattern: "GET /user/me/export/{id}/download", Access: auth.Public, Handler: downloadExport},
+	})
+
+	log.Println("Data export REST endpoints initialized")
+}
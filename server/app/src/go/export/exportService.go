@@ -0,0 +1,162 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"software-slayer/auth"
+	"software-slayer/db"
+)
+
+type ExportService interface {
+	CollectUserData(ctx context.Context, userId int) (UserData, error)
+}
+
+type ExportServiceImpl struct {
+	db *db.Database
+}
+
+func NewExportService(db *db.Database) *ExportServiceImpl {
+	return &ExportServiceImpl{db: db}
+}
+
+/*
+ * Collect everything stored about a user, read in one transaction so the parts are consistent with each other
+ * @param ctx: the context
+ * @param userId: the id of the user
+ * @return UserData: the user's data, without password, token or secret hashes
+ * @return error: sql.ErrNoRows if the user does not exist
+ */
+func (s *ExportServiceImpl) CollectUserData(ctx context.Context, userId int) (UserData, error) {
+	var data UserData
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		if data.Profile, err = getProfile(ctx, tx, userId); err != nil {
+			return err
+		}
+		if data.Learnings, err = getLearnings(ctx, tx, userId); err != nil {
+			return err
+		}
+		if data.Categories, err = getCategories(ctx, tx, userId); err != nil {
+			return err
+		}
+		if data.Tags, err = getTags(ctx, tx, userId); err != nil {
+			return err
+		}
+		if data.Sessions, err = getSessions(ctx, tx, userId); err != nil {
+			return err
+		}
+		if data.PersonalAccessTokens, err = getAccessTokens(ctx, tx, userId); err != nil {
+			return err
+		}
+		data.AuditEvents, err = getAuditEvents(ctx, tx, userId)
+		return err
+	})
+	return data, err
+}
+
+func getProfile(ctx context.Context, tx *sql.Tx, userId int) (Profile, error) {
+	var profile Profile
+	err := tx.QueryRowContext(ctx, `SELECT u.id, u.username, u.email, u.first_name, u.last_name, u.role, u.disabled_at, u.email_verified_at,
+		u.verification_sent_at, u.pending_email, u.delete_after, u.profile_visibility, u.learnings_visibility, t.confirmed_at
+		FROM users u LEFT JOIN totp_secrets t ON t.user_id = u.id WHERE u.id = ?`, userId).Scan(
+		&profile.ID, &profile.Username, &profile.Email, &profile.FirstName, &profile.LastName, &profile.Role,
+		&profile.DisabledAt, &profile.EmailVerifiedAt, &profile.VerificationSentAt, &profile.PendingEmail, &profile.DeleteAfter,
+		&profile.ProfileVisibility, &profile.LearningsVisibility, &profile.TwoFactorEnabledAt)
+	return profile, err
+}
+
+func getLearnings(ctx context.Context, tx *sql.Tx, userId int) ([]LearningRecord, error) {
+	return queryRecords(ctx, tx, `SELECT l.id, l.title, c.name, l.status, l.visibility, l.created_at, l.started_at, l.completed_at,
+		COALESCE(GROUP_CONCAT(t.name ORDER BY t.name SEPARATOR ','), '')
+		FROM user_learning_list l
+		JOIN categories c ON l.category_id = c.id
+		LEFT JOIN learning_tags lt ON lt.learning_id = l.id
+		LEFT JOIN tags t ON lt.tag_id = t.id
+		WHERE l.user_id = ? GROUP BY l.id, c.name ORDER BY l.id`, []any{userId}, func(rows *sql.Rows) (LearningRecord, error) {
+		var learning LearningRecord
+		var tags string
+		err := rows.Scan(&learning.ID, &learning.Title, &learning.Category, &learning.Status, &learning.Visibility,
+			&learning.CreatedAt, &learning.StartedAt, &learning.CompletedAt, &tags)
+		learning.Tags = make([]string, 0)
+		if tags != "" {
+			learning.Tags = strings.Split(tags, ",")
+		}
+		return learning, err
+	})
+}
+
+func getCategories(ctx context.Context, tx *sql.Tx, userId int) ([]CategoryRecord, error) {
+	return queryRecords(ctx, tx, "SELECT id, name, position FROM categories WHERE user_id = ? ORDER BY position, id",
+		[]any{userId}, func(rows *sql.Rows) (CategoryRecord, error) {
+			var category CategoryRecord
+			err := rows.Scan(&category.ID, &category.Name, &category.Position)
+			return category, err
+		})
+}
+
+func getTags(ctx context.Context, tx *sql.Tx, userId int) ([]TagRecord, error) {
+	return queryRecords(ctx, tx, "SELECT id, name FROM tags WHERE user_id = ? ORDER BY name",
+		[]any{userId}, func(rows *sql.Rows) (TagRecord, error) {
+			var tag TagRecord
+			err := rows.Scan(&tag.ID, &tag.Name)
+			return tag, err
+		})
+}
+
+func getSessions(ctx context.Context, tx *sql.Tx, userId int) ([]SessionRecord, error) {
+	return queryRecords(ctx, tx, "SELECT id, family_id, created_at, expires_at, revoked_at FROM refresh_tokens WHERE user_id = ? ORDER BY id",
+		[]any{userId}, func(rows *sql.Rows) (SessionRecord, error) {
+			var session SessionRecord
+			err := rows.Scan(&session.ID, &session.FamilyID, &session.CreatedAt, &session.ExpiresAt, &session.RevokedAt)
+			return session, err
+		})
+}
+
+func getAccessTokens(ctx context.Context, tx *sql.Tx, userId int) ([]AccessTokenRecord, error) {
+	return queryRecords(ctx, tx, `SELECT id, name, scopes, created_at, expires_at, last_used_at, revoked_at
+		FROM personal_access_tokens WHERE user_id = ? ORDER BY id`, []any{userId}, func(rows *sql.Rows) (AccessTokenRecord, error) {
+		var token AccessTokenRecord
+		err := rows.Scan(&token.ID, &token.Name, &token.Scopes, &token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt, &token.RevokedAt)
+		return token, err
+	})
+}
+
+// getAuditEvents returns the events about the user's account and the events the user performed, such as admin actions
+func getAuditEvents(ctx context.Context, tx *sql.Tx, userId int) ([]AuditEventRecord, error) {
+	return queryRecords(ctx, tx, "SELECT id, action, actor_id, subject, detail, created_at FROM audit_events WHERE subject = ? OR actor_id = ? ORDER BY id",
+		[]any{auth.AccountKey(userId), userId}, func(rows *sql.Rows) (AuditEventRecord, error) {
+			var event AuditEventRecord
+			err := rows.Scan(&event.ID, &event.Action, &event.ActorID, &event.Subject, &event.Detail, &event.CreatedAt)
+			return event, err
+		})
+}
+
+/*
+ * Run a query and scan every row it returns
+ * @param ctx: the context
+ * @param tx: the transaction to query in
+ * @param query: the query
+ * @param args: the arguments of the query
+ * @param scan: scans the current row into a record
+ * @return []T: the records, empty rather than nil when there are none
+ * @return error: an error if the query or a scan failed
+ */
+func queryRecords[T any](ctx context.Context, tx *sql.Tx, query string, args []any, scan func(rows *sql.Rows) (T, error)) ([]T, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]T, 0)
+	for rows.Next() {
+		record, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
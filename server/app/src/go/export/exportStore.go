@@ -0,0 +1,300 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrJobNotFound = errors.New("export job not found")
+
+// Store keeps export jobs and the archives they produce
+type Store interface {
+	// CreateJob records a new pending job for a user
+	CreateJob(ctx context.Context, userId int) (Job, error)
+	// GetJob returns the job with the given ID, or ErrJobNotFound
+	GetJob(ctx context.Context, id string) (Job, error)
+	GetJobsByUserId(ctx context.Context, userId int) ([]Job, error)
+	UpdateJob(ctx context.Context, job Job) error
+	// WriteArtifact stores the archive of a job, which only becomes visible once write succeeds, or returns
+	// ErrJobNotFound if the job has been removed
+	WriteArtifact(ctx context.Context, id string, write func(w io.Writer) error) error
+	// OpenArtifact opens the archive of a job, or returns ErrJobNotFound if it has none
+	OpenArtifact(ctx context.Context, id string) (io.ReadSeekCloser, error)
+	// Prune removes the jobs created before before, along with their archives
+	Prune(ctx context.Context, before time.Time) error
+	// DeleteJobsByUserId removes every job of a user along with its archive
+	DeleteJobsByUserId(ctx context.Context, userId int) error
+}
+
+/*
+ * Periodically prune old export jobs and archives until the context is cancelled
+ * @param ctx: the context that stops pruning when cancelled
+ * @param store: the export store to prune
+ * @param interval: the time between prunes
+ * @param retention: how long after they are created jobs and archives are kept
+ */
+func PruneExports(ctx context.Context, store Store, interval time.Duration, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			pruneCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			if err := store.Prune(pruneCtx, now.Add(-retention)); err != nil {
+				log.Printf("Failed to prune data exports: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// LocalStore keeps each job in <dir>/<id>.json and its archive in <dir>/<id>.zip
+type LocalStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{dir: dir}
+}
+
+func (s *LocalStore) CreateJob(ctx context.Context, userId int) (Job, error) {
+	id, err := newJobId()
+	if err != nil {
+		return Job{}, err
+	}
+
+	job := Job{ID: id, UserID: userId, Status: StatusPending, CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return Job{}, err
+	}
+	return job, s.writeJob(job)
+}
+
+func (s *LocalStore) GetJob(ctx context.Context, id string) (Job, error) {
+	if !validJobId(id) {
+		return Job{}, ErrJobNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.readJob(s.path(id, ".json"))
+}
+
+func (s *LocalStore) GetJobsByUserId(ctx context.Context, userId int) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs, err := s.readJobs()
+	if err != nil {
+		return nil, err
+	}
+
+	userJobs := make([]Job, 0)
+	for _, job := range jobs {
+		if job.UserID == userId {
+			userJobs = append(userJobs, job)
+		}
+	}
+	return userJobs, nil
+}
+
+func (s *LocalStore) UpdateJob(ctx context.Context, job Job) error {
+	if !validJobId(job.ID) {
+		return ErrJobNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A job pruned while it was running stays pruned
+	if err := s.checkJobExists(job.ID); err != nil {
+		return err
+	}
+	return s.writeJob(job)
+}
+
+func (s *LocalStore) WriteArtifact(ctx context.Context, id string, write func(w io.Writer) error) error {
+	if !validJobId(id) {
+		return ErrJobNotFound
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+
+	// Archives are written to a temporary file first, so a half-written archive is never served
+	file, err := os.CreateTemp(s.dir, id+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A job pruned or deleted with its user while it was running gets no archive
+	if err := s.checkJobExists(id); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), s.path(id, ".zip"))
+}
+
+func (s *LocalStore) OpenArtifact(ctx context.Context, id string) (io.ReadSeekCloser, error) {
+	if !validJobId(id) {
+		return nil, ErrJobNotFound
+	}
+
+	file, err := os.Open(s.path(id, ".zip"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrJobNotFound
+	}
+	return file, err
+}
+
+func (s *LocalStore) Prune(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs, err := s.readJobs()
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if !job.CreatedAt.Before(before) {
+			continue
+		}
+		if err := s.removeJob(job.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *LocalStore) DeleteJobsByUserId(ctx context.Context, userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs, err := s.readJobs()
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if job.UserID != userId {
+			continue
+		}
+		if err := s.removeJob(job.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeJob removes a job and its archive, the archive first so no archive is left without its job
+func (s *LocalStore) removeJob(id string) error {
+	if err := os.Remove(s.path(id, ".zip")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(s.path(id, ".json")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// checkJobExists returns ErrJobNotFound if a job has been removed, it must be called with s.mu held
+func (s *LocalStore) checkJobExists(id string) error {
+	if _, err := os.Stat(s.path(id, ".json")); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrJobNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// path returns the path of one of a job's files
+func (s *LocalStore) path(id string, extension string) string {
+	return filepath.Join(s.dir, id+extension)
+}
+
+// writeJob writes a job to a temporary file and renames it into place, so readers never see a partial job
+func (s *LocalStore) writeJob(job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	path := s.path(job.ID, ".json")
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (s *LocalStore) readJob(path string) (Job, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Job{}, ErrJobNotFound
+	}
+	if err != nil {
+		return Job{}, err
+	}
+
+	var job Job
+	err = json.Unmarshal(data, &job)
+	return job, err
+}
+
+// readJobs reads every job in the store, an empty store having no directory yet
+func (s *LocalStore) readJobs() ([]Job, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]Job, 0, len(entries))
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !validJobId(id) {
+			continue
+		}
+
+		job, err := s.readJob(filepath.Join(s.dir, entry.Name()))
+		if errors.Is(err, ErrJobNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
@@ -0,0 +1,143 @@
+package export
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// JobStatus is how far an export job has got
+type JobStatus string
+
+const (
+	StatusPending   JobStatus = "pending"
+	StatusRunning   JobStatus = "running"
+	StatusCompleted JobStatus = "completed"
+	StatusFailed    JobStatus = "failed"
+)
+
+// Job is an export of a user's personal data, whose archive is stored under the job's ID once it completes
+type Job struct {
+	ID          string     `json:"id"`
+	UserID      int        `json:"user_id"`
+	Status      JobStatus  `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// Finished reports whether the job has completed or failed
+func (j Job) Finished() bool {
+	return j.Status == StatusCompleted || j.Status == StatusFailed
+}
+
+// ExportJobResponse is the status of an export job, with a download link once its archive is ready
+type ExportJobResponse struct {
+	ID                string     `json:"id"`
+	Status            JobStatus  `json:"status"`
+	CreatedAt         time.Time  `json:"created_at"`
+	CompletedAt       *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	DownloadURL       string     `json:"download_url,omitempty"`
+	DownloadExpiresAt *time.Time `json:"download_expires_at,omitempty"`
+}
+
+// UserData is everything stored about a user
+type UserData struct {
+	Profile              Profile             `json:"profile"`
+	Learnings            []LearningRecord    `json:"learnings"`
+	Categories           []CategoryRecord    `json:"categories"`
+	Tags                 []TagRecord         `json:"tags"`
+	Sessions             []SessionRecord     `json:"sessions"`
+	PersonalAccessTokens []AccessTokenRecord `json:"personal_access_tokens"`
+	AuditEvents          []AuditEventRecord  `json:"audit_events"`
+}
+
+// Profile is the user's row in the users table, without the password hash
+type Profile struct {
+	ID                  int        `json:"id"`
+	Username            string     `json:"username"`
+	Email               string     `json:"email"`
+	FirstName           string     `json:"first_name"`
+	LastName            string     `json:"last_name"`
+	Role                string     `json:"role"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	EmailVerifiedAt     *time.Time `json:"email_verified_at"`
+	VerificationSentAt  *time.Time `json:"verification_sent_at"`
+	PendingEmail        *string    `json:"pending_email"`
+	DeleteAfter         *time.Time `json:"delete_after"`
+	ProfileVisibility   string     `json:"profile_visibility"`
+	LearningsVisibility string     `json:"learnings_visibility"`
+	TwoFactorEnabledAt  *time.Time `json:"two_factor_enabled_at"`
+}
+
+type LearningRecord struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Category    string     `json:"category"`
+	Status      string     `json:"status"`
+	Visibility  *string    `json:"visibility"`
+	Tags        []string   `json:"tags"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+type CategoryRecord struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+}
+
+type TagRecord struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// SessionRecord is a refresh token the user has signed in with, without the token itself
+type SessionRecord struct {
+	ID        int        `json:"id"`
+	FamilyID  string     `json:"family_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// AccessTokenRecord is a personal access token of the user, without the token itself
+type AccessTokenRecord struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     string     `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// AuditEventRecord is an audit event about the user or performed by them
+type AuditEventRecord struct {
+	ID        int       `json:"id"`
+	Action    string    `json:"action"`
+	ActorID   *int      `json:"actor_id"`
+	Subject   string    `json:"subject"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// newJobId returns a random job ID, which doubles as the name of the job's files
+func newJobId() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// validJobId reports whether id could have been returned by newJobId, so that it is safe to use in a file name
+func validJobId(id string) bool {
+	if len(id) != 32 || strings.ToLower(id) != id {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
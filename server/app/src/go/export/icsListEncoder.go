@@ -0,0 +1,103 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"software-slayer/learnings"
+)
+
+// icsTimeFormat is the UTC date-time format of iCalendar
+const icsTimeFormat = "20060102T150405Z"
+
+// icsMaxLineLength is the number of octets after which iCalendar content lines are folded
+const icsMaxLineLength = 75
+
+// icsTextEscaper escapes the characters with a meaning in iCalendar text values
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// icsStatuses maps learning item statuses to the statuses of iCalendar to-dos
+var icsStatuses = map[string]string{
+	learnings.StatusPlanned:    "NEEDS-ACTION",
+	learnings.StatusInProgress: "IN-PROCESS",
+	learnings.StatusCompleted:  "COMPLETED",
+	learnings.StatusAbandoned:  "CANCELLED",
+}
+
+// icsListEncoder writes learning items as the to-dos of an iCalendar file, so calendar apps show when they were started and completed
+type icsListEncoder struct {
+	w    io.Writer
+	list ListInfo
+}
+
+func newICSListEncoder(w io.Writer, list ListInfo) (ListEncoder, error) {
+	encoder := &icsListEncoder{w: w, list: list}
+	return encoder, encoder.writeLines(
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Software Slayer//Learning List//EN",
+		"X-WR-CALNAME:Learning list",
+	)
+}
+
+func (e *icsListEncoder) Encode(learning learnings.GetLearningResponse) error {
+	categories := make([]string, 0, len(learning.Tags)+1)
+	for _, category := range append([]string{learning.Category}, learning.Tags...) {
+		categories = append(categories, icsTextEscaper.Replace(category))
+	}
+
+	lines := []string{
+		"BEGIN:VTODO",
+		fmt.Sprintf("UID:learning-%d@software-slayer", learning.ID),
+		"DTSTAMP:" + formatICSTime(e.list.ExportedAt),
+		"CREATED:" + formatICSTime(learning.CreatedAt),
+		"SUMMARY:" + icsTextEscaper.Replace(learning.Title),
+		"CATEGORIES:" + strings.Join(categories, ","),
+		"STATUS:" + icsStatuses[learning.Status],
+	}
+	if learning.StartedAt != nil {
+		lines = append(lines, "DTSTART:"+formatICSTime(*learning.StartedAt))
+	}
+	if learning.CompletedAt != nil {
+		lines = append(lines, "COMPLETED:"+formatICSTime(*learning.CompletedAt), "PERCENT-COMPLETE:100")
+	}
+	return e.writeLines(append(lines, "END:VTODO")...)
+}
+
+func (e *icsListEncoder) Close() error {
+	return e.writeLines("END:VCALENDAR")
+}
+
+// writeLines writes content lines ending in CRLF, folding those longer than icsMaxLineLength octets
+func (e *icsListEncoder) writeLines(lines ...string) error {
+	var builder strings.Builder
+	for _, line := range lines {
+		builder.WriteString(foldICSLine(line))
+		builder.WriteString("\r\n")
+	}
+	_, err := io.WriteString(e.w, builder.String())
+	return err
+}
+
+// foldICSLine splits a content line into lines of at most icsMaxLineLength octets, continued by lines starting with a space
+func foldICSLine(line string) string {
+	var builder strings.Builder
+	length := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if length+size > icsMaxLineLength {
+			builder.WriteString("\r\n ")
+			length = 1
+		}
+		builder.WriteRune(r)
+		length += size
+	}
+	return builder.String()
+}
+
+func formatICSTime(t time.Time) string {
+	return t.UTC().Format(icsTimeFormat)
+}
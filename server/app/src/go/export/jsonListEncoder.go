@@ -0,0 +1,42 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"software-slayer/learnings"
+)
+
+// jsonListEncoder writes learning items as a JSON array, one element at a time
+type jsonListEncoder struct {
+	w     io.Writer
+	first bool
+}
+
+func newJSONListEncoder(w io.Writer, list ListInfo) (ListEncoder, error) {
+	_, err := io.WriteString(w, "[")
+	return &jsonListEncoder{w: w, first: true}, err
+}
+
+func (e *jsonListEncoder) Encode(learning learnings.GetLearningResponse) error {
+	element, err := json.Marshal(learning)
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n"
+	if e.first {
+		separator = "\n"
+		e.first = false
+	}
+	if _, err := io.WriteString(e.w, separator); err != nil {
+		return err
+	}
+	_, err = e.w.Write(element)
+	return err
+}
+
+func (e *jsonListEncoder) Close() error {
+	_, err := io.WriteString(e.w, "\n]\n")
+	return err
+}
@@ -0,0 +1,158 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"software-slayer/auth"
+	"software-slayer/learnings"
+	"software-slayer/utils"
+)
+
+var learningsService learnings.LearningsService
+
+// listExportTimeout is how long a learning list may take to stream, longer than the server's write timeout allows
+const listExportTimeout = 30 * time.Second
+
+// @Summary Export a user's learning list
+// @Description Download a user's learning list as CSV, a Markdown task list grouped by category, a JSON array or an iCalendar file of to-dos. The list is streamed page by page. Private lists and learning items are only exported for their owner, following the same rules as GET /learning/{user_id}.
+// @Tags Learning Items
+// @Produce text/csv,text/markdown,application/json,text/calendar
+// @Param Authorization header string false "Bearer token"
+// @Param user_id path int true "User ID"
+// @Param format query string true "Export format" Enums(csv, md, json, ics)
+// @Success 200 {file} file "The learning list"
+// @Failure 400 {object} utils.ErrorResponse "Invalid user ID or format"
+// @Failure 404 {object} utils.ErrorResponse "User not found"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /learning/{user_id}/export [get]
+func exportLearningList(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), listExportTimeout)
+	defer cancel()
+
+	userID, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	format, ok := LookupListFormat(r.URL.Query().Get("format"))
+	if !ok {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid format parameter")
+		return
+	}
+
+	// Hidden lists are indistinguishable from missing users, so their existence isn't revealed
+	listVisibility, err := learningsService.GetLearningsVisibility(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to export learning list")
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+	owner := auth.Resource{OwnerID: userID}
+	if !auth.CanView(principal, listVisibility, owner) {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	filter := learnings.LearningFilter{Visibilities: auth.VisibleTo(principal, owner)}
+
+	page, err := learnings.LearningPageRequest(format.Sort, utils.MaxPageLimit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to export learning list")
+		return
+	}
+
+	// The first page is fetched before anything is written, so a failure can still be reported with an error status
+	learningItems, err := learningsService.GetLearningsByUserId(ctx, userID, filter, page)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to export learning list")
+		return
+	}
+
+	log.Printf("Exporting %d learning items for user ID: %d", learningItems.Total, userID)
+
+	// The server's write timeout would cut the download off, the export's own timeout applies to it instead
+	deadline, _ := ctx.Deadline()
+	if err := http.NewResponseController(w).SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to export learning list")
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="learning-list-%d.%s"`, userID, format.Extension))
+	w.WriteHeader(http.StatusOK)
+
+	if err := streamLearningList(ctx, w, userID, format, filter, page, learningItems); err != nil {
+		// The status has been sent, so the download can only be cut short for the client to notice it is incomplete
+		log.Printf("Failed to export learning list for user ID %d: %v", userID, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+/*
+ * streamLearningList encodes a learning list page by page, flushing each page to the client as it is written
+ * @param ctx: the request context
+ * @param w: the response writer, after the headers were written
+ * @param userID: the owner of the learning list
+ * @param format: the format to encode the list in
+ * @param filter: the filter of the items the caller may see
+ * @param page: the request the first page was fetched with
+ * @param learningItems: the first page
+ * @return error: an error if a page couldn't be fetched or written
+ */
+func streamLearningList(ctx context.Context, w http.ResponseWriter, userID int, format ListFormat, filter learnings.LearningFilter,
+	page utils.PageRequest, learningItems utils.Page[learnings.GetLearningResponse]) error {
+	encoder, err := format.NewEncoder(w, ListInfo{UserID: userID, ExportedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	controller := http.NewResponseController(w)
+	for {
+		for _, learning := range learningItems.Items {
+			if err := encoder.Encode(learning); err != nil {
+				return err
+			}
+		}
+		if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if learningItems.NextCursor == "" {
+			break
+		}
+
+		if page, err = page.NextPage(learningItems.NextCursor); err != nil {
+			return err
+		}
+		if learningItems, err = learningsService.GetLearningsByUserId(ctx, userID, filter, page); err != nil {
+			return err
+		}
+	}
+
+	return encoder.Close()
+}
+
+/*
+ * Initialize the learning list export REST endpoint, after InitExportRest
+ * @param _learningsService: the learnings service the lists are read from
+ */
+func InitLearningListExportRest(_learningsService learnings.LearningsService) {
+	learningsService = _learningsService
+
+	auth.RegisterRoutes(tokenService, []auth.Route{
+		{Pattern: "GET /learning/{user_id}/export", Access: auth.OptionalAuthentication, Scope: auth.ScopeReadLearnings, Handler: exportLearningList},
+	})
+
+	log.Println("Learning list export REST endpoint initialized")
+}
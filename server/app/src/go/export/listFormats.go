@@ -0,0 +1,82 @@
+package export
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"software-slayer/auth"
+	"software-slayer/learnings"
+)
+
+// ListInfo describes the learning list being exported
+type ListInfo struct {
+	UserID     int
+	ExportedAt time.Time
+}
+
+// ListEncoder writes a learning list one item at a time, so a list never has to be held in memory
+type ListEncoder interface {
+	Encode(learning learnings.GetLearningResponse) error
+	// Close writes whatever ends the list, after its last item
+	Close() error
+}
+
+// ListFormat is a format learning lists can be exported in with GET /learning/{user_id}/export
+type ListFormat struct {
+	ContentType string
+	Extension   string
+	// Sort is the order the format wants learning items in, a sort parameter accepted by GET /learning/{user_id}
+	Sort string
+	// NewEncoder writes whatever starts the list and returns the encoder for its items
+	NewEncoder func(w io.Writer, list ListInfo) (ListEncoder, error)
+}
+
+var listFormatsMu sync.RWMutex
+var listFormats = make(map[string]ListFormat)
+
+func init() {
+	RegisterListFormat("csv", ListFormat{ContentType: "text/csv; charset=utf-8", Extension: "csv", Sort: "id", NewEncoder: newCSVListEncoder})
+	RegisterListFormat("md", ListFormat{ContentType: "text/markdown; charset=utf-8", Extension: "md", Sort: "category", NewEncoder: newMarkdownListEncoder})
+	RegisterListFormat("json", ListFormat{ContentType: "application/json", Extension: "json", Sort: "id", NewEncoder: newJSONListEncoder})
+	RegisterListFormat("ics", ListFormat{ContentType: "text/calendar; charset=utf-8", Extension: "ics", Sort: "id", NewEncoder: newICSListEncoder})
+}
+
+// RegisterListFormat makes a format available to GET /learning/{user_id}/export under the given name, replacing any format of that name
+func RegisterListFormat(name string, format ListFormat) {
+	listFormatsMu.Lock()
+	defer listFormatsMu.Unlock()
+
+	listFormats[name] = format
+}
+
+// LookupListFormat returns the format registered under the given name
+func LookupListFormat(name string) (ListFormat, bool) {
+	listFormatsMu.RLock()
+	defer listFormatsMu.RUnlock()
+
+	format, ok := listFormats[name]
+	return format, ok
+}
+
+// ListFormatNames returns the names of the registered formats in alphabetical order
+func ListFormatNames() []string {
+	listFormatsMu.RLock()
+	defer listFormatsMu.RUnlock()
+
+	names := make([]string, 0, len(listFormats))
+	for name := range listFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatVisibility returns a learning item's visibility override, or an empty string if it inherits the list's
+func formatVisibility(visibility *auth.Visibility) string {
+	if visibility == nil {
+		return ""
+	}
+	return string(*visibility)
+}
@@ -0,0 +1,60 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"software-slayer/learnings"
+)
+
+// markdownEscaper escapes the characters that would format a title instead of showing up in it
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`, "~", `\~`)
+
+// markdownListEncoder writes learning items as a task list with a heading for each category, for items sorted by category
+type markdownListEncoder struct {
+	w        io.Writer
+	category *string
+}
+
+func newMarkdownListEncoder(w io.Writer, list ListInfo) (ListEncoder, error) {
+	_, err := io.WriteString(w, "# Learning list\n")
+	return &markdownListEncoder{w: w}, err
+}
+
+func (e *markdownListEncoder) Encode(learning learnings.GetLearningResponse) error {
+	if e.category == nil || *e.category != learning.Category {
+		if _, err := fmt.Fprintf(e.w, "\n## %s\n\n", markdownEscaper.Replace(learning.Category)); err != nil {
+			return err
+		}
+		e.category = &learning.Category
+	}
+
+	checkbox := "[ ]"
+	if learning.Status == learnings.StatusCompleted {
+		checkbox = "[x]"
+	}
+	title := markdownEscaper.Replace(learning.Title)
+	switch learning.Status {
+	case learnings.StatusInProgress:
+		title += " _(in progress)_"
+	case learnings.StatusAbandoned:
+		title = "~~" + title + "~~"
+	}
+
+	line := fmt.Sprintf("- %s %s", checkbox, title)
+	for _, tag := range learning.Tags {
+		line += " `" + tag + "`"
+	}
+	_, err := io.WriteString(e.w, line+"\n")
+	return err
+}
+
+func (e *markdownListEncoder) Close() error {
+	if e.category == nil {
+		_, err := io.WriteString(e.w, "\nNothing to learn yet.\n")
+		return err
+	}
+	return nil
+}
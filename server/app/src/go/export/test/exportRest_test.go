@@ -0,0 +1,283 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"software-slayer/auth"
+	"software-slayer/export"
+)
+
+type MockExportService struct {
+	// release is waited on by exports of user 2, so a test can keep one running
+	release chan struct{}
+}
+
+func (m *MockExportService) CollectUserData(ctx context.Context, userId int) (export.UserData, error) {
+	switch userId {
+	case 2:
+		<-m.release
+	case 5:
+		return export.UserData{}, errors.New("database unavailable")
+	}
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return export.UserData{
+		Profile: export.Profile{ID: userId, Username: "gopher", Email: "gopher@example.com", FirstName: "Rob", LastName: "Pike",
+			Role: "user", ProfileVisibility: "public", LearningsVisibility: "public"},
+		Learnings: []export.LearningRecord{
+			{ID: 1, Title: "Go, the language", Category: "Languages", Status: "planned", Tags: []string{"backend", "go"}, CreatedAt: createdAt},
+		},
+		Categories:           []export.CategoryRecord{},
+		Tags:                 []export.TagRecord{{ID: 1, Name: "backend"}, {ID: 2, Name: "go"}},
+		Sessions:             []export.SessionRecord{{ID: 4, FamilyID: "family", CreatedAt: createdAt, ExpiresAt: createdAt}},
+		PersonalAccessTokens: []export.AccessTokenRecord{},
+		AuditEvents:          []export.AuditEventRecord{},
+	}, nil
+}
+
+type MockTokenService struct{}
+
+func (m *MockTokenService) GenerateToken(userID int, role auth.Role, emailVerified bool) (string, error) {
+	return "mocked_token", nil
+}
+
+func (m *MockTokenService) RevokeToken(ctx context.Context, principal auth.Principal) error {
+	return nil
+}
+
+func (m *MockTokenService) RevokeAllTokens(ctx context.Context, userID int) error {
+	return nil
+}
+
+func (m *MockTokenService) AuthorizeUser(token string) (auth.Principal, error) {
+	switch token {
+	case "valid_token":
+		return auth.Principal{UserID: 1, TokenID: "valid_token_id"}, nil
+	case "user2_token":
+		return auth.Principal{UserID: 2, TokenID: "user2_token_id"}, nil
+	case "user5_token":
+		return auth.Principal{UserID: 5, TokenID: "user5_token_id"}, nil
+	}
+	return auth.Principal{}, errors.New("invalid token")
+}
+
+var ts *httptest.Server
+var mockExportService = &MockExportService{release: make(chan struct{})}
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "exports")
+	if err != nil {
+		panic(err)
+	}
+
+	keyring := auth.NewKeyring(auth.NewHMACKey("test", []byte("secret")))
+	downloadTokenService := auth.NewDownloadTokenService(auth.TokenConfig{
+		Issuer: "test-issuer", Audience: "test-download", Lifetime: time.Minute,
+	}, keyring)
+	export.InitExportRest(mockExportService, export.NewLocalStore(dir), downloadTokenService, &MockTokenService{}, 7*24*time.Hour)
+	export.InitLearningListExportRest(&MockLearningsService{})
+	ts = httptest.NewServer(http.DefaultServeMux)
+
+	code := m.Run()
+	ts.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func doRequest(t *testing.T, method string, path string, token string) *http.Response {
+	req, _ := http.NewRequest(method, ts.URL+path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// startExport starts an export and returns its job
+func startExport(t *testing.T, token string) export.ExportJobResponse {
+	resp := doRequest(t, "POST", "/user/me/export", token)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected %d, got %d", http.StatusAccepted, resp.StatusCode)
+	}
+	var job export.ExportJobResponse
+	json.NewDecoder(resp.Body).Decode(&job)
+	return job
+}
+
+// waitForExport polls the status of an export until it has finished
+func waitForExport(t *testing.T, id string, token string) export.ExportJobResponse {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp := doRequest(t, "GET", "/user/me/export/"+id, token)
+		var job export.ExportJobResponse
+		json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+		if job.Status == export.StatusCompleted || job.Status == export.StatusFailed {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("export %s did not finish", id)
+	return export.ExportJobResponse{}
+}
+
+func TestCreateExportAndDownload(t *testing.T) {
+	job := startExport(t, "valid_token")
+	if job.Status != export.StatusPending || job.DownloadURL != "" {
+		t.Errorf("expected a pending export without a download link, got %+v", job)
+	}
+
+	job = waitForExport(t, job.ID, "valid_token")
+	if job.Status != export.StatusCompleted || job.ExpiresAt == nil || job.DownloadExpiresAt == nil {
+		t.Fatalf("expected a completed export, got %+v", job)
+	}
+
+	// The download link is all that is needed to download the archive
+	resp := doRequest(t, "GET", job.DownloadURL, "")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/zip" {
+		t.Errorf("expected application/zip, got %q", contentType)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("download is not a zip archive: %v", err)
+	}
+
+	files := make(map[string]string)
+	for _, file := range archive.File {
+		reader, _ := file.Open()
+		content, _ := io.ReadAll(reader)
+		reader.Close()
+		files[file.Name] = string(content)
+	}
+
+	for _, name := range []string{"profile", "learnings", "categories", "tags", "sessions", "personal_access_tokens", "audit_events"} {
+		if _, ok := files[name+".json"]; !ok {
+			t.Errorf("archive has no %s.json", name)
+		}
+		if _, ok := files[name+".csv"]; !ok {
+			t.Errorf("archive has no %s.csv", name)
+		}
+	}
+
+	var profile export.Profile
+	if err := json.Unmarshal([]byte(files["profile.json"]), &profile); err != nil || profile.Username != "gopher" {
+		t.Errorf("profile.json = %q", files["profile.json"])
+	}
+	if strings.Contains(files["profile.json"], "password") {
+		t.Error("profile.json contains a password")
+	}
+	expectedCSV := "id,title,category,status,visibility,tags,created_at,started_at,completed_at\n" +
+		"1,\"Go, the language\",Languages,planned,,\"backend,go\",2024-01-02T03:04:05Z,,\n"
+	if files["learnings.csv"] != expectedCSV {
+		t.Errorf("learnings.csv = %q, expected %q", files["learnings.csv"], expectedCSV)
+	}
+	if files["categories.csv"] != "id,name,position\n" {
+		t.Errorf("categories.csv = %q", files["categories.csv"])
+	}
+
+	// A finished export doesn't stop another one
+	waitForExport(t, startExport(t, "valid_token").ID, "valid_token")
+}
+
+func TestCreateExportInProgress(t *testing.T) {
+	job := startExport(t, "user2_token")
+
+	resp := doRequest(t, "POST", "/user/me/export", "user2_token")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected %d, got %d", http.StatusConflict, resp.StatusCode)
+	}
+
+	close(mockExportService.release)
+	if job = waitForExport(t, job.ID, "user2_token"); job.Status != export.StatusCompleted {
+		t.Errorf("expected a completed export, got %+v", job)
+	}
+}
+
+func TestCreateExportFailed(t *testing.T) {
+	job := startExport(t, "user5_token")
+
+	job = waitForExport(t, job.ID, "user5_token")
+	if job.Status != export.StatusFailed || job.DownloadURL != "" || job.ExpiresAt != nil {
+		t.Errorf("expected a failed export without a download link, got %+v", job)
+	}
+}
+
+func TestGetExportNotFound(t *testing.T) {
+	job := waitForExport(t, startExport(t, "user5_token").ID, "user5_token")
+
+	tests := []struct {
+		name  string
+		id    string
+		token string
+	}{
+		{"another user's export", job.ID, "valid_token"},
+		{"unknown export", strings.Repeat("0", 32), "user5_token"},
+		{"invalid id", "..", "user5_token"},
+	}
+
+	for _, test := range tests {
+		resp := doRequest(t, "GET", "/user/me/export/"+test.id, test.token)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected %d, got %d", test.name, http.StatusNotFound, resp.StatusCode)
+		}
+	}
+
+	resp := doRequest(t, "GET", "/user/me/export/"+job.ID, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
+
+func TestDownloadExportInvalidLink(t *testing.T) {
+	first := waitForExport(t, startExport(t, "valid_token").ID, "valid_token")
+	second := waitForExport(t, startExport(t, "user5_token").ID, "user5_token")
+
+	// A link only downloads the export it was issued for
+	token := first.DownloadURL[strings.Index(first.DownloadURL, "token="):]
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"another export", "/user/me/export/" + second.ID + "/download?" + token},
+		{"no token", "/user/me/export/" + first.ID + "/download"},
+		{"invalid token", "/user/me/export/" + first.ID + "/download?token=invalid"},
+	}
+
+	for _, test := range tests {
+		resp := doRequest(t, "GET", test.path, "")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: expected %d, got %d", test.name, http.StatusForbidden, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package export_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"software-slayer/db"
+	"software-slayer/export"
+)
+
+func setup(t *testing.T) (sqlmock.Sqlmock, *export.ExportServiceImpl) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	return mock, export.NewExportService(db.NewDB(database))
+}
+
+var profileColumns = []string{"id", "username", "email", "first_name", "last_name", "role", "disabled_at", "email_verified_at",
+	"verification_sent_at", "pending_email", "delete_after", "profile_visibility", "learnings_visibility", "confirmed_at"}
+
+// ExportServiceImpl tests
+
+func TestCollectUserData_Success(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery("SELECT u.id, u.username, u.email").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(profileColumns).
+			AddRow(3, "gopher", "gopher@example.com", "Rob", "Pike", "user", nil, now, now, nil, nil, "public", "private", now))
+	dbMock.ExpectQuery("SELECT l.id, l.title, c.name, l.status, l.visibility").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "category", "status", "visibility", "created_at", "started_at", "completed_at", "tags"}).
+			AddRow(1, "Go Programming", "Languages", "completed", "private", now, now, now, "backend,go").
+			AddRow(2, "Docker", "Technologies", "planned", nil, now, nil, nil, ""))
+	dbMock.ExpectQuery("SELECT id, name, position FROM categories").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "position"}).AddRow(6, "Books", 5))
+	dbMock.ExpectQuery("SELECT id, name FROM tags").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "backend").AddRow(2, "go"))
+	dbMock.ExpectQuery("SELECT id, family_id, created_at, expires_at, revoked_at FROM refresh_tokens").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "family_id", "created_at", "expires_at", "revoked_at"}).
+			AddRow(4, "family", now, now.Add(time.Hour), nil))
+	dbMock.ExpectQuery("SELECT id, name, scopes, created_at, expires_at, last_used_at, revoked_at").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "scopes", "created_at", "expires_at", "last_used_at", "revoked_at"}))
+	dbMock.ExpectQuery("SELECT id, action, actor_id, subject, detail, created_at FROM audit_events").
+		WithArgs("user:3", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "action", "actor_id", "subject", "detail", "created_at"}).
+			AddRow(9, "login_lockout", nil, "user:3", "10 failed logins", now))
+	dbMock.ExpectCommit()
+
+	// Execute
+	data, err := service.CollectUserData(ctx, 3)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, "gopher", data.Profile.Username)
+	assert.Nil(t, data.Profile.PendingEmail)
+	assert.Equal(t, &now, data.Profile.TwoFactorEnabledAt)
+	assert.Len(t, data.Learnings, 2)
+	assert.Equal(t, []string{"backend", "go"}, data.Learnings[0].Tags)
+	assert.Equal(t, "private", *data.Learnings[0].Visibility)
+	assert.Equal(t, []string{}, data.Learnings[1].Tags)
+	assert.Nil(t, data.Learnings[1].Visibility)
+	assert.Len(t, data.Categories, 1)
+	assert.Len(t, data.Tags, 2)
+	assert.Len(t, data.Sessions, 1)
+	assert.NotNil(t, data.PersonalAccessTokens)
+	assert.Empty(t, data.PersonalAccessTokens)
+	assert.Len(t, data.AuditEvents, 1)
+	assert.Nil(t, data.AuditEvents[0].ActorID)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestCollectUserData_UserNotFound(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery("SELECT u.id, u.username, u.email").
+		WithArgs(999).
+		WillReturnRows(sqlmock.NewRows(profileColumns))
+	dbMock.ExpectRollback()
+
+	// Execute
+	_, err := service.CollectUserData(ctx, 999)
+
+	// Verify
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
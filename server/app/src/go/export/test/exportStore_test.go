@@ -0,0 +1,174 @@
+package export_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"software-slayer/export"
+)
+
+func TestLocalStoreJobs(t *testing.T) {
+	store := export.NewLocalStore(t.TempDir())
+	ctx := context.Background()
+
+	job, err := store.CreateJob(ctx, 3)
+	if err != nil {
+		t.Fatalf("CreateJob() returned error: %v", err)
+	}
+	if job.Status != export.StatusPending || job.UserID != 3 || len(job.ID) != 32 {
+		t.Errorf("CreateJob() = %+v", job)
+	}
+	if _, err := store.CreateJob(ctx, 4); err != nil {
+		t.Fatalf("CreateJob() returned error: %v", err)
+	}
+
+	job.Status = export.StatusRunning
+	if err := store.UpdateJob(ctx, job); err != nil {
+		t.Fatalf("UpdateJob() returned error: %v", err)
+	}
+	if stored, err := store.GetJob(ctx, job.ID); err != nil || stored.Status != export.StatusRunning {
+		t.Errorf("GetJob() = %+v, %v", stored, err)
+	}
+
+	jobs, err := store.GetJobsByUserId(ctx, 3)
+	if err != nil || len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Errorf("GetJobsByUserId() = %+v, %v", jobs, err)
+	}
+
+	// IDs that could escape the store's directory are never looked up
+	for _, id := range []string{"", "../secret", strings.ToUpper(job.ID), job.ID + ".json"} {
+		if _, err := store.GetJob(ctx, id); !errors.Is(err, export.ErrJobNotFound) {
+			t.Errorf("GetJob(%q) returned %v, expected ErrJobNotFound", id, err)
+		}
+	}
+}
+
+func TestLocalStoreArtifacts(t *testing.T) {
+	store := export.NewLocalStore(t.TempDir())
+	ctx := context.Background()
+
+	job, _ := store.CreateJob(ctx, 3)
+	if _, err := store.OpenArtifact(ctx, job.ID); !errors.Is(err, export.ErrJobNotFound) {
+		t.Errorf("OpenArtifact() before the artifact is written returned %v, expected ErrJobNotFound", err)
+	}
+
+	// A failed write leaves no artifact behind
+	err := store.WriteArtifact(ctx, job.ID, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errors.New("write failed")
+	})
+	if err == nil {
+		t.Error("WriteArtifact() did not return the error of write")
+	}
+	if _, err := store.OpenArtifact(ctx, job.ID); !errors.Is(err, export.ErrJobNotFound) {
+		t.Errorf("OpenArtifact() after a failed write returned %v, expected ErrJobNotFound", err)
+	}
+
+	if err := store.WriteArtifact(ctx, job.ID, func(w io.Writer) error {
+		_, err := io.WriteString(w, "archive")
+		return err
+	}); err != nil {
+		t.Fatalf("WriteArtifact() returned error: %v", err)
+	}
+
+	artifact, err := store.OpenArtifact(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("OpenArtifact() returned error: %v", err)
+	}
+	content, _ := io.ReadAll(artifact)
+	artifact.Close()
+	if string(content) != "archive" {
+		t.Errorf("OpenArtifact() content = %q, expected %q", content, "archive")
+	}
+}
+
+func TestLocalStorePrune(t *testing.T) {
+	store := export.NewLocalStore(t.TempDir())
+	ctx := context.Background()
+
+	job, _ := store.CreateJob(ctx, 3)
+	store.WriteArtifact(ctx, job.ID, func(w io.Writer) error {
+		_, err := io.WriteString(w, "archive")
+		return err
+	})
+
+	// Jobs created after the cutoff are kept
+	if err := store.Prune(ctx, job.CreatedAt.Add(-time.Hour)); err != nil {
+		t.Fatalf("Prune() returned error: %v", err)
+	}
+	if _, err := store.GetJob(ctx, job.ID); err != nil {
+		t.Errorf("GetJob() after pruning older jobs returned %v", err)
+	}
+
+	if err := store.Prune(ctx, job.CreatedAt.Add(time.Hour)); err != nil {
+		t.Fatalf("Prune() returned error: %v", err)
+	}
+	if _, err := store.GetJob(ctx, job.ID); !errors.Is(err, export.ErrJobNotFound) {
+		t.Errorf("GetJob() after pruning returned %v, expected ErrJobNotFound", err)
+	}
+	if _, err := store.OpenArtifact(ctx, job.ID); !errors.Is(err, export.ErrJobNotFound) {
+		t.Errorf("OpenArtifact() after pruning returned %v, expected ErrJobNotFound", err)
+	}
+
+	// A job pruned while it runs can't be brought back
+	job.Status = export.StatusCompleted
+	if err := store.UpdateJob(ctx, job); !errors.Is(err, export.ErrJobNotFound) {
+		t.Errorf("UpdateJob() after pruning returned %v, expected ErrJobNotFound", err)
+	}
+}
+
+func TestLocalStoreDeleteJobsByUserId(t *testing.T) {
+	store := export.NewLocalStore(t.TempDir())
+	ctx := context.Background()
+
+	job, _ := store.CreateJob(ctx, 3)
+	store.WriteArtifact(ctx, job.ID, func(w io.Writer) error {
+		_, err := io.WriteString(w, "archive")
+		return err
+	})
+	other, _ := store.CreateJob(ctx, 4)
+
+	if err := store.DeleteJobsByUserId(ctx, 3); err != nil {
+		t.Fatalf("DeleteJobsByUserId() returned error: %v", err)
+	}
+	if _, err := store.GetJob(ctx, job.ID); !errors.Is(err, export.ErrJobNotFound) {
+		t.Errorf("GetJob() after deleting returned %v, expected ErrJobNotFound", err)
+	}
+	if _, err := store.OpenArtifact(ctx, job.ID); !errors.Is(err, export.ErrJobNotFound) {
+		t.Errorf("OpenArtifact() after deleting returned %v, expected ErrJobNotFound", err)
+	}
+	// Other users' jobs are kept
+	if _, err := store.GetJob(ctx, other.ID); err != nil {
+		t.Errorf("GetJob() for another user returned %v", err)
+	}
+}
+
+func TestLocalStoreWriteArtifactOfDeletedJob(t *testing.T) {
+	dir := t.TempDir()
+	store := export.NewLocalStore(dir)
+	ctx := context.Background()
+
+	// The user is deleted while their export is being written
+	job, _ := store.CreateJob(ctx, 3)
+	err := store.WriteArtifact(ctx, job.ID, func(w io.Writer) error {
+		if err := store.DeleteJobsByUserId(ctx, 3); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, "archive")
+		return err
+	})
+	if !errors.Is(err, export.ErrJobNotFound) {
+		t.Errorf("WriteArtifact() of a deleted job returned %v, expected ErrJobNotFound", err)
+	}
+
+	// No archive is left behind without its job
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		t.Errorf("Unexpected file %s left after deleting the job", entry.Name())
+	}
+}
@@ -0,0 +1,264 @@
+package export_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"software-slayer/auth"
+	"software-slayer/learnings"
+	"software-slayer/utils"
+)
+
+// listSize is the number of learning items of user 1, more than fit on one page
+const listSize = 250
+
+// privateLearningId is the learning item of user 1 that is private although the list is public
+const privateLearningId = 7
+
+// slowPageDelay is how long user 8's pages take to load after the first
+const slowPageDelay = 100 * time.Millisecond
+
+type MockLearningsService struct{}
+
+func (m *MockLearningsService) CreateLearning(ctx context.Context, userId int, title string, category string, tags []string, visibility string) error {
+	return nil
+}
+
+func (m *MockLearningsService) ImportLearnings(ctx context.Context, userId int, requests []learnings.CreateLearningRequest, onDuplicate string, dryRun bool) (learnings.ImportResult, error) {
+	return learnings.ImportResult{}, nil
+}
+
+func (m *MockLearningsService) RunBatch(ctx context.Context, operations []learnings.BatchOperation, atomic bool) ([]learnings.BatchResult, error) {
+	return make([]learnings.BatchResult, len(operations)), nil
+}
+
+func (m *MockLearningsService) UpdateLearning(ctx context.Context, userId int, id int, update *learnings.UpdateLearningRequest) error {
+	return nil
+}
+
+func (m *MockLearningsService) UpdateLearningStatus(ctx context.Context, id int, status string) (string, error) {
+	return learnings.StatusPlanned, nil
+}
+
+func (m *MockLearningsService) DeleteLearning(ctx context.Context, id int) error {
+	return nil
+}
+
+// GetLearningsByUserId pages through the listSize items of a user, failing on the second page for user 6
+// and taking slowPageDelay for every page after the first for user 8
+func (m *MockLearningsService) GetLearningsByUserId(ctx context.Context, userID int, filter learnings.LearningFilter, page utils.PageRequest) (utils.Page[learnings.GetLearningResponse], error) {
+	if userID == 4 || (userID == 6 && page.After != nil) {
+		return utils.Page[learnings.GetLearningResponse]{}, errors.New("database unavailable")
+	}
+	if userID == 8 && page.After != nil {
+		time.Sleep(slowPageDelay)
+	}
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	private := auth.VisibilityPrivate
+	var items []learnings.GetLearningResponse
+	for id := 1; id <= listSize; id++ {
+		learning := learnings.GetLearningResponse{ID: id, Status: learnings.StatusPlanned, CreatedAt: createdAt, Tags: []string{"go"},
+			LearningBase: learnings.LearningBase{Title: fmt.Sprintf("Item %d", id), Category: learnings.Languages}}
+		if id == privateLearningId {
+			learning.Visibility = &private
+			if filter.Visibilities != nil && !slices.Contains(filter.Visibilities, auth.VisibilityPrivate) {
+				continue
+			}
+		}
+		if page.After == nil || id > page.After.ID {
+			items = append(items, learning)
+		}
+	}
+
+	return utils.NewPage(items[:min(len(items), page.Limit+1)], len(items), page, func(learning learnings.GetLearningResponse) (string, int) {
+		return learning.Category, learning.ID
+	}), nil
+}
+
+// GetLearningsVisibility reports user 3's list as private and user 999 as missing
+func (m *MockLearningsService) GetLearningsVisibility(ctx context.Context, userId int) (auth.Visibility, error) {
+	switch userId {
+	case 3:
+		return auth.VisibilityPrivate, nil
+	case 999:
+		return "", sql.ErrNoRows
+	}
+	return auth.VisibilityPublic, nil
+}
+
+func (m *MockLearningsService) GetUserByLearningId(ctx context.Context, learningId int) (int, error) {
+	return 1, nil
+}
+
+func (m *MockLearningsService) GetTagsByUserId(ctx context.Context, userId int) ([]learnings.TagCount, error) {
+	return nil, nil
+}
+
+func (m *MockLearningsService) GetCategories(ctx context.Context, userId int) ([]learnings.Category, error) {
+	return nil, nil
+}
+
+func (m *MockLearningsService) GetCategoryId(ctx context.Context, userId int, name string) (int, error) {
+	return 1, nil
+}
+
+func (m *MockLearningsService) GetUserByCategoryId(ctx context.Context, categoryId int) (int, error) {
+	return 1, nil
+}
+
+func (m *MockLearningsService) CreateCategory(ctx context.Context, userId int, name string) (int, error) {
+	return 1, nil
+}
+
+func (m *MockLearningsService) RenameCategory(ctx context.Context, categoryId int, name string) error {
+	return nil
+}
+
+func (m *MockLearningsService) ReorderCategories(ctx context.Context, userId int, categoryIds []int) error {
+	return nil
+}
+
+func (m *MockLearningsService) DeleteCategory(ctx context.Context, categoryId int) error {
+	return nil
+}
+
+func TestExportLearningList_CSV(t *testing.T) {
+	resp := doRequest(t, "GET", "/learning/1/export?format=csv", "")
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/csv; charset=utf-8" {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+	if disposition := resp.Header.Get("Content-Disposition"); disposition != `attachment; filename="learning-list-1.csv"` {
+		t.Errorf("unexpected content disposition %q", disposition)
+	}
+
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every page is exported, without the private item anonymous callers can't see
+	if len(records) != listSize {
+		t.Fatalf("expected header and %d items, got %d records", listSize-1, len(records))
+	}
+	if records[0][0] != "id" || records[1][1] != "Item 1" || records[len(records)-1][0] != fmt.Sprint(listSize) {
+		t.Errorf("unexpected records %v ... %v", records[:2], records[len(records)-1])
+	}
+	for _, record := range records {
+		if record[0] == fmt.Sprint(privateLearningId) {
+			t.Errorf("expected the private item to be left out")
+		}
+	}
+}
+
+func TestExportLearningList_OwnerSeesPrivateItems(t *testing.T) {
+	resp := doRequest(t, "GET", "/learning/1/export?format=json", "valid_token")
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var items []learnings.GetLearningResponse
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != listSize {
+		t.Fatalf("expected %d items, got %d", listSize, len(items))
+	}
+	if items[privateLearningId-1].Visibility == nil || *items[privateLearningId-1].Visibility != auth.VisibilityPrivate {
+		t.Errorf("expected the private item, got %+v", items[privateLearningId-1])
+	}
+}
+
+func TestExportLearningList_Markdown(t *testing.T) {
+	resp := doRequest(t, "GET", "/learning/1/export?format=md", "")
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if !strings.HasPrefix(string(body), "# Learning list\n\n## Languages\n\n- [ ] Item 1 `go`\n") {
+		t.Errorf("unexpected markdown %q", body[:min(len(body), 80)])
+	}
+}
+
+func TestExportLearningList_PrivateList(t *testing.T) {
+	resp := doRequest(t, "GET", "/learning/3/export?format=csv", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	resp = doRequest(t, "GET", "/learning/999/export?format=csv", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected %d for a missing user, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestExportLearningList_InvalidRequest(t *testing.T) {
+	for _, path := range []string{"/learning/abc/export?format=csv", "/learning/1/export", "/learning/1/export?format=pdf"} {
+		resp := doRequest(t, "GET", path, "")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", path, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
+
+func TestExportLearningList_OutlastsWriteTimeout(t *testing.T) {
+	// The second page is only written after the server's write timeout has passed
+	server := httptest.NewUnstartedServer(http.DefaultServeMux)
+	server.Config.WriteTimeout = slowPageDelay / 2
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/learning/8/export?format=csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("expected the whole download, got %v", err)
+	}
+	if len(records) != listSize {
+		t.Errorf("expected header and %d items, got %d records", listSize-1, len(records))
+	}
+}
+
+func TestExportLearningList_ServiceError(t *testing.T) {
+	resp := doRequest(t, "GET", "/learning/4/export?format=csv", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected %d, got %d", http.StatusInternalServerError, resp.StatusCode)
+	}
+
+	// Once streaming has started the download is cut short instead
+	resp = doRequest(t, "GET", "/learning/6/export?format=json", "")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Errorf("expected the download to be aborted")
+	}
+}
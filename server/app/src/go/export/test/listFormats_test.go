@@ -0,0 +1,138 @@
+package export_test
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"software-slayer/export"
+	"software-slayer/learnings"
+)
+
+// encodeList encodes learning items in a registered format
+func encodeList(t *testing.T, name string, items ...learnings.GetLearningResponse) string {
+	format, ok := export.LookupListFormat(name)
+	if !ok {
+		t.Fatalf("format %s is not registered", name)
+	}
+
+	var buf bytes.Buffer
+	encoder, err := format.NewEncoder(&buf, export.ListInfo{UserID: 1, ExportedAt: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		if err := encoder.Encode(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func sampleLearnings() []learnings.GetLearningResponse {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	completedAt := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	return []learnings.GetLearningResponse{
+		{ID: 1, Status: learnings.StatusCompleted, CreatedAt: createdAt, StartedAt: &createdAt, CompletedAt: &completedAt,
+			Tags: []string{"backend", "go"}, LearningBase: learnings.LearningBase{Title: "Go, the *fun* parts; really", Category: learnings.Languages}},
+		{ID: 2, Status: learnings.StatusInProgress, CreatedAt: createdAt, StartedAt: &createdAt,
+			LearningBase: learnings.LearningBase{Title: "Rust", Category: learnings.Languages}},
+		{ID: 3, Status: learnings.StatusAbandoned, CreatedAt: createdAt,
+			LearningBase: learnings.LearningBase{Title: "Docker", Category: learnings.Technologies}},
+	}
+}
+
+func TestListFormatNames(t *testing.T) {
+	names := export.ListFormatNames()
+	if !slices.IsSorted(names) {
+		t.Errorf("expected sorted names, got %v", names)
+	}
+	for _, name := range []string{"csv", "ics", "json", "md"} {
+		if !slices.Contains(names, name) {
+			t.Errorf("expected format %s in %v", name, names)
+		}
+	}
+}
+
+func TestRegisterListFormat(t *testing.T) {
+	export.RegisterListFormat("test", export.ListFormat{ContentType: "text/plain", Extension: "txt", Sort: "id",
+		NewEncoder: func(w io.Writer, list export.ListInfo) (export.ListEncoder, error) { return nil, nil }})
+
+	if format, ok := export.LookupListFormat("test"); !ok || format.Extension != "txt" {
+		t.Errorf("expected the registered format, got %+v", format)
+	}
+}
+
+func TestMarkdownListEncoder(t *testing.T) {
+	expected := "# Learning list\n\n" +
+		"## Languages\n\n" +
+		"- [x] Go, the \\*fun\\* parts; really `backend` `go`\n" +
+		"- [ ] Rust _(in progress)_\n\n" +
+		"## Technologies\n\n" +
+		"- [ ] ~~Docker~~\n"
+	if output := encodeList(t, "md", sampleLearnings()...); output != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+
+	if output := encodeList(t, "md"); output != "# Learning list\n\nNothing to learn yet.\n" {
+		t.Errorf("unexpected empty list %q", output)
+	}
+}
+
+func TestJSONListEncoder(t *testing.T) {
+	if output := encodeList(t, "json"); output != "[\n]\n" {
+		t.Errorf("unexpected empty list %q", output)
+	}
+}
+
+func TestCSVListEncoder(t *testing.T) {
+	lines := strings.Split(encodeList(t, "csv", sampleLearnings()[0]), "\n")
+	if lines[1] != `1,"Go, the *fun* parts; really",Languages,completed,,"backend,go",2024-01-02T03:04:05Z,2024-01-02T03:04:05Z,2024-03-04T05:06:07Z` {
+		t.Errorf("unexpected row %q", lines[1])
+	}
+}
+
+func TestICSListEncoder(t *testing.T) {
+	output := encodeList(t, "ics", sampleLearnings()...)
+
+	for _, line := range []string{
+		"BEGIN:VCALENDAR",
+		"UID:learning-1@software-slayer",
+		"DTSTAMP:20240601T120000Z",
+		"SUMMARY:Go\\, the *fun* parts\\; really",
+		"CATEGORIES:Languages,backend,go",
+		"STATUS:COMPLETED",
+		"COMPLETED:20240304T050607Z",
+		"STATUS:IN-PROCESS",
+		"STATUS:CANCELLED",
+		"END:VCALENDAR",
+	} {
+		if !strings.Contains(output, line+"\r\n") {
+			t.Errorf("expected line %q in %q", line, output)
+		}
+	}
+	if strings.Count(output, "BEGIN:VTODO") != 3 {
+		t.Errorf("expected 3 to-dos in %q", output)
+	}
+}
+
+func TestICSListEncoder_FoldsLongLines(t *testing.T) {
+	title := strings.Repeat("é", 100)
+	output := encodeList(t, "ics", learnings.GetLearningResponse{ID: 1, Status: learnings.StatusPlanned,
+		LearningBase: learnings.LearningBase{Title: title, Category: learnings.Languages}})
+
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+	}
+	if !strings.Contains(strings.ReplaceAll(output, "\r\n ", ""), "SUMMARY:"+title+"\r\n") {
+		t.Errorf("expected the folded summary to unfold to the title")
+	}
+}
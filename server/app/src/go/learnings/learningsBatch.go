@@ -0,0 +1,166 @@
+package learnings
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"software-slayer/auth"
+)
+
+// Operations accepted by POST /learning/batch
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+)
+
+// Modes of POST /learning/batch
+const (
+	// BatchModeAtomic runs every operation or none of them
+	BatchModeAtomic = "atomic"
+	// BatchModeBestEffort runs every operation it can, reporting those that failed
+	BatchModeBestEffort = "best_effort"
+)
+
+const maxBatchOperations = 100
+
+type BatchRequest struct {
+	// Mode is BatchModeAtomic when empty
+	Mode       string                  `json:"mode,omitempty"`
+	Operations []BatchOperationRequest `json:"operations"`
+}
+
+type BatchOperationRequest struct {
+	Op string `json:"op"`
+	// ID is the learning item to update or delete
+	ID int `json:"id,omitempty"`
+	// Item is a CreateLearningRequest for creates and an UpdateLearningRequest for updates
+	Item json.RawMessage `json:"item,omitempty" swaggertype:"object"`
+}
+
+// BatchOperationResult is the outcome of an operation, with the status code the single item endpoint would have answered
+type BatchOperationResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     int    `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Mode string `json:"mode"`
+	// Succeeded counts the operations that were saved, none when an atomic batch failed
+	Succeeded int                    `json:"succeeded"`
+	Failed    int                    `json:"failed"`
+	Results   []BatchOperationResult `json:"results"`
+}
+
+// BatchOperation is a validated operation of a batch, run for the owner of the learning item it applies to
+type BatchOperation struct {
+	Op     string
+	UserID int
+	ID     int
+	Create *CreateLearningRequest
+	Update *UpdateLearningRequest
+}
+
+// BatchResult is what running an operation of a batch did
+type BatchResult struct {
+	// ID is the learning item the operation applied to, the new item for creates
+	ID  int
+	Err error
+}
+
+// batchOperationError is why an operation of a batch was rejected, with the status code to report it with
+type batchOperationError struct {
+	status  int
+	message string
+}
+
+func (e *batchOperationError) Error() string {
+	return e.message
+}
+
+/*
+ * Validate an operation of a batch and check the caller may run it, as the single item endpoints do
+ * @param ctx: the request context
+ * @param principal: the caller
+ * @param request: the operation to validate
+ * @return BatchOperation: the operation to run
+ * @return *batchOperationError: why the operation is rejected, nil if it may be run
+ */
+func prepareBatchOperation(ctx context.Context, principal auth.Principal, request BatchOperationRequest) (BatchOperation, *batchOperationError) {
+	operation := BatchOperation{Op: request.Op, UserID: principal.UserID, ID: request.ID}
+
+	var action auth.Action
+	switch request.Op {
+	case BatchOpCreate:
+		var create CreateLearningRequest
+		if err := json.Unmarshal(request.Item, &create); err != nil {
+			return operation, &batchOperationError{http.StatusBadRequest, "Invalid item"}
+		}
+		if err := validateCreateLearningRequest(ctx, principal.UserID, create); err != nil {
+			return operation, &batchOperationError{http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error())}
+		}
+		operation.Create = &create
+		return operation, nil
+	case BatchOpUpdate:
+		var update UpdateLearningRequest
+		if err := json.Unmarshal(request.Item, &update); err != nil {
+			return operation, &batchOperationError{http.StatusBadRequest, "Invalid item"}
+		}
+		operation.Update = &update
+		action = auth.ActionUpdateLearning
+	case BatchOpDelete:
+		action = auth.ActionDeleteLearning
+	default:
+		return operation, &batchOperationError{http.StatusBadRequest, "Invalid op"}
+	}
+
+	learningItemUserId, err := learningsService.GetUserByLearningId(ctx, request.ID)
+	if err != nil {
+		return operation, &batchOperationError{http.StatusNotFound, "Learning item not found"}
+	}
+
+	// Moderators and admins may remove any learning item, admins may update any of them
+	if !auth.Can(principal, action, auth.Resource{OwnerID: learningItemUserId}) {
+		return operation, &batchOperationError{http.StatusUnauthorized, fmt.Sprintf("You don't have permission to %s this learning item", request.Op)}
+	}
+	operation.UserID = learningItemUserId
+
+	// Categories and tags are resolved for the owner, who is not the caller when an admin edits the item
+	if operation.Update != nil {
+		if err := validateUpdateLearningRequest(ctx, learningItemUserId, *operation.Update); err != nil {
+			return operation, &batchOperationError{http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error())}
+		}
+	}
+	return operation, nil
+}
+
+/*
+ * Report the outcome of an operation that was run
+ * @param op: the operation
+ * @param result: the outcome of the operation
+ * @return int: the status code the single item endpoint would have answered
+ * @return string: the error message, empty on success
+ */
+func batchResultStatus(op string, result BatchResult) (int, string) {
+	switch {
+	case result.Err == nil && op == BatchOpCreate:
+		return http.StatusCreated, ""
+	case result.Err == nil && op == BatchOpDelete:
+		return http.StatusNoContent, ""
+	case result.Err == nil:
+		return http.StatusOK, ""
+	case errors.Is(result.Err, sql.ErrNoRows):
+		return http.StatusNotFound, "Learning item not found"
+	case strings.Contains(result.Err.Error(), "Duplicate entry"):
+		return http.StatusConflict, "This learning item already exists for your account"
+	}
+	return http.StatusInternalServerError, fmt.Sprintf("Failed to %s learning item", op)
+}
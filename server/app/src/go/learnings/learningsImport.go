@@ -0,0 +1,204 @@
+package learnings
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+)
+
+// Formats accepted by POST /learning/import
+const (
+	ImportFormatCSV       = "csv"
+	ImportFormatJSON      = "json"
+	ImportFormatBookmarks = "bookmarks"
+)
+
+// What an import does with items that already exist with the same title and category
+const (
+	OnDuplicateSkip   = "skip"
+	OnDuplicateUpdate = "update"
+)
+
+const (
+	maxImportRows  = 500
+	maxImportBytes = 1 << 20
+)
+
+// bookmarkPattern matches the links of a Netscape bookmark file, capturing their attributes and title
+var bookmarkPattern = regexp.MustCompile(`(?is)<a\s([^>]*)>(.*?)</a>`)
+var bookmarkTagsPattern = regexp.MustCompile(`(?i)\btags\s*=\s*"([^"]*)"`)
+var bookmarkHrefPattern = regexp.MustCompile(`(?i)\bhref\s*=\s*"([^"]*)"`)
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// ImportResult counts what an import did with the valid rows
+type ImportResult struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Skipped  int `json:"skipped"`
+}
+
+// ImportRowError is why a row of an import was rejected, rows are numbered from 1 not counting a CSV header
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Title string `json:"title,omitempty"`
+	Error string `json:"error"`
+}
+
+type ImportResponse struct {
+	// DryRun is true when nothing was saved, the counts are what the import would have done
+	DryRun bool             `json:"dry_run"`
+	Rows   int              `json:"rows"`
+	Errors []ImportRowError `json:"errors"`
+	ImportResult
+}
+
+// importRow is a learning item read from an import file
+type importRow struct {
+	row     int
+	request CreateLearningRequest
+}
+
+/*
+ * Get the import format of a request body from its content type
+ * @param contentType: the Content-Type header
+ * @return string: the format, or an empty string if the content type is not one of them
+ */
+func importFormat(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return ImportFormatCSV
+	case "application/json":
+		return ImportFormatJSON
+	case "text/html":
+		return ImportFormatBookmarks
+	}
+	return ""
+}
+
+/*
+ * Read the learning items of an import file
+ * @param format: one of the ImportFormat constants
+ * @param body: the file
+ * @param category: the category of bookmarks, which have none of their own
+ * @return []importRow: the learning items in the order they appear
+ * @return error: an error if the file could not be read, naming what is wrong with it
+ */
+func parseImport(format string, body io.Reader, category string) ([]importRow, error) {
+	switch format {
+	case ImportFormatCSV:
+		return parseImportCSV(body)
+	case ImportFormatJSON:
+		return parseImportJSON(body)
+	case ImportFormatBookmarks:
+		return parseImportBookmarks(body, category)
+	}
+	return nil, errors.New("format")
+}
+
+// parseImportCSV reads a CSV file with a header naming its title, category, tags and visibility columns, other columns are ignored
+func parseImportCSV(body io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("CSV header")
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("CSV header: no title column")
+	}
+	if _, ok := columns["category"]; !ok {
+		return nil, errors.New("CSV header: no category column")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	rows := make([]importRow, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV row %d", len(rows)+1)
+		}
+
+		request := CreateLearningRequest{
+			Visibility:   field(record, "visibility"),
+			LearningBase: LearningBase{Title: field(record, "title"), Category: field(record, "category")},
+		}
+		// Tags are separated by commas within their cell, as in the learnings.csv of a data export
+		if tags := field(record, "tags"); tags != "" {
+			request.Tags = strings.Split(tags, ",")
+		}
+		rows = append(rows, importRow{row: len(rows) + 1, request: request})
+	}
+}
+
+// parseImportJSON reads a JSON array of learning items shaped like the body of POST /learning
+func parseImportJSON(body io.Reader) ([]importRow, error) {
+	var requests []CreateLearningRequest
+	if err := json.NewDecoder(body).Decode(&requests); err != nil {
+		return nil, errors.New("JSON: expected an array of learning items")
+	}
+
+	rows := make([]importRow, len(requests))
+	for i, request := range requests {
+		rows[i] = importRow{row: i + 1, request: request}
+	}
+	return rows, nil
+}
+
+// parseImportBookmarks reads the links of a Netscape bookmark file as exported by browsers, with their TAGS if they have any
+func parseImportBookmarks(body io.Reader, category string) ([]importRow, error) {
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(strings.ToUpper(string(content)), "NETSCAPE-BOOKMARK-FILE") {
+		return nil, errors.New("bookmarks: not a Netscape bookmark file")
+	}
+
+	rows := make([]importRow, 0)
+	for _, match := range bookmarkPattern.FindAllStringSubmatch(string(content), -1) {
+		attributes, title := match[1], match[2]
+		// Browsers keep smart folders and separators as place: links, which aren't bookmarks
+		if href := bookmarkHrefPattern.FindStringSubmatch(attributes); href == nil || strings.HasPrefix(href[1], "place:") {
+			continue
+		}
+
+		request := CreateLearningRequest{
+			LearningBase: LearningBase{
+				Title:    strings.TrimSpace(html.UnescapeString(htmlTagPattern.ReplaceAllString(title, ""))),
+				Category: category,
+			},
+		}
+		if tags := bookmarkTagsPattern.FindStringSubmatch(attributes); tags != nil && tags[1] != "" {
+			request.Tags = strings.Split(html.UnescapeString(tags[1]), ",")
+		}
+		rows = append(rows, importRow{row: len(rows) + 1, request: request})
+	}
+	return rows, nil
+}
+
+// importKey identifies a learning item by the (user_id, title, category) unique key, which is compared case-insensitively
+func importKey(request CreateLearningRequest) string {
+	return strings.ToLower(request.Title) + "\x00" + strings.ToLower(request.Category)
+}
@@ -1,10 +1,15 @@
 package learnings
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -37,13 +42,9 @@ func createLearningItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId, err := tokenService.AuthorizeUser(r.Header.Get("Authorization"))
-	if err != nil {
-		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or missing authentication token")
-		return
-	}
+	userId, _ := auth.UserIDFromContext(ctx)
 
-	if err := validateCreateLearningRequest(createLearningRequest); err != nil {
+	if err := validateCreateLearningRequest(ctx, userId, createLearningRequest); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
 		return
 	}
@@ -51,7 +52,8 @@ func createLearningItem(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Creating learning item '%s' in category '%s' for user ID: %d",
 		createLearningRequest.Title, createLearningRequest.Category, userId)
 
-	err = learningsService.CreateLearning(ctx, userId, createLearningRequest.Title, createLearningRequest.Category)
+	err := learningsService.CreateLearning(ctx, userId, createLearningRequest.Title, createLearningRequest.Category,
+		createLearningRequest.Tags, createLearningRequest.Visibility)
 	if err != nil {
 		if strings.Contains(err.Error(), "Duplicate entry") {
 			utils.RespondWithError(w, http.StatusConflict, "This learning item already exists for your account")
@@ -64,8 +66,332 @@ func createLearningItem(w http.ResponseWriter, r *http.Request) {
 	utils.RespondWithJSON(w, http.StatusCreated, map[string]string{"message": "Learning item created successfully"})
 }
 
+// @Summary Import learning items
+// @Description Import learning items from a CSV file with title, category, tags and visibility columns, a JSON array of learning items, or a Netscape bookmark file exported by a browser. Each row is validated like a new learning item; invalid rows are reported and the valid rows are imported in a single transaction. Items that already exist with the same title and category are skipped, or have their visibility and tags replaced with on_duplicate=update.
+// @Tags Learning Items
+// @Accept text/csv,application/json,text/html
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param format query string false "Format of the file, taken from the Content-Type when not set" Enums(csv, json, bookmarks)
+// @Param on_duplicate query string false "What to do with items that already exist (default skip)" Enums(skip, update)
+// @Param dry_run query bool false "Validate the file and report what would be imported without saving anything"
+// @Param category query string false "Category of imported bookmarks (default Other)"
+// @Param file body string true "File to import"
+// @Success 200 {object} ImportResponse "Import report"
+// @Failure 400 {object} utils.ErrorResponse "Invalid parameters or file"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 413 {object} utils.ErrorResponse "File too large"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /learning/import [post]
+func importLearningItems(w http.ResponseWriter, r *http.Request) {
+	// Every row is validated against the user's categories, which takes longer than a single item
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = importFormat(r.Header.Get("Content-Type"))
+	}
+
+	onDuplicate := query.Get("on_duplicate")
+	if onDuplicate == "" {
+		onDuplicate = OnDuplicateSkip
+	}
+	if onDuplicate != OnDuplicateSkip && onDuplicate != OnDuplicateUpdate {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid on_duplicate parameter")
+		return
+	}
+
+	dryRun := false
+	if dryRunVal := query.Get("dry_run"); dryRunVal != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(dryRunVal); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid dry_run parameter")
+			return
+		}
+	}
+
+	category := query.Get("category")
+	if category == "" {
+		category = Other
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Import files can be at most %d bytes", maxImportBytes))
+		return
+	}
+
+	rows, err := parseImport(format, bytes.NewReader(body), category)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+	if len(rows) == 0 || len(rows) > maxImportRows {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Import files must have between 1 and %d learning items", maxImportRows))
+		return
+	}
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	response := ImportResponse{DryRun: dryRun, Rows: len(rows), Errors: make([]ImportRowError, 0)}
+	requests := make([]CreateLearningRequest, 0, len(rows))
+	firstRows := make(map[string]int, len(rows))
+	for _, row := range rows {
+		if err := validateCreateLearningRequest(ctx, userId, row.request); err != nil {
+			response.Errors = append(response.Errors, ImportRowError{Row: row.row, Title: row.request.Title, Error: fmt.Sprintf("Invalid %s", err.Error())})
+			continue
+		}
+		// A file can't insert an item and then update it, so only the first of its duplicates is imported
+		if first, ok := firstRows[importKey(row.request)]; ok {
+			response.Errors = append(response.Errors, ImportRowError{Row: row.row, Title: row.request.Title, Error: fmt.Sprintf("Duplicate of row %d", first)})
+			continue
+		}
+		firstRows[importKey(row.request)] = row.row
+		requests = append(requests, row.request)
+	}
+
+	if len(requests) > 0 {
+		response.ImportResult, err = learningsService.ImportLearnings(ctx, userId, requests, onDuplicate, dryRun)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to import learning items")
+			return
+		}
+	}
+
+	log.Printf("Imported %d rows of %s for user ID %d (dry run: %t): %d inserted, %d updated, %d skipped, %d invalid",
+		len(rows), format, userId, dryRun, response.Inserted, response.Updated, response.Skipped, len(response.Errors))
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// @Summary Create, update and delete learning items in a batch
+// @Description Run up to 100 create, update and delete operations in one request. Creates take a learning item like POST /learning, updates the ID of a learning item and the fields to change like PATCH /learning/{id}, deletes the ID of a learning item; updates and deletes are subject to the same permission checks as the single item endpoints. In atomic mode (the default) the batch runs in a single transaction, so if any operation fails nothing is saved and the other operations are reported with status 424. In best_effort mode each operation is saved on its own and the failing ones are reported. Each result carries the status code the single item endpoint would have answered.
+// @Tags Learning Items
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param batch body BatchRequest true "Operations to run"
+// @Success 200 {object} BatchResponse "Outcome of each operation"
+// @Failure 400 {object} utils.ErrorResponse "Invalid mode or number of operations"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /learning/batch [post]
+func batchLearningItems(w http.ResponseWriter, r *http.Request) {
+	// Every operation is validated and checked on its own, which takes longer than a single item
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	var batchRequest BatchRequest
+	if err := utils.Decode(w, r, &batchRequest); err != nil {
+		return
+	}
+
+	if batchRequest.Mode == "" {
+		batchRequest.Mode = BatchModeAtomic
+	}
+	if batchRequest.Mode != BatchModeAtomic && batchRequest.Mode != BatchModeBestEffort {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid mode")
+		return
+	}
+	if len(batchRequest.Operations) == 0 || len(batchRequest.Operations) > maxBatchOperations {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Batches must have between 1 and %d operations", maxBatchOperations))
+		return
+	}
+	atomic := batchRequest.Mode == BatchModeAtomic
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	response := BatchResponse{Mode: batchRequest.Mode, Results: make([]BatchOperationResult, len(batchRequest.Operations))}
+	operations := make([]BatchOperation, 0, len(batchRequest.Operations))
+	// indexes maps the operations to run back to their position in the batch
+	indexes := make([]int, 0, len(batchRequest.Operations))
+	for i, request := range batchRequest.Operations {
+		response.Results[i] = BatchOperationResult{Index: i, Op: request.Op, ID: request.ID}
+
+		operation, rejected := prepareBatchOperation(ctx, principal, request)
+		if rejected != nil {
+			response.Results[i].Status, response.Results[i].Error = rejected.status, rejected.message
+			continue
+		}
+		operations = append(operations, operation)
+		indexes = append(indexes, i)
+	}
+
+	// An atomic batch with a rejected operation is not started
+	if len(operations) > 0 && (!atomic || len(operations) == len(batchRequest.Operations)) {
+		log.Printf("Running batch of %d learning item operations in %s mode for user ID: %d", len(operations), batchRequest.Mode, principal.UserID)
+
+		results, err := learningsService.RunBatch(ctx, operations, atomic)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to run batch")
+			return
+		}
+		// The operations of a failed atomic batch were rolled back, only the failing one is reported with its own status
+		rolledBack := atomic && slices.ContainsFunc(results, func(result BatchResult) bool { return result.Err != nil })
+		for j, result := range results {
+			if rolledBack && result.Err == nil {
+				continue
+			}
+			i := indexes[j]
+			response.Results[i].Status, response.Results[i].Error = batchResultStatus(operations[j].Op, result)
+			if result.ID != 0 {
+				response.Results[i].ID = result.ID
+			}
+		}
+	}
+
+	for i := range response.Results {
+		result := &response.Results[i]
+		if result.Status == 0 {
+			result.Status, result.Error = http.StatusFailedDependency, "Not saved, another operation of the batch failed"
+		}
+		if result.Status < http.StatusBadRequest {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+
+	log.Printf("Ran batch of %d learning item operations in %s mode for user ID %d: %d succeeded, %d failed",
+		len(batchRequest.Operations), batchRequest.Mode, principal.UserID, response.Succeeded, response.Failed)
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// @Summary Update a learning item
+// @Description Update the title, category, tags and/or visibility of a learning item owned by the user. Admins can update any learning item.
+// @Tags Learning Items
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "ID of the learning item to update"
+// @Param learning_item body UpdateLearningRequest true "Fields to update"
+// @Success 200 {object} map[string]string "Learning item updated"
+// @Failure 400 {object} utils.ErrorResponse "Invalid ID or learning item data"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 404 {object} utils.ErrorResponse "Learning item not found"
+// @Failure 409 {object} utils.ErrorResponse "Learning item already exists"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /learning/{id} [patch]
+func updateLearningItem(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	learningId, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/learning/"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid learning item ID")
+		return
+	}
+
+	var updateLearningRequest UpdateLearningRequest
+	if err := utils.Decode(w, r, &updateLearningRequest); err != nil {
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	learningItemUserId, err := learningsService.GetUserByLearningId(ctx, learningId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Learning item not found")
+		return
+	}
+
+	if !auth.Can(principal, auth.ActionUpdateLearning, auth.Resource{OwnerID: learningItemUserId}) {
+		utils.RespondWithError(w, http.StatusUnauthorized, "You don't have permission to update this learning item")
+		return
+	}
+
+	// Categories and tags are resolved for the owner, who is not the caller when an admin edits the item
+	if err := validateUpdateLearningRequest(ctx, learningItemUserId, updateLearningRequest); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+
+	log.Printf("Updating learning item ID: %d of user ID: %d for user ID: %d", learningId, learningItemUserId, principal.UserID)
+
+	err = learningsService.UpdateLearning(ctx, learningItemUserId, learningId, &updateLearningRequest)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			utils.RespondWithError(w, http.StatusConflict, "This learning item already exists for your account")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update learning item")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Learning item updated successfully"})
+}
+
+// @Summary Update the status of a learning item
+// @Description Move a learning item to a new status (planned, in_progress, completed, abandoned)
+// @Tags Learning Items
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "ID of the learning item to update"
+// @Param status body UpdateLearningStatusRequest true "New status"
+// @Success 200 {object} map[string]string "Learning item status updated"
+// @Failure 400 {object} utils.ErrorResponse "Invalid ID or status"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 404 {object} utils.ErrorResponse "Learning item not found"
+// @Failure 409 {object} utils.ErrorResponse "Status transition not allowed"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /learning/{id}/status [put]
+func updateLearningItemStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	learningId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid learning item ID")
+		return
+	}
+
+	var updateStatusRequest UpdateLearningStatusRequest
+	if err := utils.Decode(w, r, &updateStatusRequest); err != nil {
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	if err := validateStatus(updateStatusRequest.Status); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+
+	learningItemUserId, err := learningsService.GetUserByLearningId(ctx, learningId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Learning item not found")
+		return
+	}
+
+	if !auth.Can(principal, auth.ActionUpdateLearning, auth.Resource{OwnerID: learningItemUserId}) {
+		utils.RespondWithError(w, http.StatusUnauthorized, "You don't have permission to update this learning item")
+		return
+	}
+
+	previousStatus, err := learningsService.UpdateLearningStatus(ctx, learningId, updateStatusRequest.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Learning item not found")
+		return
+	}
+	if errors.Is(err, ErrStatusTransition) {
+		utils.RespondWithError(w, http.StatusConflict, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update learning item status")
+		return
+	}
+
+	log.Printf("Moved learning item ID: %d from '%s' to '%s' for user ID: %d",
+		learningId, previousStatus, updateStatusRequest.Status, principal.UserID)
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Learning item status updated successfully"})
+}
+
 // @Summary Delete a learning item
-// @Description Delete a learning item for a user
+// @Description Delete a learning item owned by the user. Moderators and admins can delete any learning item.
 // @Tags Learning Items
 // @Accept json
 // @Produce json
@@ -87,11 +413,7 @@ func deleteLearningItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId, err := tokenService.AuthorizeUser(r.Header.Get("Authorization"))
-	if err != nil {
-		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or missing authentication token")
-		return
-	}
+	principal, _ := auth.PrincipalFromContext(ctx)
 
 	learningItemUserId, err := learningsService.GetUserByLearningId(ctx, learningId)
 	if err != nil {
@@ -99,12 +421,13 @@ func deleteLearningItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if userId != learningItemUserId {
+	// Moderators and admins may remove any learning item
+	if !auth.Can(principal, auth.ActionDeleteLearning, auth.Resource{OwnerID: learningItemUserId}) {
 		utils.RespondWithError(w, http.StatusUnauthorized, "You don't have permission to delete this learning item")
 		return
 	}
 
-	log.Printf("Deleting learning item ID: %d for user ID: %d", learningId, userId)
+	log.Printf("Deleting learning item ID: %d of user ID: %d for user ID: %d", learningId, learningItemUserId, principal.UserID)
 
 	err = learningsService.DeleteLearning(ctx, learningId)
 	if err != nil {
@@ -116,11 +439,19 @@ func deleteLearningItem(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Get learning items by user id
-// @Description Get all learning items for a user
+// @Description Get the learning items of a user that the caller may see. Learning lists and items are public, visible to signed in users (organization) or private to their owner; moderators and admins see everything. Lists the caller may not see are reported as not found.
 // @Tags Learning Items
 // @Produce json
+// @Param Authorization header string false "Bearer token"
 // @Param user_id path int true "User ID"
-// @Success 200 {array} GetLearningResponse
+// @Param status query string false "Only return learning items with this status"
+// @Param tags query string false "Comma-separated list of tags to filter by"
+// @Param tags_match query string false "Whether items must match any (default) or all of the tags" Enums(any, all)
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query int false "Maximum number of learning items to return (1-100, default 20)"
+// @Param sort query string false "Field to sort by" Enums(id, title, category, status, created_at)
+// @Param order query string false "Sort direction" Enums(asc, desc)
+// @Success 200 {object} utils.Page[GetLearningResponse]
 // @Failure 400 {object} utils.ErrorResponse "Invalid user ID"
 // @Failure 404 {object} utils.ErrorResponse "User not found"
 // @Failure 500 {object} utils.ErrorResponse "Server error"
@@ -135,26 +466,369 @@ func getLearningItemsByUserId(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	filter, err := parseLearningFilter(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s parameter", err.Error()))
+		return
+	}
+
+	page, err := utils.ParsePageRequest(r, learningSortColumns, "id")
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s parameter", err.Error()))
+		return
+	}
+
+	// Hidden lists are indistinguishable from missing users, so their existence isn't revealed
+	listVisibility, err := learningsService.GetLearningsVisibility(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve learning items")
+		return
+	}
+
+	// Anonymous callers have the zero principal, which only sees public lists
+	principal, _ := auth.PrincipalFromContext(ctx)
+	owner := auth.Resource{OwnerID: userID}
+	if !auth.CanView(principal, listVisibility, owner) {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	filter.Visibilities = auth.VisibleTo(principal, owner)
+
 	log.Printf("Fetching learning items for user ID: %d", userID)
 
-	learningItems, err := learningsService.GetLearningsByUserId(ctx, userID)
+	learningItems, err := learningsService.GetLearningsByUserId(ctx, userID, filter, page)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve learning items")
 		return
 	}
 
-	log.Printf("Found %d learning items for user ID: %d", len(learningItems), userID)
+	log.Printf("Found %d of %d learning items for user ID: %d", len(learningItems.Items), learningItems.Total, userID)
 	utils.RespondWithJSON(w, http.StatusOK, learningItems)
 }
 
-// @Summary Get learning item categories
-// @Description Get all learning item categories
+/*
+ * parseLearningFilter reads the learning item filters from the query string
+ * @param r: the request
+ * @return LearningFilter: the parsed filter
+ * @return error: an error naming the invalid parameter
+ */
+func parseLearningFilter(r *http.Request) (LearningFilter, error) {
+	query := r.URL.Query()
+	filter := LearningFilter{Status: query.Get("status")}
+	if filter.Status != "" {
+		if err := validateStatus(filter.Status); err != nil {
+			return filter, err
+		}
+	}
+
+	if tags := query.Get("tags"); tags != "" {
+		filter.Tags = normalizeTags(strings.Split(tags, ","))
+		if err := validateTags(filter.Tags); err != nil {
+			return filter, errors.New("tags")
+		}
+	}
+
+	switch query.Get("tags_match") {
+	case "", "any":
+		filter.MatchAllTags = false
+	case "all":
+		filter.MatchAllTags = true
+	default:
+		return filter, errors.New("tags_match")
+	}
+
+	return filter, nil
+}
+
+// @Summary Get the current user's tags
+// @Description Get all tags used by the current user along with the number of learning items carrying each
 // @Tags Learning Items
 // @Produce json
-// @Success 200 {array} string
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {array} TagCount
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /learning/tags [get]
+func getLearningItemTags(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	tags, err := learningsService.GetTagsByUserId(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve tags")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, tags)
+}
+
+// @Summary Get learning item categories
+// @Description Get the default learning item categories followed by the current user's own categories
+// @Tags Learning Categories
+// @Produce json
+// @Param Authorization header string false "Bearer token"
+// @Success 200 {array} Category
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
 // @Router /learning/categories [get]
 func getLearningItemCategories(w http.ResponseWriter, r *http.Request) {
-	utils.RespondWithJSON(w, http.StatusOK, categoriesList)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// Anonymous callers have user ID 0, so they only see the default categories
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	categories, err := learningsService.GetCategories(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve categories")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, categories)
+}
+
+// @Summary Create a learning item category
+// @Description Create a new category for the current user's learning items
+// @Tags Learning Categories
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param category body CategoryRequest true "Category to create"
+// @Success 201 {object} Category
+// @Failure 400 {object} utils.ErrorResponse "Invalid category name"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 409 {object} utils.ErrorResponse "Category already exists"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /learning/categories [post]
+func createCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var categoryRequest CategoryRequest
+	if err := utils.Decode(w, r, &categoryRequest); err != nil {
+		return
+	}
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	if err := validateCategoryName(categoryRequest.Name); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+
+	_, err := learningsService.GetCategoryId(ctx, userId, categoryRequest.Name)
+	if err == nil {
+		utils.RespondWithError(w, http.StatusConflict, "A category with this name already exists")
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create category")
+		return
+	}
+
+	log.Printf("Creating category '%s' for user ID: %d", categoryRequest.Name, userId)
+
+	categoryId, err := learningsService.CreateCategory(ctx, userId, categoryRequest.Name)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			utils.RespondWithError(w, http.StatusConflict, "A category with this name already exists")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create category")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, Category{ID: categoryId, Name: categoryRequest.Name, Custom: true})
+}
+
+// @Summary Rename a learning item category
+// @Description Rename one of the current user's categories
+// @Tags Learning Categories
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "ID of the category to rename"
+// @Param category body CategoryRequest true "New category name"
+// @Success 200 {object} map[string]string "Category renamed"
+// @Failure 400 {object} utils.ErrorResponse "Invalid ID or category name"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 404 {object} utils.ErrorResponse "Category not found"
+// @Failure 409 {object} utils.ErrorResponse "Category already exists"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /learning/categories/{id} [patch]
+func renameCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	categoryId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	var categoryRequest CategoryRequest
+	if err := utils.Decode(w, r, &categoryRequest); err != nil {
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+	userId := principal.UserID
+
+	if err := validateCategoryName(categoryRequest.Name); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+
+	categoryUserId, err := learningsService.GetUserByCategoryId(ctx, categoryId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	if !auth.Can(principal, auth.ActionManageCategory, auth.Resource{OwnerID: categoryUserId}) {
+		utils.RespondWithError(w, http.StatusUnauthorized, "You don't have permission to modify this category")
+		return
+	}
+
+	existingId, err := learningsService.GetCategoryId(ctx, userId, categoryRequest.Name)
+	if err == nil && existingId != categoryId {
+		utils.RespondWithError(w, http.StatusConflict, "A category with this name already exists")
+		return
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to rename category")
+		return
+	}
+
+	log.Printf("Renaming category ID: %d to '%s' for user ID: %d", categoryId, categoryRequest.Name, userId)
+
+	err = learningsService.RenameCategory(ctx, categoryId, categoryRequest.Name)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			utils.RespondWithError(w, http.StatusConflict, "A category with this name already exists")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to rename category")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Category renamed successfully"})
+}
+
+// @Summary Reorder learning item categories
+// @Description Set the display order of the current user's categories
+// @Tags Learning Categories
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param order body ReorderCategoriesRequest true "IDs of all of the user's categories in their new order"
+// @Success 200 {object} map[string]string "Categories reordered"
+// @Failure 400 {object} utils.ErrorResponse "Invalid category IDs"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /learning/categories/order [put]
+func reorderCategories(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var reorderRequest ReorderCategoriesRequest
+	if err := utils.Decode(w, r, &reorderRequest); err != nil {
+		return
+	}
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	categories, err := learningsService.GetCategories(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve categories")
+		return
+	}
+
+	// The new order must name every one of the user's own categories exactly once
+	remaining := make(map[int]struct{})
+	for _, category := range categories {
+		if category.Custom {
+			remaining[category.ID] = struct{}{}
+		}
+	}
+	for _, categoryId := range reorderRequest.IDs {
+		if _, ok := remaining[categoryId]; !ok {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid ids: each of your categories must be listed exactly once")
+			return
+		}
+		delete(remaining, categoryId)
+	}
+	if len(remaining) > 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid ids: each of your categories must be listed exactly once")
+		return
+	}
+
+	log.Printf("Reordering %d categories for user ID: %d", len(reorderRequest.IDs), userId)
+
+	if err := learningsService.ReorderCategories(ctx, userId, reorderRequest.IDs); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reorder categories")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Categories reordered successfully"})
+}
+
+// @Summary Delete a learning item category
+// @Description Delete one of the current user's categories that is no longer used by any learning item
+// @Tags Learning Categories
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "ID of the category to delete"
+// @Success 204 "No Content"
+// @Failure 400 {object} utils.ErrorResponse "Invalid ID"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 404 {object} utils.ErrorResponse "Category not found"
+// @Failure 409 {object} utils.ErrorResponse "Category still in use"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /learning/categories/{id} [delete]
+func deleteCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	categoryId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+	userId := principal.UserID
+
+	categoryUserId, err := learningsService.GetUserByCategoryId(ctx, categoryId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	if !auth.Can(principal, auth.ActionManageCategory, auth.Resource{OwnerID: categoryUserId}) {
+		utils.RespondWithError(w, http.StatusUnauthorized, "You don't have permission to delete this category")
+		return
+	}
+
+	log.Printf("Deleting category ID: %d for user ID: %d", categoryId, userId)
+
+	err = learningsService.DeleteCategory(ctx, categoryId)
+	if err != nil {
+		if strings.Contains(err.Error(), "foreign key constraint") {
+			utils.RespondWithError(w, http.StatusConflict, "This category is still used by learning items")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete category")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusNoContent, nil)
 }
 
 // InitLearningsRest initializes the learning REST endpoints
@@ -162,10 +836,21 @@ func InitLearningsRest(_learningsService LearningsService, _tokenService auth.To
 	learningsService = _learningsService
 	tokenService = _tokenService
 
-	http.HandleFunc("GET /learning/", getLearningItemsByUserId)
-	http.HandleFunc("GET /learning/categories", getLearningItemCategories)
-	http.HandleFunc("POST /learning", createLearningItem)
-	http.HandleFunc("DELETE /learning/", deleteLearningItem)
+	auth.RegisterRoutes(tokenService, []auth.Route{
+		{Pattern: "GET /learning/", Access: auth.OptionalAuthentication, Scope: auth.ScopeReadLearnings, Handler: getLearningItemsByUserId},
+		{Pattern: "GET /learning/categories", Access: auth.OptionalAuthentication, Scope: auth.ScopeReadLearnings, Handler: getLearningItemCategories},
+		{Pattern: "POST /learning/categories", Access: auth.Authenticated, Scope: auth.ScopeWriteLearnings, Handler: createCategory},
+		{Pattern: "PUT /learning/categories/order", Access: auth.Authenticated, Scope: auth.ScopeWriteLearnings, Handler: reorderCategories},
+		{Pattern: "PATCH /learning/categories/{id}", Access: auth.Authenticated, Scope: auth.ScopeWriteLearnings, Handler: renameCategory},
+		{Pattern: "DELETE /learning/categories/{id}", Access: auth.Authenticated, Scope: auth.ScopeWriteLearnings, Handler: deleteCategory},
+		{Pattern: "GET /learning/tags", Access: auth.Authenticated, Scope: auth.ScopeReadLearnings, Handler: getLearningItemTags},
+		{Pattern: "POST /learning", Access: auth.Authenticated, Scope: auth.ScopeWriteLearnings, Handler: createLearningItem},
+		{Pattern: "POST /learning/import", Access: auth.Authenticated, Scope: auth.ScopeWriteLearnings, Handler: importLearningItems},
+		{Pattern: "POST /learning/batch", Access: auth.Authenticated, Scope: auth.ScopeWriteLearnings, Handler: batchLearningItems},
+		{Pattern: "PATCH /learning/", Access: auth.Authenticated, Scope: auth.ScopeWriteLearnings, Handler: updateLearningItem},
+		{Pattern: "PUT /learning/{id}/status", Access: auth.Authenticated, Scope: auth.ScopeWriteLearnings, Handler: updateLearningItemStatus},
+		{Pattern: "DELETE /learning/", Access: auth.Authenticated, Scope: auth.ScopeWriteLearnings, Handler: deleteLearningItem},
+	})
 
 	log.Println("Learning REST endpoints initialized")
 }
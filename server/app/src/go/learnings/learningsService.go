@@ -2,15 +2,59 @@ package learnings
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 
+	"software-slayer/auth"
 	"software-slayer/db"
+	"software-slayer/utils"
 )
 
 type LearningsService interface {
-	CreateLearning(ctx context.Context, userId int, title string, category string) error
+	CreateLearning(ctx context.Context, userId int, title string, category string, tags []string, visibility string) error
+	ImportLearnings(ctx context.Context, userId int, requests []CreateLearningRequest, onDuplicate string, dryRun bool) (ImportResult, error)
+	RunBatch(ctx context.Context, operations []BatchOperation, atomic bool) ([]BatchResult, error)
+	UpdateLearning(ctx context.Context, userId int, id int, update *UpdateLearningRequest) error
+	UpdateLearningStatus(ctx context.Context, id int, status string) (string, error)
 	DeleteLearning(ctx context.Context, id int) error
-	GetLearningsByUserId(ctx context.Context, userID int) ([]GetLearningResponse, error)
+	GetLearningsByUserId(ctx context.Context, userID int, filter LearningFilter, page utils.PageRequest) (utils.Page[GetLearningResponse], error)
+	GetLearningsVisibility(ctx context.Context, userId int) (auth.Visibility, error)
 	GetUserByLearningId(ctx context.Context, learningId int) (int, error)
+	GetTagsByUserId(ctx context.Context, userId int) ([]TagCount, error)
+	GetCategories(ctx context.Context, userId int) ([]Category, error)
+	GetCategoryId(ctx context.Context, userId int, name string) (int, error)
+	GetUserByCategoryId(ctx context.Context, categoryId int) (int, error)
+	CreateCategory(ctx context.Context, userId int, name string) (int, error)
+	RenameCategory(ctx context.Context, categoryId int, name string) error
+	ReorderCategories(ctx context.Context, userId int, categoryIds []int) error
+	DeleteCategory(ctx context.Context, categoryId int) error
+}
+
+// categoryIdQuery resolves a category name to its ID among the defaults and the given user's categories
+const categoryIdQuery = "(SELECT id FROM categories WHERE name = ? AND (user_id IS NULL OR user_id = ?) LIMIT 1)"
+
+// importQueries insert an imported learning item, LAST_INSERT_ID(id) returning the existing item's ID when it is a duplicate
+var importQueries = map[string]string{
+	OnDuplicateSkip: "INSERT INTO user_learning_list (user_id, title, category_id, visibility) VALUES (?, ?, " + categoryIdQuery + ", ?) " +
+		"ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)",
+	OnDuplicateUpdate: "INSERT INTO user_learning_list (user_id, title, category_id, visibility) VALUES (?, ?, " + categoryIdQuery + ", ?) " +
+		"ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id), visibility = VALUES(visibility)",
+}
+
+// errDryRun rolls back the transaction of a dry run import
+var errDryRun = errors.New("dry run")
+
+// errBatchFailed rolls back the transaction of an atomic batch after one of its operations failed
+var errBatchFailed = errors.New("batch failed")
+
+// statusUpdateQueries holds the update for each status so that the lifecycle timestamps stay consistent with it
+var statusUpdateQueries = map[string]string{
+	StatusPlanned:    "UPDATE user_learning_list SET status = ?, started_at = NULL, completed_at = NULL WHERE id = ?",
+	StatusInProgress: "UPDATE user_learning_list SET status = ?, started_at = COALESCE(started_at, CURRENT_TIMESTAMP), completed_at = NULL WHERE id = ?",
+	StatusCompleted:  "UPDATE user_learning_list SET status = ?, started_at = COALESCE(started_at, CURRENT_TIMESTAMP), completed_at = CURRENT_TIMESTAMP WHERE id = ?",
+	StatusAbandoned:  "UPDATE user_learning_list SET status = ?, completed_at = NULL WHERE id = ?",
 }
 
 type LearningsServiceImpl struct {
@@ -21,10 +65,108 @@ func NewLearningsService(db *db.Database) *LearningsServiceImpl {
 	return &LearningsServiceImpl{db: db}
 }
 
-func (s *LearningsServiceImpl) CreateLearning(ctx context.Context, userId int, title string, category string) error {
-	_, err := s.db.ExecContext(ctx, "INSERT INTO user_learning_list (user_id, title, category) VALUES (?, ?, ?)",
-		userId, title, category)
-	return err
+func (s *LearningsServiceImpl) CreateLearning(ctx context.Context, userId int, title string, category string, tags []string, visibility string) error {
+	return s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		_, err := createLearning(ctx, tx, userId, title, category, tags, visibility)
+		return err
+	})
+}
+
+/*
+ * Import learning items in a single transaction
+ * @param ctx: the context
+ * @param userId: the user importing the learning items
+ * @param requests: the validated learning items, without duplicates among them
+ * @param onDuplicate: OnDuplicateSkip to leave existing items alone, or OnDuplicateUpdate to replace their visibility and tags
+ * @param dryRun: roll the import back, to find out what it would do
+ * @return ImportResult: how many items were inserted, updated and skipped
+ * @return error: an error if the import failed, in which case nothing was imported
+ */
+func (s *LearningsServiceImpl) ImportLearnings(ctx context.Context, userId int, requests []CreateLearningRequest, onDuplicate string, dryRun bool) (ImportResult, error) {
+	query, ok := importQueries[onDuplicate]
+	if !ok {
+		return ImportResult{}, fmt.Errorf("unknown duplicate handling: %s", onDuplicate)
+	}
+
+	var result ImportResult
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		for _, request := range requests {
+			inserted, err := tx.ExecContext(ctx, query, userId, request.Title, request.Category, userId, itemVisibility(request.Visibility))
+			if err != nil {
+				return err
+			}
+
+			// One row is affected by an insert, none or two when the item already existed
+			affected, err := inserted.RowsAffected()
+			if err != nil {
+				return err
+			}
+			switch {
+			case affected == 1:
+				result.Inserted++
+			case onDuplicate == OnDuplicateUpdate:
+				result.Updated++
+			default:
+				result.Skipped++
+				continue
+			}
+
+			if affected == 1 && len(request.Tags) == 0 {
+				continue
+			}
+			learningId, err := inserted.LastInsertId()
+			if err != nil {
+				return err
+			}
+			if err := setLearningTags(ctx, tx, userId, int(learningId), request.Tags); err != nil {
+				return err
+			}
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return result, nil
+	}
+	return result, err
+}
+
+func (s *LearningsServiceImpl) UpdateLearning(ctx context.Context, userId int, id int, update *UpdateLearningRequest) error {
+	return s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		return updateLearning(ctx, tx, userId, id, update)
+	})
+}
+
+/*
+ * Move a learning item to a new status, checking the transition against the status locked by the update
+ * @param ctx: the context of the request
+ * @param id: the ID of the learning item
+ * @param status: the new status of the learning item
+ * @return string: the status the learning item had before the update
+ * @return error: sql.ErrNoRows if the learning item doesn't exist, ErrStatusTransition if it may not move to the status
+ */
+func (s *LearningsServiceImpl) UpdateLearningStatus(ctx context.Context, id int, status string) (string, error) {
+	query, ok := statusUpdateQueries[status]
+	if !ok {
+		return "", fmt.Errorf("unknown status: %s", status)
+	}
+	var current string
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		// Lock the row, so concurrent updates can't both move the item from the same status
+		err := tx.QueryRowContext(ctx, "SELECT status FROM user_learning_list WHERE id = ? FOR UPDATE", id).Scan(&current)
+		if err != nil {
+			return err
+		}
+		if err := validateStatusTransition(current, status); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, query, status, id)
+		return err
+	})
+	return current, err
 }
 
 func (s *LearningsServiceImpl) DeleteLearning(ctx context.Context, id int) error {
@@ -32,23 +174,123 @@ func (s *LearningsServiceImpl) DeleteLearning(ctx context.Context, id int) error
 	return err
 }
 
-func (s *LearningsServiceImpl) GetLearningsByUserId(ctx context.Context, userID int) ([]GetLearningResponse, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT id, category, title FROM user_learning_list WHERE user_id = ?", userID)
+/*
+ * Run a batch of create, update and delete operations
+ * In atomic mode the batch runs in a single transaction that stops at the first failing operation and is rolled back,
+ * otherwise each operation runs in its own transaction and the others carry on when one fails
+ * @param ctx: the context
+ * @param operations: the validated operations, in the order they are run
+ * @param atomic: whether the batch is all-or-nothing
+ * @return []BatchResult: the outcome of each operation, operations after the failing one of an atomic batch have none
+ * @return error: an error if the transaction of an atomic batch couldn't be started or committed
+ */
+func (s *LearningsServiceImpl) RunBatch(ctx context.Context, operations []BatchOperation, atomic bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(operations))
+	if !atomic {
+		for i, operation := range operations {
+			results[i].Err = s.db.WithTx(ctx, func(tx *sql.Tx) error {
+				var err error
+				results[i].ID, err = runBatchOperation(ctx, tx, operation)
+				return err
+			})
+		}
+		return results, nil
+	}
+
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		for i, operation := range operations {
+			id, err := runBatchOperation(ctx, tx, operation)
+			results[i] = BatchResult{ID: id, Err: err}
+			if err != nil {
+				return errBatchFailed
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errBatchFailed) {
+		return results, nil
+	}
+	return results, err
+}
+
+func (s *LearningsServiceImpl) GetLearningsByUserId(ctx context.Context, userID int, filter LearningFilter, page utils.PageRequest) (utils.Page[GetLearningResponse], error) {
+	where := "l.user_id = ?"
+	args := []any{userID}
+	if filter.Status != "" {
+		where += " AND l.status = ?"
+		args = append(args, filter.Status)
+	}
+	if len(filter.Tags) > 0 {
+		// Items must carry at least one of the tags, or all of them when MatchAllTags is set
+		minMatches := 1
+		if filter.MatchAllTags {
+			minMatches = len(filter.Tags)
+		}
+		where += " AND l.id IN (SELECT ft.learning_id FROM learning_tags ft JOIN tags fn ON fn.id = ft.tag_id " +
+			"WHERE fn.name IN (?" + strings.Repeat(", ?", len(filter.Tags)-1) + ") GROUP BY ft.learning_id HAVING COUNT(*) >= ?)"
+		for _, tag := range filter.Tags {
+			args = append(args, tag)
+		}
+		args = append(args, minMatches)
+	}
+	if filter.Visibilities != nil {
+		// Items without an override have the visibility of the owner's learning list
+		where += " AND COALESCE(l.visibility, (SELECT u.learnings_visibility FROM users u WHERE u.id = l.user_id)) IN (?" +
+			strings.Repeat(", ?", len(filter.Visibilities)-1) + ")"
+		for _, visibility := range filter.Visibilities {
+			args = append(args, visibility)
+		}
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM user_learning_list l WHERE "+where, args...).Scan(&total); err != nil {
+		return utils.Page[GetLearningResponse]{}, err
+	}
+
+	query := "SELECT l.id, c.name, l.title, l.status, l.created_at, l.started_at, l.completed_at, l.visibility, " +
+		"GROUP_CONCAT(t.name ORDER BY t.name SEPARATOR ',') FROM user_learning_list l " +
+		"JOIN categories c ON c.id = l.category_id " +
+		"LEFT JOIN learning_tags lt ON lt.learning_id = l.id LEFT JOIN tags t ON t.id = lt.tag_id " +
+		"WHERE " + where
+	if keyset, keysetArgs := page.KeysetClause("l.id"); keyset != "" {
+		query += " AND " + keyset
+		args = append(args, keysetArgs...)
+	}
+	order, orderArgs := page.OrderClause("l.id")
+	query += " GROUP BY l.id, c.name" + order
+	args = append(args, orderArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return utils.Page[GetLearningResponse]{}, err
 	}
 	defer rows.Close()
 
 	learnings := make([]GetLearningResponse, 0)
 	for rows.Next() {
 		var learning GetLearningResponse
-		if err := rows.Scan(&learning.ID, &learning.Category, &learning.Title); err != nil {
-			return nil, err
+		var tags sql.NullString
+		if err := rows.Scan(&learning.ID, &learning.Category, &learning.Title, &learning.Status,
+			&learning.CreatedAt, &learning.StartedAt, &learning.CompletedAt, &learning.Visibility, &tags); err != nil {
+			return utils.Page[GetLearningResponse]{}, err
+		}
+		learning.Tags = make([]string, 0)
+		if tags.Valid && tags.String != "" {
+			learning.Tags = strings.Split(tags.String, ",")
 		}
 		learnings = append(learnings, learning)
 	}
 
-	return learnings, nil
+	return utils.NewPage(learnings, total, page, func(learning GetLearningResponse) (string, int) {
+		return learningCursor(learning, page.Sort)
+	}), nil
+}
+
+// GetLearningsVisibility returns the visibility of a user's learning list, or sql.ErrNoRows if the user doesn't exist
+func (s *LearningsServiceImpl) GetLearningsVisibility(ctx context.Context, userId int) (auth.Visibility, error) {
+	var visibility auth.Visibility
+	err := s.db.QueryRowContext(ctx, "SELECT learnings_visibility FROM users WHERE id = ?", userId).Scan(&visibility)
+	return visibility, err
 }
 
 func (s *LearningsServiceImpl) GetUserByLearningId(ctx context.Context, learningId int) (int, error) {
@@ -57,3 +299,221 @@ func (s *LearningsServiceImpl) GetUserByLearningId(ctx context.Context, learning
 		learningId).Scan(&userId)
 	return userId, err
 }
+
+func (s *LearningsServiceImpl) GetTagsByUserId(ctx context.Context, userId int) ([]TagCount, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT t.name, COUNT(*) FROM tags t JOIN learning_tags lt ON lt.tag_id = t.id "+
+		"WHERE t.user_id = ? GROUP BY t.id, t.name ORDER BY t.name", userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]TagCount, 0)
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+func (s *LearningsServiceImpl) GetCategories(ctx context.Context, userId int) ([]Category, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, user_id IS NOT NULL FROM categories WHERE user_id IS NULL OR user_id = ? "+
+		"ORDER BY user_id IS NOT NULL, position, id", userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make([]Category, 0)
+	for rows.Next() {
+		var category Category
+		if err := rows.Scan(&category.ID, &category.Name, &category.Custom); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, nil
+}
+
+func (s *LearningsServiceImpl) GetCategoryId(ctx context.Context, userId int, name string) (int, error) {
+	var categoryId int
+	err := s.db.QueryRowContext(ctx, "SELECT id FROM categories WHERE name = ? AND (user_id IS NULL OR user_id = ?) LIMIT 1",
+		name, userId).Scan(&categoryId)
+	return categoryId, err
+}
+
+func (s *LearningsServiceImpl) GetUserByCategoryId(ctx context.Context, categoryId int) (int, error) {
+	// Default categories have no owner and are reported as belonging to user 0
+	var userId sql.NullInt64
+	err := s.db.QueryRowContext(ctx, "SELECT user_id FROM categories WHERE id = ?",
+		categoryId).Scan(&userId)
+	return int(userId.Int64), err
+}
+
+func (s *LearningsServiceImpl) CreateCategory(ctx context.Context, userId int, name string) (int, error) {
+	result, err := s.db.ExecContext(ctx, "INSERT INTO categories (user_id, name, position) "+
+		"SELECT ?, ?, COALESCE(MAX(position) + 1, 0) FROM categories WHERE user_id = ?", userId, name, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	categoryId, err := result.LastInsertId()
+	return int(categoryId), err
+}
+
+func (s *LearningsServiceImpl) RenameCategory(ctx context.Context, categoryId int, name string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE categories SET name = ? WHERE id = ?", name, categoryId)
+	return err
+}
+
+func (s *LearningsServiceImpl) ReorderCategories(ctx context.Context, userId int, categoryIds []int) error {
+	return s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		for position, categoryId := range categoryIds {
+			_, err := tx.ExecContext(ctx, "UPDATE categories SET position = ? WHERE id = ? AND user_id = ?",
+				position, categoryId, userId)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *LearningsServiceImpl) DeleteCategory(ctx context.Context, categoryId int) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM categories WHERE id = ?", categoryId)
+	return err
+}
+
+/*
+ * Replace the tags on a learning item, creating any of the user's tags that do not exist yet
+ * @param ctx: the request context
+ * @param tx: the transaction to run in
+ * @param userId: the owner of the learning item and its tags
+ * @param learningId: the learning item to tag
+ * @param tags: the complete new set of tags
+ * @return error: an error if any statement fails
+ */
+func setLearningTags(ctx context.Context, tx *sql.Tx, userId int, learningId int, tags []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM learning_tags WHERE learning_id = ?", learningId); err != nil {
+		return err
+	}
+
+	for _, tag := range normalizeTags(tags) {
+		// LAST_INSERT_ID(id) makes LastInsertId return the existing row's ID when the tag already exists
+		result, err := tx.ExecContext(ctx, "INSERT INTO tags (user_id, name) VALUES (?, ?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)",
+			userId, tag)
+		if err != nil {
+			return err
+		}
+
+		tagId, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "INSERT INTO learning_tags (learning_id, tag_id) VALUES (?, ?)", learningId, tagId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Insert a learning item and its tags within a transaction
+ * @param ctx: the context
+ * @param tx: the transaction
+ * @param userId: the owner of the learning item
+ * @param title: the title of the learning item
+ * @param category: the name of the category, resolved for the owner
+ * @param tags: the tags of the learning item
+ * @param visibility: the visibility override of the learning item, empty to inherit the learning list's
+ * @return int: the ID of the new learning item
+ * @return error: an error if any statement fails
+ */
+func createLearning(ctx context.Context, tx *sql.Tx, userId int, title string, category string, tags []string, visibility string) (int, error) {
+	result, err := tx.ExecContext(ctx, "INSERT INTO user_learning_list (user_id, title, category_id, visibility) VALUES (?, ?, "+categoryIdQuery+", ?)",
+		userId, title, category, userId, itemVisibility(visibility))
+	if err != nil {
+		return 0, err
+	}
+
+	learningId, err := result.LastInsertId()
+	if err != nil || len(tags) == 0 {
+		return int(learningId), err
+	}
+	return int(learningId), setLearningTags(ctx, tx, userId, int(learningId), tags)
+}
+
+/*
+ * Apply the fields present in an update to a learning item within a transaction
+ * @param ctx: the context
+ * @param tx: the transaction
+ * @param userId: the owner of the learning item, whose categories and tags are used
+ * @param id: the ID of the learning item
+ * @param update: the fields to update
+ * @return error: an error if any statement fails
+ */
+func updateLearning(ctx context.Context, tx *sql.Tx, userId int, id int, update *UpdateLearningRequest) error {
+	if update.Title != nil || update.Category != nil {
+		_, err := tx.ExecContext(ctx, "UPDATE user_learning_list SET title = COALESCE(?, title), category_id = COALESCE("+categoryIdQuery+", category_id) WHERE id = ?",
+			update.Title, update.Category, userId, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	if update.Visibility != nil {
+		if _, err := tx.ExecContext(ctx, "UPDATE user_learning_list SET visibility = ? WHERE id = ?", itemVisibility(*update.Visibility), id); err != nil {
+			return err
+		}
+	}
+
+	if update.Tags == nil {
+		return nil
+	}
+	return setLearningTags(ctx, tx, userId, id, *update.Tags)
+}
+
+/*
+ * Run an operation of a batch within a transaction
+ * @param ctx: the context
+ * @param tx: the transaction
+ * @param operation: the operation to run
+ * @return int: the ID of the learning item the operation applied to
+ * @return error: sql.ErrNoRows if the learning item to update or delete no longer exists, or an error if any statement fails
+ */
+func runBatchOperation(ctx context.Context, tx *sql.Tx, operation BatchOperation) (int, error) {
+	switch operation.Op {
+	case BatchOpCreate:
+		create := operation.Create
+		return createLearning(ctx, tx, operation.UserID, create.Title, create.Category, create.Tags, create.Visibility)
+	case BatchOpUpdate:
+		// MySQL counts updates that change nothing as no rows affected, so the item is locked and checked to still belong to
+		// its owner instead, an earlier operation of the batch may have deleted it
+		var exists int
+		err := tx.QueryRowContext(ctx, "SELECT 1 FROM user_learning_list WHERE id = ? AND user_id = ? FOR UPDATE",
+			operation.ID, operation.UserID).Scan(&exists)
+		if err != nil {
+			return operation.ID, err
+		}
+		return operation.ID, updateLearning(ctx, tx, operation.UserID, operation.ID, operation.Update)
+	case BatchOpDelete:
+		result, err := tx.ExecContext(ctx, "DELETE FROM user_learning_list WHERE id = ?", operation.ID)
+		if err != nil {
+			return operation.ID, err
+		}
+		// An earlier operation of the batch may have deleted the item already
+		deleted, err := result.RowsAffected()
+		if err == nil && deleted == 0 {
+			err = sql.ErrNoRows
+		}
+		return operation.ID, err
+	}
+	return 0, fmt.Errorf("unknown batch operation: %s", operation.Op)
+}
@@ -1,10 +1,19 @@
 package learnings
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"software-slayer/auth"
+	"software-slayer/utils"
 )
 
+// Default categories, seeded in init.sql and visible to every user
 const (
 	Languages    = "Languages"
 	Technologies = "Technologies"
@@ -13,14 +22,40 @@ const (
 	Other        = "Other"
 )
 
+const (
+	StatusPlanned    = "planned"
+	StatusInProgress = "in_progress"
+	StatusCompleted  = "completed"
+	StatusAbandoned  = "abandoned"
+)
+
+const maxTagsPerLearning = 10
+
+// VisibilityInherit clears a learning item's visibility override, so it has the visibility of the learning list
+const VisibilityInherit = "inherit"
+
 var titleValidator = regexp.MustCompile(`^.{1,100}$`)
-var categoriesList = []string{Languages, Technologies, Concepts, Projects, Other}
-var categoriesMap = map[string]struct{}{
-	Languages:    {},
-	Technologies: {},
-	Concepts:     {},
-	Projects:     {},
-	Other:        {},
+var tagValidator = regexp.MustCompile(`^[a-zA-Z0-9 _+#.-]{1,30}$`)
+var categoryNameValidator = regexp.MustCompile(`^\S(.{0,48}\S)?$`)
+
+// learningSortColumns maps the sort parameter accepted by GET /learning/{user_id} to the expression it sorts by
+var learningSortColumns = map[string]string{
+	"id":         "l.id",
+	"title":      "l.title",
+	"category":   "c.name",
+	"status":     "CAST(l.status AS CHAR)",
+	"created_at": "l.created_at",
+}
+
+// ErrStatusTransition is returned when a learning item may not move from its current status to the requested one
+var ErrStatusTransition = errors.New("status transition")
+
+// statusTransitions lists the statuses a learning item may move to from each status
+var statusTransitions = map[string]map[string]struct{}{
+	StatusPlanned:    {StatusInProgress: {}, StatusCompleted: {}, StatusAbandoned: {}},
+	StatusInProgress: {StatusPlanned: {}, StatusCompleted: {}, StatusAbandoned: {}},
+	StatusCompleted:  {StatusInProgress: {}},
+	StatusAbandoned:  {StatusPlanned: {}, StatusInProgress: {}},
 }
 
 type LearningBase struct {
@@ -35,26 +70,251 @@ type LearningDB struct {
 }
 
 type CreateLearningRequest struct {
+	Tags []string `json:"tags,omitempty"`
+	// Visibility overrides the visibility of the learning list for this item, it inherits it when empty
+	Visibility string `json:"visibility,omitempty"`
 	LearningBase
 }
 
+type UpdateLearningRequest struct {
+	Title    *string   `json:"title,omitempty"`
+	Category *string   `json:"category,omitempty"`
+	Tags     *[]string `json:"tags,omitempty"`
+	// Visibility overrides the visibility of the learning list for this item, "inherit" removes the override
+	Visibility *string `json:"visibility,omitempty"`
+}
+
+type UpdateLearningStatusRequest struct {
+	Status string `json:"status"`
+}
+
+type LearningFilter struct {
+	Status       string
+	Tags         []string
+	MatchAllTags bool
+	// Visibilities are the visibilities of the items the caller may see, nil for all of them
+	Visibilities []auth.Visibility
+}
+
+type Category struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Custom bool   `json:"custom"`
+}
+
+type CategoryRequest struct {
+	Name string `json:"name"`
+}
+
+type ReorderCategoriesRequest struct {
+	IDs []int `json:"ids"`
+}
+
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
 type GetLearningResponse struct {
-	ID int `json:"id"`
+	ID          int        `json:"id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	Tags        []string   `json:"tags"`
+	// Visibility is the item's override of the learning list's visibility, null when it inherits it
+	Visibility *auth.Visibility `json:"visibility"`
 	LearningBase
 }
 
 /*
  * Validate the CreateLearningRequest
+ * @param ctx: the request context
+ * @param userId: the user creating the learning item
  * @param createLearningRequest: the CreateLearningRequest to validate
  * @return error: an error if the CreateLearningRequest is invalid
  */
-func validateCreateLearningRequest(createLearningRequest CreateLearningRequest) error {
-	if _, ok := categoriesMap[createLearningRequest.Category]; !ok {
+func validateCreateLearningRequest(ctx context.Context, userId int, createLearningRequest CreateLearningRequest) error {
+	if err := validateCategory(ctx, userId, createLearningRequest.Category); err != nil {
+		return err
+	}
+	if err := validateTitle(createLearningRequest.Title); err != nil {
+		return err
+	}
+	if createLearningRequest.Visibility != "" {
+		if err := validateItemVisibility(createLearningRequest.Visibility); err != nil {
+			return err
+		}
+	}
+	return validateTags(createLearningRequest.Tags)
+}
+
+/*
+ * Validate the UpdateLearningRequest
+ * Only the fields present in the request are checked, using the same rules as a CreateLearningRequest
+ * @param ctx: the request context
+ * @param userId: the user updating the learning item
+ * @param updateLearningRequest: the UpdateLearningRequest to validate
+ * @return error: an error if the UpdateLearningRequest is invalid
+ */
+func validateUpdateLearningRequest(ctx context.Context, userId int, updateLearningRequest UpdateLearningRequest) error {
+	if updateLearningRequest.Title == nil && updateLearningRequest.Category == nil && updateLearningRequest.Tags == nil &&
+		updateLearningRequest.Visibility == nil {
+		return errors.New("request: no fields to update")
+	}
+	if updateLearningRequest.Category != nil {
+		if err := validateCategory(ctx, userId, *updateLearningRequest.Category); err != nil {
+			return err
+		}
+	}
+	if updateLearningRequest.Title != nil {
+		if err := validateTitle(*updateLearningRequest.Title); err != nil {
+			return err
+		}
+	}
+	if updateLearningRequest.Visibility != nil {
+		if err := validateItemVisibility(*updateLearningRequest.Visibility); err != nil {
+			return err
+		}
+	}
+	if updateLearningRequest.Tags != nil {
+		return validateTags(*updateLearningRequest.Tags)
+	}
+	return nil
+}
+
+/*
+ * Validate that a learning item may move from one status to another
+ * @param from: the current status of the learning item
+ * @param to: the requested status of the learning item
+ * @return error: an error if the transition is not allowed
+ */
+func validateStatusTransition(from string, to string) error {
+	if err := validateStatus(to); err != nil {
+		return err
+	}
+	if _, ok := statusTransitions[from][to]; !ok {
+		return fmt.Errorf("%w from %s to %s", ErrStatusTransition, from, to)
+	}
+	return nil
+}
+
+func validateStatus(status string) error {
+	if _, ok := statusTransitions[status]; !ok {
+		return errors.New("status")
+	}
+	return nil
+}
+
+// validateCategory checks that the category is one of the defaults or one of the user's own categories
+func validateCategory(ctx context.Context, userId int, category string) error {
+	if _, err := learningsService.GetCategoryId(ctx, userId, category); err != nil {
 		return errors.New("category")
 	}
-	if ok := titleValidator.MatchString(createLearningRequest.Title); !ok {
+	return nil
+}
+
+func validateCategoryName(name string) error {
+	if ok := categoryNameValidator.MatchString(name); !ok {
+		return errors.New("name")
+	}
+	return nil
+}
+
+func validateTitle(title string) error {
+	if ok := titleValidator.MatchString(title); !ok {
 		return errors.New("title")
 	}
 	return nil
 }
 
+// validateItemVisibility checks that a learning item visibility is a visibility or VisibilityInherit
+func validateItemVisibility(visibility string) error {
+	if visibility == VisibilityInherit {
+		return nil
+	}
+	if _, err := auth.ParseVisibility(visibility); err != nil {
+		return errors.New("visibility")
+	}
+	return nil
+}
+
+/*
+ * Get the value a learning item's visibility is stored as
+ * @param visibility: the visibility from a request, empty or VisibilityInherit to inherit the learning list's
+ * @return any: the visibility, or nil to store NULL
+ */
+func itemVisibility(visibility string) any {
+	if visibility == "" || visibility == VisibilityInherit {
+		return nil
+	}
+	return visibility
+}
+
+func validateTags(tags []string) error {
+	if len(tags) > maxTagsPerLearning {
+		return fmt.Errorf("tags: at most %d tags are allowed", maxTagsPerLearning)
+	}
+	for _, tag := range tags {
+		if ok := tagValidator.MatchString(strings.TrimSpace(tag)); !ok {
+			return errors.New("tag")
+		}
+	}
+	return nil
+}
+
+/*
+ * Get the cursor position of a learning item in a list sorted by the given sort parameter
+ * @param learning: the learning item
+ * @param sort: a key of learningSortColumns
+ * @return string: the learning item's value for the sort
+ * @return int: the learning item's ID
+ */
+func learningCursor(learning GetLearningResponse, sort string) (string, int) {
+	switch sort {
+	case "title":
+		return learning.Title, learning.ID
+	case "category":
+		return learning.Category, learning.ID
+	case "status":
+		return learning.Status, learning.ID
+	case "created_at":
+		return learning.CreatedAt.UTC().Format(time.DateTime), learning.ID
+	default:
+		return strconv.Itoa(learning.ID), learning.ID
+	}
+}
+
+/*
+ * Get the request for the first page of a learning list, for callers walking the whole list rather than serving a page
+ * @param sort: a sort parameter accepted by GET /learning/{user_id}
+ * @param limit: the number of learning items per page
+ * @return utils.PageRequest: the page request, sorted in ascending order
+ * @return error: an error if the sort is not accepted
+ */
+func LearningPageRequest(sort string, limit int) (utils.PageRequest, error) {
+	column, ok := learningSortColumns[sort]
+	if !ok {
+		return utils.PageRequest{}, fmt.Errorf("unknown sort: %s", sort)
+	}
+	return utils.PageRequest{Limit: limit, Sort: sort, Column: column}, nil
+}
+
+/*
+ * Normalize a list of tags so that equivalent tags are stored once
+ * @param tags: the tags to normalize
+ * @return []string: the trimmed, lower-cased tags with duplicates removed
+ */
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if _, ok := seen[tag]; ok || tag == "" {
+			continue
+		}
+		seen[tag] = struct{}{}
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
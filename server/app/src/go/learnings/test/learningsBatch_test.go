@@ -0,0 +1,151 @@
+package learnings_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"software-slayer/learnings"
+)
+
+// postBatch posts a batch and decodes the batch report
+func postBatch(t *testing.T, token string, body string) (int, learnings.BatchResponse) {
+	req, _ := http.NewRequest("POST", ts.URL+"/learning/batch", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var response learnings.BatchResponse
+	json.NewDecoder(resp.Body).Decode(&response)
+	return resp.StatusCode, response
+}
+
+// batchStatuses returns the status of each operation of a batch report
+func batchStatuses(response learnings.BatchResponse) []int {
+	statuses := make([]int, 0, len(response.Results))
+	for _, result := range response.Results {
+		statuses = append(statuses, result.Status)
+	}
+	return statuses
+}
+
+func TestBatchLearningItemsAtomic(t *testing.T) {
+	body := `{"operations": [
+		{"op": "create", "item": {"title": "Learn Go", "category": "Languages", "tags": ["go"]}},
+		{"op": "update", "id": 1, "item": {"title": "Learn Go Generics"}},
+		{"op": "delete", "id": 1}
+	]}`
+
+	status, response := postBatch(t, "valid_token", body)
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+
+	expected := []learnings.BatchOperationResult{
+		{Index: 0, Op: learnings.BatchOpCreate, ID: 100, Status: http.StatusCreated},
+		{Index: 1, Op: learnings.BatchOpUpdate, ID: 1, Status: http.StatusOK},
+		{Index: 2, Op: learnings.BatchOpDelete, ID: 1, Status: http.StatusNoContent},
+	}
+	if response.Mode != learnings.BatchModeAtomic || response.Succeeded != 3 || response.Failed != 0 ||
+		!reflect.DeepEqual(response.Results, expected) {
+		t.Errorf("unexpected batch report: %+v", response)
+	}
+	if len(batchOperations) != 3 || batchOperations[0].UserID != 1 || batchOperations[0].Create.Title != "Learn Go" ||
+		*batchOperations[1].Update.Title != "Learn Go Generics" {
+		t.Errorf("unexpected batch operations: %+v", batchOperations)
+	}
+}
+
+func TestBatchLearningItemsAtomicRollback(t *testing.T) {
+	body := `{"mode": "atomic", "operations": [
+		{"op": "create", "item": {"title": "Learn Go", "category": "Languages"}},
+		{"op": "update", "id": 1, "item": {"title": "duplicate"}},
+		{"op": "delete", "id": 1}
+	]}`
+
+	_, response := postBatch(t, "valid_token", body)
+	statuses := batchStatuses(response)
+	if !reflect.DeepEqual(statuses, []int{http.StatusFailedDependency, http.StatusConflict, http.StatusFailedDependency}) ||
+		response.Succeeded != 0 || response.Failed != 3 {
+		t.Errorf("unexpected batch report: %+v", response)
+	}
+}
+
+func TestBatchLearningItemsAtomicRejected(t *testing.T) {
+	// User 2 may not delete user 1's item, so nothing is run
+	batchOperations = nil
+	body := `{"operations": [
+		{"op": "create", "item": {"title": "Learn Go", "category": "Languages"}},
+		{"op": "delete", "id": 1}
+	]}`
+
+	_, response := postBatch(t, "user2_token", body)
+	statuses := batchStatuses(response)
+	if !reflect.DeepEqual(statuses, []int{http.StatusFailedDependency, http.StatusUnauthorized}) {
+		t.Errorf("unexpected statuses %v", statuses)
+	}
+	if batchOperations != nil {
+		t.Errorf("expected the batch not to run, got %+v", batchOperations)
+	}
+}
+
+func TestBatchLearningItemsBestEffort(t *testing.T) {
+	body := `{"mode": "best_effort", "operations": [
+		{"op": "create", "item": {"title": "Learn Go", "category": "Languages"}},
+		{"op": "create", "item": {"title": "Knitting", "category": "Crafts"}},
+		{"op": "create", "item": {"title": "duplicate", "category": "Languages"}},
+		{"op": "delete", "id": 2},
+		{"op": "delete", "id": 999},
+		{"op": "move", "id": 1},
+		{"op": "update", "id": 1, "item": {"visibility": "private"}}
+	]}`
+
+	_, response := postBatch(t, "valid_token", body)
+	expected := []int{http.StatusCreated, http.StatusBadRequest, http.StatusConflict, http.StatusUnauthorized,
+		http.StatusNotFound, http.StatusBadRequest, http.StatusOK}
+	if statuses := batchStatuses(response); !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected statuses %v, got %v", expected, statuses)
+	}
+	if response.Succeeded != 2 || response.Failed != 5 || response.Results[1].Error != "Invalid category" {
+		t.Errorf("unexpected batch report: %+v", response)
+	}
+	if len(batchOperations) != 3 {
+		t.Errorf("expected the valid operations to run, got %+v", batchOperations)
+	}
+}
+
+func TestBatchLearningItemsModerator(t *testing.T) {
+	// Moderators may delete any learning item but not update it, as with the single item endpoints
+	body := `{"mode": "best_effort", "operations": [
+		{"op": "delete", "id": 1},
+		{"op": "update", "id": 1, "item": {"title": "Learn Go Generics"}}
+	]}`
+
+	_, response := postBatch(t, "moderator_token", body)
+	if statuses := batchStatuses(response); !reflect.DeepEqual(statuses, []int{http.StatusNoContent, http.StatusUnauthorized}) {
+		t.Errorf("unexpected statuses %v", statuses)
+	}
+	if len(batchOperations) != 1 || batchOperations[0].UserID != 1 {
+		t.Errorf("expected the delete to run for the owner, got %+v", batchOperations)
+	}
+}
+
+func TestBatchLearningItemsInvalid(t *testing.T) {
+	tooMany := `{"operations": [` + strings.Repeat(`{"op": "delete", "id": 1},`, 100) + `{"op": "delete", "id": 1}]}`
+	for _, body := range []string{`{"operations": []}`, `{"mode": "sometimes", "operations": [{"op": "delete", "id": 1}]}`, tooMany} {
+		if status, _ := postBatch(t, "valid_token", body); status != http.StatusBadRequest {
+			t.Errorf("expected %d, got %d", http.StatusBadRequest, status)
+		}
+	}
+
+	if status, _ := postBatch(t, "read_learnings_pat", `{"operations": [{"op": "delete", "id": 1}]}`); status != http.StatusForbidden {
+		t.Errorf("expected %d without the write scope, got %d", http.StatusForbidden, status)
+	}
+}
@@ -0,0 +1,145 @@
+package learnings_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"software-slayer/learnings"
+)
+
+// postImport posts an import file and decodes the import report
+func postImport(t *testing.T, query string, contentType string, body string) (int, learnings.ImportResponse) {
+	req, _ := http.NewRequest("POST", ts.URL+"/learning/import"+query, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer valid_token")
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var response learnings.ImportResponse
+	json.NewDecoder(resp.Body).Decode(&response)
+	return resp.StatusCode, response
+}
+
+func TestImportLearningItemsCSV(t *testing.T) {
+	csv := "title,category,tags,status\n" +
+		"Go Programming,Languages,\"backend,go\",planned\n" +
+		"Existing,Technologies,,\n" +
+		",Languages,,\n" +
+		"Go Programming,Languages,,\n" +
+		"Knitting,Crafts,,\n"
+
+	status, response := postImport(t, "", "text/csv", csv)
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+
+	expectedErrors := []learnings.ImportRowError{
+		{Row: 3, Error: "Invalid title"},
+		{Row: 4, Title: "Go Programming", Error: "Duplicate of row 1"},
+		{Row: 5, Title: "Knitting", Error: "Invalid category"},
+	}
+	if response.DryRun || response.Rows != 5 || response.Inserted != 1 || response.Skipped != 1 ||
+		!reflect.DeepEqual(response.Errors, expectedErrors) {
+		t.Errorf("unexpected import report: %+v", response)
+	}
+	if len(importedLearnings) != 2 || !reflect.DeepEqual(importedLearnings[0].Tags, []string{"backend", "go"}) {
+		t.Errorf("unexpected imported learning items: %+v", importedLearnings)
+	}
+}
+
+func TestImportLearningItemsJSON(t *testing.T) {
+	body := `[{"title": "Existing", "category": "Languages"}, {"title": "Docker", "category": "Technologies", "visibility": "private", "tags": ["devops"]}]`
+
+	status, response := postImport(t, "?on_duplicate=update", "application/json", body)
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+	if response.Rows != 2 || response.Inserted != 1 || response.Updated != 1 || len(response.Errors) != 0 {
+		t.Errorf("unexpected import report: %+v", response)
+	}
+	if len(importedLearnings) != 2 || importedLearnings[1].Visibility != "private" {
+		t.Errorf("unexpected imported learning items: %+v", importedLearnings)
+	}
+}
+
+func TestImportLearningItemsBookmarks(t *testing.T) {
+	bookmarks := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1700000000">Reading</H3>
+    <DL><p>
+        <DT><A HREF="https://go.dev/tour" ADD_DATE="1700000000" TAGS="go,backend">A Tour of Go &amp; more</A>
+        <DT><A HREF="place:sort=8&maxResults=10">Most Visited</A>
+    </DL><p>
+    <DT><A HREF="https://martinfowler.com/articles/microservices.html">Microservices</A>
+</DL><p>`
+
+	status, response := postImport(t, "?dry_run=true&category=Concepts", "text/html; charset=UTF-8", bookmarks)
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+	if !response.DryRun || response.Rows != 2 || response.Inserted != 2 || len(response.Errors) != 0 {
+		t.Errorf("unexpected import report: %+v", response)
+	}
+
+	expected := []learnings.CreateLearningRequest{
+		{Tags: []string{"go", "backend"}, LearningBase: learnings.LearningBase{Title: "A Tour of Go & more", Category: learnings.Concepts}},
+		{LearningBase: learnings.LearningBase{Title: "Microservices", Category: learnings.Concepts}},
+	}
+	if !reflect.DeepEqual(importedLearnings, expected) {
+		t.Errorf("expected imported learning items %+v, got %+v", expected, importedLearnings)
+	}
+}
+
+func TestImportLearningItemsInvalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		expected    int
+	}{
+		{"unknown format", "", "text/plain", "title,category\nGo,Languages\n", http.StatusBadRequest},
+		{"invalid format parameter", "?format=xml", "text/csv", "title,category\nGo,Languages\n", http.StatusBadRequest},
+		{"invalid on_duplicate", "?on_duplicate=replace", "text/csv", "title,category\nGo,Languages\n", http.StatusBadRequest},
+		{"invalid dry_run", "?dry_run=maybe", "text/csv", "title,category\nGo,Languages\n", http.StatusBadRequest},
+		{"CSV without a category column", "", "text/csv", "title\nGo\n", http.StatusBadRequest},
+		{"CSV without rows", "", "text/csv", "title,category\n", http.StatusBadRequest},
+		{"JSON object", "", "application/json", `{"title": "Go", "category": "Languages"}`, http.StatusBadRequest},
+		{"HTML that isn't a bookmark file", "", "text/html", `<a href="https://go.dev">Go</a>`, http.StatusBadRequest},
+		{"too many rows", "?format=csv", "", "title,category\n" + strings.Repeat("Go,Languages\n", 501), http.StatusBadRequest},
+		{"too large", "", "text/csv", "title,category\n" + strings.Repeat("x", 1<<20), http.StatusRequestEntityTooLarge},
+		{"import failure", "", "text/csv", "title,category\nfail,Languages\n", http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		if status, _ := postImport(t, test.query, test.contentType, test.body); status != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expected, status)
+		}
+	}
+}
+
+func TestImportLearningItemsMissingScope(t *testing.T) {
+	req, _ := http.NewRequest("POST", ts.URL+"/learning/import", strings.NewReader("title,category\nGo,Languages\n"))
+	req.Header.Set("Authorization", "Bearer read_learnings_pat")
+	req.Header.Set("Content-Type", "text/csv")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+}
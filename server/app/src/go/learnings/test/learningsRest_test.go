@@ -3,25 +3,96 @@ package learnings_test
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
 	"testing"
 
+	"software-slayer/auth"
 	"software-slayer/learnings"
+	"software-slayer/utils"
 )
 
 type MockLearningsService struct{}
 
-func (m *MockLearningsService) CreateLearning(ctx context.Context, userId int, title string, category string) error {
+func (m *MockLearningsService) CreateLearning(ctx context.Context, userId int, title string, category string, tags []string, visibility string) error {
 	if title == "invalid" {
 		return errors.New("invalid title")
 	}
 	return nil
 }
 
+// importedLearnings records the learning items of the last import
+var importedLearnings []learnings.CreateLearningRequest
+
+// ImportLearnings treats items titled "Existing" as already existing, and fails for items titled "fail"
+func (m *MockLearningsService) ImportLearnings(ctx context.Context, userId int, requests []learnings.CreateLearningRequest, onDuplicate string, dryRun bool) (learnings.ImportResult, error) {
+	importedLearnings = requests
+	var result learnings.ImportResult
+	for _, request := range requests {
+		switch {
+		case request.Title == "fail":
+			return learnings.ImportResult{}, errors.New("database error")
+		case request.Title != "Existing":
+			result.Inserted++
+		case onDuplicate == learnings.OnDuplicateUpdate:
+			result.Updated++
+		default:
+			result.Skipped++
+		}
+	}
+	return result, nil
+}
+
+// batchOperations records the operations of the last batch
+var batchOperations []learnings.BatchOperation
+
+// RunBatch fails operations on items titled "duplicate" as duplicates, stopping at the first failure of an atomic batch
+func (m *MockLearningsService) RunBatch(ctx context.Context, operations []learnings.BatchOperation, atomic bool) ([]learnings.BatchResult, error) {
+	batchOperations = operations
+	results := make([]learnings.BatchResult, len(operations))
+	for i, operation := range operations {
+		results[i].ID = operation.ID
+		if operation.Op == learnings.BatchOpCreate {
+			results[i].ID = 100 + i
+		}
+		if (operation.Create != nil && operation.Create.Title == "duplicate") ||
+			(operation.Update != nil && operation.Update.Title != nil && *operation.Update.Title == "duplicate") {
+			results[i] = learnings.BatchResult{Err: errors.New("Error 1062: Duplicate entry")}
+			if atomic {
+				return results, nil
+			}
+		}
+	}
+	return results, nil
+}
+
+func (m *MockLearningsService) UpdateLearning(ctx context.Context, userId int, id int, update *learnings.UpdateLearningRequest) error {
+	if update.Title != nil && *update.Title == "duplicate" {
+		return errors.New("Error 1062: Duplicate entry")
+	}
+	return nil
+}
+
+func (m *MockLearningsService) UpdateLearningStatus(ctx context.Context, id int, status string) (string, error) {
+	switch id {
+	case 1:
+		return learnings.StatusPlanned, nil
+	case 2:
+		// Completed items can only be reopened
+		if status != learnings.StatusInProgress {
+			return learnings.StatusCompleted, fmt.Errorf("%w from %s to %s", learnings.ErrStatusTransition, learnings.StatusCompleted, status)
+		}
+		return learnings.StatusCompleted, nil
+	}
+	return "", sql.ErrNoRows
+}
+
 func (m *MockLearningsService) DeleteLearning(ctx context.Context, id int) error {
 	if id == 999 {
 		return errors.New("learning item not found")
@@ -29,27 +100,85 @@ func (m *MockLearningsService) DeleteLearning(ctx context.Context, id int) error
 	return nil
 }
 
-func (m *MockLearningsService) GetLearningsByUserId(ctx context.Context, userID int) ([]learnings.GetLearningResponse, error) {
+func (m *MockLearningsService) GetLearningsByUserId(ctx context.Context, userID int, filter learnings.LearningFilter, page utils.PageRequest) (utils.Page[learnings.GetLearningResponse], error) {
 	if userID == 999 {
-		return nil, errors.New("user not found")
+		return utils.Page[learnings.GetLearningResponse]{}, errors.New("user not found")
 	}
 
-	return []learnings.GetLearningResponse{
+	learningItems := []learnings.GetLearningResponse{
 		{
-			ID: 1,
+			ID:     1,
+			Status: learnings.StatusPlanned,
+			Tags:   []string{"backend", "go"},
 			LearningBase: learnings.LearningBase{
 				Title:    "Go Programming",
 				Category: learnings.Languages,
 			},
 		},
 		{
-			ID: 2,
+			ID:     2,
+			Status: learnings.StatusCompleted,
+			Tags:   []string{"backend", "devops"},
 			LearningBase: learnings.LearningBase{
 				Title:    "Docker",
 				Category: learnings.Technologies,
 			},
 		},
-	}, nil
+		{
+			ID:         3,
+			Status:     learnings.StatusInProgress,
+			Tags:       []string{"diary"},
+			Visibility: &privateVisibility,
+			LearningBase: learnings.LearningBase{
+				Title:    "Journaling",
+				Category: learnings.Other,
+			},
+		},
+	}
+
+	filtered := make([]learnings.GetLearningResponse, 0)
+	for _, learningItem := range learningItems {
+		if filter.Status != "" && learningItem.Status != filter.Status {
+			continue
+		}
+		if learningItem.Visibility != nil && filter.Visibilities != nil && !slices.Contains(filter.Visibilities, *learningItem.Visibility) {
+			continue
+		}
+		if len(filter.Tags) > 0 {
+			matches := 0
+			for _, tag := range filter.Tags {
+				for _, itemTag := range learningItem.Tags {
+					if tag == itemTag {
+						matches++
+					}
+				}
+			}
+			if matches == 0 || (filter.MatchAllTags && matches < len(filter.Tags)) {
+				continue
+			}
+		}
+		filtered = append(filtered, learningItem)
+	}
+	return utils.NewPage(filtered, len(filtered), page, func(learningItem learnings.GetLearningResponse) (string, int) {
+		return learningItem.Title, learningItem.ID
+	}), nil
+}
+
+var privateVisibility = auth.VisibilityPrivate
+
+// GetLearningsVisibility makes the list of user 1 public, of user 2 private and of user 5 visible to signed in users
+func (m *MockLearningsService) GetLearningsVisibility(ctx context.Context, userId int) (auth.Visibility, error) {
+	switch userId {
+	case 1:
+		return auth.VisibilityPublic, nil
+	case 2:
+		return auth.VisibilityPrivate, nil
+	case 5:
+		return auth.VisibilityOrganization, nil
+	case 998:
+		return "", errors.New("database error")
+	}
+	return "", sql.ErrNoRows
 }
 
 func (m *MockLearningsService) GetUserByLearningId(ctx context.Context, learningId int) (int, error) {
@@ -59,23 +188,112 @@ func (m *MockLearningsService) GetUserByLearningId(ctx context.Context, learning
 	if learningId == 2 {
 		return 2, nil
 	}
+	if learningId == 3 {
+		// Deleted before its status is updated
+		return 1, nil
+	}
 	return 0, errors.New("learning item not found")
 }
 
+func (m *MockLearningsService) GetTagsByUserId(ctx context.Context, userId int) ([]learnings.TagCount, error) {
+	return []learnings.TagCount{
+		{Name: "backend", Count: 2},
+		{Name: "go", Count: 1},
+	}, nil
+}
+
+var mockCategories = []learnings.Category{
+	{ID: 1, Name: learnings.Languages},
+	{ID: 2, Name: learnings.Technologies},
+	{ID: 3, Name: learnings.Concepts},
+	{ID: 4, Name: learnings.Projects},
+	{ID: 5, Name: learnings.Other},
+	{ID: 6, Name: "Reading", Custom: true},
+	{ID: 7, Name: "Talks", Custom: true},
+}
+
+// GetCategories returns the five defaults to everyone and categories 6 and 7 to user 1
+func (m *MockLearningsService) GetCategories(ctx context.Context, userId int) ([]learnings.Category, error) {
+	if userId == 1 {
+		return mockCategories, nil
+	}
+	return mockCategories[:5], nil
+}
+
+// GetCategoryId fails for the name "Broken", as if the database were unavailable
+func (m *MockLearningsService) GetCategoryId(ctx context.Context, userId int, name string) (int, error) {
+	if name == "Broken" {
+		return 0, errors.New("database error")
+	}
+	categories, _ := m.GetCategories(ctx, userId)
+	for _, category := range categories {
+		if category.Name == name {
+			return category.ID, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (m *MockLearningsService) GetUserByCategoryId(ctx context.Context, categoryId int) (int, error) {
+	if categoryId >= 1 && categoryId <= 5 {
+		return 0, nil
+	}
+	if categoryId == 6 || categoryId == 7 {
+		return 1, nil
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (m *MockLearningsService) CreateCategory(ctx context.Context, userId int, name string) (int, error) {
+	return 8, nil
+}
+
+func (m *MockLearningsService) RenameCategory(ctx context.Context, categoryId int, name string) error {
+	return nil
+}
+
+func (m *MockLearningsService) ReorderCategories(ctx context.Context, userId int, categoryIds []int) error {
+	return nil
+}
+
+func (m *MockLearningsService) DeleteCategory(ctx context.Context, categoryId int) error {
+	if categoryId == 7 {
+		return errors.New("Error 1451: Cannot delete or update a parent row: a foreign key constraint fails")
+	}
+	return nil
+}
+
 type MockTokenService struct{}
 
-func (m *MockTokenService) GenerateToken(userID int) (string, error) {
+func (m *MockTokenService) GenerateToken(userID int, role auth.Role, emailVerified bool) (string, error) {
 	return "mocked_token", nil
 }
 
-func (m *MockTokenService) AuthorizeUser(token string) (int, error) {
+func (m *MockTokenService) RevokeToken(ctx context.Context, principal auth.Principal) error {
+	return nil
+}
+
+func (m *MockTokenService) RevokeAllTokens(ctx context.Context, userID int) error {
+	return nil
+}
+
+func (m *MockTokenService) AuthorizeUser(token string) (auth.Principal, error) {
 	if token == "valid_token" {
-		return 1, nil
+		return auth.Principal{UserID: 1, TokenID: "valid_token_id"}, nil
 	}
 	if token == "user2_token" {
-		return 2, nil
+		return auth.Principal{UserID: 2, TokenID: "user2_token_id"}, nil
+	}
+	if token == "read_learnings_pat" {
+		return auth.Principal{UserID: 1, Scopes: []string{auth.ScopeReadLearnings}}, nil
+	}
+	if token == "moderator_token" {
+		return auth.Principal{UserID: 3, Role: auth.RoleModerator, TokenID: "moderator_token_id"}, nil
 	}
-	return 0, errors.New("invalid token")
+	if token == "admin_token" {
+		return auth.Principal{UserID: 4, Role: auth.RoleAdmin, TokenID: "admin_token_id"}, nil
+	}
+	return auth.Principal{}, errors.New("invalid token")
 }
 
 var ts *httptest.Server
@@ -100,7 +318,7 @@ func TestCreateLearningItemSuccess(t *testing.T) {
 	body, _ := json.Marshal(requestBody)
 
 	req, _ := http.NewRequest("POST", ts.URL+"/learning", bytes.NewBuffer(body))
-	req.Header.Set("Authorization", "valid_token")
+	req.Header.Set("Authorization", "Bearer valid_token")
 	req.Header.Set("Content-Type", "application/json")
 
 	client := &http.Client{}
@@ -125,7 +343,7 @@ func TestCreateLearningItemUnauthorized(t *testing.T) {
 	body, _ := json.Marshal(requestBody)
 
 	req, _ := http.NewRequest("POST", ts.URL+"/learning", bytes.NewBuffer(body))
-	req.Header.Set("Authorization", "invalid_token")
+	req.Header.Set("Authorization", "Bearer invalid_token")
 	req.Header.Set("Content-Type", "application/json")
 
 	client := &http.Client{}
@@ -150,7 +368,213 @@ func TestCreateLearningItemBadRequest(t *testing.T) {
 	body, _ := json.Marshal(requestBody)
 
 	req, _ := http.NewRequest("POST", ts.URL+"/learning", bytes.NewBuffer(body))
-	req.Header.Set("Authorization", "valid_token")
+	req.Header.Set("Authorization", "Bearer valid_token")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestCreateLearningItemTooManyTags(t *testing.T) {
+	requestBody := learnings.CreateLearningRequest{
+		Tags: []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"},
+		LearningBase: learnings.LearningBase{
+			Title:    "Learn Go",
+			Category: learnings.Languages,
+		},
+	}
+	body, _ := json.Marshal(requestBody)
+
+	req, _ := http.NewRequest("POST", ts.URL+"/learning", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer valid_token")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestUpdateLearningItemSuccess(t *testing.T) {
+	title := "Learn Go Generics"
+	body, _ := json.Marshal(learnings.UpdateLearningRequest{Title: &title})
+
+	req, _ := http.NewRequest("PATCH", ts.URL+"/learning/1", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer valid_token")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestUpdateLearningItemBadRequest(t *testing.T) {
+	category := "InvalidCategory"
+	body, _ := json.Marshal(learnings.UpdateLearningRequest{Category: &category})
+
+	req, _ := http.NewRequest("PATCH", ts.URL+"/learning/1", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer valid_token")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestUpdateLearningItemVisibility(t *testing.T) {
+	for visibility, expected := range map[string]int{
+		"private":                   http.StatusOK,
+		learnings.VisibilityInherit: http.StatusOK,
+		"friends":                   http.StatusBadRequest,
+	} {
+		body, _ := json.Marshal(learnings.UpdateLearningRequest{Visibility: &visibility})
+
+		req, _ := http.NewRequest("PATCH", ts.URL+"/learning/1", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer valid_token")
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != expected {
+			t.Errorf("visibility %q: expected %d, got %d", visibility, expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestUpdateLearningItemEmptyRequest(t *testing.T) {
+	req, _ := http.NewRequest("PATCH", ts.URL+"/learning/1", bytes.NewBufferString("{}"))
+	req.Header.Set("Authorization", "Bearer valid_token")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestUpdateLearningItemUnauthorized(t *testing.T) {
+	// User 2 trying to update User 1's item
+	title := "Learn Go Generics"
+	body, _ := json.Marshal(learnings.UpdateLearningRequest{Title: &title})
+
+	req, _ := http.NewRequest("PATCH", ts.URL+"/learning/1", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer user2_token")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
+
+func TestUpdateLearningItemNotFound(t *testing.T) {
+	title := "Learn Go Generics"
+	body, _ := json.Marshal(learnings.UpdateLearningRequest{Title: &title})
+
+	req, _ := http.NewRequest("PATCH", ts.URL+"/learning/999", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer valid_token")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestUpdateLearningItemConflict(t *testing.T) {
+	title := "duplicate"
+	body, _ := json.Marshal(learnings.UpdateLearningRequest{Title: &title})
+
+	req, _ := http.NewRequest("PATCH", ts.URL+"/learning/1", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer valid_token")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected %d, got %d", http.StatusConflict, resp.StatusCode)
+	}
+}
+
+func TestUpdateLearningItemStatusSuccess(t *testing.T) {
+	body, _ := json.Marshal(learnings.UpdateLearningStatusRequest{Status: learnings.StatusInProgress})
+
+	req, _ := http.NewRequest("PUT", ts.URL+"/learning/1/status", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer valid_token")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestUpdateLearningItemStatusInvalidStatus(t *testing.T) {
+	body, _ := json.Marshal(learnings.UpdateLearningStatusRequest{Status: "finished"})
+
+	req, _ := http.NewRequest("PUT", ts.URL+"/learning/1/status", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer valid_token")
 	req.Header.Set("Content-Type", "application/json")
 
 	client := &http.Client{}
@@ -165,9 +589,68 @@ func TestCreateLearningItemBadRequest(t *testing.T) {
 	}
 }
 
+func TestUpdateLearningItemStatusIllegalTransition(t *testing.T) {
+	// Item 2 is completed and can only be reopened
+	body, _ := json.Marshal(learnings.UpdateLearningStatusRequest{Status: learnings.StatusAbandoned})
+
+	req, _ := http.NewRequest("PUT", ts.URL+"/learning/2/status", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer user2_token")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected %d, got %d", http.StatusConflict, resp.StatusCode)
+	}
+}
+
+func TestUpdateLearningItemStatusDeleted(t *testing.T) {
+	body, _ := json.Marshal(learnings.UpdateLearningStatusRequest{Status: learnings.StatusInProgress})
+
+	req, _ := http.NewRequest("PUT", ts.URL+"/learning/3/status", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer valid_token")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestUpdateLearningItemStatusUnauthorized(t *testing.T) {
+	// User 2 trying to update User 1's item
+	body, _ := json.Marshal(learnings.UpdateLearningStatusRequest{Status: learnings.StatusInProgress})
+
+	req, _ := http.NewRequest("PUT", ts.URL+"/learning/1/status", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer user2_token")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
+
 func TestDeleteLearningItemSuccess(t *testing.T) {
 	req, _ := http.NewRequest("DELETE", ts.URL+"/learning/1", nil)
-	req.Header.Set("Authorization", "valid_token")
+	req.Header.Set("Authorization", "Bearer valid_token")
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -184,7 +667,7 @@ func TestDeleteLearningItemSuccess(t *testing.T) {
 func TestDeleteLearningItemUnauthorized(t *testing.T) {
 	// User 2 trying to delete User 1's item
 	req, _ := http.NewRequest("DELETE", ts.URL+"/learning/1", nil)
-	req.Header.Set("Authorization", "user2_token")
+	req.Header.Set("Authorization", "Bearer user2_token")
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -198,9 +681,44 @@ func TestDeleteLearningItemUnauthorized(t *testing.T) {
 	}
 }
 
+func TestDeleteLearningItemAsModeratorOrAdmin(t *testing.T) {
+	for _, token := range []string{"moderator_token", "admin_token"} {
+		req, _ := http.NewRequest("DELETE", ts.URL+"/learning/1", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("token %q: expected %d, got %d", token, http.StatusNoContent, resp.StatusCode)
+		}
+	}
+}
+
+func TestUpdateLearningItemStatusAsModerator(t *testing.T) {
+	// Moderators can remove learning items, but not edit them
+	for token, expected := range map[string]int{"moderator_token": http.StatusUnauthorized, "admin_token": http.StatusOK} {
+		req, _ := http.NewRequest("PUT", ts.URL+"/learning/1/status", bytes.NewBufferString(`{"status": "in_progress"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != expected {
+			t.Errorf("token %q: expected %d, got %d", token, expected, resp.StatusCode)
+		}
+	}
+}
+
 func TestDeleteLearningItemNotFound(t *testing.T) {
 	req, _ := http.NewRequest("DELETE", ts.URL+"/learning/999", nil)
-	req.Header.Set("Authorization", "valid_token")
+	req.Header.Set("Authorization", "Bearer valid_token")
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -225,13 +743,160 @@ func TestGetLearningItemsByUserIdSuccess(t *testing.T) {
 		t.Errorf("expected %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	var learningItems []learnings.GetLearningResponse
+	var learningItems utils.Page[learnings.GetLearningResponse]
+	if err := json.NewDecoder(resp.Body).Decode(&learningItems); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(learningItems.Items) != 2 || learningItems.Total != 2 {
+		t.Errorf("expected %d items, got %d of %d", 2, len(learningItems.Items), learningItems.Total)
+	}
+}
+
+func TestGetLearningItemsByUserIdStatusFilter(t *testing.T) {
+	resp, err := http.Get(ts.URL + "/learning/1?status=completed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var learningItems utils.Page[learnings.GetLearningResponse]
+	if err := json.NewDecoder(resp.Body).Decode(&learningItems); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(learningItems.Items) != 1 || learningItems.Items[0].Status != learnings.StatusCompleted {
+		t.Errorf("expected only the completed item, got %v", learningItems.Items)
+	}
+}
+
+func TestGetLearningItemsByUserIdInvalidStatus(t *testing.T) {
+	resp, err := http.Get(ts.URL + "/learning/1?status=finished")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestGetLearningItemsByUserIdTagFilter(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{"tags=go,devops", 2},
+		{"tags=go,devops&tags_match=all", 0},
+		{"tags=Backend,GO&tags_match=all", 1},
+	}
+
+	for _, test := range tests {
+		resp, err := http.Get(ts.URL + "/learning/1?" + test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var learningItems utils.Page[learnings.GetLearningResponse]
+		if err := json.NewDecoder(resp.Body).Decode(&learningItems); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if len(learningItems.Items) != test.expected {
+			t.Errorf("%s: expected %d items, got %d", test.query, test.expected, len(learningItems.Items))
+		}
+	}
+}
+
+func TestGetLearningItemsByUserIdInvalidTagsMatch(t *testing.T) {
+	resp, err := http.Get(ts.URL + "/learning/1?tags=go&tags_match=some")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestGetLearningItemTags(t *testing.T) {
+	req, _ := http.NewRequest("GET", ts.URL+"/learning/tags", nil)
+	req.Header.Set("Authorization", "Bearer valid_token")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var tags []learnings.TagCount
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tags) != 2 {
+		t.Errorf("expected %d tags, got %d", 2, len(tags))
+	}
+}
+
+func TestGetLearningItemTagsUnauthorized(t *testing.T) {
+	resp, err := http.Get(ts.URL + "/learning/tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
+
+func TestGetLearningItemsByUserIdPagination(t *testing.T) {
+	resp, err := http.Get(ts.URL + "/learning/1?limit=1&sort=title&order=desc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var learningItems utils.Page[learnings.GetLearningResponse]
 	if err := json.NewDecoder(resp.Body).Decode(&learningItems); err != nil {
 		t.Fatal(err)
 	}
 
-	if len(learningItems) != 2 {
-		t.Errorf("expected %d items, got %d", 2, len(learningItems))
+	if len(learningItems.Items) != 1 || learningItems.Total != 2 || learningItems.NextCursor == "" {
+		t.Errorf("expected 1 of 2 items and a next cursor, got %+v", learningItems)
+	}
+}
+
+func TestGetLearningItemsByUserIdInvalidPagination(t *testing.T) {
+	queries := []string{"limit=0", "limit=101", "sort=password", "order=up", "cursor=garbage"}
+
+	for _, query := range queries {
+		resp, err := http.Get(ts.URL + "/learning/1?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", query, http.StatusBadRequest, resp.StatusCode)
+		}
 	}
 }
 
@@ -254,11 +919,68 @@ func TestGetLearningItemsByUserIdNotFound(t *testing.T) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestGetLearningItemsByUserIdServerError(t *testing.T) {
+	resp, err := http.Get(ts.URL + "/learning/998")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
 	if resp.StatusCode != http.StatusInternalServerError {
 		t.Errorf("expected %d, got %d", http.StatusInternalServerError, resp.StatusCode)
 	}
 }
 
+func TestGetLearningItemsByUserIdVisibility(t *testing.T) {
+	tests := []struct {
+		userId   string
+		token    string
+		expected int
+		items    int
+	}{
+		{"1", "", http.StatusOK, 2},
+		{"1", "valid_token", http.StatusOK, 3},
+		{"1", "user2_token", http.StatusOK, 2},
+		{"1", "moderator_token", http.StatusOK, 3},
+		{"2", "", http.StatusNotFound, 0},
+		{"2", "valid_token", http.StatusNotFound, 0},
+		{"2", "user2_token", http.StatusOK, 3},
+		{"2", "admin_token", http.StatusOK, 3},
+		{"5", "", http.StatusNotFound, 0},
+		{"5", "valid_token", http.StatusOK, 2},
+		{"5", "invalid_token", http.StatusUnauthorized, 0},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", ts.URL+"/learning/"+test.userId, nil)
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var learningItems utils.Page[learnings.GetLearningResponse]
+		json.NewDecoder(resp.Body).Decode(&learningItems)
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("user %s with %q: expected %d, got %d", test.userId, test.token, test.expected, resp.StatusCode)
+			continue
+		}
+		if len(learningItems.Items) != test.items {
+			t.Errorf("user %s with %q: expected %d items, got %d", test.userId, test.token, test.items, len(learningItems.Items))
+		}
+	}
+}
+
 func TestGetLearningItemCategories(t *testing.T) {
 	resp, err := http.Get(ts.URL + "/learning/categories")
 	if err != nil {
@@ -270,7 +992,7 @@ func TestGetLearningItemCategories(t *testing.T) {
 		t.Errorf("expected %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	var categories []string
+	var categories []learnings.Category
 	if err := json.NewDecoder(resp.Body).Decode(&categories); err != nil {
 		t.Fatal(err)
 	}
@@ -291,7 +1013,7 @@ func TestGetLearningItemCategories(t *testing.T) {
 	for _, expectedCategory := range expectedCategories {
 		found := false
 		for _, category := range categories {
-			if category == expectedCategory {
+			if category.Name == expectedCategory {
 				found = true
 				break
 			}
@@ -301,3 +1023,221 @@ func TestGetLearningItemCategories(t *testing.T) {
 		}
 	}
 }
+
+func TestGetLearningItemCategoriesWithCustom(t *testing.T) {
+	req, _ := http.NewRequest("GET", ts.URL+"/learning/categories", nil)
+	req.Header.Set("Authorization", "Bearer valid_token")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var categories []learnings.Category
+	if err := json.NewDecoder(resp.Body).Decode(&categories); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(categories) != 7 {
+		t.Errorf("expected %d categories, got %d", 7, len(categories))
+	}
+}
+
+func TestCreateLearningItemCustomCategory(t *testing.T) {
+	tests := []struct {
+		token    string
+		expected int
+	}{
+		{"valid_token", http.StatusCreated},
+		// User 2 cannot use user 1's category
+		{"user2_token", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		requestBody := learnings.CreateLearningRequest{
+			LearningBase: learnings.LearningBase{
+				Title:    "The Pragmatic Programmer",
+				Category: "Reading",
+			},
+		}
+		body, _ := json.Marshal(requestBody)
+
+		req, _ := http.NewRequest("POST", ts.URL+"/learning", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		req.Header.Set("Content-Type", "application/json")
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.token, test.expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestCreateCategory(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		expected int
+	}{
+		{"Podcasts", "valid_token", http.StatusCreated},
+		{"Podcasts", "invalid_token", http.StatusUnauthorized},
+		{"", "valid_token", http.StatusBadRequest},
+		{learnings.Languages, "valid_token", http.StatusConflict},
+		{"Reading", "valid_token", http.StatusConflict},
+		{"Broken", "valid_token", http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		body, _ := json.Marshal(learnings.CategoryRequest{Name: test.name})
+
+		req, _ := http.NewRequest("POST", ts.URL+"/learning/categories", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		req.Header.Set("Content-Type", "application/json")
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("%q: expected %d, got %d", test.name, test.expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestRenameCategory(t *testing.T) {
+	tests := []struct {
+		path     string
+		name     string
+		token    string
+		expected int
+	}{
+		{"/learning/categories/6", "Books", "valid_token", http.StatusOK},
+		{"/learning/categories/6", "Reading", "valid_token", http.StatusOK},
+		{"/learning/categories/6", "Talks", "valid_token", http.StatusConflict},
+		{"/learning/categories/6", "Broken", "valid_token", http.StatusInternalServerError},
+		{"/learning/categories/6", "Books", "user2_token", http.StatusUnauthorized},
+		{"/learning/categories/1", "Books", "valid_token", http.StatusUnauthorized},
+		{"/learning/categories/99", "Books", "valid_token", http.StatusNotFound},
+		{"/learning/categories/abc", "Books", "valid_token", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		body, _ := json.Marshal(learnings.CategoryRequest{Name: test.name})
+
+		req, _ := http.NewRequest("PATCH", ts.URL+test.path, bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		req.Header.Set("Content-Type", "application/json")
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("%s %q: expected %d, got %d", test.path, test.name, test.expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestReorderCategories(t *testing.T) {
+	tests := []struct {
+		ids      []int
+		expected int
+	}{
+		{[]int{7, 6}, http.StatusOK},
+		{[]int{7}, http.StatusBadRequest},
+		{[]int{7, 6, 6}, http.StatusBadRequest},
+		{[]int{7, 6, 1}, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		body, _ := json.Marshal(learnings.ReorderCategoriesRequest{IDs: test.ids})
+
+		req, _ := http.NewRequest("PUT", ts.URL+"/learning/categories/order", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer valid_token")
+		req.Header.Set("Content-Type", "application/json")
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("%v: expected %d, got %d", test.ids, test.expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestDeleteCategory(t *testing.T) {
+	tests := []struct {
+		path     string
+		token    string
+		expected int
+	}{
+		{"/learning/categories/6", "valid_token", http.StatusNoContent},
+		{"/learning/categories/7", "valid_token", http.StatusConflict},
+		{"/learning/categories/6", "user2_token", http.StatusUnauthorized},
+		{"/learning/categories/2", "valid_token", http.StatusUnauthorized},
+		{"/learning/categories/99", "valid_token", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("DELETE", ts.URL+test.path, nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.path, test.expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestPersonalAccessTokenScopes(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"GET", "/learning/tags", "", http.StatusOK},
+		{"GET", "/learning/categories", "", http.StatusOK},
+		{"POST", "/learning", `{"title": "Go", "category": "Languages"}`, http.StatusForbidden},
+		{"DELETE", "/learning/1", "", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, ts.URL+test.path, bytes.NewBufferString(test.body))
+		req.Header.Set("Authorization", "Bearer read_learnings_pat")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("%s %s: expected %d, got %d", test.method, test.path, test.expected, resp.StatusCode)
+		}
+	}
+}
@@ -5,14 +5,19 @@ import (
 	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 
+	"software-slayer/auth"
 	"software-slayer/db"
 	"software-slayer/learnings"
+	"software-slayer/utils"
 )
 
+var learningColumns = []string{"id", "category", "title", "status", "created_at", "started_at", "completed_at", "visibility", "tags"}
+
 func setup(t *testing.T) (sqlmock.Sqlmock, *learnings.LearningsServiceImpl) {
 	// Create a mock sql.DB object using sqlmock
 	database, mock, err := sqlmock.New()
@@ -36,12 +41,52 @@ func TestCreateLearning_Success(t *testing.T) {
 	title := "Go Programming"
 	category := "Languages"
 
+	dbMock.ExpectBegin()
 	dbMock.ExpectExec("INSERT INTO user_learning_list").
-		WithArgs(userId, title, category).
+		WithArgs(userId, title, category, userId, nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	dbMock.ExpectCommit()
 
 	// Execute
-	err := service.CreateLearning(ctx, userId, title, category)
+	err := service.CreateLearning(ctx, userId, title, category, nil, "")
+
+	// Verify
+	assert.NoError(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestCreateLearning_WithTags(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+	title := "Go Programming"
+	category := "Languages"
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("INSERT INTO user_learning_list").
+		WithArgs(userId, title, category, userId, nil).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	dbMock.ExpectExec("DELETE FROM learning_tags").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbMock.ExpectExec("INSERT INTO tags").
+		WithArgs(userId, "go").
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	dbMock.ExpectExec("INSERT INTO learning_tags").
+		WithArgs(7, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("INSERT INTO tags").
+		WithArgs(userId, "backend").
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	dbMock.ExpectExec("INSERT INTO learning_tags").
+		WithArgs(7, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	// Execute - duplicate and differently-cased tags are collapsed
+	err := service.CreateLearning(ctx, userId, title, category, []string{" Go", "backend", "go"}, "")
 
 	// Verify
 	assert.NoError(t, err)
@@ -57,12 +102,14 @@ func TestCreateLearning_DBError(t *testing.T) {
 	title := "Go Programming"
 	category := "Languages"
 
+	dbMock.ExpectBegin()
 	dbMock.ExpectExec("INSERT INTO user_learning_list").
-		WithArgs(userId, title, category).
+		WithArgs(userId, title, category, userId, nil).
 		WillReturnError(errors.New("database error"))
+	dbMock.ExpectRollback()
 
 	// Execute
-	err := service.CreateLearning(ctx, userId, title, category)
+	err := service.CreateLearning(ctx, userId, title, category, nil, "")
 
 	// Verify
 	assert.Error(t, err)
@@ -79,12 +126,14 @@ func TestCreateLearning_DuplicateEntry(t *testing.T) {
 	title := "Go Programming"
 	category := "Languages"
 
+	dbMock.ExpectBegin()
 	dbMock.ExpectExec("INSERT INTO user_learning_list").
-		WithArgs(userId, title, category).
+		WithArgs(userId, title, category, userId, nil).
 		WillReturnError(errors.New("Error 1062: Duplicate entry"))
+	dbMock.ExpectRollback()
 
 	// Execute
-	err := service.CreateLearning(ctx, userId, title, category)
+	err := service.CreateLearning(ctx, userId, title, category, nil, "")
 
 	// Verify
 	assert.Error(t, err)
@@ -92,175 +141,1065 @@ func TestCreateLearning_DuplicateEntry(t *testing.T) {
 	assert.NoError(t, dbMock.ExpectationsWereMet())
 }
 
-// DeleteLearning tests
+func TestCreateLearning_TagError(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
 
-func TestDeleteLearning_Success(t *testing.T) {
+	userId := 1
+	title := "Go Programming"
+	category := "Languages"
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("INSERT INTO user_learning_list").
+		WithArgs(userId, title, category, userId, nil).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	dbMock.ExpectExec("DELETE FROM learning_tags").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbMock.ExpectExec("INSERT INTO tags").
+		WithArgs(userId, "go").
+		WillReturnError(errors.New("database error"))
+	dbMock.ExpectRollback()
+
+	// Execute
+	err := service.CreateLearning(ctx, userId, title, category, []string{"go"}, "")
+
+	// Verify - the learning item insert is rolled back along with the tags
+	assert.Error(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestCreateLearning_WithVisibility(t *testing.T) {
 	// Setup
 	dbMock, service := setup(t)
 	ctx := context.Background()
 
-	learningId := 1
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("INSERT INTO user_learning_list \\(user_id, title, category_id, visibility\\)").
+		WithArgs(1, "Journaling", "Other", 1, "private").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	dbMock.ExpectCommit()
 
-	dbMock.ExpectExec("DELETE FROM user_learning_list").
-		WithArgs(learningId).
+	// Execute
+	err := service.CreateLearning(ctx, 1, "Journaling", "Other", nil, "private")
+
+	// Verify
+	assert.NoError(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+// ImportLearnings tests
+
+func TestImportLearnings_Skip(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	requests := []learnings.CreateLearningRequest{
+		{Tags: []string{"go"}, LearningBase: learnings.LearningBase{Title: "Go Programming", Category: learnings.Languages}},
+		{Visibility: "private", LearningBase: learnings.LearningBase{Title: "Docker", Category: learnings.Technologies}},
+		{Tags: []string{"rust"}, LearningBase: learnings.LearningBase{Title: "Rust", Category: learnings.Languages}},
+	}
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("INSERT INTO user_learning_list .* ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID\\(id\\)$").
+		WithArgs(1, "Go Programming", learnings.Languages, 1, nil).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	dbMock.ExpectExec("DELETE FROM learning_tags").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbMock.ExpectExec("INSERT INTO tags").
+		WithArgs(1, "go").
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	dbMock.ExpectExec("INSERT INTO learning_tags").
+		WithArgs(7, 3).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("INSERT INTO user_learning_list").
+		WithArgs(1, "Docker", learnings.Technologies, 1, "private").
+		WillReturnResult(sqlmock.NewResult(8, 1))
+	// Rust already exists, so its tags are left alone
+	dbMock.ExpectExec("INSERT INTO user_learning_list").
+		WithArgs(1, "Rust", learnings.Languages, 1, nil).
+		WillReturnResult(sqlmock.NewResult(2, 0))
+	dbMock.ExpectCommit()
 
 	// Execute
-	err := service.DeleteLearning(ctx, learningId)
+	result, err := service.ImportLearnings(ctx, 1, requests, learnings.OnDuplicateSkip, false)
 
 	// Verify
 	assert.NoError(t, err)
+	assert.Equal(t, learnings.ImportResult{Inserted: 2, Skipped: 1}, result)
 	assert.NoError(t, dbMock.ExpectationsWereMet())
 }
 
-func TestDeleteLearning_NotFound(t *testing.T) {
+func TestImportLearnings_Update(t *testing.T) {
 	// Setup
 	dbMock, service := setup(t)
 	ctx := context.Background()
 
-	learningId := 999
+	requests := []learnings.CreateLearningRequest{
+		{Visibility: "public", LearningBase: learnings.LearningBase{Title: "Rust", Category: learnings.Languages}},
+	}
 
-	dbMock.ExpectExec("DELETE FROM user_learning_list").
-		WithArgs(learningId).
-		WillReturnResult(sqlmock.NewResult(0, 0))
+	// Existing items have their tags replaced, even with none
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("INSERT INTO user_learning_list .* visibility = VALUES\\(visibility\\)").
+		WithArgs(1, "Rust", learnings.Languages, 1, "public").
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	dbMock.ExpectExec("DELETE FROM learning_tags").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
 
 	// Execute
-	err := service.DeleteLearning(ctx, learningId)
+	result, err := service.ImportLearnings(ctx, 1, requests, learnings.OnDuplicateUpdate, false)
 
-	// Verify - Note: Current implementation doesn't return error for no rows affected
-	// This is a design choice that could be changed if needed
+	// Verify
 	assert.NoError(t, err)
+	assert.Equal(t, learnings.ImportResult{Updated: 1}, result)
 	assert.NoError(t, dbMock.ExpectationsWereMet())
 }
 
-func TestDeleteLearning_DBError(t *testing.T) {
+func TestImportLearnings_DryRun(t *testing.T) {
 	// Setup
 	dbMock, service := setup(t)
 	ctx := context.Background()
 
-	learningId := 1
+	requests := []learnings.CreateLearningRequest{
+		{LearningBase: learnings.LearningBase{Title: "Docker", Category: learnings.Technologies}},
+	}
 
-	dbMock.ExpectExec("DELETE FROM user_learning_list").
-		WithArgs(learningId).
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("INSERT INTO user_learning_list").
+		WithArgs(1, "Docker", learnings.Technologies, 1, nil).
+		WillReturnResult(sqlmock.NewResult(8, 1))
+	dbMock.ExpectRollback()
+
+	// Execute
+	result, err := service.ImportLearnings(ctx, 1, requests, learnings.OnDuplicateSkip, true)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, learnings.ImportResult{Inserted: 1}, result)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestImportLearnings_Error(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	requests := []learnings.CreateLearningRequest{
+		{LearningBase: learnings.LearningBase{Title: "Docker", Category: learnings.Technologies}},
+		{LearningBase: learnings.LearningBase{Title: "Go Programming", Category: learnings.Languages}},
+	}
+
+	// A failed row rolls back the rows imported before it
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("INSERT INTO user_learning_list").
+		WithArgs(1, "Docker", learnings.Technologies, 1, nil).
+		WillReturnResult(sqlmock.NewResult(8, 1))
+	dbMock.ExpectExec("INSERT INTO user_learning_list").
+		WithArgs(1, "Go Programming", learnings.Languages, 1, nil).
 		WillReturnError(errors.New("database error"))
+	dbMock.ExpectRollback()
 
 	// Execute
-	err := service.DeleteLearning(ctx, learningId)
+	_, err := service.ImportLearnings(ctx, 1, requests, learnings.OnDuplicateSkip, false)
 
 	// Verify
 	assert.Error(t, err)
-	assert.Equal(t, "database error", err.Error())
 	assert.NoError(t, dbMock.ExpectationsWereMet())
 }
 
-// GetLearningsByUserId tests
+// RunBatch tests
 
-func TestGetLearningsByUserId_Success(t *testing.T) {
+func TestRunBatch_Atomic(t *testing.T) {
 	// Setup
 	dbMock, service := setup(t)
 	ctx := context.Background()
 
-	userId := 1
-	expectedLearnings := []learnings.GetLearningResponse{
-		{
-			ID: 1,
-			LearningBase: learnings.LearningBase{
-				Title:    "Go Programming",
-				Category: "Languages",
-			},
-		},
-		{
-			ID: 2,
-			LearningBase: learnings.LearningBase{
-				Title:    "Docker",
-				Category: "Technologies",
-			},
-		},
+	title := "Rust"
+	operations := []learnings.BatchOperation{
+		{Op: learnings.BatchOpCreate, UserID: 1, Create: &learnings.CreateLearningRequest{LearningBase: learnings.LearningBase{Title: "Go", Category: learnings.Languages}}},
+		{Op: learnings.BatchOpUpdate, UserID: 1, ID: 3, Update: &learnings.UpdateLearningRequest{Title: &title}},
+		{Op: learnings.BatchOpDelete, UserID: 1, ID: 4},
 	}
 
-	rows := sqlmock.NewRows([]string{"id", "category", "title"})
-	for _, learning := range expectedLearnings {
-		rows.AddRow(learning.ID, learning.Category, learning.Title)
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("INSERT INTO user_learning_list").
+		WithArgs(1, "Go", learnings.Languages, 1, nil).
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	dbMock.ExpectQuery("SELECT 1 FROM user_learning_list WHERE id = \\? AND user_id = \\? FOR UPDATE").
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	dbMock.ExpectExec("UPDATE user_learning_list SET title").
+		WithArgs(&title, nil, 1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("DELETE FROM user_learning_list WHERE id = \\?").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	// Execute
+	results, err := service.RunBatch(ctx, operations, true)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, []learnings.BatchResult{{ID: 9}, {ID: 3}, {ID: 4}}, results)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestRunBatch_AtomicRollback(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	operations := []learnings.BatchOperation{
+		{Op: learnings.BatchOpDelete, UserID: 1, ID: 4},
+		{Op: learnings.BatchOpDelete, UserID: 1, ID: 4},
+		{Op: learnings.BatchOpDelete, UserID: 1, ID: 5},
 	}
 
-	dbMock.ExpectQuery("SELECT id, category, title FROM user_learning_list").
-		WithArgs(userId).
-		WillReturnRows(rows)
+	// The second delete finds nothing left to delete, so the batch stops and the first delete is rolled back
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("DELETE FROM user_learning_list").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("DELETE FROM user_learning_list").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbMock.ExpectRollback()
 
 	// Execute
-	learningItems, err := service.GetLearningsByUserId(ctx, userId)
+	results, err := service.RunBatch(ctx, operations, true)
 
 	// Verify
 	assert.NoError(t, err)
-	assert.Len(t, learningItems, 2)
-	assert.Equal(t, expectedLearnings, learningItems)
+	assert.Equal(t, []learnings.BatchResult{{ID: 4}, {ID: 4, Err: sql.ErrNoRows}, {}}, results)
 	assert.NoError(t, dbMock.ExpectationsWereMet())
 }
 
-func TestGetLearningsByUserId_NoItems(t *testing.T) {
+func TestRunBatch_UpdateDeletedItem(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	title := "Rust"
+	operations := []learnings.BatchOperation{
+		{Op: learnings.BatchOpDelete, UserID: 1, ID: 3},
+		{Op: learnings.BatchOpUpdate, UserID: 1, ID: 3, Update: &learnings.UpdateLearningRequest{Title: &title}},
+	}
+
+	// The update finds the item deleted by the first operation gone, instead of updating nothing
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("DELETE FROM user_learning_list").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectQuery("SELECT 1 FROM user_learning_list WHERE id = \\? AND user_id = \\? FOR UPDATE").
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"1"}))
+	dbMock.ExpectRollback()
+
+	// Execute
+	results, err := service.RunBatch(ctx, operations, true)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, []learnings.BatchResult{{ID: 3}, {ID: 3, Err: sql.ErrNoRows}}, results)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestRunBatch_BestEffort(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	operations := []learnings.BatchOperation{
+		{Op: learnings.BatchOpCreate, UserID: 1, Create: &learnings.CreateLearningRequest{Tags: []string{"go"}, LearningBase: learnings.LearningBase{Title: "Go", Category: learnings.Languages}}},
+		{Op: learnings.BatchOpDelete, UserID: 1, ID: 4},
+	}
+
+	// Each operation has its own transaction, the failing create doesn't stop the delete
+	duplicate := errors.New("Error 1062: Duplicate entry")
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("INSERT INTO user_learning_list").
+		WithArgs(1, "Go", learnings.Languages, 1, nil).
+		WillReturnError(duplicate)
+	dbMock.ExpectRollback()
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("DELETE FROM user_learning_list").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	// Execute
+	results, err := service.RunBatch(ctx, operations, false)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, []learnings.BatchResult{{Err: duplicate}, {ID: 4}}, results)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+// UpdateLearning tests
+
+func TestUpdateLearning_Success(t *testing.T) {
 	// Setup
 	dbMock, service := setup(t)
 	ctx := context.Background()
 
 	userId := 1
+	learningId := 1
+	title := "Go Generics"
+	category := "Concepts"
 
-	rows := sqlmock.NewRows([]string{"id", "category", "title"})
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("UPDATE user_learning_list SET title = COALESCE\\(\\?, title\\), category_id = COALESCE\\(\\(SELECT id FROM categories .*\\), category_id\\) WHERE id = \\?").
+		WithArgs(title, category, userId, learningId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
 
-	dbMock.ExpectQuery("SELECT id, category, title FROM user_learning_list").
-		WithArgs(userId).
-		WillReturnRows(rows)
+	// Execute
+	err := service.UpdateLearning(ctx, userId, learningId, &learnings.UpdateLearningRequest{Title: &title, Category: &category})
+
+	// Verify
+	assert.NoError(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestUpdateLearning_Visibility(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	private := "private"
+	inherit := learnings.VisibilityInherit
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("UPDATE user_learning_list SET visibility = \\? WHERE id = \\?").
+		WithArgs("private", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+	// Inheriting the list's visibility removes the override
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("UPDATE user_learning_list SET visibility = \\? WHERE id = \\?").
+		WithArgs(nil, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
 
 	// Execute
-	learningItems, err := service.GetLearningsByUserId(ctx, userId)
+	err := service.UpdateLearning(ctx, 1, 1, &learnings.UpdateLearningRequest{Visibility: &private})
+	inheritErr := service.UpdateLearning(ctx, 1, 1, &learnings.UpdateLearningRequest{Visibility: &inherit})
 
 	// Verify
 	assert.NoError(t, err)
-	assert.Empty(t, learningItems)
+	assert.NoError(t, inheritErr)
 	assert.NoError(t, dbMock.ExpectationsWereMet())
 }
 
-func TestGetLearningsByUserId_DBError(t *testing.T) {
+func TestUpdateLearning_PartialUpdate(t *testing.T) {
 	// Setup
 	dbMock, service := setup(t)
 	ctx := context.Background()
 
 	userId := 1
+	learningId := 1
+	title := "Go Generics"
 
-	dbMock.ExpectQuery("SELECT id, category, title FROM user_learning_list").
-		WithArgs(userId).
-		WillReturnError(errors.New("database error"))
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("UPDATE user_learning_list").
+		WithArgs(title, nil, userId, learningId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
 
 	// Execute
-	learningItems, err := service.GetLearningsByUserId(ctx, userId)
+	err := service.UpdateLearning(ctx, userId, learningId, &learnings.UpdateLearningRequest{Title: &title})
 
 	// Verify
-	assert.Error(t, err)
-	assert.Nil(t, learningItems)
-	assert.Equal(t, "database error", err.Error())
+	assert.NoError(t, err)
 	assert.NoError(t, dbMock.ExpectationsWereMet())
 }
 
-func TestGetLearningsByUserId_ScanError(t *testing.T) {
+func TestUpdateLearning_ClearTags(t *testing.T) {
 	// Setup
 	dbMock, service := setup(t)
 	ctx := context.Background()
 
 	userId := 1
+	learningId := 1
+	tags := []string{}
 
-	// Create a row with wrong types to cause a scan error
-	rows := sqlmock.NewRows([]string{"id", "category", "title"}).
-		AddRow("not an int", 123, 456) // ID should be int, not string
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("DELETE FROM learning_tags").
+		WithArgs(learningId).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	dbMock.ExpectCommit()
 
-	dbMock.ExpectQuery("SELECT id, category, title FROM user_learning_list").
-		WithArgs(userId).
-		WillReturnRows(rows)
+	// Execute
+	err := service.UpdateLearning(ctx, userId, learningId, &learnings.UpdateLearningRequest{Tags: &tags})
+
+	// Verify
+	assert.NoError(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestUpdateLearning_DuplicateEntry(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+	learningId := 1
+	title := "Go Generics"
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("UPDATE user_learning_list").
+		WithArgs(title, nil, userId, learningId).
+		WillReturnError(errors.New("Error 1062: Duplicate entry"))
+	dbMock.ExpectRollback()
+
+	// Execute
+	err := service.UpdateLearning(ctx, userId, learningId, &learnings.UpdateLearningRequest{Title: &title})
+
+	// Verify
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Duplicate entry")
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+// DeleteLearning tests
+
+func TestDeleteLearning_Success(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	learningId := 1
+
+	dbMock.ExpectExec("DELETE FROM user_learning_list").
+		WithArgs(learningId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Execute
+	err := service.DeleteLearning(ctx, learningId)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestDeleteLearning_NotFound(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	learningId := 999
+
+	dbMock.ExpectExec("DELETE FROM user_learning_list").
+		WithArgs(learningId).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Execute
+	err := service.DeleteLearning(ctx, learningId)
+
+	// Verify - Note: Current implementation doesn't return error for no rows affected
+	// This is a design choice that could be changed if needed
+	assert.NoError(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestDeleteLearning_DBError(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	learningId := 1
+
+	dbMock.ExpectExec("DELETE FROM user_learning_list").
+		WithArgs(learningId).
+		WillReturnError(errors.New("database error"))
+
+	// Execute
+	err := service.DeleteLearning(ctx, learningId)
+
+	// Verify
+	assert.Error(t, err)
+	assert.Equal(t, "database error", err.Error())
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+// GetLearningsByUserId tests
+
+var firstPage = utils.PageRequest{Limit: 20, Sort: "id", Column: "l.id"}
+
+func TestGetLearningsByUserId_Success(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	startedAt := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	expectedLearnings := []learnings.GetLearningResponse{
+		{
+			ID:        1,
+			Status:    learnings.StatusPlanned,
+			CreatedAt: createdAt,
+			Tags:      []string{"backend", "go"},
+			LearningBase: learnings.LearningBase{
+				Title:    "Go Programming",
+				Category: "Languages",
+			},
+		},
+		{
+			ID:        2,
+			Status:    learnings.StatusInProgress,
+			CreatedAt: createdAt,
+			StartedAt: &startedAt,
+			Tags:      []string{},
+			LearningBase: learnings.LearningBase{
+				Title:    "Docker",
+				Category: "Technologies",
+			},
+		},
+	}
+
+	rows := sqlmock.NewRows(learningColumns).
+		AddRow(1, "Languages", "Go Programming", learnings.StatusPlanned, createdAt, nil, nil, nil, "backend,go").
+		AddRow(2, "Technologies", "Docker", learnings.StatusInProgress, createdAt, startedAt, nil, nil, nil)
+
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM user_learning_list l WHERE l.user_id = \\?").
+		WithArgs(userId).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	dbMock.ExpectQuery("SELECT l.id, c.name, l.title, l.status, l.created_at, l.started_at, l.completed_at, l.visibility, GROUP_CONCAT\\(.*\\) FROM user_learning_list l .* ORDER BY l.id ASC, l.id ASC LIMIT \\?").
+		WithArgs(userId, 21).
+		WillReturnRows(rows)
+
+	// Execute
+	learningItems, err := service.GetLearningsByUserId(ctx, userId, learnings.LearningFilter{}, firstPage)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Len(t, learningItems.Items, 2)
+	assert.Equal(t, expectedLearnings, learningItems.Items)
+	assert.Equal(t, 2, learningItems.Total)
+	assert.Empty(t, learningItems.NextCursor)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestGetLearningsByUserId_NextPage(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	page := utils.PageRequest{Limit: 1, Sort: "title", Column: "l.title", Desc: true,
+		After: &utils.Cursor{Sort: "title", Desc: true, Value: "Kubernetes", ID: 5}}
+
+	// The extra row beyond the limit signals that another page exists
+	rows := sqlmock.NewRows(learningColumns).
+		AddRow(1, "Languages", "Go Programming", learnings.StatusPlanned, createdAt, nil, nil, nil, nil).
+		AddRow(2, "Technologies", "Docker", learnings.StatusPlanned, createdAt, nil, nil, nil, nil)
+
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM user_learning_list l").
+		WithArgs(userId).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	dbMock.ExpectQuery("WHERE l.user_id = \\? AND \\(l.title < \\? OR \\(l.title = \\? AND l.id < \\?\\)\\) GROUP BY l.id, c.name ORDER BY l.title DESC, l.id DESC LIMIT \\?").
+		WithArgs(userId, "Kubernetes", "Kubernetes", 5, 2).
+		WillReturnRows(rows)
+
+	// Execute
+	learningItems, err := service.GetLearningsByUserId(ctx, userId, learnings.LearningFilter{}, page)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Len(t, learningItems.Items, 1)
+	assert.Equal(t, 3, learningItems.Total)
+	assert.NotEmpty(t, learningItems.NextCursor)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestGetLearningsByUserId_NoItems(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+
+	rows := sqlmock.NewRows(learningColumns)
+
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM user_learning_list l").
+		WithArgs(userId).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	dbMock.ExpectQuery("SELECT l.id, c.name, l.title, l.status, l.created_at, l.started_at, l.completed_at, l.visibility, GROUP_CONCAT\\(.*\\) FROM user_learning_list l").
+		WithArgs(userId, 21).
+		WillReturnRows(rows)
+
+	// Execute
+	learningItems, err := service.GetLearningsByUserId(ctx, userId, learnings.LearningFilter{}, firstPage)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Empty(t, learningItems.Items)
+	assert.Equal(t, 0, learningItems.Total)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestGetLearningsByUserId_DBError(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM user_learning_list l").
+		WithArgs(userId).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	dbMock.ExpectQuery("SELECT l.id, c.name, l.title, l.status, l.created_at, l.started_at, l.completed_at, l.visibility, GROUP_CONCAT\\(.*\\) FROM user_learning_list l").
+		WithArgs(userId, 21).
+		WillReturnError(errors.New("database error"))
+
+	// Execute
+	learningItems, err := service.GetLearningsByUserId(ctx, userId, learnings.LearningFilter{}, firstPage)
+
+	// Verify
+	assert.Error(t, err)
+	assert.Nil(t, learningItems.Items)
+	assert.Equal(t, "database error", err.Error())
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestGetLearningsByUserId_CountError(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM user_learning_list l").
+		WithArgs(userId).
+		WillReturnError(errors.New("database error"))
+
+	// Execute
+	learningItems, err := service.GetLearningsByUserId(ctx, userId, learnings.LearningFilter{}, firstPage)
+
+	// Verify
+	assert.Error(t, err)
+	assert.Nil(t, learningItems.Items)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestGetLearningsByUserId_ScanError(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+
+	// Create a row with wrong types to cause a scan error
+	rows := sqlmock.NewRows(learningColumns).
+		AddRow("not an int", 123, 456, "planned", time.Now(), nil, nil, nil, nil) // ID should be int, not string
+
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM user_learning_list l").
+		WithArgs(userId).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	dbMock.ExpectQuery("SELECT l.id, c.name, l.title, l.status, l.created_at, l.started_at, l.completed_at, l.visibility, GROUP_CONCAT\\(.*\\) FROM user_learning_list l").
+		WithArgs(userId, 21).
+		WillReturnRows(rows)
+
+	// Execute
+	learningItems, err := service.GetLearningsByUserId(ctx, userId, learnings.LearningFilter{}, firstPage)
+
+	// Verify
+	assert.Error(t, err)
+	assert.Nil(t, learningItems.Items)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestGetLearningsByUserId_StatusFilter(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+
+	rows := sqlmock.NewRows(learningColumns)
+
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM user_learning_list l WHERE l.user_id = \\? AND l.status = \\?").
+		WithArgs(userId, learnings.StatusCompleted).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	dbMock.ExpectQuery("SELECT .* FROM user_learning_list l .* WHERE l.user_id = \\? AND l.status = \\? GROUP BY l.id, c.name").
+		WithArgs(userId, learnings.StatusCompleted, 21).
+		WillReturnRows(rows)
+
+	// Execute
+	learningItems, err := service.GetLearningsByUserId(ctx, userId, learnings.LearningFilter{Status: learnings.StatusCompleted}, firstPage)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Empty(t, learningItems.Items)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestGetLearningsByUserId_TagFilter(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+
+	rows := sqlmock.NewRows(learningColumns)
+
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM user_learning_list l").
+		WithArgs(userId, "go", "docker", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	dbMock.ExpectQuery("WHERE fn.name IN \\(\\?, \\?\\) GROUP BY ft.learning_id HAVING COUNT\\(\\*\\) >= \\?\\) GROUP BY l.id, c.name").
+		WithArgs(userId, "go", "docker", 2, 21).
+		WillReturnRows(rows)
+
+	// Execute
+	learningItems, err := service.GetLearningsByUserId(ctx, userId,
+		learnings.LearningFilter{Tags: []string{"go", "docker"}, MatchAllTags: true}, firstPage)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Empty(t, learningItems.Items)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestGetLearningsByUserId_VisibilityFilter(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+	rows := sqlmock.NewRows(learningColumns).
+		AddRow(3, "Other", "Journaling", learnings.StatusPlanned, time.Now(), nil, nil, auth.VisibilityOrganization, nil)
+
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM user_learning_list l").
+		WithArgs(userId, auth.VisibilityPublic, auth.VisibilityOrganization).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	dbMock.ExpectQuery("AND COALESCE\\(l.visibility, \\(SELECT u.learnings_visibility FROM users u WHERE u.id = l.user_id\\)\\) IN \\(\\?, \\?\\) GROUP BY l.id, c.name").
+		WithArgs(userId, auth.VisibilityPublic, auth.VisibilityOrganization, 21).
+		WillReturnRows(rows)
+
+	// Execute
+	learningItems, err := service.GetLearningsByUserId(ctx, userId,
+		learnings.LearningFilter{Visibilities: []auth.Visibility{auth.VisibilityPublic, auth.VisibilityOrganization}}, firstPage)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Len(t, learningItems.Items, 1)
+	assert.Equal(t, auth.VisibilityOrganization, *learningItems.Items[0].Visibility)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+// GetLearningsVisibility tests
+
+func TestGetLearningsVisibility(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	dbMock.ExpectQuery("SELECT learnings_visibility FROM users WHERE id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"learnings_visibility"}).AddRow("private"))
+	dbMock.ExpectQuery("SELECT learnings_visibility FROM users WHERE id = \\?").
+		WithArgs(999).
+		WillReturnRows(sqlmock.NewRows([]string{"learnings_visibility"}))
+
+	// Execute
+	visibility, err := service.GetLearningsVisibility(ctx, 1)
+	_, notFoundErr := service.GetLearningsVisibility(ctx, 999)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, auth.VisibilityPrivate, visibility)
+	assert.ErrorIs(t, notFoundErr, sql.ErrNoRows)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+// UpdateLearningStatus tests
+
+func TestUpdateLearningStatus_Completed(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	learningId := 1
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery("SELECT status FROM user_learning_list WHERE id = \\? FOR UPDATE").
+		WithArgs(learningId).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(learnings.StatusInProgress))
+	dbMock.ExpectExec("UPDATE user_learning_list SET status = \\?, started_at = COALESCE\\(started_at, CURRENT_TIMESTAMP\\), completed_at = CURRENT_TIMESTAMP").
+		WithArgs(learnings.StatusCompleted, learningId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	// Execute
+	previous, err := service.UpdateLearningStatus(ctx, learningId, learnings.StatusCompleted)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, learnings.StatusInProgress, previous)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestUpdateLearningStatus_Planned(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	learningId := 1
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery("SELECT status FROM user_learning_list WHERE id = \\? FOR UPDATE").
+		WithArgs(learningId).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(learnings.StatusAbandoned))
+	dbMock.ExpectExec("UPDATE user_learning_list SET status = \\?, started_at = NULL, completed_at = NULL").
+		WithArgs(learnings.StatusPlanned, learningId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	// Execute
+	previous, err := service.UpdateLearningStatus(ctx, learningId, learnings.StatusPlanned)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, learnings.StatusAbandoned, previous)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestUpdateLearningStatus_IllegalTransition(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	learningId := 1
+
+	// Completed by a concurrent request since the item was last read
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery("SELECT status FROM user_learning_list WHERE id = \\? FOR UPDATE").
+		WithArgs(learningId).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(learnings.StatusCompleted))
+	dbMock.ExpectRollback()
+
+	// Execute
+	_, err := service.UpdateLearningStatus(ctx, learningId, learnings.StatusAbandoned)
+
+	// Verify
+	assert.ErrorIs(t, err, learnings.ErrStatusTransition)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestUpdateLearningStatus_NotFound(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	learningId := 999
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery("SELECT status FROM user_learning_list WHERE id = \\? FOR UPDATE").
+		WithArgs(learningId).
+		WillReturnError(sql.ErrNoRows)
+	dbMock.ExpectRollback()
+
+	// Execute
+	_, err := service.UpdateLearningStatus(ctx, learningId, learnings.StatusInProgress)
+
+	// Verify
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestUpdateLearningStatus_UnknownStatus(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	// Execute
+	_, err := service.UpdateLearningStatus(ctx, 1, "finished")
+
+	// Verify
+	assert.Error(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+// GetTagsByUserId tests
+
+func TestGetTagsByUserId_Success(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+
+	rows := sqlmock.NewRows([]string{"name", "count"}).
+		AddRow("backend", 2).
+		AddRow("go", 1)
+
+	dbMock.ExpectQuery("SELECT t.name, COUNT\\(\\*\\) FROM tags t").
+		WithArgs(userId).
+		WillReturnRows(rows)
+
+	// Execute
+	tags, err := service.GetTagsByUserId(ctx, userId)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, []learnings.TagCount{{Name: "backend", Count: 2}, {Name: "go", Count: 1}}, tags)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestGetTagsByUserId_DBError(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	dbMock.ExpectQuery("SELECT t.name, COUNT\\(\\*\\) FROM tags t").
+		WithArgs(1).
+		WillReturnError(errors.New("database error"))
+
+	// Execute
+	tags, err := service.GetTagsByUserId(ctx, 1)
+
+	// Verify
+	assert.Error(t, err)
+	assert.Nil(t, tags)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+// Category tests
+
+func TestGetCategories_Success(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+
+	rows := sqlmock.NewRows([]string{"id", "name", "custom"}).
+		AddRow(1, learnings.Languages, false).
+		AddRow(6, "Reading", true)
+
+	dbMock.ExpectQuery("SELECT id, name, user_id IS NOT NULL FROM categories WHERE user_id IS NULL OR user_id = \\?").
+		WithArgs(userId).
+		WillReturnRows(rows)
+
+	// Execute
+	categories, err := service.GetCategories(ctx, userId)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, []learnings.Category{
+		{ID: 1, Name: learnings.Languages, Custom: false},
+		{ID: 6, Name: "Reading", Custom: true},
+	}, categories)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestGetCategoryId_NotFound(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	dbMock.ExpectQuery("SELECT id FROM categories WHERE name = \\? AND \\(user_id IS NULL OR user_id = \\?\\)").
+		WithArgs("Reading", 2).
+		WillReturnError(sql.ErrNoRows)
+
+	// Execute
+	_, err := service.GetCategoryId(ctx, 2, "Reading")
+
+	// Verify
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestGetUserByCategoryId_DefaultCategory(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	rows := sqlmock.NewRows([]string{"user_id"}).
+		AddRow(nil)
+
+	dbMock.ExpectQuery("SELECT user_id FROM categories").
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	// Execute
+	userId, err := service.GetUserByCategoryId(ctx, 1)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, 0, userId)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestCreateCategory_Success(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+
+	dbMock.ExpectExec("INSERT INTO categories \\(user_id, name, position\\) SELECT \\?, \\?, COALESCE\\(MAX\\(position\\) \\+ 1, 0\\)").
+		WithArgs(userId, "Reading", userId).
+		WillReturnResult(sqlmock.NewResult(6, 1))
+
+	// Execute
+	categoryId, err := service.CreateCategory(ctx, userId, "Reading")
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Equal(t, 6, categoryId)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestReorderCategories_Success(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	userId := 1
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("UPDATE categories SET position = \\? WHERE id = \\? AND user_id = \\?").
+		WithArgs(0, 7, userId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("UPDATE categories SET position = \\? WHERE id = \\? AND user_id = \\?").
+		WithArgs(1, 6, userId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	// Execute
+	err := service.ReorderCategories(ctx, userId, []int{7, 6})
+
+	// Verify
+	assert.NoError(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestDeleteCategory_InUse(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	dbMock.ExpectExec("DELETE FROM categories").
+		WithArgs(6).
+		WillReturnError(errors.New("Error 1451: Cannot delete or update a parent row: a foreign key constraint fails"))
 
 	// Execute
-	learningItems, err := service.GetLearningsByUserId(ctx, userId)
+	err := service.DeleteCategory(ctx, 6)
 
 	// Verify
 	assert.Error(t, err)
-	assert.Nil(t, learningItems)
+	assert.Contains(t, err.Error(), "foreign key constraint")
 	assert.NoError(t, dbMock.ExpectationsWereMet())
 }
 
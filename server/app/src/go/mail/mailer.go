@@ -0,0 +1,98 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidHeader = errors.New("message headers must not contain line breaks")
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails to users
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// InMemoryMailer keeps every message it is sent, for tests
+type InMemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewInMemoryMailer() *InMemoryMailer {
+	return &InMemoryMailer{}
+}
+
+func (m *InMemoryMailer) Send(ctx context.Context, message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// Messages returns a copy of the messages sent so far
+func (m *InMemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message(nil), m.messages...)
+}
+
+// FileMailer writes each message to a file instead of sending it, for local development
+type FileMailer struct {
+	dir string
+}
+
+func NewFileMailer(dir string) *FileMailer {
+	return &FileMailer{dir: dir}
+}
+
+/*
+ * Write a message to <dir>/<timestamp>-<recipient>.eml
+ * @param ctx: the context
+ * @param message: the message to write
+ * @return error: an error if the message could not be written
+ */
+func (m *FileMailer) Send(ctx context.Context, message Message) error {
+	if err := validateHeaders(message); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), filepath.Base(message.To))
+	return os.WriteFile(filepath.Join(m.dir, name), formatMessage("", message), 0600)
+}
+
+// formatMessage formats a message as an RFC 5322 email
+func formatMessage(from string, message Message) []byte {
+	header := ""
+	if from != "" {
+		header += fmt.Sprintf("From: %s\r\n", from)
+	}
+	header += fmt.Sprintf("To: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n",
+		message.To, message.Subject, time.Now().Format(time.RFC1123Z))
+	return []byte(header + message.Body)
+}
+
+// validateHeaders prevents header injection, the recipient and subject are written into headers as is
+func validateHeaders(message Message) error {
+	if strings.ContainsAny(message.To+message.Subject, "\r\n") {
+		return ErrInvalidHeader
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package mail_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"software-slayer/mail"
+)
+
+func TestInMemoryMailer(t *testing.T) {
+	mailer := mail.NewInMemoryMailer()
+	message := mail.Message{To: "user@example.com", Subject: "Hello", Body: "Body"}
+
+	if err := mailer.Send(context.Background(), message); err != nil {
+		t.Fatalf("Send() returned error: %v", err)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != 1 || messages[0] != message {
+		t.Errorf("Messages() = %+v, expected %+v", messages, message)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer := mail.NewFileMailer(dir)
+
+	err := mailer.Send(context.Background(), mail.Message{To: "user@example.com", Subject: "Hello", Body: "Body"})
+	if err != nil {
+		t.Fatalf("Send() returned error: %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected 1 file in %s, got %v, %v", dir, files, err)
+	}
+	if !strings.HasSuffix(files[0].Name(), "-user@example.com.eml") {
+		t.Errorf("unexpected file name %s", files[0].Name())
+	}
+
+	contents, _ := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if !strings.Contains(string(contents), "To: user@example.com\r\nSubject: Hello\r\n") || !strings.HasSuffix(string(contents), "\r\n\r\nBody") {
+		t.Errorf("unexpected message:\n%s", contents)
+	}
+}
+
+func TestMailersRejectHeaderInjection(t *testing.T) {
+	message := mail.Message{To: "user@example.com\r\nBcc: other@example.com", Subject: "Hello", Body: "Body"}
+
+	mailers := []mail.Mailer{
+		mail.NewFileMailer(t.TempDir()),
+		mail.NewSMTPMailer(mail.SMTPConfig{Host: "localhost", Port: "0"}),
+	}
+	for _, mailer := range mailers {
+		if err := mailer.Send(context.Background(), message); !errors.Is(err, mail.ErrInvalidHeader) {
+			t.Errorf("%T.Send() returned %v, expected ErrInvalidHeader", mailer, err)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -13,7 +15,10 @@ import (
 	"software-slayer/configs"
 	"software-slayer/db"
 	_ "software-slayer/docs"
+	"software-slayer/export"
 	"software-slayer/learnings"
+	"software-slayer/mail"
+	"software-slayer/search"
 	"software-slayer/user"
 
 	httpSwagger "github.com/swaggo/http-swagger"
@@ -27,34 +32,246 @@ func main() {
 	log.Println("Starting Software Slayer API server...")
 
 	// Initialize services
-	tokenService := initTokenService()
 	database := initDB()
 	defer database.Close()
 
+	keyring := initKeyring()
+	revocationStore := auth.NewMySQLRevocationStore(database)
+	personalAccessTokenService := auth.NewPersonalAccessTokenService(database)
+	tokenService := auth.NewTokenService(auth.TokenConfig{
+		Issuer:   configs.TOKEN_ISSUER,
+		Audience: configs.TOKEN_AUDIENCE,
+		Lifetime: configs.TOKEN_LIFETIME,
+		Leeway:   configs.TOKEN_LEEWAY,
+	}, keyring, revocationStore, personalAccessTokenService)
+	log.Println("JWT token service initialized successfully")
+
+	verificationPolicy, err := auth.ParseEmailVerificationPolicy(
+		envOrDefault(configs.EMAIL_VERIFICATION_POLICY_ENV_VAR, configs.DEFAULT_EMAIL_VERIFICATION_POLICY))
+	if err != nil {
+		log.Fatalf("Failed to configure email verification: %v", err)
+	}
+	auth.SetEmailVerificationPolicy(verificationPolicy)
+	log.Printf("Unverified users are handled with the %s email verification policy", verificationPolicy)
+
+	initPasswordPolicy()
+
+	// Prune expired token revocations in the background until shutdown
+	pruneCtx, stopPruning := context.WithCancel(context.Background())
+	defer stopPruning()
+	go auth.PruneRevocations(pruneCtx, revocationStore, configs.REVOCATION_PRUNE_PERIOD)
+	loginAttemptStore := auth.NewMySQLLoginAttemptStore(database)
+	go auth.PruneLoginAttempts(pruneCtx, loginAttemptStore, configs.LOGIN_ATTEMPT_PRUNE_PERIOD, configs.LOGIN_ATTEMPT_RESET_AFTER)
+	exportStore := initExportStore()
+	go export.PruneExports(pruneCtx, exportStore, configs.EXPORT_PRUNE_PERIOD, configs.EXPORT_RETENTION)
+	userService := user.NewUserService(database, exportStore)
+	go user.RunScheduledDeletions(pruneCtx, userService, configs.ACCOUNT_DELETION_CHECK_PERIOD)
+
 	initSwagger()
 
 	// Initialize REST handlers
-	user.InitUserRest(user.NewUserService(database), tokenService)
-	learnings.InitLearningsRest(learnings.NewLearningsService(database), tokenService)
+	mailer := initMailer()
+	auth.InitAuthRest(keyring)
+	user.InitUserRest(userService, tokenService, auth.NewRefreshTokenService(database, configs.REFRESH_TOKEN_LIFETIME),
+		personalAccessTokenService)
+	user.InitPasswordRest(mailer, auth.NewPasswordResetService(database, configs.PASSWORD_RESET_TOKEN_LIFETIME),
+		envOrDefault(configs.PASSWORD_RESET_URL_ENV_VAR, configs.DEFAULT_PASSWORD_RESET_URL), configs.PASSWORD_RESET_TOKEN_LIFETIME)
+	user.InitEmailVerificationRest(mailer, auth.NewEmailVerificationService(auth.TokenConfig{
+		Issuer:   configs.TOKEN_ISSUER,
+		Audience: configs.EMAIL_VERIFICATION_AUDIENCE,
+		Lifetime: configs.EMAIL_VERIFICATION_TOKEN_LIFETIME,
+		Leeway:   configs.TOKEN_LEEWAY,
+	}, keyring), envOrDefault(configs.EMAIL_VERIFICATION_URL_ENV_VAR, configs.DEFAULT_EMAIL_VERIFICATION_URL),
+		configs.EMAIL_VERIFICATION_TOKEN_LIFETIME, configs.EMAIL_VERIFICATION_RESEND_INTERVAL)
+	user.InitTwoFactorRest(auth.NewTwoFactorService(database, initSecretCipher(), configs.TWO_FACTOR_ISSUER, auth.TokenConfig{
+		Issuer:   configs.TOKEN_ISSUER,
+		Audience: configs.TWO_FACTOR_AUDIENCE,
+		Lifetime: configs.TWO_FACTOR_CHALLENGE_LIFETIME,
+		Leeway:   configs.TOKEN_LEEWAY,
+	}, keyring))
+	user.InitLoginThrottleRest(auth.NewLoginThrottle(loginAttemptStore, auth.NewMySQLAuditLog(database), auth.ThrottleConfig{
+		FreeAttempts:     configs.LOGIN_ACCOUNT_FREE_ATTEMPTS,
+		BaseDelay:        configs.LOGIN_BACKOFF_BASE_DELAY,
+		MaxDelay:         configs.LOGIN_BACKOFF_MAX_DELAY,
+		LockoutThreshold: configs.LOGIN_ACCOUNT_LOCKOUT_THRESHOLD,
+		LockoutDuration:  configs.LOGIN_LOCKOUT_DURATION,
+		ResetAfter:       configs.LOGIN_ATTEMPT_RESET_AFTER,
+	}, auth.ThrottleConfig{
+		FreeAttempts:     configs.LOGIN_IP_FREE_ATTEMPTS,
+		BaseDelay:        configs.LOGIN_BACKOFF_BASE_DELAY,
+		MaxDelay:         configs.LOGIN_BACKOFF_MAX_DELAY,
+		LockoutThreshold: configs.LOGIN_IP_LOCKOUT_THRESHOLD,
+		LockoutDuration:  configs.LOGIN_LOCKOUT_DURATION,
+		ResetAfter:       configs.LOGIN_ATTEMPT_RESET_AFTER,
+	}))
+	user.InitAccountDeletionRest(envDurationOrDefault(configs.ACCOUNT_DELETION_GRACE_PERIOD_ENV_VAR, configs.DEFAULT_ACCOUNT_DELETION_GRACE_PERIOD))
+	learningsService := learnings.NewLearningsService(database)
+	export.InitExportRest(export.NewExportService(database), exportStore, auth.NewDownloadTokenService(auth.TokenConfig{
+		Issuer:   configs.TOKEN_ISSUER,
+		Audience: configs.EXPORT_DOWNLOAD_AUDIENCE,
+		Lifetime: configs.EXPORT_DOWNLOAD_LINK_LIFETIME,
+		Leeway:   configs.TOKEN_LEEWAY,
+	}, keyring), tokenService, configs.EXPORT_RETENTION)
+	export.InitLearningListExportRest(learningsService)
+	learnings.InitLearningsRest(learningsService, tokenService)
+	search.InitSearchRest(search.NewSearchService(database), tokenService)
 
 	// Start server with graceful shutdown
 	startServerWithGracefulShutdown()
 }
 
 /*
- * Initialize the auth package with the jwt secret and token lifetime
+ * Load the JWT signing keys and reload them whenever the process receives SIGHUP
  */
-func initTokenService() *auth.TokenServiceImpl {
-	jwtSecretPath := os.Getenv(configs.JWT_SECRET_FILE_ENV_VAR)
-	log.Printf("Reading JWT secret from: %s", jwtSecretPath)
+func initKeyring() *auth.Keyring {
+	keysDir := os.Getenv(configs.JWT_KEYS_DIR_ENV_VAR)
+	log.Printf("Reading JWT signing keys from: %s", keysDir)
 
-	jwtSecret, err := os.ReadFile(jwtSecretPath)
+	keyring, err := auth.LoadKeyring(keysDir)
 	if err != nil {
-		log.Fatalf("Failed to read JWT secret file: %v", err)
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
 	}
 
-	log.Println("JWT token service initialized successfully")
-	return auth.NewTokenService(configs.TOKEN_LIFETIME, jwtSecret)
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			log.Println("Received SIGHUP, reloading JWT signing keys")
+			if err := keyring.Reload(); err != nil {
+				log.Printf("Failed to reload JWT signing keys, keeping current keys: %v", err)
+			}
+		}
+	}()
+
+	return keyring
+}
+
+/*
+ * Initialize the cipher TOTP secrets are encrypted with, from the key in TOTP_ENCRYPTION_KEY_FILE
+ */
+func initSecretCipher() *auth.SecretCipher {
+	keyPath := os.Getenv(configs.TOTP_ENCRYPTION_KEY_FILE_ENV_VAR)
+	log.Printf("Reading TOTP encryption key from: %s", keyPath)
+
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		log.Fatalf("Failed to read TOTP encryption key: %v", err)
+	}
+
+	cipher, err := auth.NewSecretCipher([]byte(strings.TrimSpace(string(key))))
+	if err != nil {
+		log.Fatalf("Failed to initialize TOTP encryption: %v", err)
+	}
+	return cipher
+}
+
+/*
+ * Configure the password policy and bcrypt cost from the environment
+ * Passwords must have a minimum entropy or mix of character classes, must not contain the username or email,
+ * and must not be in BREACHED_PASSWORDS_FILE when it is set
+ */
+func initPasswordPolicy() {
+	if err := auth.SetBcryptCost(envIntOrDefault(configs.BCRYPT_COST_ENV_VAR, configs.DEFAULT_BCRYPT_COST)); err != nil {
+		log.Fatalf("Failed to configure password hashing: %v", err)
+	}
+
+	policy := auth.PasswordPolicies{
+		auth.LengthPolicy{Min: configs.PASSWORD_MIN_LENGTH, Max: configs.PASSWORD_MAX_LENGTH},
+		auth.PersonalInfoPolicy{},
+	}
+	if bits := envIntOrDefault(configs.PASSWORD_MIN_ENTROPY_BITS_ENV_VAR, configs.DEFAULT_PASSWORD_MIN_ENTROPY_BITS); bits > 0 {
+		policy = append(policy, auth.EntropyPolicy{MinBits: float64(bits)})
+	}
+	if classes := envIntOrDefault(configs.PASSWORD_MIN_CHARACTER_CLASSES_ENV_VAR, configs.DEFAULT_PASSWORD_MIN_CHARACTER_CLASSES); classes > 0 {
+		policy = append(policy, auth.CharacterClassPolicy{MinClasses: classes})
+	}
+
+	if path := os.Getenv(configs.BREACHED_PASSWORDS_FILE_ENV_VAR); path != "" {
+		breached, err := auth.LoadBreachedPasswordPolicy(path)
+		if err != nil {
+			log.Fatalf("Failed to load breached passwords: %v", err)
+		}
+		log.Printf("Loaded %d breached passwords from: %s", breached.Len(), path)
+		policy = append(policy, breached)
+	}
+
+	auth.SetPasswordPolicy(policy)
+}
+
+/*
+ * Initialize the mailer, sending through SMTP when SMTP_HOST is set and writing emails to files otherwise
+ */
+func initMailer() mail.Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		dir := envOrDefault("MAIL_DIR", configs.DEFAULT_MAIL_DIR)
+		log.Printf("SMTP_HOST is not set, writing emails to: %s", dir)
+		return mail.NewFileMailer(dir)
+	}
+
+	var password []byte
+	if passwordPath := os.Getenv("SMTP_PASSWORD_FILE"); passwordPath != "" {
+		var err error
+		password, err = os.ReadFile(passwordPath)
+		if err != nil {
+			log.Fatalf("Failed to read SMTP password: %v", err)
+		}
+	}
+
+	config := mail.SMTPConfig{
+		Host:     host,
+		Port:     envOrDefault("SMTP_PORT", configs.DEFAULT_SMTP_PORT),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: strings.TrimSpace(string(password)),
+		From:     os.Getenv("MAIL_FROM"),
+	}
+	log.Printf("Sending emails through SMTP server %s:%s as %s", config.Host, config.Port, config.From)
+	return mail.NewSMTPMailer(config)
+}
+
+/*
+ * Initialize the store data exports are kept in, a directory set by EXPORT_DIR
+ */
+func initExportStore() *export.LocalStore {
+	dir := envOrDefault(configs.EXPORT_DIR_ENV_VAR, configs.DEFAULT_EXPORT_DIR)
+	log.Printf("Storing data exports in: %s", dir)
+	return export.NewLocalStore(dir)
+}
+
+// envOrDefault returns the value of an environment variable, or fallback if it is not set
+func envOrDefault(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envIntOrDefault returns the integer value of an environment variable, or fallback if it is not set
+func envIntOrDefault(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("%s must be an integer, got %q", name, value)
+	}
+	return number
+}
+
+// envDurationOrDefault returns the duration in an environment variable, such as "336h", or fallback if it is not set
+func envDurationOrDefault(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		log.Fatalf("%s must be a non-negative duration such as 336h, got %q", name, value)
+	}
+	return duration
 }
 
 /*
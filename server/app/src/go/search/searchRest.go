@@ -0,0 +1,73 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"software-slayer/auth"
+	"software-slayer/utils"
+)
+
+var searchService SearchService
+var tokenService auth.TokenService
+
+// @Summary Search learning items and users
+// @Description Full-text search over the titles of learning items and the usernames, first and last names of profiles the caller may see. Anonymous callers only find public ones, signed in callers also find those visible to the organization and their own. Results are ranked and grouped by type.
+// @Tags Search
+// @Produce json
+// @Param Authorization header string false "Bearer token"
+// @Param q query string true "Search query"
+// @Param limit query int false "Maximum number of results of each type (1-50, default 10)"
+// @Success 200 {object} SearchResponse
+// @Failure 400 {object} utils.ErrorResponse "Invalid query parameters"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /search [get]
+func search(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	query := r.URL.Query().Get("q")
+	if err := validateQuery(query); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s parameter", err.Error()))
+		return
+	}
+
+	limit := DefaultResultLimit
+	if limitVal := r.URL.Query().Get("limit"); limitVal != "" {
+		var err error
+		limit, err = strconv.Atoi(limitVal)
+		if err != nil || limit < 1 || limit > MaxResultLimit {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid limit parameter")
+			return
+		}
+	}
+
+	// Anonymous callers have the zero principal, which only finds public profiles and learning items
+	principal, _ := auth.PrincipalFromContext(ctx)
+	scope := SearchScope{Visibilities: auth.VisibleTo(principal, auth.Resource{}), UserID: principal.UserID}
+
+	results, err := searchService.Search(ctx, query, limit, scope)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to search")
+		return
+	}
+
+	log.Printf("Search for %q matched %d learning items and %d users", query, len(results.Learnings), len(results.Users))
+	utils.RespondWithJSON(w, http.StatusOK, results)
+}
+
+// InitSearchRest initializes the search REST endpoints
+func InitSearchRest(_searchService SearchService, _tokenService auth.TokenService) {
+	searchService = _searchService
+	tokenService = _tokenService
+
+	auth.RegisterRoutes(tokenService, []auth.Route{
+		{Pattern: "GET /search", Access: auth.OptionalAuthentication, Scope: auth.ScopeReadLearnings, Handler: search},
+	})
+
+	log.Println("Search REST endpoints initialized")
+}
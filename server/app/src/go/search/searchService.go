@@ -0,0 +1,148 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"software-slayer/db"
+)
+
+type SearchService interface {
+	Search(ctx context.Context, query string, limit int, scope SearchScope) (SearchResponse, error)
+}
+
+type SearchServiceImpl struct {
+	db *db.Database
+}
+
+func NewSearchService(db *db.Database) *SearchServiceImpl {
+	return &SearchServiceImpl{db: db}
+}
+
+// minTokenSize is InnoDB's default innodb_ft_min_token_size, shorter words are left out of FULLTEXT indexes built with it
+const minTokenSize = 3
+
+// learningSearchQuery is completed with the matches of short terms and the condition on the visibility of the learning items the caller may find
+const learningSearchQuery = `SELECT l.id, l.user_id, l.title, c.name, MATCH(l.title) AGAINST(? IN BOOLEAN MODE) AS score
+	FROM user_learning_list l
+	JOIN categories c ON c.id = l.category_id
+	JOIN users u ON u.id = l.user_id
+	WHERE (MATCH(l.title) AGAINST(? IN BOOLEAN MODE)%s)%s
+	ORDER BY score DESC, l.id
+	LIMIT ?`
+
+// userSearchQuery is completed with the matches of short terms and the condition on the visibility of the profiles the caller may find
+const userSearchQuery = `SELECT id, username, first_name, last_name, MATCH(username, first_name, last_name) AGAINST(? IN BOOLEAN MODE) AS score
+	FROM users
+	WHERE (MATCH(username, first_name, last_name) AGAINST(? IN BOOLEAN MODE)%s)%s
+	ORDER BY score DESC, id
+	LIMIT ?`
+
+// Short terms are matched against the start of any word of the searched columns
+const learningShortTermMatch = " OR CONCAT(' ', l.title) LIKE ?"
+const userShortTermMatch = " OR CONCAT(' ', username, ' ', first_name, ' ', last_name) LIKE ?"
+
+/*
+ * Search learning item titles and user names using the FULLTEXT indexes
+ * @param ctx: the context
+ * @param query: the raw search query
+ * @param limit: the maximum number of results of each type
+ * @param scope: the learning items and profiles the caller may find
+ * @return SearchResponse: the ranked results grouped by type
+ * @return error: an error if the search failed
+ */
+func (s *SearchServiceImpl) Search(ctx context.Context, query string, limit int, scope SearchScope) (SearchResponse, error) {
+	response := SearchResponse{Learnings: []LearningResult{}, Users: []UserResult{}}
+
+	terms := booleanQuery(query)
+	if terms == "" {
+		return response, nil
+	}
+	learningArgs, userArgs := []any{terms, terms}, []any{terms, terms}
+
+	// A FULLTEXT index built with the default minimum token size doesn't find terms like "go" or "c", so those are
+	// also matched with LIKE. This scans the table, which queries without short terms still avoid.
+	learningMatch, userMatch := "", ""
+	for _, pattern := range shortTermPatterns(query) {
+		learningMatch += learningShortTermMatch
+		userMatch += userShortTermMatch
+		learningArgs = append(learningArgs, pattern)
+		userArgs = append(userArgs, pattern)
+	}
+
+	// Learning items are found when both the owner's list and the item, which inherits the list's visibility, are visible
+	learningCondition, userCondition := "", ""
+	if scope.Visibilities != nil {
+		placeholders := "(?" + strings.Repeat(", ?", len(scope.Visibilities)-1) + ")"
+		learningCondition = " AND (l.user_id = ? OR (u.learnings_visibility IN " + placeholders +
+			" AND COALESCE(l.visibility, u.learnings_visibility) IN " + placeholders + "))"
+		userCondition = " AND (id = ? OR profile_visibility IN " + placeholders + ")"
+
+		learningArgs = append(learningArgs, scope.UserID)
+		userArgs = append(userArgs, scope.UserID)
+		for range 2 {
+			for _, visibility := range scope.Visibilities {
+				learningArgs = append(learningArgs, visibility)
+			}
+		}
+		for _, visibility := range scope.Visibilities {
+			userArgs = append(userArgs, visibility)
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(learningSearchQuery, learningMatch, learningCondition), append(learningArgs, limit)...)
+	if err != nil {
+		return response, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result LearningResult
+		if err := rows.Scan(&result.ID, &result.UserID, &result.Title, &result.Category, &result.Score); err != nil {
+			return response, err
+		}
+		response.Learnings = append(response.Learnings, result)
+	}
+	if err := rows.Err(); err != nil {
+		return response, err
+	}
+
+	userRows, err := s.db.QueryContext(ctx, fmt.Sprintf(userSearchQuery, userMatch, userCondition), append(userArgs, limit)...)
+	if err != nil {
+		return response, err
+	}
+	defer userRows.Close()
+
+	for userRows.Next() {
+		var result UserResult
+		if err := userRows.Scan(&result.ID, &result.Username, &result.FirstName, &result.LastName, &result.Score); err != nil {
+			return response, err
+		}
+		response.Users = append(response.Users, result)
+	}
+
+	return response, userRows.Err()
+}
+
+// booleanQuery turns the raw query into a boolean mode search that prefix matches every term
+func booleanQuery(query string) string {
+	terms := tokenize(query)
+	for i, term := range terms {
+		terms[i] = term + "*"
+	}
+	return strings.Join(terms, " ")
+}
+
+// shortTermPatterns returns LIKE patterns matching the start of a word for the terms shorter than minTokenSize
+func shortTermPatterns(query string) []string {
+	var patterns []string
+	for _, term := range tokenize(query) {
+		// Terms are only letters and digits, so they need no escaping
+		if utf8.RuneCountInString(term) < minTokenSize {
+			patterns = append(patterns, "% "+term+"%")
+		}
+	}
+	return patterns
+}
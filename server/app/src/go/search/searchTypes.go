@@ -0,0 +1,73 @@
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"software-slayer/auth"
+	"software-slayer/learnings"
+	"software-slayer/user"
+)
+
+const (
+	DefaultResultLimit = 10
+	MaxResultLimit     = 50
+	maxQueryLength     = 100
+)
+
+type LearningResult struct {
+	ID     int     `json:"id"`
+	UserID int     `json:"user_id"`
+	Score  float64 `json:"score"`
+	learnings.LearningBase
+}
+
+type UserResult struct {
+	Score float64 `json:"score"`
+	user.GetUserResponse
+}
+
+// SearchScope is what a caller may find
+type SearchScope struct {
+	// Visibilities are the visibilities of other users' profiles and learning items the caller may see, nil for all of them
+	Visibilities []auth.Visibility
+	// UserID is the caller, whose own profile and learning items are found whatever their visibility, 0 for anonymous callers
+	UserID int
+}
+
+// SearchResponse holds the ranked results of a search grouped by type, best match first
+type SearchResponse struct {
+	Learnings []LearningResult `json:"learnings"`
+	Users     []UserResult     `json:"users"`
+}
+
+/*
+ * Split a search query into lowercase terms, dropping punctuation and search operators
+ * @param query: the raw search query
+ * @return []string: the unique terms in the order they appear
+ */
+func tokenize(query string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(query), isSeparator)
+
+	seen := make(map[string]bool, len(fields))
+	terms := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if !seen[field] {
+			seen[field] = true
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func validateQuery(query string) error {
+	if len(query) > maxQueryLength || len(tokenize(query)) == 0 {
+		return errors.New("q")
+	}
+	return nil
+}
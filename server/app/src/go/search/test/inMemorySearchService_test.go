@@ -0,0 +1,184 @@
+package search_test
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+
+	"software-slayer/auth"
+	"software-slayer/learnings"
+	"software-slayer/search"
+	"software-slayer/user"
+)
+
+// InMemorySearchService is an inverted index over users and learning items for use in tests
+type InMemorySearchService struct {
+	mu        sync.RWMutex
+	learnings map[int]indexedLearning
+	users     map[int]indexedUser
+	// index maps each term to the number of times it appears in each document
+	learningIndex map[string]map[int]int
+	userIndex     map[string]map[int]int
+}
+
+type indexedLearning struct {
+	result search.LearningResult
+	// visibility is the effective visibility of the item, taking the list's into account
+	visibility auth.Visibility
+}
+
+type indexedUser struct {
+	result     search.UserResult
+	visibility auth.Visibility
+}
+
+func NewInMemorySearchService() *InMemorySearchService {
+	return &InMemorySearchService{
+		learnings:     make(map[int]indexedLearning),
+		users:         make(map[int]indexedUser),
+		learningIndex: make(map[string]map[int]int),
+		userIndex:     make(map[string]map[int]int),
+	}
+}
+
+// AddUser indexes a user with the given profile visibility by username and first and last name
+func (s *InMemorySearchService) AddUser(u user.GetUserResponse, visibility auth.Visibility) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[u.ID] = indexedUser{result: search.UserResult{GetUserResponse: u}, visibility: visibility}
+	addToIndex(s.userIndex, u.ID, strings.Join([]string{u.Username, u.FirstName, u.LastName}, " "))
+}
+
+// AddLearning indexes a learning item belonging to a user by title, with the visibility it has in effect
+func (s *InMemorySearchService) AddLearning(userId int, learning learnings.GetLearningResponse, visibility auth.Visibility) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.learnings[learning.ID] = indexedLearning{
+		result:     search.LearningResult{ID: learning.ID, UserID: userId, LearningBase: learning.LearningBase},
+		visibility: visibility,
+	}
+	addToIndex(s.learningIndex, learning.ID, learning.Title)
+}
+
+// Search prefix matches each query term against the indexed terms, a document scoring one point per matching occurrence
+func (s *InMemorySearchService) Search(ctx context.Context, query string, limit int, scope search.SearchScope) (search.SearchResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	terms := strings.FieldsFunc(strings.ToLower(query), isSeparator)
+	response := search.SearchResponse{Learnings: []search.LearningResult{}, Users: []search.UserResult{}}
+
+	visible := func(ownerId int, visibility auth.Visibility) bool {
+		return scope.Visibilities == nil || ownerId == scope.UserID || slices.Contains(scope.Visibilities, visibility)
+	}
+	for _, match := range rank(s.learningIndex, terms) {
+		learning := s.learnings[match.id]
+		if len(response.Learnings) < limit && visible(learning.result.UserID, learning.visibility) {
+			learning.result.Score = match.score
+			response.Learnings = append(response.Learnings, learning.result)
+		}
+	}
+	for _, match := range rank(s.userIndex, terms) {
+		u := s.users[match.id]
+		if len(response.Users) < limit && visible(u.result.ID, u.visibility) {
+			u.result.Score = match.score
+			response.Users = append(response.Users, u.result)
+		}
+	}
+
+	return response, nil
+}
+
+type scoredID struct {
+	id    int
+	score float64
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func addToIndex(index map[string]map[int]int, id int, text string) {
+	for _, term := range strings.FieldsFunc(strings.ToLower(text), isSeparator) {
+		if index[term] == nil {
+			index[term] = make(map[int]int)
+		}
+		index[term][id]++
+	}
+}
+
+// rank scores every document matching at least one term, best first
+func rank(index map[string]map[int]int, terms []string) []scoredID {
+	scores := make(map[int]float64)
+	for indexed, postings := range index {
+		for _, term := range terms {
+			if !strings.HasPrefix(indexed, term) {
+				continue
+			}
+			for id, count := range postings {
+				scores[id] += float64(count)
+			}
+		}
+	}
+
+	ranked := make([]scoredID, 0, len(scores))
+	for id, score := range scores {
+		ranked = append(ranked, scoredID{id: id, score: score})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].id < ranked[j].id
+	})
+	return ranked
+}
+
+// InMemorySearchService tests
+
+func TestInMemorySearch_Ranking(t *testing.T) {
+	// Setup
+	index := NewInMemorySearchService()
+	index.AddLearning(1, learnings.GetLearningResponse{ID: 1, LearningBase: learnings.LearningBase{Title: "Docker"}}, auth.VisibilityPublic)
+	index.AddLearning(1, learnings.GetLearningResponse{ID: 2, LearningBase: learnings.LearningBase{Title: "Go concurrency"}}, auth.VisibilityPublic)
+	index.AddLearning(2, learnings.GetLearningResponse{ID: 3, LearningBase: learnings.LearningBase{Title: "Go and gRPC: Go services"}}, auth.VisibilityPublic)
+	index.AddUser(user.GetUserResponse{ID: 1, UserBase: user.UserBase{Username: "gopher", FirstName: "Rob", LastName: "Pike"}}, auth.VisibilityPublic)
+
+	// Execute
+	results, err := index.Search(context.Background(), "GO", 10, search.SearchScope{})
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Len(t, results.Learnings, 2)
+	assert.Equal(t, 3, results.Learnings[0].ID)
+	assert.Equal(t, 2.0, results.Learnings[0].Score)
+	assert.Equal(t, 2, results.Learnings[1].ID)
+	assert.Len(t, results.Users, 1)
+	assert.Equal(t, 1, results.Users[0].ID)
+}
+
+func TestInMemorySearch_Limit(t *testing.T) {
+	// Setup
+	index := NewInMemorySearchService()
+	for id := 1; id <= 3; id++ {
+		index.AddLearning(1, learnings.GetLearningResponse{ID: id, LearningBase: learnings.LearningBase{Title: "Kubernetes"}}, auth.VisibilityPublic)
+	}
+
+	// Execute
+	results, err := index.Search(context.Background(), "kube", 2, search.SearchScope{})
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Len(t, results.Learnings, 2)
+	// Ties are broken by ID
+	assert.Equal(t, 1, results.Learnings[0].ID)
+	assert.Equal(t, 2, results.Learnings[1].ID)
+}
@@ -0,0 +1,175 @@
+package search_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"testing"
+
+	"software-slayer/auth"
+	"software-slayer/learnings"
+	"software-slayer/search"
+	"software-slayer/user"
+)
+
+type MockTokenService struct{}
+
+func (m *MockTokenService) GenerateToken(userID int, role auth.Role, emailVerified bool) (string, error) {
+	return "mocked_token", nil
+}
+
+func (m *MockTokenService) RevokeToken(ctx context.Context, principal auth.Principal) error {
+	return nil
+}
+
+func (m *MockTokenService) RevokeAllTokens(ctx context.Context, userID int) error {
+	return nil
+}
+
+func (m *MockTokenService) AuthorizeUser(token string) (auth.Principal, error) {
+	switch token {
+	case "valid_token":
+		return auth.Principal{UserID: 1, TokenID: "valid_token_id"}, nil
+	case "user2_token":
+		return auth.Principal{UserID: 2, TokenID: "user2_token_id"}, nil
+	}
+	return auth.Principal{}, errors.New("invalid token")
+}
+
+var ts *httptest.Server
+
+func TestMain(m *testing.M) {
+	index := NewInMemorySearchService()
+	index.AddUser(user.GetUserResponse{ID: 1, UserBase: user.UserBase{Username: "gopher", FirstName: "Rob", LastName: "Pike"}}, auth.VisibilityPublic)
+	index.AddUser(user.GetUserResponse{ID: 2, UserBase: user.UserBase{Username: "rustacean", FirstName: "Go", LastName: "Ferris"}}, auth.VisibilityPublic)
+	index.AddUser(user.GetUserResponse{ID: 3, UserBase: user.UserBase{Username: "gonzo", FirstName: "Ada", LastName: "Lovelace"}}, auth.VisibilityOrganization)
+	index.AddLearning(1, learnings.GetLearningResponse{ID: 1, LearningBase: learnings.LearningBase{Title: "Go Programming", Category: learnings.Languages}}, auth.VisibilityPublic)
+	index.AddLearning(2, learnings.GetLearningResponse{ID: 2, LearningBase: learnings.LearningBase{Title: "Rust", Category: learnings.Languages}}, auth.VisibilityPublic)
+	index.AddLearning(1, learnings.GetLearningResponse{ID: 3, LearningBase: learnings.LearningBase{Title: "Golang internals", Category: learnings.Languages}}, auth.VisibilityOrganization)
+	index.AddLearning(1, learnings.GetLearningResponse{ID: 4, LearningBase: learnings.LearningBase{Title: "Gossip protocols", Category: learnings.Concepts}}, auth.VisibilityPrivate)
+
+	search.InitSearchRest(index, &MockTokenService{})
+	ts = httptest.NewServer(http.DefaultServeMux)
+	defer ts.Close()
+
+	os.Exit(m.Run())
+}
+
+func doSearch(t *testing.T, query url.Values) *http.Response {
+	return doSearchAs(t, "", query)
+}
+
+func doSearchAs(t *testing.T, token string, query url.Values) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest("GET", ts.URL+"/search?"+query.Encode(), nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	return resp
+}
+
+func TestSearchSuccess(t *testing.T) {
+	resp := doSearch(t, url.Values{"q": {"go"}})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v", resp.Status)
+	}
+
+	var results search.SearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(results.Learnings) != 1 || results.Learnings[0].Title != "Go Programming" || results.Learnings[0].UserID != 1 {
+		t.Errorf("Unexpected learning results: %+v", results.Learnings)
+	}
+	// "go" prefix matches both "gopher" and the first name "Go"
+	if len(results.Users) != 2 {
+		t.Errorf("Expected 2 user results, got %d", len(results.Users))
+	}
+}
+
+func TestSearchNoMatches(t *testing.T) {
+	resp := doSearch(t, url.Values{"q": {"haskell"}})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v", resp.Status)
+	}
+
+	var results search.SearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if results.Learnings == nil || results.Users == nil {
+		t.Error("Expected empty result lists rather than null")
+	}
+	if len(results.Learnings) != 0 || len(results.Users) != 0 {
+		t.Errorf("Expected no results, got %+v", results)
+	}
+}
+
+func TestSearchInvalidQuery(t *testing.T) {
+	tests := []url.Values{
+		{},
+		{"q": {"  +*- "}},
+		{"q": {"go"}, "limit": {"0"}},
+		{"q": {"go"}, "limit": {"abc"}},
+	}
+
+	for _, query := range tests {
+		resp := doSearch(t, query)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status Bad Request for %v, got %v", query, resp.Status)
+		}
+	}
+}
+
+func TestSearchVisibility(t *testing.T) {
+	tests := []struct {
+		token     string
+		learnings []int
+		users     []int
+	}{
+		// Anonymous callers only find public learning items and profiles
+		{"", []int{1}, []int{1, 2}},
+		// Signed in callers also find those visible to the organization
+		{"user2_token", []int{1, 3}, []int{1, 2, 3}},
+		// Owners also find their private learning items
+		{"valid_token", []int{1, 3, 4}, []int{1, 2, 3}},
+	}
+
+	for _, test := range tests {
+		resp := doSearchAs(t, test.token, url.Values{"q": {"go"}})
+
+		var results search.SearchResponse
+		json.NewDecoder(resp.Body).Decode(&results)
+		resp.Body.Close()
+
+		var learningIds, userIds []int
+		for _, result := range results.Learnings {
+			learningIds = append(learningIds, result.ID)
+		}
+		for _, result := range results.Users {
+			userIds = append(userIds, result.ID)
+		}
+		slices.Sort(learningIds)
+		slices.Sort(userIds)
+		if !slices.Equal(learningIds, test.learnings) || !slices.Equal(userIds, test.users) {
+			t.Errorf("%q: expected learning items %v and users %v, got %v and %v", test.token, test.learnings, test.users, learningIds, userIds)
+		}
+	}
+}
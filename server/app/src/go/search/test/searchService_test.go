@@ -0,0 +1,156 @@
+package search_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"software-slayer/auth"
+	"software-slayer/db"
+	"software-slayer/learnings"
+	"software-slayer/search"
+)
+
+func setup(t *testing.T) (sqlmock.Sqlmock, *search.SearchServiceImpl) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	return mock, search.NewSearchService(db.NewDB(database))
+}
+
+// SearchServiceImpl tests
+
+func TestSearch_Success(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	// Operators are stripped and every term is prefix matched
+	terms := "go* web*"
+
+	dbMock.ExpectQuery("SELECT l.id, l.user_id, l.title, c.name, MATCH").
+		WithArgs(terms, terms, "% go%", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "title", "category", "score"}).
+			AddRow(3, 1, "Go web servers", learnings.Technologies, 2.5).
+			AddRow(1, 2, "Go Programming", learnings.Languages, 1.2))
+	dbMock.ExpectQuery("SELECT id, username, first_name, last_name, MATCH").
+		WithArgs(terms, terms, "% go%", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "first_name", "last_name", "score"}).
+			AddRow(1, "gopher", "Rob", "Pike", 0.8))
+
+	// Execute
+	results, err := service.Search(ctx, "+go -web*", 5, search.SearchScope{})
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Len(t, results.Learnings, 2)
+	assert.Equal(t, 3, results.Learnings[0].ID)
+	assert.Equal(t, 2.5, results.Learnings[0].Score)
+	assert.Equal(t, 2, results.Learnings[1].UserID)
+	assert.Len(t, results.Users, 1)
+	assert.Equal(t, "gopher", results.Users[0].Username)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestSearch_Scope(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	// A signed in caller finds what is public or visible to the organization, and everything of their own
+	scope := search.SearchScope{Visibilities: []auth.Visibility{auth.VisibilityPublic, auth.VisibilityOrganization}, UserID: 4}
+
+	dbMock.ExpectQuery("AND \\(l.user_id = \\? OR \\(u.learnings_visibility IN \\(\\?, \\?\\) AND COALESCE\\(l.visibility, u.learnings_visibility\\) IN \\(\\?, \\?\\)\\)\\)").
+		WithArgs("go*", "go*", "% go%", 4, auth.VisibilityPublic, auth.VisibilityOrganization, auth.VisibilityPublic, auth.VisibilityOrganization, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "title", "category", "score"}))
+	dbMock.ExpectQuery("AND \\(id = \\? OR profile_visibility IN \\(\\?, \\?\\)\\)").
+		WithArgs("go*", "go*", "% go%", 4, auth.VisibilityPublic, auth.VisibilityOrganization, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "first_name", "last_name", "score"}))
+
+	// Execute
+	_, err := service.Search(ctx, "go", 5, scope)
+
+	// Verify
+	assert.NoError(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestSearch_ShortTerms(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	// Terms shorter than the default FULLTEXT minimum token size are also matched with LIKE
+	terms := "c* ui* rust*"
+
+	dbMock.ExpectQuery("WHERE \\(MATCH\\(l.title\\) AGAINST\\(\\? IN BOOLEAN MODE\\) OR CONCAT\\(' ', l.title\\) LIKE \\? OR CONCAT\\(' ', l.title\\) LIKE \\?\\)").
+		WithArgs(terms, terms, "% c%", "% ui%", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "title", "category", "score"}).
+			AddRow(2, 1, "C pointers", learnings.Languages, 0))
+	dbMock.ExpectQuery("WHERE \\(MATCH\\(username, first_name, last_name\\) AGAINST\\(\\? IN BOOLEAN MODE\\) OR CONCAT\\(' ', username, ' ', first_name, ' ', last_name\\) LIKE \\? OR").
+		WithArgs(terms, terms, "% c%", "% ui%", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "first_name", "last_name", "score"}))
+
+	// Execute
+	results, err := service.Search(ctx, "C UI rust", 5, search.SearchScope{})
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Len(t, results.Learnings, 1)
+	assert.Equal(t, "C pointers", results.Learnings[0].Title)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestSearch_LongTermsUseIndexOnly(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+	ctx := context.Background()
+
+	dbMock.ExpectQuery("SELECT l.id, l.user_id, l.title, c.name, MATCH").
+		WithArgs("rust*", "rust*", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "title", "category", "score"}))
+	dbMock.ExpectQuery("SELECT id, username, first_name, last_name, MATCH").
+		WithArgs("rust*", "rust*", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "first_name", "last_name", "score"}))
+
+	// Execute
+	_, err := service.Search(ctx, "rust", 5, search.SearchScope{})
+
+	// Verify
+	assert.NoError(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestSearch_EmptyQuery(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+
+	// Execute
+	results, err := service.Search(context.Background(), "**", 5, search.SearchScope{})
+
+	// Verify
+	assert.NoError(t, err)
+	assert.Empty(t, results.Learnings)
+	assert.Empty(t, results.Users)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestSearch_DatabaseError(t *testing.T) {
+	// Setup
+	dbMock, service := setup(t)
+
+	dbMock.ExpectQuery("SELECT l.id, l.user_id, l.title, c.name, MATCH").
+		WillReturnError(errors.New("database error"))
+
+	// Execute
+	_, err := service.Search(context.Background(), "go", 5, search.SearchScope{})
+
+	// Verify
+	assert.EqualError(t, err, "database error")
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
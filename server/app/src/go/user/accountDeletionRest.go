@@ -0,0 +1,167 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"software-slayer/auth"
+	"software-slayer/mail"
+	"software-slayer/utils"
+)
+
+// accountDeletionGracePeriod is how long after asking to delete their account a user can log in to cancel the deletion
+var accountDeletionGracePeriod time.Duration
+
+// @Summary Delete the current user's account
+// @Description Schedule the current user's account for deletion. Every session is signed out and every personal access token revoked, and the account is deleted along with its learning items, categories, tags and data exports once the grace period ends. Logging in before then cancels the deletion.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body DeleteAccountRequest true "Current password"
+// @Success 202 {object} DeleteAccountResponse "Account scheduled for deletion"
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Current password is incorrect"
+// @Failure 429 {object} utils.ErrorResponse "Too many failed password attempts"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/me [delete]
+func deleteOwnAccount(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var request DeleteAccountRequest
+	if err := utils.Decode(w, r, &request); err != nil {
+		return
+	}
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	user, err := userService.GetUserById(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user information")
+		return
+	}
+
+	if !checkCurrentPassword(ctx, w, r, user, request.Password) {
+		return
+	}
+
+	deleteAfter := time.Now().Add(accountDeletionGracePeriod).UTC().Truncate(time.Second)
+	if err := userService.ScheduleDeletion(ctx, userId, deleteAfter); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+
+	// Logging in cancels the deletion, so no session or personal access token may keep the account usable without one
+	if err := refreshTokenService.RevokeAllRefreshTokens(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+	if err := tokenService.RevokeAllTokens(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+	if err := personalAccessTokenService.RevokeAllTokens(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke personal access tokens")
+		return
+	}
+
+	go sendMail(userId, mail.Message{
+		To:      user.Email,
+		Subject: "Your Software Slayer account will be deleted",
+		Body: fmt.Sprintf("Hi %s,\n\nYour Software Slayer account and everything in it will be deleted on %s. "+
+			"If you change your mind, log in before then and the deletion will be cancelled.\n",
+			user.Username, deleteAfter.Format(time.RFC1123)),
+	})
+
+	log.Printf("Scheduled deletion of user ID %d after %s", userId, deleteAfter.Format(time.RFC3339))
+	utils.RespondWithJSON(w, http.StatusAccepted, DeleteAccountResponse{
+		Message:     "Account scheduled for deletion, log in before it is deleted to cancel",
+		DeleteAfter: deleteAfter,
+	})
+}
+
+/*
+ * cancelAccountDeletion cancels the scheduled deletion of an account the user has just logged in to
+ * @param ctx: the request context
+ * @param user: the user who logged in
+ * @return error: an error if the deletion could not be cancelled
+ */
+func cancelAccountDeletion(ctx context.Context, user UserDB) error {
+	cancelled, err := userService.CancelDeletion(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if cancelled {
+		log.Printf("Cancelled scheduled deletion of user ID %d after login", user.ID)
+		go sendMail(user.ID, mail.Message{
+			To:      user.Email,
+			Subject: "Your Software Slayer account will not be deleted",
+			Body: fmt.Sprintf("Hi %s,\n\nYou logged in to your Software Slayer account, so it will no longer be deleted. "+
+				"If this wasn't you, change your password.\n", user.Username),
+		})
+	}
+	return nil
+}
+
+/*
+ * RunScheduledDeletions deletes the accounts whose grace period has ended every interval, until the context is cancelled
+ * @param ctx: the context that stops the job
+ * @param service: the service the accounts are deleted with
+ * @param interval: how often to look for accounts to delete
+ */
+func RunScheduledDeletions(ctx context.Context, service UserService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			deleteCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			deleteScheduledAccounts(deleteCtx, service, now)
+			cancel()
+		}
+	}
+}
+
+// deleteScheduledAccounts deletes every account whose grace period ended by now, one transaction per account
+func deleteScheduledAccounts(ctx context.Context, service UserService, now time.Time) {
+	userIds, err := service.GetUsersDueForDeletion(ctx, now)
+	if err != nil {
+		log.Printf("Failed to find accounts scheduled for deletion: %v", err)
+		return
+	}
+
+	for _, userId := range userIds {
+		// The user may have logged in since they were listed, which the deletion checks again
+		deleted, err := service.DeleteUserIfDue(ctx, userId, now)
+		if err != nil {
+			log.Printf("Failed to delete account of user ID %d: %v", userId, err)
+			continue
+		}
+		if deleted {
+			log.Printf("Deleted account of user ID %d after its grace period", userId)
+		}
+	}
+}
+
+/*
+ * InitAccountDeletionRest initializes the account deletion REST endpoints, after InitUserRest and InitEmailVerificationRest
+ * @param gracePeriod: how long accounts are kept after their user asks to delete them
+ */
+func InitAccountDeletionRest(gracePeriod time.Duration) {
+	accountDeletionGracePeriod = gracePeriod
+
+	auth.RegisterRoutes(tokenService, []auth.Route{
+		{Pattern: "DELETE /user/me", Access: auth.Authenticated, AllowUnverified: true, Handler: deleteOwnAccount},
+	})
+
+	log.Println("Account deletion REST endpoints initialized")
+}
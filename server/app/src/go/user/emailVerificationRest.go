@@ -0,0 +1,315 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"software-slayer/auth"
+	"software-slayer/mail"
+	"software-slayer/utils"
+)
+
+var emailVerificationService auth.EmailVerificationService
+var emailVerificationURL string
+
+// emailVerificationTokenLifetime is how long verification links are valid for, as stated in verification emails
+var emailVerificationTokenLifetime time.Duration
+
+// verificationResendInterval is how long users have to wait before another verification email is sent
+var verificationResendInterval time.Duration
+
+// @Summary Verify email
+// @Description Verify the email of an account with the token from its verification email. Tokens sent to a new address by POST /user/me/email change the account's email to it.
+// @Tags Users
+// @Produce json
+// @Param token query string true "Token from the verification email"
+// @Success 200 {object} map[string]string "Email verified"
+// @Failure 400 {object} utils.ErrorResponse "Invalid or expired token"
+// @Failure 409 {object} utils.ErrorResponse "New email already used by another account"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/verify [get]
+func verifyEmail(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, email, err := emailVerificationService.ParseVerificationToken(r.URL.Query().Get("token"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired verification token")
+		return
+	}
+
+	user, err := userService.GetUserById(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired verification token")
+		return
+	}
+
+	// A link for another address can only be for an email change, it only works for the latest change requested
+	if user.Email != email {
+		confirmEmailChange(ctx, w, user, email)
+		return
+	}
+
+	if !user.EmailVerified {
+		if err := userService.MarkEmailVerified(ctx, userId, email); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to verify email")
+			return
+		}
+		log.Printf("Verified email of user ID %d", userId)
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Email verified successfully"})
+}
+
+/*
+ * confirmEmailChange changes a user's email to the new address whose verification link was opened
+ * @param ctx: the request context
+ * @param w: the response writer
+ * @param user: the user changing their email
+ * @param email: the new email from the verification token
+ */
+func confirmEmailChange(ctx context.Context, w http.ResponseWriter, user UserDB, email string) {
+	changed, err := userService.ConfirmEmailChange(ctx, user.ID, email)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			utils.RespondWithError(w, http.StatusConflict, "A user with this email already exists")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to change email")
+		return
+	}
+	if !changed {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired verification token")
+		return
+	}
+
+	// Let the owner of the old address know, in case someone else changed it
+	go sendMail(user.ID, mail.Message{
+		To:      user.Email,
+		Subject: "Your Software Slayer email was changed",
+		Body: fmt.Sprintf("Hi %s,\n\nThe email of your Software Slayer account was changed to %s.\n\n"+
+			"If you didn't change it, reset your password and contact support.\n", user.Username, email),
+	})
+
+	log.Printf("Changed email of user ID %d", user.ID)
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Email changed successfully"})
+}
+
+// @Summary Change email
+// @Description Send a verification link to a new email for the current user, confirmed with their password. The email changes once the link is opened.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body ChangeEmailRequest true "New email and current password"
+// @Success 202 {object} map[string]string "Verification email sent to the new address"
+// @Failure 400 {object} utils.ErrorResponse "Invalid email"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Password is incorrect"
+// @Failure 409 {object} utils.ErrorResponse "Email already used by an account"
+// @Failure 429 {object} utils.ErrorResponse "Too many failed password attempts"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/me/email [post]
+func changeEmail(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var request ChangeEmailRequest
+	if err := utils.Decode(w, r, &request); err != nil {
+		return
+	}
+
+	if ok := emailValidator.MatchString(request.Email); !ok {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid email")
+		return
+	}
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	user, err := userService.GetUserById(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user information")
+		return
+	}
+
+	if !checkCurrentPassword(ctx, w, r, user, request.Password) {
+		return
+	}
+
+	if strings.EqualFold(request.Email, user.Email) {
+		utils.RespondWithError(w, http.StatusBadRequest, "This is already your email")
+		return
+	}
+	if _, err := userService.GetUserByIdentifier(ctx, request.Email); err == nil {
+		utils.RespondWithError(w, http.StatusConflict, "A user with this email already exists")
+		return
+	}
+
+	if err := userService.RequestEmailChange(ctx, userId, request.Email); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to change email")
+		return
+	}
+
+	go sendEmailChangeVerification(user.ID, request.Email, user.Username)
+
+	log.Printf("User ID %d requested an email change", userId)
+	utils.RespondWithJSON(w, http.StatusAccepted, map[string]string{"message": "A verification link was sent to the new email"})
+}
+
+// @Summary Resend verification email
+// @Description Send another verification email to the current user. Emails can only be sent once per resend interval.
+// @Tags Users
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 202 {object} map[string]string "Verification email sent"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 409 {object} utils.ErrorResponse "Email already verified"
+// @Failure 429 {object} utils.ErrorResponse "Verification email sent too recently"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/verify/resend [post]
+func resendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	user, err := userService.GetUserById(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user information")
+		return
+	}
+
+	if user.EmailVerified {
+		utils.RespondWithError(w, http.StatusConflict, "Email is already verified")
+		return
+	}
+
+	claimed, err := userService.ClaimVerificationEmail(ctx, userId, verificationResendInterval)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+	if !claimed {
+		w.Header().Set("Retry-After", fmt.Sprintf("%.0f", verificationResendInterval.Seconds()))
+		utils.RespondWithError(w, http.StatusTooManyRequests, "A verification email was sent recently, please wait before requesting another")
+		return
+	}
+
+	go sendVerificationEmail(user.ID, user.Email, user.Username)
+
+	utils.RespondWithJSON(w, http.StatusAccepted, map[string]string{"message": "Verification email sent"})
+}
+
+/*
+ * sendVerificationEmail mails a verification link to a user, the caller is responsible for throttling
+ * @param userId: the id of the user
+ * @param email: the email to verify
+ * @param username: the username the email is addressed to
+ */
+func sendVerificationEmail(userId int, email string, username string) {
+	link, err := verificationLink(userId, email)
+	if err != nil {
+		log.Printf("Failed to generate verification token for user ID %d: %v", userId, err)
+		return
+	}
+
+	sendMail(userId, mail.Message{
+		To:      email,
+		Subject: "Verify your Software Slayer email",
+		Body: fmt.Sprintf("Hi %s,\n\nWelcome to Software Slayer! To verify your email address, open this link:\n\n%s\n\n"+
+			"The link expires in %s. If you didn't create an account, you can ignore this email.\n",
+			username, link, emailVerificationTokenLifetime),
+	})
+}
+
+/*
+ * sendEmailChangeVerification mails a link to a user's new email that changes their email to it
+ * @param userId: the id of the user
+ * @param email: the new email
+ * @param username: the username the email is addressed to
+ */
+func sendEmailChangeVerification(userId int, email string, username string) {
+	link, err := verificationLink(userId, email)
+	if err != nil {
+		log.Printf("Failed to generate verification token for user ID %d: %v", userId, err)
+		return
+	}
+
+	sendMail(userId, mail.Message{
+		To:      email,
+		Subject: "Confirm your new Software Slayer email",
+		Body: fmt.Sprintf("Hi %s,\n\nTo change the email of your Software Slayer account to this address, open this link:\n\n%s\n\n"+
+			"The link expires in %s. If you didn't ask to change your email, you can ignore this email.\n",
+			username, link, emailVerificationTokenLifetime),
+	})
+}
+
+// verificationLink returns a link that verifies a user's email when opened
+func verificationLink(userId int, email string) (string, error) {
+	token, err := emailVerificationService.GenerateVerificationToken(userId, email)
+	if err != nil {
+		return "", err
+	}
+	return emailVerificationURL + "?token=" + url.QueryEscape(token), nil
+}
+
+// sendMail sends an email about a user's account, logging whether it was sent
+func sendMail(userId int, message mail.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := mailer.Send(ctx, message); err != nil {
+		log.Printf("Failed to send %q email to user ID %d: %v", message.Subject, userId, err)
+		return
+	}
+
+	log.Printf("Sent %q email to user ID %d", message.Subject, userId)
+}
+
+/*
+ * sendFirstVerificationEmail sends the verification email of a newly created user
+ * @param userId: the id of the user
+ * @param email: the email to verify
+ * @param username: the username the email is addressed to
+ */
+func sendFirstVerificationEmail(userId int, email string, username string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Claiming starts the resend interval, so the user can't ask for another email straight away
+	if _, err := userService.ClaimVerificationEmail(ctx, userId, verificationResendInterval); err != nil {
+		log.Printf("Failed to record verification email for user ID %d: %v", userId, err)
+	}
+
+	sendVerificationEmail(userId, email, username)
+}
+
+/*
+ * InitEmailVerificationRest initializes the email verification REST endpoints, after InitUserRest
+ * @param _mailer: the mailer verification emails are sent with
+ * @param _emailVerificationService: the service verification tokens are generated and parsed with
+ * @param verifyURL: the URL verification links open, the token is added as the token query parameter
+ * @param tokenLifetime: how long verification tokens are valid for
+ * @param resendInterval: how long users have to wait before another verification email is sent
+ */
+func InitEmailVerificationRest(_mailer mail.Mailer, _emailVerificationService auth.EmailVerificationService, verifyURL string,
+	tokenLifetime time.Duration, resendInterval time.Duration) {
+	mailer = _mailer
+	emailVerificationService = _emailVerificationService
+	emailVerificationURL = verifyURL
+	emailVerificationTokenLifetime = tokenLifetime
+	verificationResendInterval = resendInterval
+
+	auth.RegisterRoutes(tokenService, []auth.Route{
+		{Pattern: "GET /user/verify", Access: auth.Public, Handler: verifyEmail},
+		{Pattern: "POST /user/verify/resend", Access: auth.Authenticated, AllowUnverified: true, Handler: resendVerificationEmail},
+		{Pattern: "POST /user/me/email", Access: auth.Authenticated, AllowUnverified: true, Handler: changeEmail},
+	})
+
+	log.Println("Email verification REST endpoints initialized")
+}
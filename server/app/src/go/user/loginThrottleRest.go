@@ -0,0 +1,102 @@
+package user
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"software-slayer/auth"
+	"software-slayer/utils"
+)
+
+var loginThrottle *auth.LoginThrottle
+
+// @Summary Unlock an account
+// @Description Lift a login lockout from an account and forget its failed logins. The unlock is recorded in the audit log. Requires the admin role.
+// @Tags Admin
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "ID of the user"
+// @Success 200 {object} map[string]string "Account unlocked"
+// @Failure 400 {object} utils.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Forbidden"
+// @Failure 404 {object} utils.ErrorResponse "User not found"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /admin/users/{id}/unlock [post]
+func unlockAccount(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+	if !auth.Can(principal, auth.ActionUnlockAccount, auth.Resource{}) {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have permission to unlock accounts")
+		return
+	}
+
+	if _, err := userService.GetUserById(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := loginThrottle.Unlock(ctx, userId, principal.UserID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to unlock account")
+		return
+	}
+
+	log.Printf("User ID %d unlocked account of user ID %d", principal.UserID, userId)
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Account unlocked successfully"})
+}
+
+/*
+ * checkLoginThrottle responds with 429 if logins for the account or client are delayed or locked out
+ * @param ctx: the request context
+ * @param w: the response writer
+ * @param accountKey: the login attempt key of the account being logged in to
+ * @param ip: the client address
+ * @return bool: whether the login may be attempted
+ */
+func checkLoginThrottle(ctx context.Context, w http.ResponseWriter, accountKey string, ip string) bool {
+	wait, err := loginThrottle.Check(ctx, accountKey, ip)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to log in")
+		return false
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		utils.RespondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, please try again later")
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure records a failed login, failing to record it doesn't change the response
+func recordLoginFailure(ctx context.Context, accountKey string, ip string) {
+	if err := loginThrottle.RecordFailure(ctx, accountKey, ip); err != nil {
+		log.Printf("Failed to record failed login for %s: %v", accountKey, err)
+	}
+}
+
+/*
+ * InitLoginThrottleRest initializes the login throttle and the account unlock endpoint, after InitUserRest
+ * POST /login relies on the throttle, so it must be initialized before the server starts
+ * @param _loginThrottle: the throttle failed logins are tracked with
+ */
+func InitLoginThrottleRest(_loginThrottle *auth.LoginThrottle) {
+	loginThrottle = _loginThrottle
+
+	auth.RegisterRoutes(tokenService, []auth.Route{
+		{Pattern: "POST /admin/users/{id}/unlock", Access: auth.Authenticated, Handler: unlockAccount},
+	})
+
+	log.Println("Login throttle REST endpoints initialized")
+}
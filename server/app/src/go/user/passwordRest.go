@@ -0,0 +1,183 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"software-slayer/auth"
+	"software-slayer/mail"
+	"software-slayer/utils"
+)
+
+var mailer mail.Mailer
+var passwordResetService auth.PasswordResetService
+var passwordResetURL string
+
+// passwordResetTokenLifetime is how long reset tokens are valid for, as stated in reset emails
+var passwordResetTokenLifetime time.Duration
+
+// @Summary Forgot password
+// @Description Email a link to reset the password of the account with this email. The response is the same whether or not the account exists.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Email of the account"
+// @Success 202 {object} map[string]string "Reset email sent if the account exists"
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Router /password/forgot [post]
+func forgotPassword(w http.ResponseWriter, r *http.Request) {
+	var request ForgotPasswordRequest
+	if err := utils.Decode(w, r, &request); err != nil {
+		return
+	}
+
+	if ok := emailValidator.MatchString(request.Email); !ok {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid email")
+		return
+	}
+
+	// The email is sent in the background, so neither the response nor its timing reveal whether the account exists
+	go sendPasswordResetEmail(request.Email)
+
+	utils.RespondWithJSON(w, http.StatusAccepted, map[string]string{"message": "If an account with this email exists, a password reset link has been sent"})
+}
+
+/*
+ * sendPasswordResetEmail issues a reset token for the account with the email and mails it to the user
+ * @param email: the email the reset was requested for
+ */
+func sendPasswordResetEmail(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user, err := userService.GetUserByIdentifier(ctx, email)
+	if err != nil || user.Email != email || user.Disabled {
+		log.Printf("Password reset requested for unknown or disabled account")
+		return
+	}
+
+	token, err := passwordResetService.IssueResetToken(ctx, user.ID)
+	if err != nil {
+		log.Printf("Failed to issue password reset token for user ID %d: %v", user.ID, err)
+		return
+	}
+
+	link := passwordResetURL + "?token=" + url.QueryEscape(token)
+	err = mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Software Slayer password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your Software Slayer account. "+
+			"To choose a new password, open this link:\n\n%s\n\nor enter this code in the app:\n\n%s\n\n"+
+			"The link expires in %s and can only be used once. If you didn't ask to reset your password, you can ignore this email.\n",
+			user.Username, link, token, passwordResetTokenLifetime),
+	})
+	if err != nil {
+		log.Printf("Failed to send password reset email to user ID %d: %v", user.ID, err)
+		return
+	}
+
+	log.Printf("Sent password reset email to user ID %d", user.ID)
+}
+
+// @Summary Reset password
+// @Description Set a new password with a token from a password reset email. Every session of the user is logged out.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string "Password reset"
+// @Failure 400 {object} utils.ErrorResponse "Invalid password, or invalid, used or expired token"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /password/reset [post]
+func resetPassword(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var request ResetPasswordRequest
+	if err := utils.Decode(w, r, &request); err != nil {
+		return
+	}
+
+	// The token is only used up once the password is accepted, so a rejected password can be retried with the same link
+	userId, err := passwordResetService.LookupResetToken(ctx, request.Token)
+	if err != nil {
+		respondWithResetTokenError(w, err)
+		return
+	}
+
+	user, err := userService.GetUserById(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	if err := auth.CheckPassword(request.Password, user.Username, user.Email); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid password: %s", err.Error()))
+		return
+	}
+
+	userId, err = passwordResetService.ConsumeResetToken(ctx, request.Token)
+	if err != nil {
+		respondWithResetTokenError(w, err)
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to process password")
+		return
+	}
+
+	if err := userService.UpdatePassword(ctx, userId, passwordHash); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	// Whoever knew the old password must not stay logged in
+	if err := refreshTokenService.RevokeAllRefreshTokens(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+	if err := tokenService.RevokeAllTokens(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	log.Printf("Reset password for user ID %d", userId)
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Password reset successfully"})
+}
+
+// respondWithResetTokenError responds to a reset token that could not be looked up or consumed
+func respondWithResetTokenError(w http.ResponseWriter, err error) {
+	if errors.Is(err, auth.ErrInvalidResetToken) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired password reset token")
+		return
+	}
+	utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reset password")
+}
+
+/*
+ * InitPasswordRest initializes the password reset REST endpoints, after InitUserRest
+ * @param _mailer: the mailer reset emails are sent with
+ * @param _passwordResetService: the service reset tokens are issued and consumed with
+ * @param resetURL: the page reset links open, the token is added as the token query parameter
+ * @param tokenLifetime: how long reset tokens are valid for
+ */
+func InitPasswordRest(_mailer mail.Mailer, _passwordResetService auth.PasswordResetService, resetURL string, tokenLifetime time.Duration) {
+	mailer = _mailer
+	passwordResetService = _passwordResetService
+	passwordResetURL = resetURL
+	passwordResetTokenLifetime = tokenLifetime
+
+	auth.RegisterRoutes(tokenService, []auth.Route{
+		{Pattern: "POST /password/forgot", Access: auth.Public, Handler: forgotPassword},
+		{Pattern: "POST /password/reset", Access: auth.Public, Handler: resetPassword},
+	})
+
+	log.Println("Password reset REST endpoints initialized")
+}
@@ -0,0 +1,93 @@
+package user_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"software-slayer/user"
+)
+
+func TestDeleteOwnAccount(t *testing.T) {
+	tests := []struct {
+		password string
+		token    string
+		expected int
+	}{
+		{"wrong", "user2_token", http.StatusForbidden},
+		{"password123", "invalid_token", http.StatusUnauthorized},
+		{"password123", "valid_token", http.StatusAccepted},
+	}
+
+	for _, test := range tests {
+		body, _ := json.Marshal(user.DeleteAccountRequest{Password: test.password})
+		req, _ := http.NewRequest("DELETE", ts.URL+"/user/me", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("password %q with %q: expected %d, got %d", test.password, test.token, test.expected, resp.StatusCode)
+		}
+	}
+
+	scheduledDeletionsMu.Lock()
+	defer scheduledDeletionsMu.Unlock()
+	if _, ok := scheduledDeletions[2]; ok {
+		t.Error("expected user 2 not to be scheduled for deletion")
+	}
+	expected := time.Now().Add(14 * 24 * time.Hour)
+	if deleteAfter, ok := scheduledDeletions[1]; !ok || deleteAfter.After(expected) || deleteAfter.Before(expected.Add(-time.Minute)) {
+		t.Errorf("expected user 1 to be deleted after %v, got %v", expected, deleteAfter)
+	}
+	delete(scheduledDeletions, 1)
+
+	if !revokedPersonalAccessTokens[1] || revokedPersonalAccessTokens[2] {
+		t.Errorf("expected only user 1's personal access tokens to be revoked, got %v", revokedPersonalAccessTokens)
+	}
+}
+
+func TestLoginCancelsAccountDeletion(t *testing.T) {
+	scheduledDeletionsMu.Lock()
+	scheduledDeletions[7] = time.Now().Add(time.Hour)
+	scheduledDeletionsMu.Unlock()
+
+	if resp := postLogin(t, "legacy@example.com", "password123"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	scheduledDeletionsMu.Lock()
+	defer scheduledDeletionsMu.Unlock()
+	if _, ok := scheduledDeletions[7]; ok {
+		t.Error("expected logging in to cancel the deletion")
+	}
+}
+
+func TestRunScheduledDeletions(t *testing.T) {
+	scheduledDeletionsMu.Lock()
+	scheduledDeletions[8] = time.Now().Add(-time.Minute)
+	scheduledDeletions[9] = time.Now().Add(time.Hour)
+	scheduledDeletionsMu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go user.RunScheduledDeletions(ctx, &MockUserService{}, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	scheduledDeletionsMu.Lock()
+	defer scheduledDeletionsMu.Unlock()
+	if _, ok := scheduledDeletions[8]; ok {
+		t.Error("expected the account past its grace period to be deleted")
+	}
+	if _, ok := scheduledDeletions[9]; !ok {
+		t.Error("expected the account in its grace period to be kept")
+	}
+	delete(scheduledDeletions, 9)
+}
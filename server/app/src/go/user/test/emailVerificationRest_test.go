@@ -0,0 +1,217 @@
+package user_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"software-slayer/auth"
+	"software-slayer/user"
+)
+
+type MockEmailVerificationService struct{}
+
+func (m *MockEmailVerificationService) GenerateVerificationToken(userId int, email string) (string, error) {
+	return "verification_token", nil
+}
+
+func (m *MockEmailVerificationService) ParseVerificationToken(token string) (int, string, error) {
+	switch token {
+	case "verification_token":
+		return 1, "test@example.com", nil
+	case "old_email_token":
+		return 1, "old@example.com", nil
+	case "new_email_token":
+		return 1, "new@example.com", nil
+	case "taken_email_token":
+		return 1, "taken@example.com", nil
+	case "expired_token":
+		return -1, "", auth.ErrTokenExpired
+	}
+	return -1, "", auth.ErrInvalidVerificationToken
+}
+
+func TestCreateUserSendsVerificationEmail(t *testing.T) {
+	body, _ := json.Marshal(user.CreateUserRequest{
+		Email:    "test@example.com",
+		Password: "password123",
+		UserBase: user.UserBase{Username: "testuser", FirstName: "John", LastName: "Doe"},
+	})
+
+	sent := len(testMailer.Messages())
+	resp, err := http.Post(ts.URL+"/user", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+
+	waitForMessages(sent + 1)
+	messages := testMailer.Messages()
+	if len(messages) != sent+1 {
+		t.Fatal("expected a verification email")
+	}
+	if messages[sent].To != "test@example.com" || !strings.Contains(messages[sent].Body, "https://example.com/user/verify?token=verification_token") {
+		t.Errorf("unexpected verification email %+v", messages[sent])
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	tests := map[string]int{
+		"verification_token": http.StatusOK,
+		"old_email_token":    http.StatusBadRequest,
+		"new_email_token":    http.StatusOK,
+		"taken_email_token":  http.StatusConflict,
+		"expired_token":      http.StatusBadRequest,
+		"":                   http.StatusBadRequest,
+	}
+
+	for token, expected := range tests {
+		resp, err := http.Get(ts.URL + "/user/verify?token=" + token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != expected {
+			t.Errorf("token %q: expected %d, got %d", token, expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	tests := []struct {
+		token    string
+		expected int
+	}{
+		{"valid_token", http.StatusAccepted},
+		{"user2_token", http.StatusTooManyRequests},
+		{"invalid_token", http.StatusUnauthorized},
+	}
+
+	sent := len(testMailer.Messages())
+	for _, test := range tests {
+		req, _ := http.NewRequest("POST", ts.URL+"/user/verify/resend", nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("token %q: expected %d, got %d", test.token, test.expected, resp.StatusCode)
+		}
+	}
+
+	waitForMessages(sent + 1)
+	if messages := testMailer.Messages(); len(messages) != sent+1 || messages[sent].To != "test@example.com" {
+		t.Errorf("expected 1 verification email, got %+v", messages[sent:])
+	}
+}
+
+func TestEmailVerificationPolicy(t *testing.T) {
+	defer auth.SetEmailVerificationPolicy(auth.VerificationOptional)
+
+	login, _ := json.Marshal(user.Credentials{Identifier: "test@example.com", Password: "password123"})
+	createToken, _ := json.Marshal(user.CreatePersonalAccessTokenRequest{Name: "ci", Scopes: []string{auth.ScopeReadLearnings}})
+
+	tests := []struct {
+		policy      auth.EmailVerificationPolicy
+		login       int
+		createToken int
+	}{
+		{auth.VerificationOptional, http.StatusOK, http.StatusCreated},
+		{auth.VerificationReadOnly, http.StatusOK, http.StatusForbidden},
+		{auth.VerificationRequired, http.StatusForbidden, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		auth.SetEmailVerificationPolicy(test.policy)
+
+		resp, err := http.Post(ts.URL+"/login", "application/json", bytes.NewBuffer(login))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.login {
+			t.Errorf("policy %s: expected login %d, got %d", test.policy, test.login, resp.StatusCode)
+		}
+
+		// The mocked user is unverified, so only the optional policy lets them make changes
+		req, _ := http.NewRequest("POST", ts.URL+"/user/tokens", bytes.NewBuffer(createToken))
+		req.Header.Set("Authorization", "Bearer valid_token")
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.createToken {
+			t.Errorf("policy %s: expected token creation %d, got %d", test.policy, test.createToken, resp.StatusCode)
+		}
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	tests := []struct {
+		request  user.ChangeEmailRequest
+		token    string
+		expected int
+	}{
+		{user.ChangeEmailRequest{Email: "new@example.com", Password: "wrong"}, "user2_token", http.StatusForbidden},
+		{user.ChangeEmailRequest{Email: "not-an-email", Password: "password123"}, "valid_token", http.StatusBadRequest},
+		{user.ChangeEmailRequest{Email: "Test@Example.com", Password: "password123"}, "valid_token", http.StatusBadRequest},
+		{user.ChangeEmailRequest{Email: "2fa@example.com", Password: "password123"}, "valid_token", http.StatusConflict},
+		{user.ChangeEmailRequest{Email: "new@example.com", Password: "password123"}, "invalid_token", http.StatusUnauthorized},
+		{user.ChangeEmailRequest{Email: "new@example.com", Password: "password123"}, "valid_token", http.StatusAccepted},
+	}
+
+	sent := len(testMailer.Messages())
+	for _, test := range tests {
+		body, _ := json.Marshal(test.request)
+		req, _ := http.NewRequest("POST", ts.URL+"/user/me/email", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("request %+v with %q: expected %d, got %d", test.request, test.token, test.expected, resp.StatusCode)
+		}
+	}
+
+	// The link goes to the new address, the email only changes once it is opened
+	waitForMessages(sent + 1)
+	messages := testMailer.Messages()
+	if len(messages) != sent+1 || messages[sent].To != "new@example.com" || !strings.Contains(messages[sent].Body, "verify?token=verification_token") {
+		t.Errorf("expected a verification email to the new address, got %+v", messages[sent:])
+	}
+}
+
+func TestConfirmEmailChangeNotifiesOldAddress(t *testing.T) {
+	sent := len(testMailer.Messages())
+	resp, err := http.Get(ts.URL + "/user/verify?token=new_email_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	waitForMessages(sent + 1)
+	messages := testMailer.Messages()
+	if len(messages) != sent+1 || messages[sent].To != "test@example.com" || !strings.Contains(messages[sent].Body, "new@example.com") {
+		t.Errorf("expected a notice to the old address, got %+v", messages[sent:])
+	}
+}
@@ -0,0 +1,106 @@
+package user_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"software-slayer/auth"
+	"software-slayer/user"
+)
+
+var testAuditLog = auth.NewInMemoryAuditLog()
+
+// testLoginThrottle delays logins to an account after 2 failures and locks it out after 3, addresses are hardly throttled
+var testLoginThrottle = auth.NewLoginThrottle(auth.NewInMemoryLoginAttemptStore(), testAuditLog, auth.ThrottleConfig{
+	FreeAttempts:     2,
+	BaseDelay:        time.Minute,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 3,
+	LockoutDuration:  time.Hour,
+	ResetAfter:       time.Hour,
+}, auth.ThrottleConfig{
+	FreeAttempts:     100,
+	BaseDelay:        time.Minute,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 1000,
+	LockoutDuration:  time.Hour,
+	ResetAfter:       time.Hour,
+})
+
+func postLogin(t *testing.T, identifier string, password string) *http.Response {
+	t.Helper()
+
+	body, _ := json.Marshal(user.Credentials{Identifier: identifier, Password: password})
+	resp, err := http.Post(ts.URL+"/login", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestLoginThrottle(t *testing.T) {
+	for _, identifier := range []string{"test@example.com", "nobody@example.com"} {
+		for i := 0; i < 3; i++ {
+			if resp := postLogin(t, identifier, "wrong"); resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("failed login %d for %s: expected status %d, got %d", i+1, identifier, http.StatusUnauthorized, resp.StatusCode)
+			}
+		}
+
+		// Locked out accounts are refused even with the right password
+		resp := postLogin(t, identifier, "password123")
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("login for %s: expected status %d, got %d", identifier, http.StatusTooManyRequests, resp.StatusCode)
+		}
+		if resp.Header.Get("Retry-After") != "3600" {
+			t.Errorf("login for %s: expected Retry-After 3600, got %q", identifier, resp.Header.Get("Retry-After"))
+		}
+	}
+
+	events := testAuditLog.Events()
+	if len(events) != 2 || events[0].Subject != auth.AccountKey(1) || events[1].Subject != auth.IdentifierKey("nobody@example.com") {
+		t.Errorf("expected lockout events for both identifiers, got %+v", events)
+	}
+}
+
+func TestUnlockAccount(t *testing.T) {
+	tests := []struct {
+		path     string
+		token    string
+		expected int
+	}{
+		{"/admin/users/1/unlock", "valid_token", http.StatusForbidden},
+		{"/admin/users/1/unlock", "moderator_token", http.StatusForbidden},
+		{"/admin/users/abc/unlock", "admin_token", http.StatusBadRequest},
+		{"/admin/users/99/unlock", "admin_token", http.StatusNotFound},
+		{"/admin/users/1/unlock", "admin_token", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("POST", ts.URL+test.path, nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("POST %s with %q: expected %d, got %d", test.path, test.token, test.expected, resp.StatusCode)
+		}
+	}
+
+	if resp := postLogin(t, "test@example.com", "password123"); resp.StatusCode != http.StatusOK {
+		t.Errorf("login after unlock: expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	events := testAuditLog.Events()
+	last := events[len(events)-1]
+	if last.Action != auth.AuditAccountUnlock || last.ActorID != 4 || last.Subject != auth.AccountKey(1) {
+		t.Errorf("expected an unlock event by user 4, got %+v", last)
+	}
+}
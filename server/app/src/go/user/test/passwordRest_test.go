@@ -0,0 +1,106 @@
+package user_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"software-slayer/auth"
+	"software-slayer/mail"
+	"software-slayer/user"
+)
+
+var testMailer = mail.NewInMemoryMailer()
+
+type MockPasswordResetService struct{}
+
+func (m *MockPasswordResetService) IssueResetToken(ctx context.Context, userId int) (string, error) {
+	return "reset_token", nil
+}
+
+func (m *MockPasswordResetService) LookupResetToken(ctx context.Context, token string) (int, error) {
+	return m.ConsumeResetToken(ctx, token)
+}
+
+func (m *MockPasswordResetService) ConsumeResetToken(ctx context.Context, token string) (int, error) {
+	if token == "reset_token" {
+		return 1, nil
+	}
+	return -1, auth.ErrInvalidResetToken
+}
+
+// waitForMessages waits up to a second for emails that are sent in the background
+func waitForMessages(count int) {
+	for i := 0; i < 100 && len(testMailer.Messages()) < count; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestForgotPassword(t *testing.T) {
+	tests := []struct {
+		email    string
+		expected int
+	}{
+		{"test@example.com", http.StatusAccepted},
+		{"unknown@example.com", http.StatusAccepted},
+		{"disabled@example.com", http.StatusAccepted},
+		{"not an email", http.StatusBadRequest},
+	}
+
+	sent := len(testMailer.Messages())
+	for _, test := range tests {
+		body, _ := json.Marshal(user.ForgotPasswordRequest{Email: test.email})
+
+		resp, err := http.Post(ts.URL+"/password/forgot", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("email %q: expected %d, got %d", test.email, test.expected, resp.StatusCode)
+		}
+	}
+
+	// Only the enabled account with that email gets a reset link, give other emails time to be sent too
+	waitForMessages(sent + 1)
+	time.Sleep(50 * time.Millisecond)
+	messages := testMailer.Messages()
+	if len(messages) != sent+1 {
+		t.Fatalf("expected 1 reset email, got %+v", messages[sent:])
+	}
+	if messages[sent].To != "test@example.com" || !strings.Contains(messages[sent].Body, "https://example.com/reset?token=reset_token") {
+		t.Errorf("unexpected reset email %+v", messages[sent])
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	tests := []struct {
+		request  user.ResetPasswordRequest
+		expected int
+	}{
+		{user.ResetPasswordRequest{Token: "reset_token", Password: "newpassword123"}, http.StatusOK},
+		{user.ResetPasswordRequest{Token: "used_token", Password: "newpassword123"}, http.StatusBadRequest},
+		{user.ResetPasswordRequest{Token: "reset_token", Password: "short"}, http.StatusBadRequest},
+		{user.ResetPasswordRequest{Token: "reset_token", Password: "test@example.com!"}, http.StatusBadRequest},
+		{user.ResetPasswordRequest{Token: "reset_token", Password: "breached-password"}, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		body, _ := json.Marshal(test.request)
+
+		resp, err := http.Post(ts.URL+"/password/reset", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("request %+v: expected %d, got %d", test.request, test.expected, resp.StatusCode)
+		}
+	}
+}
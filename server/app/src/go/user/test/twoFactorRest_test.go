@@ -0,0 +1,248 @@
+package user_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"software-slayer/auth"
+	"software-slayer/user"
+)
+
+// MockTwoFactorService has user 6 enrolled, accepting the code 123456 and any challenge token starting with challenge_
+type MockTwoFactorService struct {
+	mu   sync.Mutex
+	used map[string]bool
+}
+
+func (m *MockTwoFactorService) BeginEnrollment(ctx context.Context, userId int, account string) (auth.TOTPEnrollment, error) {
+	if userId == 6 {
+		return auth.TOTPEnrollment{}, auth.ErrTwoFactorAlreadyEnabled
+	}
+	return auth.TOTPEnrollment{Secret: "SECRET", URI: "otpauth://totp/Test:" + account + "?secret=SECRET"}, nil
+}
+
+func (m *MockTwoFactorService) ConfirmEnrollment(ctx context.Context, userId int, code string) ([]string, error) {
+	if code != "123456" {
+		return nil, auth.ErrInvalidTwoFactorCode
+	}
+	return []string{"abcde-fghij"}, nil
+}
+
+func (m *MockTwoFactorService) IsEnabled(ctx context.Context, userId int) (bool, error) {
+	return userId == 6, nil
+}
+
+func (m *MockTwoFactorService) VerifyCode(ctx context.Context, userId int, code string) error {
+	if userId != 6 {
+		return auth.ErrTwoFactorNotEnabled
+	}
+	if code != "123456" {
+		return auth.ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (m *MockTwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userId int) ([]string, error) {
+	return []string{"klmno-pqrst"}, nil
+}
+
+func (m *MockTwoFactorService) Disable(ctx context.Context, userId int) error {
+	return nil
+}
+
+func (m *MockTwoFactorService) IssueChallenge(userId int) (string, error) {
+	return "challenge_token", nil
+}
+
+func (m *MockTwoFactorService) ParseChallenge(token string) (int, error) {
+	if strings.HasPrefix(token, "challenge_") {
+		return 6, nil
+	}
+	return -1, auth.ErrInvalidChallengeToken
+}
+
+func (m *MockTwoFactorService) ConsumeChallenge(ctx context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.used == nil {
+		m.used = make(map[string]bool)
+	}
+	if m.used[token] {
+		return auth.ErrChallengeUsed
+	}
+	m.used[token] = true
+	return nil
+}
+
+func postTwoFactorLogin(t *testing.T, request user.TwoFactorLoginRequest) *http.Response {
+	t.Helper()
+
+	body, _ := json.Marshal(request)
+	resp, err := http.Post(ts.URL+"/login/2fa", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestLoginRequiresTwoFactor(t *testing.T) {
+	body, _ := json.Marshal(user.Credentials{Identifier: "2fa@example.com", Password: "password123"})
+
+	resp, err := http.Post(ts.URL+"/login", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected %d, got %d", http.StatusAccepted, resp.StatusCode)
+	}
+
+	var challenge user.TwoFactorChallengeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&challenge); err != nil {
+		t.Fatal(err)
+	}
+	if !challenge.TwoFactorRequired || challenge.ChallengeToken != "challenge_token" {
+		t.Errorf("expected a challenge, got %+v", challenge)
+	}
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	tests := []struct {
+		request  user.TwoFactorLoginRequest
+		expected int
+	}{
+		{user.TwoFactorLoginRequest{ChallengeToken: "challenge_login", Code: "123456"}, http.StatusOK},
+		// Challenges are single-use
+		{user.TwoFactorLoginRequest{ChallengeToken: "challenge_login", Code: "123456"}, http.StatusUnauthorized},
+		{user.TwoFactorLoginRequest{ChallengeToken: "challenge_wrong_code", Code: "654321"}, http.StatusUnauthorized},
+		{user.TwoFactorLoginRequest{ChallengeToken: "challenge_wrong_code", Code: "123456"}, http.StatusUnauthorized},
+		{user.TwoFactorLoginRequest{ChallengeToken: "expired_challenge", Code: "123456"}, http.StatusUnauthorized},
+		{user.TwoFactorLoginRequest{ChallengeToken: "challenge_no_code"}, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		resp := postTwoFactorLogin(t, test.request)
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("request %+v: expected %d, got %d", test.request, test.expected, resp.StatusCode)
+		}
+		if resp.StatusCode == http.StatusOK {
+			var loginResponse user.LoginResponse
+			if err := json.NewDecoder(resp.Body).Decode(&loginResponse); err != nil || loginResponse.Token != "mocked_token" || loginResponse.UserInfo.ID != 6 {
+				t.Errorf("expected tokens for user 6, got %+v", loginResponse)
+			}
+		}
+		resp.Body.Close()
+	}
+
+	// Forget the failed code so later tests can log in
+	testLoginThrottle.Unlock(context.Background(), 6, 1)
+}
+
+func TestTwoFactorLoginThrottle(t *testing.T) {
+	defer testLoginThrottle.Unlock(context.Background(), 6, 1)
+
+	for _, challenge := range []string{"challenge_guess_a", "challenge_guess_b", "challenge_guess_c"} {
+		resp := postTwoFactorLogin(t, user.TwoFactorLoginRequest{ChallengeToken: challenge, Code: "000000"})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("failed code with %s: expected %d, got %d", challenge, http.StatusUnauthorized, resp.StatusCode)
+		}
+	}
+
+	// Failed codes delay the account like failed passwords, even with a new challenge and the right code
+	resp := postTwoFactorLogin(t, user.TwoFactorLoginRequest{ChallengeToken: "challenge_guess_d", Code: "123456"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected %d, got %d", http.StatusTooManyRequests, resp.StatusCode)
+	}
+
+	// The throttled attempt didn't use up the challenge
+	testLoginThrottle.Unlock(context.Background(), 6, 1)
+	resp = postTwoFactorLogin(t, user.TwoFactorLoginRequest{ChallengeToken: "challenge_guess_d", Code: "123456"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d after unlocking, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestTwoFactorEnrollment(t *testing.T) {
+	// Forget the failed code so later tests can log in
+	defer testLoginThrottle.Unlock(context.Background(), 6, 1)
+
+	tests := []struct {
+		path     string
+		token    string
+		body     string
+		expected int
+	}{
+		{"/user/2fa/enroll", "valid_token", "", http.StatusOK},
+		{"/user/2fa/enroll", "invalid_token", "", http.StatusUnauthorized},
+		{"/user/2fa/confirm", "valid_token", `{"code": "123456"}`, http.StatusOK},
+		{"/user/2fa/confirm", "valid_token", `{"code": "000000"}`, http.StatusBadRequest},
+		{"/user/2fa/disable", "valid_token", `{"code": "123456"}`, http.StatusConflict},
+		{"/user/2fa/recovery-codes", "valid_token", `{"code": "123456"}`, http.StatusConflict},
+		{"/user/2fa/enroll", "2fa_token", "", http.StatusConflict},
+		{"/user/2fa/recovery-codes", "2fa_token", `{"code": "123456"}`, http.StatusOK},
+		{"/user/2fa/disable", "2fa_token", `{"code": "000000"}`, http.StatusForbidden},
+		{"/user/2fa/disable", "2fa_token", `{"code": "123456"}`, http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("POST", ts.URL+test.path, bytes.NewBufferString(test.body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("%s with %q: expected %d, got %d", test.path, test.body, test.expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestTwoFactorCodeThrottle(t *testing.T) {
+	defer testLoginThrottle.Unlock(context.Background(), 6, 1)
+
+	post := func(path string, code string) int {
+		req, _ := http.NewRequest("POST", ts.URL+path, bytes.NewBufferString(`{"code": "`+code+`"}`))
+		req.Header.Set("Authorization", "Bearer 2fa_token")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for _, path := range []string{"/user/2fa/disable", "/user/2fa/recovery-codes", "/user/2fa/disable"} {
+		if status := post(path, "000000"); status != http.StatusForbidden {
+			t.Errorf("failed code on %s: expected %d, got %d", path, http.StatusForbidden, status)
+		}
+	}
+
+	// Guessing codes with a signed in session locks the account out like failed logins
+	for _, path := range []string{"/user/2fa/disable", "/user/2fa/recovery-codes"} {
+		if status := post(path, "123456"); status != http.StatusTooManyRequests {
+			t.Errorf("%s: expected %d, got %d", path, http.StatusTooManyRequests, status)
+		}
+	}
+
+	// So does logging in
+	resp := postTwoFactorLogin(t, user.TwoFactorLoginRequest{ChallengeToken: "challenge_code_guess", Code: "123456"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("login: expected %d, got %d", http.StatusTooManyRequests, resp.StatusCode)
+	}
+}
@@ -8,23 +8,34 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
+	"sync"
 	"testing"
+	"time"
 
 	"software-slayer/auth"
 	"software-slayer/user"
+	"software-slayer/utils"
 )
 
 type MockUserService struct{}
 
-func (m *MockUserService) CreateUser(ctx context.Context, user *user.CreateUserRequest, passwordHash string) error {
+// legacyPasswordHash is "password123" hashed with a lower bcrypt cost than new hashes, so it is upgraded on login
+const legacyPasswordHash = "$2a$04$2il/babWtGhVKtR/1oriFOHYVkaTvNVKqvxKqaMYL0G3o/hQtvdSe"
+
+// updatedPasswords are the users whose password hash was updated, by id
+var updatedPasswords = map[int]string{}
+
+func (m *MockUserService) CreateUser(ctx context.Context, user *user.CreateUserRequest, passwordHash string) (int, error) {
 	if user.Email == "invalid" {
-		return errors.New("invalid email")
+		return -1, errors.New("invalid email")
 	}
-	return nil
+	return 1, nil
 }
 
-func (m *MockUserService) GetUsers(ctx context.Context) ([]user.GetUserResponse, error) {
-	return []user.GetUserResponse{
+// GetUsers lists public user 1 and private user 3
+func (m *MockUserService) GetUsers(ctx context.Context, filter user.UserFilter, page utils.PageRequest) (utils.Page[user.GetUserResponse], error) {
+	users := []user.GetUserResponse{
 		{
 			ID: 1,
 			UserBase: user.UserBase{
@@ -33,7 +44,22 @@ func (m *MockUserService) GetUsers(ctx context.Context) ([]user.GetUserResponse,
 				LastName:  "Doe",
 			},
 		},
-	}, nil
+		{
+			ID:       3,
+			UserBase: user.UserBase{Username: "hiddenuser"},
+		},
+	}
+	visibilities := map[int]auth.Visibility{1: auth.VisibilityPublic, 3: auth.VisibilityPrivate}
+
+	visible := make([]user.GetUserResponse, 0)
+	for _, u := range users {
+		if filter.Visibilities == nil || slices.Contains(filter.Visibilities, visibilities[u.ID]) || u.ID == filter.ViewerID {
+			visible = append(visible, u)
+		}
+	}
+	return utils.NewPage(visible, len(visible), page, func(u user.GetUserResponse) (string, int) {
+		return u.Username, u.ID
+	}), nil
 }
 
 func (m *MockUserService) GetUserByIdentifier(ctx context.Context, identifier string) (user.UserDB, error) {
@@ -46,6 +72,28 @@ func (m *MockUserService) GetUserByIdentifier(ctx context.Context, identifier st
 			PasswordHash: hashedPassword,
 		}, nil
 	}
+	if identifier == "2fa@example.com" {
+		return user.UserDB{
+			ID:           6,
+			Email:        "2fa@example.com",
+			PasswordHash: hashedPassword,
+		}, nil
+	}
+	if identifier == "legacy@example.com" {
+		return user.UserDB{
+			ID:           7,
+			Email:        "legacy@example.com",
+			PasswordHash: legacyPasswordHash,
+		}, nil
+	}
+	if identifier == "disabled@example.com" {
+		return user.UserDB{
+			ID:           5,
+			Email:        "disabled@example.com",
+			PasswordHash: legacyPasswordHash,
+			Disabled:     true,
+		}, nil
+	}
 	return user.UserDB{}, errors.New("user not found")
 }
 
@@ -54,31 +102,250 @@ func (m *MockUserService) GetUserById(ctx context.Context, id int) (user.UserDB,
 		return user.UserDB{
 			ID:           1,
 			Email:        "test@example.com",
-			PasswordHash: "hashedpassword",
+			PasswordHash: legacyPasswordHash,
+			UserBase:     user.UserBase{Username: "testuser", FirstName: "John", LastName: "Doe"},
 		}, nil
 	}
+	if id == 2 {
+		return user.UserDB{ID: 2, Email: "user2@example.com", PasswordHash: legacyPasswordHash}, nil
+	}
+	if id == 6 {
+		return user.UserDB{ID: 6, Email: "2fa@example.com"}, nil
+	}
 	return user.UserDB{}, errors.New("user not found")
 }
 
+func (m *MockUserService) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	updatedPasswords[id] = passwordHash
+	return nil
+}
+
+func (m *MockUserService) UpdateProfile(ctx context.Context, id int, profile user.UserBase) error {
+	if profile.Username == "takenuser" {
+		return errors.New("Error 1062 (23000): Duplicate entry 'takenuser' for key 'users.username'")
+	}
+	return nil
+}
+
+func (m *MockUserService) RequestEmailChange(ctx context.Context, id int, email string) error {
+	return nil
+}
+
+// ConfirmEmailChange only accepts new@example.com as the pending email, and taken@example.com is taken in the meantime
+func (m *MockUserService) ConfirmEmailChange(ctx context.Context, id int, email string) (bool, error) {
+	switch email {
+	case "new@example.com":
+		return true, nil
+	case "taken@example.com":
+		return false, errors.New("Error 1062 (23000): Duplicate entry 'taken@example.com' for key 'users.email'")
+	}
+	return false, nil
+}
+
+func (m *MockUserService) MarkEmailVerified(ctx context.Context, id int, email string) error {
+	return nil
+}
+
+func (m *MockUserService) ClaimVerificationEmail(ctx context.Context, id int, interval time.Duration) (bool, error) {
+	// User 2 was sent a verification email too recently
+	return id != 2, nil
+}
+
+func (m *MockUserService) GetAccounts(ctx context.Context, page utils.PageRequest) (utils.Page[user.AccountResponse], error) {
+	accounts := []user.AccountResponse{
+		{
+			Disabled: true,
+			GetCurrentUserResponse: user.GetCurrentUserResponse{
+				Email: "test@example.com",
+				Role:  auth.RoleUser,
+				GetUserResponse: user.GetUserResponse{
+					ID:       1,
+					UserBase: user.UserBase{Username: "testuser"},
+				},
+			},
+		},
+	}
+	return utils.NewPage(accounts, len(accounts), page, func(a user.AccountResponse) (string, int) {
+		return a.Username, a.ID
+	}), nil
+}
+
+func (m *MockUserService) SetUserDisabled(ctx context.Context, id int, disabled bool) error {
+	return nil
+}
+
+func (m *MockUserService) DeleteUser(ctx context.Context, id int) error {
+	return nil
+}
+
+func (m *MockUserService) GetPrivacySettings(ctx context.Context, id int) (user.PrivacySettings, error) {
+	return user.PrivacySettings{ProfileVisibility: auth.VisibilityPublic, LearningsVisibility: auth.VisibilityPublic}, nil
+}
+
+// updatedPrivacySettings are the privacy settings users changed to, by id
+var updatedPrivacySettings = map[int]user.PrivacySettings{}
+
+func (m *MockUserService) UpdatePrivacySettings(ctx context.Context, id int, settings user.PrivacySettings) error {
+	updatedPrivacySettings[id] = settings
+	return nil
+}
+
+// scheduledDeletions are when the users whose accounts are scheduled for deletion are deleted, by id
+var scheduledDeletions = map[int]time.Time{}
+var scheduledDeletionsMu sync.Mutex
+
+func (m *MockUserService) ScheduleDeletion(ctx context.Context, id int, deleteAfter time.Time) error {
+	scheduledDeletionsMu.Lock()
+	defer scheduledDeletionsMu.Unlock()
+	scheduledDeletions[id] = deleteAfter
+	return nil
+}
+
+func (m *MockUserService) CancelDeletion(ctx context.Context, id int) (bool, error) {
+	scheduledDeletionsMu.Lock()
+	defer scheduledDeletionsMu.Unlock()
+	_, ok := scheduledDeletions[id]
+	delete(scheduledDeletions, id)
+	return ok, nil
+}
+
+func (m *MockUserService) GetUsersDueForDeletion(ctx context.Context, now time.Time) ([]int, error) {
+	scheduledDeletionsMu.Lock()
+	defer scheduledDeletionsMu.Unlock()
+	ids := []int{}
+	for id, deleteAfter := range scheduledDeletions {
+		if !deleteAfter.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (m *MockUserService) DeleteUserIfDue(ctx context.Context, id int, now time.Time) (bool, error) {
+	scheduledDeletionsMu.Lock()
+	defer scheduledDeletionsMu.Unlock()
+	deleteAfter, ok := scheduledDeletions[id]
+	if !ok || deleteAfter.After(now) {
+		return false, nil
+	}
+	delete(scheduledDeletions, id)
+	return true, nil
+}
+
 type MockTokenService struct{}
 
-func (m *MockTokenService) GenerateToken(userID int) (string, error) {
+func (m *MockTokenService) GenerateToken(userID int, role auth.Role, emailVerified bool) (string, error) {
 	return "mocked_token", nil
 }
 
-func (m *MockTokenService) AuthorizeUser(token string) (int, error) {
+func (m *MockTokenService) RevokeToken(ctx context.Context, principal auth.Principal) error {
+	return nil
+}
+
+func (m *MockTokenService) RevokeAllTokens(ctx context.Context, userID int) error {
+	return nil
+}
+
+func (m *MockTokenService) AuthorizeUser(token string) (auth.Principal, error) {
 	if token == "valid_token" {
-		return 1, nil
+		return auth.Principal{UserID: 1, TokenID: "valid_token_id"}, nil
+	}
+	if token == "admin_token" {
+		return auth.Principal{UserID: 4, Role: auth.RoleAdmin, TokenID: "admin_token_id"}, nil
+	}
+	if token == "moderator_token" {
+		return auth.Principal{UserID: 3, Role: auth.RoleModerator, TokenID: "moderator_token_id"}, nil
+	}
+	if token == "user2_token" {
+		return auth.Principal{UserID: 2, TokenID: "user2_token_id"}, nil
+	}
+	if token == "2fa_token" {
+		return auth.Principal{UserID: 6, TokenID: "2fa_token_id"}, nil
+	}
+	if token == "read_users_pat" {
+		return auth.Principal{UserID: 1, Scopes: []string{auth.ScopeReadUsers}}, nil
 	}
-	return 0, errors.New("invalid token")
+	if token == "read_learnings_pat" {
+		return auth.Principal{UserID: 1, Scopes: []string{auth.ScopeReadLearnings}}, nil
+	}
+	if token == "expired_token" {
+		return auth.Principal{}, auth.ErrTokenExpired
+	}
+	return auth.Principal{}, errors.New("invalid token")
 }
 
 var ts *httptest.Server
 
+type MockRefreshTokenService struct{}
+
+func (m *MockRefreshTokenService) IssueRefreshToken(ctx context.Context, userId int) (string, error) {
+	return "refresh_token", nil
+}
+
+func (m *MockRefreshTokenService) RotateRefreshToken(ctx context.Context, refreshToken string) (int, string, error) {
+	switch refreshToken {
+	case "refresh_token":
+		return 1, "rotated_refresh_token", nil
+	case "reused_refresh_token":
+		return -1, "", auth.ErrRefreshTokenReused
+	case "db_error":
+		return -1, "", errors.New("database error")
+	}
+	return -1, "", auth.ErrInvalidRefreshToken
+}
+
+func (m *MockRefreshTokenService) RevokeRefreshToken(ctx context.Context, userId int, refreshToken string) error {
+	return nil
+}
+
+func (m *MockRefreshTokenService) RevokeAllRefreshTokens(ctx context.Context, userId int) error {
+	return nil
+}
+
+type MockPersonalAccessTokenService struct{}
+
+func (m *MockPersonalAccessTokenService) CreateToken(ctx context.Context, userId int, name string, scopes []string, expiresAt *time.Time) (auth.PersonalAccessToken, string, error) {
+	return auth.PersonalAccessToken{ID: 1, Name: name, Scopes: scopes, ExpiresAt: expiresAt}, auth.PersonalAccessTokenPrefix + "secret", nil
+}
+
+func (m *MockPersonalAccessTokenService) GetTokens(ctx context.Context, userId int) ([]auth.PersonalAccessToken, error) {
+	return []auth.PersonalAccessToken{{ID: 1, Name: "ci", Scopes: []string{auth.ScopeReadLearnings}}}, nil
+}
+
+// revokedPersonalAccessTokens are the users whose personal access tokens were all revoked
+var revokedPersonalAccessTokens = map[int]bool{}
+
+func (m *MockPersonalAccessTokenService) RevokeAllTokens(ctx context.Context, userId int) error {
+	revokedPersonalAccessTokens[userId] = true
+	return nil
+}
+
+func (m *MockPersonalAccessTokenService) RevokeToken(ctx context.Context, userId int, id int) error {
+	if userId == 1 && id == 1 {
+		return nil
+	}
+	return auth.ErrPersonalAccessTokenNotFound
+}
+
+func (m *MockPersonalAccessTokenService) AuthenticateToken(ctx context.Context, token string) (auth.Principal, error) {
+	return auth.Principal{}, auth.ErrInvalidPersonalAccessToken
+}
+
 func TestMain(m *testing.M) {
 	mockUserService := &MockUserService{}
 	mockTokenService := &MockTokenService{}
-	user.InitUserRest(mockUserService, mockTokenService)
+	mockRefreshTokenService := &MockRefreshTokenService{}
+	auth.SetPasswordPolicy(auth.PasswordPolicies{
+		auth.LengthPolicy{Min: 8, Max: 64},
+		auth.PersonalInfoPolicy{},
+		auth.NewBreachedPasswordPolicy([]string{"breached-password"}),
+	})
+	user.InitUserRest(mockUserService, mockTokenService, mockRefreshTokenService, &MockPersonalAccessTokenService{})
+	user.InitPasswordRest(testMailer, &MockPasswordResetService{}, "https://example.com/reset", time.Hour)
+	user.InitTwoFactorRest(&MockTwoFactorService{})
+	user.InitLoginThrottleRest(testLoginThrottle)
+	user.InitEmailVerificationRest(testMailer, &MockEmailVerificationService{}, "https://example.com/user/verify", 24*time.Hour, time.Minute)
+	user.InitAccountDeletionRest(14 * 24 * time.Hour)
 	ts = httptest.NewServer(http.DefaultServeMux)
 	defer ts.Close()
 
@@ -108,6 +375,49 @@ func TestCreateUserSuccess(t *testing.T) {
 	}
 }
 
+func TestCreateUserWeakPassword(t *testing.T) {
+	passwords := []string{"short", "testuser123", "breached-password"}
+
+	for _, password := range passwords {
+		body, _ := json.Marshal(user.CreateUserRequest{
+			Email:    "new@example.com",
+			Password: password,
+			UserBase: user.UserBase{Username: "testuser", FirstName: "John", LastName: "Doe"},
+		})
+
+		resp, err := http.Post(ts.URL+"/user", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("password %q: expected %d, got %d", password, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
+
+func TestHandleLoginRehashesPassword(t *testing.T) {
+	body, _ := json.Marshal(user.Credentials{Identifier: "legacy@example.com", Password: "password123"})
+
+	resp, err := http.Post(ts.URL+"/login", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	hash, ok := updatedPasswords[7]
+	if !ok || auth.NeedsRehash(hash) || auth.ValidatePassword("password123", hash) != nil {
+		t.Errorf("expected password hash to be upgraded, got %q", hash)
+	}
+	if _, ok := updatedPasswords[6]; ok {
+		t.Error("password hash with the current cost was rehashed")
+	}
+}
+
 func TestHandleLoginSuccess(t *testing.T) {
 	requestBody := user.Credentials{
 		Identifier: "test@example.com",
@@ -124,6 +434,104 @@ func TestHandleLoginSuccess(t *testing.T) {
 	if resp.StatusCode != http.StatusOK {
 		t.Errorf("expected %d, got %d", http.StatusOK, resp.StatusCode)
 	}
+
+	var loginResponse user.LoginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&loginResponse); err != nil {
+		t.Fatal(err)
+	}
+	if loginResponse.Token != "mocked_token" || loginResponse.RefreshToken != "refresh_token" {
+		t.Errorf("expected both tokens, got %+v", loginResponse)
+	}
+}
+
+func TestRefreshTokenSuccess(t *testing.T) {
+	body, _ := json.Marshal(user.RefreshTokenRequest{RefreshToken: "refresh_token"})
+
+	resp, err := http.Post(ts.URL+"/token/refresh", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var refreshResponse user.RefreshTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&refreshResponse); err != nil {
+		t.Fatal(err)
+	}
+	if refreshResponse.Token != "mocked_token" || refreshResponse.RefreshToken != "rotated_refresh_token" {
+		t.Errorf("expected rotated tokens, got %+v", refreshResponse)
+	}
+}
+
+func TestRefreshTokenFailures(t *testing.T) {
+	tests := map[string]int{
+		"":                     http.StatusBadRequest,
+		"unknown_token":        http.StatusUnauthorized,
+		"reused_refresh_token": http.StatusUnauthorized,
+		"db_error":             http.StatusInternalServerError,
+	}
+
+	for refreshToken, expected := range tests {
+		body, _ := json.Marshal(user.RefreshTokenRequest{RefreshToken: refreshToken})
+
+		resp, err := http.Post(ts.URL+"/token/refresh", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != expected {
+			t.Errorf("refresh token %q: expected %d, got %d", refreshToken, expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestLogout(t *testing.T) {
+	tests := []struct {
+		token    string
+		body     string
+		expected int
+	}{
+		{"valid_token", "", http.StatusNoContent},
+		{"valid_token", `{"refresh_token": "refresh_token"}`, http.StatusNoContent},
+		{"valid_token", "not json", http.StatusBadRequest},
+		{"invalid_token", "", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("POST", ts.URL+"/logout", bytes.NewBufferString(test.body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("token %q, body %q: expected %d, got %d", test.token, test.body, test.expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	for token, expected := range map[string]int{"valid_token": http.StatusNoContent, "invalid_token": http.StatusUnauthorized} {
+		req, _ := http.NewRequest("POST", ts.URL+"/logout/all", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != expected {
+			t.Errorf("token %q: expected %d, got %d", token, expected, resp.StatusCode)
+		}
+	}
 }
 
 func TestGetAllUsers(t *testing.T) {
@@ -138,6 +546,97 @@ func TestGetAllUsers(t *testing.T) {
 	}
 }
 
+func TestGetAllUsersVisibility(t *testing.T) {
+	for token, expected := range map[string]int{"": 1, "valid_token": 1, "admin_token": 2, "moderator_token": 2} {
+		req, _ := http.NewRequest("GET", ts.URL+"/user", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var users utils.Page[user.GetUserResponse]
+		err = json.NewDecoder(resp.Body).Decode(&users)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(users.Items) != expected {
+			t.Errorf("with %q: expected %d users, got %d", token, expected, len(users.Items))
+		}
+	}
+}
+
+func TestUpdatePrivacySettings(t *testing.T) {
+	tests := []struct {
+		body     string
+		token    string
+		expected int
+	}{
+		{`{"profile_visibility": "private"}`, "valid_token", http.StatusOK},
+		{`{"learnings_visibility": "friends"}`, "valid_token", http.StatusBadRequest},
+		{`{}`, "valid_token", http.StatusBadRequest},
+		{`{"profile_visibility": "private"}`, "invalid_token", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("PATCH", ts.URL+"/user/me/privacy", bytes.NewBufferString(test.body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("%s with %q: expected %d, got %d", test.body, test.token, test.expected, resp.StatusCode)
+		}
+	}
+
+	expected := user.PrivacySettings{ProfileVisibility: auth.VisibilityPrivate, LearningsVisibility: auth.VisibilityPublic}
+	if settings := updatedPrivacySettings[1]; settings != expected {
+		t.Errorf("expected %+v to be stored, got %+v", expected, settings)
+	}
+}
+
+func TestGetAllUsersPage(t *testing.T) {
+	resp, err := http.Get(ts.URL + "/user?limit=10&sort=username&order=desc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var users utils.Page[user.GetUserResponse]
+	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(users.Items) != 1 || users.Total != 1 || users.NextCursor != "" {
+		t.Errorf("expected a single page with 1 user, got %+v", users)
+	}
+}
+
+func TestGetAllUsersInvalidSort(t *testing.T) {
+	resp, err := http.Get(ts.URL + "/user?sort=email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
 func TestGetCurrentUser(t *testing.T) {
 	req, _ := http.NewRequest("GET", ts.URL+"/user", nil)
 	req.Header.Set("Authorization", "Bearer valid_token")
@@ -153,3 +652,302 @@ func TestGetCurrentUser(t *testing.T) {
 		t.Errorf("expected %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 }
+
+func TestGetCurrentUserExpiredToken(t *testing.T) {
+	req, _ := http.NewRequest("GET", ts.URL+"/user?current=true", nil)
+	req.Header.Set("Authorization", "Bearer expired_token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	var errorResponse utils.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
+		t.Fatal(err)
+	}
+	if errorResponse.Message != "Authentication token has expired" {
+		t.Errorf("unexpected message %q", errorResponse.Message)
+	}
+}
+
+func TestHandleLoginDisabledAccount(t *testing.T) {
+	body, _ := json.Marshal(user.Credentials{Identifier: "disabled@example.com", Password: "password123"})
+
+	resp, err := http.Post(ts.URL+"/login", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+	// Refused logins don't upgrade the password hash
+	if _, ok := updatedPasswords[5]; ok {
+		t.Error("password hash of a disabled account was rehashed")
+	}
+}
+
+func TestListAccounts(t *testing.T) {
+	tests := map[string]int{
+		"admin_token":     http.StatusOK,
+		"moderator_token": http.StatusForbidden,
+		"valid_token":     http.StatusForbidden,
+		"invalid_token":   http.StatusUnauthorized,
+	}
+
+	for token, expected := range tests {
+		req, _ := http.NewRequest("GET", ts.URL+"/admin/users", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != expected {
+			t.Errorf("token %q: expected %d, got %d", token, expected, resp.StatusCode)
+			continue
+		}
+
+		if expected == http.StatusOK {
+			var accounts utils.Page[user.AccountResponse]
+			if err := json.NewDecoder(resp.Body).Decode(&accounts); err != nil {
+				t.Fatal(err)
+			}
+			if len(accounts.Items) != 1 || accounts.Items[0].Email != "test@example.com" || !accounts.Items[0].Disabled {
+				t.Errorf("unexpected accounts %+v", accounts)
+			}
+		}
+	}
+}
+
+func TestAdminAccountActions(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		token    string
+		expected int
+	}{
+		{"POST", "/admin/users/2/disable", "admin_token", http.StatusOK},
+		{"POST", "/admin/users/2/enable", "admin_token", http.StatusOK},
+		{"POST", "/admin/users/2/disable", "valid_token", http.StatusForbidden},
+		{"POST", "/admin/users/2/disable", "moderator_token", http.StatusForbidden},
+		{"POST", "/admin/users/4/disable", "admin_token", http.StatusBadRequest},
+		{"POST", "/admin/users/99/disable", "admin_token", http.StatusNotFound},
+		{"POST", "/admin/users/abc/disable", "admin_token", http.StatusBadRequest},
+		{"DELETE", "/admin/users/2", "admin_token", http.StatusNoContent},
+		{"DELETE", "/admin/users/1", "valid_token", http.StatusForbidden},
+		{"DELETE", "/admin/users/4", "admin_token", http.StatusBadRequest},
+		{"DELETE", "/admin/users/99", "admin_token", http.StatusNotFound},
+		{"DELETE", "/admin/users/2", "invalid_token", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, ts.URL+test.path, nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("%s %s with %q: expected %d, got %d", test.method, test.path, test.token, test.expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestCreatePersonalAccessToken(t *testing.T) {
+	tests := []struct {
+		token    string
+		body     string
+		expected int
+	}{
+		{"valid_token", `{"name": "ci", "scopes": ["read:learnings", "write:learnings"]}`, http.StatusCreated},
+		{"valid_token", `{"name": "ci", "scopes": ["read:users"], "expires_in_days": 30}`, http.StatusCreated},
+		{"valid_token", `{"name": "ci", "scopes": []}`, http.StatusBadRequest},
+		{"valid_token", `{"name": "ci", "scopes": ["admin"]}`, http.StatusBadRequest},
+		{"valid_token", `{"name": "ci", "scopes": ["read:users", "read:users"]}`, http.StatusBadRequest},
+		{"valid_token", `{"name": "", "scopes": ["read:users"]}`, http.StatusBadRequest},
+		{"valid_token", `{"name": "ci", "scopes": ["read:users"], "expires_in_days": 0}`, http.StatusBadRequest},
+		{"valid_token", `{"name": "ci", "scopes": ["read:users"], "expires_in_days": 366}`, http.StatusBadRequest},
+		{"read_users_pat", `{"name": "ci", "scopes": ["read:users"]}`, http.StatusForbidden},
+		{"invalid_token", `{"name": "ci", "scopes": ["read:users"]}`, http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("POST", ts.URL+"/user/tokens", bytes.NewBufferString(test.body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("token %q, body %s: expected %d, got %d", test.token, test.body, test.expected, resp.StatusCode)
+			continue
+		}
+
+		if resp.StatusCode == http.StatusCreated {
+			var created user.CreatePersonalAccessTokenResponse
+			if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+				t.Fatal(err)
+			}
+			if !auth.IsPersonalAccessToken(created.Token) || created.Name != "ci" || len(created.Scopes) == 0 {
+				t.Errorf("unexpected response %+v", created)
+			}
+		}
+	}
+}
+
+func TestGetPersonalAccessTokens(t *testing.T) {
+	for token, expected := range map[string]int{"valid_token": http.StatusOK, "read_users_pat": http.StatusForbidden} {
+		req, _ := http.NewRequest("GET", ts.URL+"/user/tokens", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != expected {
+			t.Errorf("token %q: expected %d, got %d", token, expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestRevokePersonalAccessToken(t *testing.T) {
+	tests := []struct {
+		path     string
+		token    string
+		expected int
+	}{
+		{"/user/tokens/1", "valid_token", http.StatusNoContent},
+		{"/user/tokens/2", "valid_token", http.StatusNotFound},
+		{"/user/tokens/abc", "valid_token", http.StatusBadRequest},
+		{"/user/tokens/1", "read_users_pat", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("DELETE", ts.URL+test.path, nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("%s with %q: expected %d, got %d", test.path, test.token, test.expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestGetCurrentUserScopes(t *testing.T) {
+	for token, expected := range map[string]int{"read_users_pat": http.StatusOK, "read_learnings_pat": http.StatusForbidden} {
+		req, _ := http.NewRequest("GET", ts.URL+"/user?current=true", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != expected {
+			t.Errorf("token %q: expected %d, got %d", token, expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestUpdateProfileEndpoint(t *testing.T) {
+	tests := []struct {
+		body     string
+		token    string
+		expected int
+	}{
+		{`{"first_name": "Jane"}`, "valid_token", http.StatusOK},
+		{`{"username": "new_name", "last_name": "Smith"}`, "valid_token", http.StatusOK},
+		{`{"username": "takenuser"}`, "valid_token", http.StatusConflict},
+		{`{"username": "not@valid"}`, "valid_token", http.StatusBadRequest},
+		{`{"first_name": ""}`, "valid_token", http.StatusBadRequest},
+		{`{"first_name": "Jane"}`, "read_users_pat", http.StatusForbidden},
+		{`{"first_name": "Jane"}`, "invalid_token", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("PATCH", ts.URL+"/user/me", bytes.NewBufferString(test.body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("%s with %q: expected %d, got %d", test.body, test.token, test.expected, resp.StatusCode)
+		}
+		if resp.StatusCode == http.StatusOK {
+			var profile user.GetCurrentUserResponse
+			json.NewDecoder(resp.Body).Decode(&profile)
+			if profile.ID != 1 || profile.Email != "test@example.com" || (profile.FirstName != "Jane" && profile.LastName != "Smith") {
+				t.Errorf("%s: unexpected profile %+v", test.body, profile)
+			}
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	tests := []struct {
+		request  user.ChangePasswordRequest
+		token    string
+		expected int
+	}{
+		{user.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "newpassword123"}, "user2_token", http.StatusForbidden},
+		{user.ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "short"}, "valid_token", http.StatusBadRequest},
+		{user.ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "breached-password"}, "valid_token", http.StatusBadRequest},
+		{user.ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "newpassword123"}, "valid_token", http.StatusOK},
+		{user.ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "newpassword123"}, "invalid_token", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		body, _ := json.Marshal(test.request)
+		req, _ := http.NewRequest("POST", ts.URL+"/user/me/password", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("request %+v with %q: expected %d, got %d", test.request, test.token, test.expected, resp.StatusCode)
+		}
+		// The current session is kept with new tokens
+		if resp.StatusCode == http.StatusOK {
+			var tokens user.RefreshTokenResponse
+			if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil || tokens.Token != "mocked_token" || tokens.RefreshToken != "refresh_token" {
+				t.Errorf("expected new tokens, got %+v", tokens)
+			}
+		}
+		resp.Body.Close()
+	}
+
+	if hash := updatedPasswords[1]; auth.ValidatePassword("newpassword123", hash) != nil {
+		t.Error("expected the new password to be stored")
+	}
+}
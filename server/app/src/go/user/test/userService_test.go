@@ -4,23 +4,36 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 
+	"software-slayer/auth"
 	"software-slayer/db"
+	"software-slayer/export"
 	"software-slayer/user"
+	"software-slayer/utils"
 )
 
+var firstPage = utils.PageRequest{Limit: 20, Sort: "id", Column: "id"}
+
 func setup(t *testing.T) (sqlmock.Sqlmock, *user.UserServiceImpl) {
+	mock, s, _ := setupWithExports(t)
+	return mock, s
+}
+
+// setupWithExports also returns the export store the service deletes users' exports from
+func setupWithExports(t *testing.T) (sqlmock.Sqlmock, *user.UserServiceImpl, *export.LocalStore) {
 	// Create a mock sql.DB object using sqlmock
 	database, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 
-	s := user.NewUserService(db.NewDB(database))
+	exports := export.NewLocalStore(t.TempDir())
+	s := user.NewUserService(db.NewDB(database), exports)
 
-	return mock, s
+	return mock, s, exports
 }
 
 func TestCreateUser(t *testing.T) {
@@ -37,12 +50,15 @@ func TestCreateUser(t *testing.T) {
 	}
 	passwordHash := "passwordHash"
 
-	dbMock.ExpectExec("INSERT INTO users").WithArgs(user.Email, user.Username, passwordHash, user.FirstName, user.LastName).WillReturnResult(sqlmock.NewResult(1, 1))
+	dbMock.ExpectExec("INSERT INTO users").WithArgs(user.Email, user.Username, passwordHash, user.FirstName, user.LastName).WillReturnResult(sqlmock.NewResult(7, 1))
 
-	err := s.CreateUser(ctx, user, passwordHash)
+	id, err := s.CreateUser(ctx, user, passwordHash)
 	if err != nil {
 		t.Error("Expected nil, got ", err)
 	}
+	if id != 7 {
+		t.Error("Expected id 7, got ", id)
+	}
 }
 
 func TestCreateUserError(t *testing.T) {
@@ -61,7 +77,7 @@ func TestCreateUserError(t *testing.T) {
 
 	dbMock.ExpectExec("INSERT INTO users").WithArgs(user.Email, user.Username, user.FirstName, user.LastName).WillReturnError(errors.New("error"))
 
-	err := s.CreateUser(ctx, user, passwordHash)
+	_, err := s.CreateUser(ctx, user, passwordHash)
 	if err == nil {
 		t.Error("Expected error, got nil")
 	}
@@ -95,22 +111,56 @@ func TestGetUsers(t *testing.T) {
 		rows.AddRow(user.ID, user.Username, user.FirstName, user.LastName)
 	}
 
-	dbMock.ExpectQuery("SELECT id, username, first_name, last_name FROM users").WillReturnRows(rows)
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(len(users)))
+	dbMock.ExpectQuery("SELECT id, username, first_name, last_name FROM users ORDER BY id ASC, id ASC LIMIT \\?").WithArgs(21).WillReturnRows(rows)
 
-	res, err := s.GetUsers(ctx)
+	res, err := s.GetUsers(ctx, user.UserFilter{}, firstPage)
 	if err != nil {
 		t.Error("Expected nil, got ", err)
 	}
 
-	if len(res) != len(users) {
-		t.Error("Expected 2 users, got ", len(users))
+	if len(res.Items) != len(users) || res.Total != len(users) {
+		t.Error("Expected 2 users, got ", len(res.Items))
 	}
 
 	for i, user := range users {
-		if user.ID != res[i].ID || user.Username != res[i].Username || user.FirstName != res[i].FirstName || user.LastName != res[i].LastName {
-			t.Error("Expected ", user, ", got ", res[i])
+		if user.ID != res.Items[i].ID || user.Username != res.Items[i].Username || user.FirstName != res.Items[i].FirstName || user.LastName != res.Items[i].LastName {
+			t.Error("Expected ", user, ", got ", res.Items[i])
 		}
 	}
+
+	if res.NextCursor != "" {
+		t.Error("Expected no next cursor, got ", res.NextCursor)
+	}
+}
+
+func TestGetUsersNextPage(t *testing.T) {
+	dbMock, s := setup(t)
+	ctx := context.Background()
+
+	page := utils.PageRequest{Limit: 1, Sort: "username", Column: "username",
+		After: &utils.Cursor{Sort: "username", Value: "alice", ID: 3}}
+
+	rows := sqlmock.NewRows([]string{"id", "username", "first_name", "last_name"}).
+		AddRow(1, "user1", "John", "Doe").
+		AddRow(2, "user2", "Jane", "Doe")
+
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	dbMock.ExpectQuery("SELECT id, username, first_name, last_name FROM users WHERE \\(username > \\? OR \\(username = \\? AND id > \\?\\)\\) ORDER BY username ASC, id ASC LIMIT \\?").
+		WithArgs("alice", "alice", 3, 2).WillReturnRows(rows)
+
+	res, err := s.GetUsers(ctx, user.UserFilter{}, page)
+	if err != nil {
+		t.Error("Expected nil, got ", err)
+	}
+
+	if len(res.Items) != 1 || res.Items[0].Username != "user1" {
+		t.Error("Expected only user1, got ", res.Items)
+	}
+
+	if res.NextCursor == "" {
+		t.Error("Expected a next cursor")
+	}
 }
 
 func TestGetNoUsers(t *testing.T) {
@@ -119,15 +169,16 @@ func TestGetNoUsers(t *testing.T) {
 
 	rows := sqlmock.NewRows([]string{"id", "username", "first_name", "last_name"})
 
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	dbMock.ExpectQuery("SELECT id, username, first_name, last_name FROM users").WillReturnRows(rows)
 
-	res, err := s.GetUsers(ctx)
+	res, err := s.GetUsers(ctx, user.UserFilter{}, firstPage)
 	if err != nil {
 		t.Error("Expected nil, got ", err)
 	}
 
-	if len(res) != 0 {
-		t.Error("Expected 0 users, got ", len(res))
+	if len(res.Items) != 0 {
+		t.Error("Expected 0 users, got ", len(res.Items))
 	}
 }
 
@@ -135,14 +186,62 @@ func TestGetUsersError(t *testing.T) {
 	dbMock, s := setup(t)
 	ctx := context.Background()
 
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
 	dbMock.ExpectQuery("SELECT id, username, first_name, last_name FROM users").WillReturnError(errors.New("error"))
 
-	_, err := s.GetUsers(ctx)
+	_, err := s.GetUsers(ctx, user.UserFilter{}, firstPage)
 	if err == nil {
 		t.Error("Expected error, got nil")
 	}
 }
 
+func TestGetUsersVisibility(t *testing.T) {
+	dbMock, s := setup(t)
+	ctx := context.Background()
+
+	filter := user.UserFilter{ViewerID: 4, Visibilities: []auth.Visibility{auth.VisibilityPublic, auth.VisibilityOrganization}}
+	rows := sqlmock.NewRows([]string{"id", "username", "first_name", "last_name"}).AddRow(4, "user4", "Jane", "Doe")
+
+	// Viewers always see their own profile, whatever its visibility
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users WHERE \\(profile_visibility IN \\(\\?, \\?\\) OR id = \\?\\)").
+		WithArgs(auth.VisibilityPublic, auth.VisibilityOrganization, 4).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	dbMock.ExpectQuery("SELECT id, username, first_name, last_name FROM users WHERE \\(profile_visibility IN \\(\\?, \\?\\) OR id = \\?\\) AND \\(username > \\? OR \\(username = \\? AND id > \\?\\)\\) ORDER BY username ASC, id ASC LIMIT \\?").
+		WithArgs(auth.VisibilityPublic, auth.VisibilityOrganization, 4, "alice", "alice", 3, 21).WillReturnRows(rows)
+
+	page := utils.PageRequest{Limit: 20, Sort: "username", Column: "username",
+		After: &utils.Cursor{Sort: "username", Value: "alice", ID: 3}}
+	res, err := s.GetUsers(ctx, filter, page)
+	if err != nil || len(res.Items) != 1 || res.Total != 1 {
+		t.Errorf("Expected user4, got %+v, %v", res, err)
+	}
+	if err := dbMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPrivacySettings(t *testing.T) {
+	dbMock, s := setup(t)
+	ctx := context.Background()
+
+	dbMock.ExpectQuery("SELECT profile_visibility, learnings_visibility FROM users WHERE id = \\?").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"profile_visibility", "learnings_visibility"}).AddRow("public", "organization"))
+	dbMock.ExpectExec("UPDATE users SET profile_visibility = \\?, learnings_visibility = \\? WHERE id = \\?").
+		WithArgs(auth.VisibilityPrivate, auth.VisibilityOrganization, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	settings, err := s.GetPrivacySettings(ctx, 2)
+	if err != nil || settings.ProfileVisibility != auth.VisibilityPublic || settings.LearningsVisibility != auth.VisibilityOrganization {
+		t.Errorf("Expected public profile and organization learnings, got %+v, %v", settings, err)
+	}
+
+	settings.ProfileVisibility = auth.VisibilityPrivate
+	if err := s.UpdatePrivacySettings(ctx, 2, settings); err != nil {
+		t.Error("Expected nil, got ", err)
+	}
+	if err := dbMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestGetUserByIdentifier(t *testing.T) {
 	dbMock, s := setup(t)
 	ctx := context.Background()
@@ -155,30 +254,31 @@ func TestGetUserByIdentifier(t *testing.T) {
 			LastName:  "Doe",
 		},
 		Email: "user1@hotmail.com",
+		Role:  auth.RoleUser,
 	}
 
-	rows := sqlmock.NewRows([]string{"id", "username", "email", "password_hash", "first_name", "last_name"}).AddRow(user.ID, user.Username, user.Email, user.PasswordHash, user.FirstName, user.LastName)
+	rows := sqlmock.NewRows([]string{"id", "username", "email", "password_hash", "first_name", "last_name", "role", "disabled", "email_verified"}).AddRow(user.ID, user.Username, user.Email, user.PasswordHash, user.FirstName, user.LastName, user.Role, user.Disabled, user.EmailVerified)
 
-	dbMock.ExpectQuery("SELECT id, username, email, password_hash, first_name, last_name FROM users WHERE email = \\? OR username = \\?").WithArgs(user.Email, user.Email).WillReturnRows(rows)
-	dbMock.ExpectQuery("SELECT id, username, email, password_hash, first_name, last_name FROM users WHERE email = \\? OR username = \\?").WithArgs(user.Username, user.Username).WillReturnRows(rows)
+	dbMock.ExpectQuery("SELECT id, username, email, password_hash, first_name, last_name, role, disabled_at IS NOT NULL, email_verified_at IS NOT NULL FROM users WHERE email = \\? OR username = \\?").WithArgs(user.Email, user.Email).WillReturnRows(rows)
+	dbMock.ExpectQuery("SELECT id, username, email, password_hash, first_name, last_name, role, disabled_at IS NOT NULL, email_verified_at IS NOT NULL FROM users WHERE email = \\? OR username = \\?").WithArgs(user.Username, user.Username).WillReturnRows(rows)
 
 	res, err := s.GetUserByIdentifier(ctx, user.Email)
 	if err != nil {
 		t.Error("Expected nil, got ", err)
 	}
 
-	if user.ID != res.ID || user.Username != res.Username || user.Email != res.Email || user.PasswordHash != res.PasswordHash || user.FirstName != res.FirstName || user.LastName != res.LastName {
+	if user.ID != res.ID || user.Username != res.Username || user.Email != res.Email || user.PasswordHash != res.PasswordHash || user.FirstName != res.FirstName || user.LastName != res.LastName || user.Role != res.Role || user.Disabled != res.Disabled || user.EmailVerified != res.EmailVerified {
 		t.Error("Expected ", user, ", got ", res)
 	}
 
-	rows.AddRow(user.ID, user.Username, user.Email, user.PasswordHash, user.FirstName, user.LastName)
+	rows.AddRow(user.ID, user.Username, user.Email, user.PasswordHash, user.FirstName, user.LastName, user.Role, user.Disabled, user.EmailVerified)
 
 	res, err = s.GetUserByIdentifier(ctx, user.Username)
 	if err != nil {
 		t.Error("Expected nil, got ", err)
 	}
 
-	if user.ID != res.ID || user.Username != res.Username || user.Email != res.Email || user.PasswordHash != res.PasswordHash || user.FirstName != res.FirstName || user.LastName != res.LastName {
+	if user.ID != res.ID || user.Username != res.Username || user.Email != res.Email || user.PasswordHash != res.PasswordHash || user.FirstName != res.FirstName || user.LastName != res.LastName || user.Role != res.Role || user.Disabled != res.Disabled || user.EmailVerified != res.EmailVerified {
 		t.Error("Expected ", user, ", got ", res)
 	}
 }
@@ -187,9 +287,9 @@ func TestGetUserByIdentifierNoUser(t *testing.T) {
 	dbMock, s := setup(t)
 	ctx := context.Background()
 
-	rows := sqlmock.NewRows([]string{"id", "username", "email", "password_hash", "first_name", "last_name"})
+	rows := sqlmock.NewRows([]string{"id", "username", "email", "password_hash", "first_name", "last_name", "role", "disabled", "email_verified"})
 
-	dbMock.ExpectQuery("SELECT id, username, email, password_hash, first_name, last_name FROM users WHERE email = \\? OR username = \\?").WillReturnRows(rows)
+	dbMock.ExpectQuery("SELECT id, username, email, password_hash, first_name, last_name, role, disabled_at IS NOT NULL, email_verified_at IS NOT NULL FROM users WHERE email = \\? OR username = \\?").WillReturnRows(rows)
 
 	_, err := s.GetUserByIdentifier(ctx, "")
 	if err == nil {
@@ -201,7 +301,7 @@ func TestGetUserByIdentifierError(t *testing.T) {
 	dbMock, s := setup(t)
 	ctx := context.Background()
 
-	dbMock.ExpectQuery("SELECT id, username, email, password_hash, first_name, last_name FROM users WHERE email = \\? OR username = \\?").WillReturnError(errors.New("error"))
+	dbMock.ExpectQuery("SELECT id, username, email, password_hash, first_name, last_name, role, disabled_at IS NOT NULL, email_verified_at IS NOT NULL FROM users WHERE email = \\? OR username = \\?").WillReturnError(errors.New("error"))
 
 	_, err := s.GetUserByIdentifier(ctx, "")
 	if err == nil {
@@ -220,19 +320,22 @@ func TestGetUserById(t *testing.T) {
 			FirstName: "John",
 			LastName:  "Doe",
 		},
-		Email: "user@email.ca",
+		Email:         "user@email.ca",
+		Role:          auth.RoleAdmin,
+		Disabled:      true,
+		EmailVerified: true,
 	}
 
-	rows := sqlmock.NewRows([]string{"id", "username", "email", "password_hash", "first_name", "last_name"}).AddRow(user.ID, user.Username, user.Email, user.PasswordHash, user.FirstName, user.LastName)
+	rows := sqlmock.NewRows([]string{"id", "username", "email", "password_hash", "first_name", "last_name", "role", "disabled", "email_verified"}).AddRow(user.ID, user.Username, user.Email, user.PasswordHash, user.FirstName, user.LastName, user.Role, user.Disabled, user.EmailVerified)
 
-	dbMock.ExpectQuery("SELECT id, username, email, password_hash, first_name, last_name FROM users WHERE id = \\?").WithArgs(user.ID).WillReturnRows(rows)
+	dbMock.ExpectQuery("SELECT id, username, email, password_hash, first_name, last_name, role, disabled_at IS NOT NULL, email_verified_at IS NOT NULL FROM users WHERE id = \\?").WithArgs(user.ID).WillReturnRows(rows)
 
 	res, err := s.GetUserById(ctx, user.ID)
 	if err != nil {
 		t.Error("Expected nil, got ", err)
 	}
 
-	if user.ID != res.ID || user.Username != res.Username || user.Email != res.Email || user.PasswordHash != res.PasswordHash || user.FirstName != res.FirstName || user.LastName != res.LastName {
+	if user.ID != res.ID || user.Username != res.Username || user.Email != res.Email || user.PasswordHash != res.PasswordHash || user.FirstName != res.FirstName || user.LastName != res.LastName || user.Role != res.Role || user.Disabled != res.Disabled || user.EmailVerified != res.EmailVerified {
 		t.Error("Expected ", user, ", got ", res)
 	}
 }
@@ -241,12 +344,280 @@ func TestGetUserByIdNoUser(t *testing.T) {
 	dbMock, s := setup(t)
 	ctx := context.Background()
 
-	rows := sqlmock.NewRows([]string{"id", "username", "email", "password_hash", "first_name", "last_name"})
+	rows := sqlmock.NewRows([]string{"id", "username", "email", "password_hash", "first_name", "last_name", "role", "disabled", "email_verified"})
 
-	dbMock.ExpectQuery("SELECT id, username, email, password_hash, first_name, last_name FROM users WHERE id = \\?").WillReturnRows(rows)
+	dbMock.ExpectQuery("SELECT id, username, email, password_hash, first_name, last_name, role, disabled_at IS NOT NULL, email_verified_at IS NOT NULL FROM users WHERE id = \\?").WillReturnRows(rows)
 
 	_, err := s.GetUserById(ctx, 1)
 	if err == nil {
 		t.Error("Expected error, got nil")
 	}
 }
+
+func TestGetAccounts(t *testing.T) {
+	dbMock, s := setup(t)
+	ctx := context.Background()
+
+	dbMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	rows := sqlmock.NewRows([]string{"id", "username", "email", "first_name", "last_name", "role", "disabled", "email_verified"}).
+		AddRow(1, "admin", "admin@email.ca", "Ada", "Lovelace", "admin", false, true).
+		AddRow(2, "user", "user@email.ca", "John", "Doe", "user", true, false)
+	dbMock.ExpectQuery("SELECT id, username, email, first_name, last_name, role, disabled_at IS NOT NULL, email_verified_at IS NOT NULL FROM users ORDER BY id ASC, id ASC LIMIT \\?").
+		WithArgs(21).WillReturnRows(rows)
+
+	accounts, err := s.GetAccounts(ctx, firstPage)
+	if err != nil {
+		t.Fatal("Expected nil, got ", err)
+	}
+
+	if accounts.Total != 2 || len(accounts.Items) != 2 {
+		t.Fatal("Expected 2 accounts, got ", accounts)
+	}
+	if accounts.Items[0].Role != auth.RoleAdmin || accounts.Items[0].Disabled || !accounts.Items[0].EmailVerified || accounts.Items[1].Email != "user@email.ca" || !accounts.Items[1].Disabled {
+		t.Error("Unexpected accounts ", accounts.Items)
+	}
+}
+
+func TestSetUserDisabled(t *testing.T) {
+	dbMock, s := setup(t)
+	ctx := context.Background()
+
+	dbMock.ExpectExec("UPDATE users SET disabled_at = IF\\(\\?, COALESCE\\(disabled_at, CURRENT_TIMESTAMP\\), NULL\\) WHERE id = \\?").
+		WithArgs(true, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := s.SetUserDisabled(ctx, 2, true); err != nil {
+		t.Error("Expected nil, got ", err)
+	}
+	if err := dbMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMarkEmailVerified(t *testing.T) {
+	dbMock, s := setup(t)
+	ctx := context.Background()
+
+	dbMock.ExpectExec("UPDATE users SET email_verified_at = COALESCE\\(email_verified_at, CURRENT_TIMESTAMP\\) WHERE id = \\? AND email = \\?").
+		WithArgs(2, "user@email.ca").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := s.MarkEmailVerified(ctx, 2, "user@email.ca"); err != nil {
+		t.Error("Expected nil, got ", err)
+	}
+	if err := dbMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClaimVerificationEmail(t *testing.T) {
+	dbMock, s := setup(t)
+	ctx := context.Background()
+
+	// No row is updated when the user is verified or was sent an email within the interval
+	dbMock.ExpectExec("UPDATE users SET verification_sent_at = CURRENT_TIMESTAMP").
+		WithArgs(2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("UPDATE users SET verification_sent_at = CURRENT_TIMESTAMP").
+		WithArgs(2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if claimed, err := s.ClaimVerificationEmail(ctx, 2, time.Minute); err != nil || !claimed {
+		t.Error("Expected claimed, got ", claimed, err)
+	}
+	if claimed, err := s.ClaimVerificationEmail(ctx, 2, time.Minute); err != nil || claimed {
+		t.Error("Expected not claimed, got ", claimed, err)
+	}
+	if err := dbMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	dbMock, s := setup(t)
+	ctx := context.Background()
+
+	dbMock.ExpectExec("UPDATE users SET username = \\?, first_name = \\?, last_name = \\? WHERE id = \\?").
+		WithArgs("newname", "Jane", "Doe", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := s.UpdateProfile(ctx, 1, user.UserBase{Username: "newname", FirstName: "Jane", LastName: "Doe"}); err != nil {
+		t.Error("Expected nil, got ", err)
+	}
+	if err := dbMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEmailChange(t *testing.T) {
+	dbMock, s := setup(t)
+	ctx := context.Background()
+
+	dbMock.ExpectExec("UPDATE users SET pending_email = \\? WHERE id = \\?").
+		WithArgs("new@example.com", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	// No row is updated when the verified email is not the pending one
+	dbMock.ExpectExec("UPDATE users SET email = pending_email, pending_email = NULL").
+		WithArgs(1, "new@example.com").WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("UPDATE users SET email = pending_email, pending_email = NULL").
+		WithArgs(1, "old@example.com").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := s.RequestEmailChange(ctx, 1, "new@example.com"); err != nil {
+		t.Error("Expected nil, got ", err)
+	}
+	if changed, err := s.ConfirmEmailChange(ctx, 1, "new@example.com"); err != nil || !changed {
+		t.Error("Expected changed, got ", changed, err)
+	}
+	if changed, err := s.ConfirmEmailChange(ctx, 1, "old@example.com"); err != nil || changed {
+		t.Error("Expected not changed, got ", changed, err)
+	}
+	if err := dbMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	dbMock, s, exports := setupWithExports(t)
+	ctx := context.Background()
+	job, _ := exports.CreateJob(ctx, 2)
+	otherJob, _ := exports.CreateJob(ctx, 3)
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("DELETE FROM user_learning_list WHERE user_id = \\?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 3))
+	dbMock.ExpectExec("DELETE FROM tags WHERE user_id = \\?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 2))
+	dbMock.ExpectExec("DELETE FROM categories WHERE user_id = \\?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("DELETE FROM users WHERE id = \\?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	if err := s.DeleteUser(ctx, 2); err != nil {
+		t.Error("Expected nil, got ", err)
+	}
+	if err := dbMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	// The user's data exports are deleted with them
+	if _, err := exports.GetJob(ctx, job.ID); !errors.Is(err, export.ErrJobNotFound) {
+		t.Errorf("Expected the export to be deleted, got %v", err)
+	}
+	if _, err := exports.GetJob(ctx, otherJob.ID); err != nil {
+		t.Errorf("Expected another user's export to be kept, got %v", err)
+	}
+}
+
+func TestDeleteUserRollsBack(t *testing.T) {
+	dbMock, s, exports := setupWithExports(t)
+	ctx := context.Background()
+	job, _ := exports.CreateJob(ctx, 2)
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("DELETE FROM user_learning_list WHERE user_id = \\?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 3))
+	dbMock.ExpectExec("DELETE FROM tags WHERE user_id = \\?").WithArgs(2).WillReturnError(errors.New("error"))
+	dbMock.ExpectRollback()
+
+	if err := s.DeleteUser(ctx, 2); err == nil {
+		t.Error("Expected error, got nil")
+	}
+	if err := dbMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if _, err := exports.GetJob(ctx, job.ID); err != nil {
+		t.Errorf("Expected the export to be kept, got %v", err)
+	}
+}
+
+func TestDeleteUserKeepsExportsIfCommitFails(t *testing.T) {
+	dbMock, s, exports := setupWithExports(t)
+	ctx := context.Background()
+	job, _ := exports.CreateJob(ctx, 2)
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("DELETE FROM user_learning_list WHERE user_id = \\?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 3))
+	dbMock.ExpectExec("DELETE FROM tags WHERE user_id = \\?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 2))
+	dbMock.ExpectExec("DELETE FROM categories WHERE user_id = \\?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("DELETE FROM users WHERE id = \\?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit().WillReturnError(errors.New("error"))
+
+	if err := s.DeleteUser(ctx, 2); err == nil {
+		t.Error("Expected error, got nil")
+	}
+	if err := dbMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	// The user still exists, so their exports are kept
+	if _, err := exports.GetJob(ctx, job.ID); err != nil {
+		t.Errorf("Expected the export to be kept, got %v", err)
+	}
+}
+
+func TestScheduleAndCancelDeletion(t *testing.T) {
+	dbMock, s := setup(t)
+	ctx := context.Background()
+	deleteAfter := time.Now().UTC().Truncate(time.Second)
+
+	dbMock.ExpectExec("UPDATE users SET delete_after = \\? WHERE id = \\?").WithArgs(deleteAfter, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("UPDATE users SET delete_after = NULL WHERE id = \\? AND delete_after IS NOT NULL").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("UPDATE users SET delete_after = NULL WHERE id = \\? AND delete_after IS NOT NULL").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := s.ScheduleDeletion(ctx, 2, deleteAfter); err != nil {
+		t.Error("Expected nil, got ", err)
+	}
+	if cancelled, err := s.CancelDeletion(ctx, 2); err != nil || !cancelled {
+		t.Errorf("Expected the deletion to be cancelled, got %v, %v", cancelled, err)
+	}
+	if cancelled, err := s.CancelDeletion(ctx, 2); err != nil || cancelled {
+		t.Errorf("Expected nothing to cancel, got %v, %v", cancelled, err)
+	}
+	if err := dbMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetUsersDueForDeletion(t *testing.T) {
+	dbMock, s := setup(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	dbMock.ExpectQuery("SELECT id FROM users WHERE delete_after <= \\?").WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(5))
+
+	ids, err := s.GetUsersDueForDeletion(ctx, now)
+	if err != nil || len(ids) != 2 || ids[0] != 2 || ids[1] != 5 {
+		t.Errorf("Expected [2 5], got %v, %v", ids, err)
+	}
+	if err := dbMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeleteUserIfDue(t *testing.T) {
+	dbMock, s, exports := setupWithExports(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	deletedJob, _ := exports.CreateJob(ctx, 2)
+	keptJob, _ := exports.CreateJob(ctx, 5)
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery("SELECT delete_after <= \\? FROM users WHERE id = \\? AND delete_after IS NOT NULL FOR UPDATE").WithArgs(now, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"due"}).AddRow(true))
+	dbMock.ExpectExec("DELETE FROM user_learning_list WHERE user_id = \\?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 3))
+	dbMock.ExpectExec("DELETE FROM tags WHERE user_id = \\?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 2))
+	dbMock.ExpectExec("DELETE FROM categories WHERE user_id = \\?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("DELETE FROM users WHERE id = \\?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	// The user logged in after being listed, cancelling the deletion
+	dbMock.ExpectBegin()
+	dbMock.ExpectQuery("SELECT delete_after <= \\? FROM users WHERE id = \\? AND delete_after IS NOT NULL FOR UPDATE").WithArgs(now, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"due"}))
+	dbMock.ExpectCommit()
+
+	if deleted, err := s.DeleteUserIfDue(ctx, 2, now); err != nil || !deleted {
+		t.Errorf("Expected user 2 to be deleted, got %v, %v", deleted, err)
+	}
+	if deleted, err := s.DeleteUserIfDue(ctx, 5, now); err != nil || deleted {
+		t.Errorf("Expected user 5 to be kept, got %v, %v", deleted, err)
+	}
+	if err := dbMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if _, err := exports.GetJob(ctx, deletedJob.ID); !errors.Is(err, export.ErrJobNotFound) {
+		t.Errorf("Expected the deleted user's export to be deleted, got %v", err)
+	}
+	if _, err := exports.GetJob(ctx, keptJob.ID); err != nil {
+		t.Errorf("Expected the kept user's export to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"software-slayer/auth"
+	"software-slayer/utils"
+)
+
+var twoFactorService auth.TwoFactorService
+
+// @Summary Complete a two-factor login
+// @Description Exchange the challenge token returned by POST /login and a code from an authenticator app, or a recovery code, for an access token and refresh token. A challenge token can only be used once, after an invalid code the user logs in again. Failed codes count towards the same throttle as failed passwords.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorLoginRequest true "Challenge token and code"
+// @Success 200 {object} LoginResponse
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Invalid or expired challenge token, or invalid code"
+// @Failure 403 {object} utils.ErrorResponse "Account disabled, or email not verified"
+// @Failure 429 {object} utils.ErrorResponse "Too many failed login attempts"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /login/2fa [post]
+func handleTwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var request TwoFactorLoginRequest
+	if err := utils.Decode(w, r, &request); err != nil {
+		return
+	}
+
+	if request.Code == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	userId, err := twoFactorService.ParseChallenge(request.ChallengeToken)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token")
+		return
+	}
+
+	// Codes are guessed against the same limits as passwords, checked before the challenge is used up
+	ip := utils.ClientIP(r)
+	accountKey := auth.AccountKey(userId)
+	if !checkLoginThrottle(ctx, w, accountKey, ip) {
+		return
+	}
+
+	if err := twoFactorService.ConsumeChallenge(ctx, request.ChallengeToken); err != nil {
+		if errors.Is(err, auth.ErrChallengeUsed) || errors.Is(err, auth.ErrInvalidChallengeToken) || errors.Is(err, auth.ErrTokenExpired) {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to verify two-factor code")
+		return
+	}
+
+	user, err := userService.GetUserById(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token")
+		return
+	}
+
+	// The account may have been disabled since the password was checked
+	if user.Disabled {
+		utils.RespondWithError(w, http.StatusForbidden, "This account has been disabled")
+		return
+	}
+
+	if !auth.CanLogIn(user.EmailVerified) {
+		utils.RespondWithError(w, http.StatusForbidden, "Verify your email address before logging in")
+		return
+	}
+
+	if err := twoFactorService.VerifyCode(ctx, userId, request.Code); err != nil {
+		if errors.Is(err, auth.ErrInvalidTwoFactorCode) || errors.Is(err, auth.ErrTwoFactorNotEnabled) {
+			recordLoginFailure(ctx, accountKey, ip)
+			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid two-factor code")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to verify two-factor code")
+		return
+	}
+
+	if err := loginThrottle.RecordSuccess(ctx, accountKey); err != nil {
+		log.Printf("Failed to reset failed logins for user ID %d: %v", user.ID, err)
+	}
+
+	completeLogin(ctx, w, user)
+}
+
+// @Summary Start two-factor enrollment
+// @Description Generate a TOTP secret for the current user. Two-factor authentication is enabled once a code generated from it is confirmed with POST /user/2fa/confirm.
+// @Tags Two-Factor Authentication
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} auth.TOTPEnrollment
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 409 {object} utils.ErrorResponse "Two-factor authentication already enabled"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/2fa/enroll [post]
+func enrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	user, err := userService.GetUserById(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user information")
+		return
+	}
+
+	enrollment, err := twoFactorService.BeginEnrollment(ctx, userId, user.Email)
+	if err != nil {
+		if errors.Is(err, auth.ErrTwoFactorAlreadyEnabled) {
+			utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to start two-factor enrollment")
+		return
+	}
+
+	log.Printf("Started two-factor enrollment for user ID %d", userId)
+	utils.RespondWithJSON(w, http.StatusOK, enrollment)
+}
+
+// @Summary Confirm two-factor enrollment
+// @Description Enable two-factor authentication with the first code from an authenticator app. The recovery codes are only returned once.
+// @Tags Two-Factor Authentication
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body TwoFactorCodeRequest true "Code from the authenticator app"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} utils.ErrorResponse "Invalid code, or enrollment not started"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 409 {object} utils.ErrorResponse "Two-factor authentication already enabled"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/2fa/confirm [post]
+func confirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var request TwoFactorCodeRequest
+	if err := utils.Decode(w, r, &request); err != nil {
+		return
+	}
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	recoveryCodes, err := twoFactorService.ConfirmEnrollment(ctx, userId, request.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidTwoFactorCode):
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid two-factor code")
+		case errors.Is(err, auth.ErrTwoFactorNotEnabled):
+			utils.RespondWithError(w, http.StatusBadRequest, "Two-factor enrollment has not been started")
+		case errors.Is(err, auth.ErrTwoFactorAlreadyEnabled):
+			utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		default:
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		}
+		return
+	}
+
+	log.Printf("Enabled two-factor authentication for user ID %d", userId)
+	utils.RespondWithJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+// @Summary Disable two-factor authentication
+// @Description Disable two-factor authentication for the current user, confirmed with a code from the authenticator app or a recovery code
+// @Tags Two-Factor Authentication
+// @Accept json
+// @Param Authorization header string true "Bearer token"
+// @Param request body TwoFactorCodeRequest true "Code from the authenticator app, or a recovery code"
+// @Success 204 "Two-factor authentication disabled"
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Invalid two-factor code"
+// @Failure 409 {object} utils.ErrorResponse "Two-factor authentication not enabled"
+// @Failure 429 {object} utils.ErrorResponse "Too many failed attempts"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/2fa/disable [post]
+func disableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, ok := verifyTwoFactorCode(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	if err := twoFactorService.Disable(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	log.Printf("Disabled two-factor authentication for user ID %d", userId)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Regenerate recovery codes
+// @Description Replace the current user's recovery codes, confirmed with a code from the authenticator app or a recovery code. The old codes stop working and the new codes are only returned once.
+// @Tags Two-Factor Authentication
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body TwoFactorCodeRequest true "Code from the authenticator app, or a recovery code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Invalid two-factor code"
+// @Failure 409 {object} utils.ErrorResponse "Two-factor authentication not enabled"
+// @Failure 429 {object} utils.ErrorResponse "Too many failed attempts"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/2fa/recovery-codes [post]
+func regenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, ok := verifyTwoFactorCode(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := twoFactorService.RegenerateRecoveryCodes(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to regenerate recovery codes")
+		return
+	}
+
+	log.Printf("Regenerated recovery codes for user ID %d", userId)
+	utils.RespondWithJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+/*
+ * verifyTwoFactorCode checks the code in the request body against the current user's two-factor enrollment,
+ * responding with an error if it is not valid
+ * @param ctx: the request context
+ * @param w: the response writer
+ * @param r: the request
+ * @return int: the id of the current user
+ * @return bool: whether the code is valid
+ */
+func verifyTwoFactorCode(ctx context.Context, w http.ResponseWriter, r *http.Request) (int, bool) {
+	var request TwoFactorCodeRequest
+	if err := utils.Decode(w, r, &request); err != nil {
+		return -1, false
+	}
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	// Guesses count towards the same lockout as failed logins, so a stolen session can't brute force the code
+	ip := utils.ClientIP(r)
+	accountKey := auth.AccountKey(userId)
+	if !checkLoginThrottle(ctx, w, accountKey, ip) {
+		return -1, false
+	}
+
+	if err := twoFactorService.VerifyCode(ctx, userId, request.Code); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidTwoFactorCode):
+			recordLoginFailure(ctx, accountKey, ip)
+			utils.RespondWithError(w, http.StatusForbidden, "Invalid two-factor code")
+		case errors.Is(err, auth.ErrTwoFactorNotEnabled):
+			utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is not enabled")
+		default:
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to verify two-factor code")
+		}
+		return -1, false
+	}
+
+	return userId, true
+}
+
+/*
+ * InitTwoFactorRest initializes the two-factor authentication REST endpoints, after InitUserRest
+ * POST /login relies on the two-factor service, so it must be initialized before the server starts
+ * @param _twoFactorService: the service TOTP enrollments and challenges are managed with
+ */
+func InitTwoFactorRest(_twoFactorService auth.TwoFactorService) {
+	twoFactorService = _twoFactorService
+
+	auth.RegisterRoutes(tokenService, []auth.Route{
+		{Pattern: "POST /login/2fa", Access: auth.Public, Handler: handleTwoFactorLogin},
+		{Pattern: "POST /user/2fa/enroll", Access: auth.Authenticated, AllowUnverified: true, Handler: enrollTwoFactor},
+		{Pattern: "POST /user/2fa/confirm", Access: auth.Authenticated, AllowUnverified: true, Handler: confirmTwoFactor},
+		{Pattern: "POST /user/2fa/disable", Access: auth.Authenticated, AllowUnverified: true, Handler: disableTwoFactor},
+		{Pattern: "POST /user/2fa/recovery-codes", Access: auth.Authenticated, AllowUnverified: true, Handler: regenerateRecoveryCodes},
+	})
+
+	log.Println("Two-factor authentication REST endpoints initialized")
+}
@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,9 +16,11 @@ import (
 
 var userService UserService
 var tokenService auth.TokenService
+var refreshTokenService auth.RefreshTokenService
+var personalAccessTokenService auth.PersonalAccessTokenService
 
 // @Summary Create a new user
-// @Description Register a new user with an email, password, and name
+// @Description Register a new user with an email, password, and name. A link to verify the email is sent to it.
 // @Tags Users
 // @Accept json
 // @Produce json
@@ -48,7 +51,7 @@ func createUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = userService.CreateUser(ctx, &user, passwordHash)
+	userId, err := userService.CreateUser(ctx, &user, passwordHash)
 	if err != nil {
 		if strings.Contains(err.Error(), "Duplicate entry") {
 			utils.RespondWithError(w, http.StatusConflict, "A user with this email or username already exists")
@@ -58,19 +61,25 @@ func createUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// New accounts start unverified, the email is sent in the background so a slow mail server doesn't delay the response
+	go sendFirstVerificationEmail(userId, user.Email, user.Username)
+
 	log.Printf("Successfully created user: %s", user.Username)
 	utils.RespondWithJSON(w, http.StatusCreated, map[string]string{"message": "User created successfully"})
 }
 
 // @Summary Login
-// @Description Login with an email/username and password
+// @Description Login with an email/username and password. Users with two-factor authentication get a challenge token to complete the login with POST /login/2fa. Repeated failures delay further logins for the account and client, and eventually lock them out for a while.
 // @Tags Users
 // @Accept json
 // @Produce json
 // @Param credentials body Credentials true "Credentials object for login"
 // @Success 200 {object} LoginResponse
+// @Success 202 {object} TwoFactorChallengeResponse "Two-factor code required"
 // @Failure 400 {object} utils.ErrorResponse "Invalid credentials format"
 // @Failure 401 {object} utils.ErrorResponse "Authentication failed"
+// @Failure 403 {object} utils.ErrorResponse "Account disabled, or email not verified"
+// @Failure 429 {object} utils.ErrorResponse "Too many failed login attempts"
 // @Failure 500 {object} utils.ErrorResponse "Server error"
 // @Router /login [post]
 func handleLogin(w http.ResponseWriter, r *http.Request) {
@@ -85,18 +94,119 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Login attempt for: %s", credentials.Identifier)
 
 	user, err := userService.GetUserByIdentifier(ctx, credentials.Identifier)
+
+	// Failures are tracked per account so guessing can't switch between its email and username,
+	// unknown identifiers are tracked too so they can't be told apart from accounts by their responses
+	ip := utils.ClientIP(r)
+	accountKey := auth.IdentifierKey(credentials.Identifier)
+	if err == nil {
+		accountKey = auth.AccountKey(user.ID)
+	}
+
+	// Checked before the password so throttled attempts don't cost a bcrypt comparison
+	if !checkLoginThrottle(ctx, w, accountKey, ip) {
+		return
+	}
+
 	if err != nil {
+		auth.CompareDummyPassword(credentials.Password)
+		recordLoginFailure(ctx, accountKey, ip)
 		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
 	err = auth.ValidatePassword(credentials.Password, user.PasswordHash)
 	if err != nil {
+		recordLoginFailure(ctx, accountKey, ip)
 		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
-	token, err := tokenService.GenerateToken(user.ID)
+	if user.Disabled {
+		utils.RespondWithError(w, http.StatusForbidden, "This account has been disabled")
+		return
+	}
+
+	if !auth.CanLogIn(user.EmailVerified) {
+		utils.RespondWithError(w, http.StatusForbidden, "Verify your email address before logging in")
+		return
+	}
+
+	twoFactorEnabled, err := twoFactorService.IsEnabled(ctx, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+
+	// Only hashes of accounts that passed every check are upgraded
+	rehashPassword(ctx, user, credentials.Password)
+
+	// Enrolled users only get a challenge for the password, the tokens are issued by POST /login/2fa
+	if twoFactorEnabled {
+		challengeToken, err := twoFactorService.IssueChallenge(user.ID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
+
+		log.Printf("Password accepted for user ID %d, awaiting two-factor code", user.ID)
+		utils.RespondWithJSON(w, http.StatusAccepted, TwoFactorChallengeResponse{TwoFactorRequired: true, ChallengeToken: challengeToken})
+		return
+	}
+
+	// Failures are only forgotten once the login is complete, so the password step can't reset the count of failed codes
+	if err := loginThrottle.RecordSuccess(ctx, accountKey); err != nil {
+		log.Printf("Failed to reset failed logins for user ID %d: %v", user.ID, err)
+	}
+
+	completeLogin(ctx, w, user)
+}
+
+/*
+ * rehashPassword upgrades the stored hash of a user's password if it was created with an outdated cost or algorithm,
+ * which can only be done while the plain password is known. Failing to upgrade the hash doesn't fail the login.
+ * @param ctx: the request context
+ * @param user: the user who logged in
+ * @param password: the password the user logged in with
+ */
+func rehashPassword(ctx context.Context, user UserDB, password string) {
+	if !auth.NeedsRehash(user.PasswordHash) {
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		log.Printf("Failed to rehash password for user ID %d: %v", user.ID, err)
+		return
+	}
+	if err := userService.UpdatePassword(ctx, user.ID, passwordHash); err != nil {
+		log.Printf("Failed to store rehashed password for user ID %d: %v", user.ID, err)
+		return
+	}
+
+	log.Printf("Upgraded password hash for user ID %d", user.ID)
+}
+
+/*
+ * completeLogin issues an access token and refresh token to a user who has been authenticated
+ * @param ctx: the request context
+ * @param w: the response writer
+ * @param user: the user logging in
+ */
+func completeLogin(ctx context.Context, w http.ResponseWriter, user UserDB) {
+	// Logging in during the grace period keeps an account the user asked to delete
+	if err := cancelAccountDeletion(ctx, user); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+
+	token, err := tokenService.GenerateToken(user.ID, user.Role, user.EmailVerified)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	refreshToken, err := refreshTokenService.IssueRefreshToken(ctx, user.ID)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
 		return
@@ -105,26 +215,157 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Successful login for user: %s (ID: %d)", user.Username, user.ID)
 
 	loginResponse := LoginResponse{
-		Token: token,
-		UserInfo: GetCurrentUserResponse{
-			Email: user.Email,
-			GetUserResponse: GetUserResponse{
-				ID:       user.ID,
-				UserBase: user.UserBase,
-			},
-		},
+		Token:        token,
+		RefreshToken: refreshToken,
+		UserInfo:     newCurrentUserResponse(user),
 	}
 
 	utils.RespondWithJSON(w, http.StatusOK, loginResponse)
 }
 
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token can only be used once; reusing one revokes every token issued from the same login.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param request body RefreshTokenRequest true "Refresh token returned by login or a previous refresh"
+// @Success 200 {object} RefreshTokenResponse
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Invalid, expired or reused refresh token"
+// @Failure 403 {object} utils.ErrorResponse "Account disabled, or email not verified"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /token/refresh [post]
+func refreshToken(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var request RefreshTokenRequest
+	if err := utils.Decode(w, r, &request); err != nil {
+		return
+	}
+
+	if request.RefreshToken == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid refresh_token")
+		return
+	}
+
+	userId, newRefreshToken, err := refreshTokenService.RotateRefreshToken(ctx, request.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokenReused) {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+
+	// The role is read again so that role changes and email verification apply from the next refresh
+	user, err := userService.GetUserById(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+
+	if user.Disabled {
+		utils.RespondWithError(w, http.StatusForbidden, "This account has been disabled")
+		return
+	}
+
+	if !auth.CanLogIn(user.EmailVerified) {
+		utils.RespondWithError(w, http.StatusForbidden, "Verify your email address before logging in")
+		return
+	}
+
+	token, err := tokenService.GenerateToken(user.ID, user.Role, user.EmailVerified)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	log.Printf("Refreshed token for user ID: %d", userId)
+	utils.RespondWithJSON(w, http.StatusOK, RefreshTokenResponse{Token: token, RefreshToken: newRefreshToken})
+}
+
+// @Summary Logout
+// @Description Revoke the current access token and, if given, the refresh token issued with it
+// @Tags Users
+// @Accept json
+// @Param Authorization header string true "Bearer token"
+// @Param request body RefreshTokenRequest false "Refresh token to revoke"
+// @Success 204 "Logged out"
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /logout [post]
+func logout(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	// The body is optional, clients that never stored the refresh token can send none
+	var request RefreshTokenRequest
+	if r.ContentLength != 0 {
+		if err := utils.Decode(w, r, &request); err != nil {
+			return
+		}
+	}
+
+	if err := tokenService.RevokeToken(ctx, principal); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	if request.RefreshToken != "" {
+		if err := refreshTokenService.RevokeRefreshToken(ctx, principal.UserID, request.RefreshToken); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to log out")
+			return
+		}
+	}
+
+	log.Printf("User ID %d logged out", principal.UserID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Logout everywhere
+// @Description Revoke every access token and refresh token of the current user
+// @Tags Users
+// @Param Authorization header string true "Bearer token"
+// @Success 204 "Logged out of all sessions"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /logout/all [post]
+func logoutAll(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	if err := refreshTokenService.RevokeAllRefreshTokens(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	if err := tokenService.RevokeAllTokens(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	log.Printf("User ID %d logged out of all sessions", userId)
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // @Summary Get users
-// @Description Get a filtered set of users
+// @Description Get a filtered set of users. Only profiles the caller may see are listed: public ones to everyone, organization ones to signed in users and private ones to moderators and admins.
 // @Tags Users
 // @Produce json
 // @Param Authorization header string false "Bearer token"
 // @Param current query bool false "Get only the current user"
-// @Success 200 {array} GetUserResponse
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query int false "Maximum number of users to return (1-100, default 20)"
+// @Param sort query string false "Field to sort by" Enums(id, username, first_name, last_name)
+// @Param order query string false "Sort direction" Enums(asc, desc)
+// @Success 200 {object} utils.Page[GetUserResponse]
 // @Failure 400 {object} utils.ErrorResponse "Invalid request parameters"
 // @Failure 401 {object} utils.ErrorResponse "Unauthorized"
 // @Failure 500 {object} utils.ErrorResponse "Server error"
@@ -150,7 +391,7 @@ func getUsers(w http.ResponseWriter, r *http.Request) {
 	if current {
 		getCurrentUser(ctx, w, r)
 	} else {
-		getAllUsers(ctx, w)
+		getAllUsers(ctx, w, r)
 	}
 }
 
@@ -161,56 +402,596 @@ func getUsers(w http.ResponseWriter, r *http.Request) {
  * @param r: the request
  */
 func getCurrentUser(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	userId, err := tokenService.AuthorizeUser(r.Header.Get("Authorization"))
+	// GET /user allows anonymous callers, but the current user only exists for authenticated ones
+	userId, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, auth.TokenErrorMessage(auth.ErrTokenMissing))
+		return
+	}
+
+	user, err := userService.GetUserById(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user information")
+		return
+	}
+
+	log.Printf("Retrieved current user: %s (ID: %d)", user.Username, user.ID)
+
+	utils.RespondWithJSON(w, http.StatusOK, newCurrentUserResponse(user))
+}
+
+/*
+ * getAllUsers gets a page of users from the database and returns it as a response.
+ * @param ctx: the request context
+ * @param w: the response writer
+ * @param r: the request
+ */
+func getAllUsers(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	page, err := utils.ParsePageRequest(r, userSortColumns, "id")
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s parameter", err.Error()))
+		return
+	}
+
+	// Anonymous callers have the zero principal, which only sees public profiles
+	principal, _ := auth.PrincipalFromContext(ctx)
+	filter := UserFilter{ViewerID: principal.UserID, Visibilities: auth.VisibleTo(principal, auth.Resource{})}
+
+	users, err := userService.GetUsers(ctx, filter, page)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve users")
+		return
+	}
+
+	log.Printf("Retrieved %d of %d users", len(users.Items), users.Total)
+	utils.RespondWithJSON(w, http.StatusOK, users)
+}
+
+// @Summary Create a personal access token
+// @Description Create a long-lived token for scripts and integrations, restricted to the given scopes. The token is only returned once. Personal access tokens can't be used to manage tokens.
+// @Tags Personal Access Tokens
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body CreatePersonalAccessTokenRequest true "Name, scopes (read:learnings, write:learnings, read:users) and optional lifetime"
+// @Success 201 {object} CreatePersonalAccessTokenResponse
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Personal access tokens can't create tokens"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/tokens [post]
+func createPersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var request CreatePersonalAccessTokenRequest
+	if err := utils.Decode(w, r, &request); err != nil {
+		return
+	}
+
+	if err := validateCreatePersonalAccessTokenRequest(request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	var expiresAt *time.Time
+	if request.ExpiresInDays != nil {
+		expiry := time.Now().AddDate(0, 0, *request.ExpiresInDays)
+		expiresAt = &expiry
+	}
+
+	details, token, err := personalAccessTokenService.CreateToken(ctx, userId, request.Name, request.Scopes, expiresAt)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create personal access token")
+		return
+	}
+
+	log.Printf("Created personal access token ID: %d with scopes %v for user ID: %d", details.ID, details.Scopes, userId)
+	utils.RespondWithJSON(w, http.StatusCreated, CreatePersonalAccessTokenResponse{Token: token, PersonalAccessToken: details})
+}
+
+// @Summary Get personal access tokens
+// @Description Get the personal access tokens of the current user that have not been revoked
+// @Tags Personal Access Tokens
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {array} auth.PersonalAccessToken
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Personal access tokens can't list tokens"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/tokens [get]
+func getPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	tokens, err := personalAccessTokenService.GetTokens(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve personal access tokens")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, tokens)
+}
 
+// @Summary Revoke a personal access token
+// @Description Revoke a personal access token of the current user, it stops working immediately
+// @Tags Personal Access Tokens
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "ID of the personal access token"
+// @Success 204 "Token revoked"
+// @Failure 400 {object} utils.ErrorResponse "Invalid token ID"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Personal access tokens can't revoke tokens"
+// @Failure 404 {object} utils.ErrorResponse "Token not found"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/tokens/{id} [delete]
+func revokePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tokenId, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or missing authentication token")
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid token ID")
 		return
 	}
 
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	if err := personalAccessTokenService.RevokeToken(ctx, userId, tokenId); err != nil {
+		if errors.Is(err, auth.ErrPersonalAccessTokenNotFound) {
+			utils.RespondWithError(w, http.StatusNotFound, "Personal access token not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke personal access token")
+		return
+	}
+
+	log.Printf("Revoked personal access token ID: %d for user ID: %d", tokenId, userId)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Update current user profile
+// @Description Change the username, first name or last name of the current user. Fields left out are kept.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body UpdateProfileRequest true "Fields to change"
+// @Success 200 {object} GetCurrentUserResponse
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 409 {object} utils.ErrorResponse "Username already taken"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/me [patch]
+func updateProfile(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var request UpdateProfileRequest
+	if err := utils.Decode(w, r, &request); err != nil {
+		return
+	}
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
 	user, err := userService.GetUserById(ctx, userId)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user information")
 		return
 	}
 
-	log.Printf("Retrieved current user: %s (ID: %d)", user.Username, user.ID)
+	user.UserBase = applyProfileUpdate(user.UserBase, request)
+	if err := validateUserBase(user.UserBase); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+
+	if err := userService.UpdateProfile(ctx, userId, user.UserBase); err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			utils.RespondWithError(w, http.StatusConflict, "A user with this username already exists")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update profile")
+		return
+	}
+
+	log.Printf("Updated profile of user ID %d", userId)
+	utils.RespondWithJSON(w, http.StatusOK, newCurrentUserResponse(user))
+}
+
+// @Summary Get privacy settings
+// @Description Get who may see the current user's profile and learning list: everyone (public), signed in users (organization) or only the user (private)
+// @Tags Users
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} PrivacySettings
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/me/privacy [get]
+func getPrivacySettings(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	settings, err := userService.GetPrivacySettings(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve privacy settings")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, settings)
+}
+
+// @Summary Update privacy settings
+// @Description Change who may see the current user's profile and learning list. Learning items can override the visibility of the list with their own.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body UpdatePrivacyRequest true "Settings to change"
+// @Success 200 {object} PrivacySettings
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/me/privacy [patch]
+func updatePrivacySettings(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var request UpdatePrivacyRequest
+	if err := utils.Decode(w, r, &request); err != nil {
+		return
+	}
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	settings, err := userService.GetPrivacySettings(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve privacy settings")
+		return
+	}
+
+	settings, err = applyPrivacyUpdate(settings, request)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+
+	if err := userService.UpdatePrivacySettings(ctx, userId, settings); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update privacy settings")
+		return
+	}
+
+	log.Printf("Updated privacy settings of user ID %d to %+v", userId, settings)
+	utils.RespondWithJSON(w, http.StatusOK, settings)
+}
+
+// @Summary Change password
+// @Description Change the current user's password. Every other session is signed out and has to log in again with the new password, the current session continues with the access token and refresh token returned.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} RefreshTokenResponse "Password changed, tokens for the current session"
+// @Failure 400 {object} utils.ErrorResponse "Invalid new password"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Current password is incorrect"
+// @Failure 429 {object} utils.ErrorResponse "Too many failed password attempts"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /user/me/password [post]
+func changePassword(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var request ChangePasswordRequest
+	if err := utils.Decode(w, r, &request); err != nil {
+		return
+	}
+
+	userId, _ := auth.UserIDFromContext(ctx)
+
+	user, err := userService.GetUserById(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user information")
+		return
+	}
+
+	if !checkCurrentPassword(ctx, w, r, user, request.CurrentPassword) {
+		return
+	}
+
+	if err := auth.CheckPassword(request.NewPassword, user.Username, user.Email); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid password: %s", err.Error()))
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(request.NewPassword)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to process password")
+		return
+	}
 
-	currentUserResponse := GetCurrentUserResponse{
-		Email: user.Email,
+	if err := userService.UpdatePassword(ctx, userId, passwordHash); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	// Sessions started with the old password must end, the current one is kept with tokens issued after the revocation
+	if err := refreshTokenService.RevokeAllRefreshTokens(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+	if err := tokenService.RevokeAllTokens(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	token, err := tokenService.GenerateToken(userId, user.Role, user.EmailVerified)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	refreshToken, err := refreshTokenService.IssueRefreshToken(ctx, userId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	log.Printf("Changed password for user ID %d", userId)
+	utils.RespondWithJSON(w, http.StatusOK, RefreshTokenResponse{Token: token, RefreshToken: refreshToken})
+}
+
+/*
+ * checkCurrentPassword confirms a sensitive account change with the user's current password, responding with an error
+ * if it is wrong. Wrong passwords count as failed logins, so a stolen session can't be used to guess the password.
+ * @param ctx: the request context
+ * @param w: the response writer
+ * @param r: the request
+ * @param user: the current user
+ * @param password: the password given in the request
+ * @return bool: whether the password is correct
+ */
+func checkCurrentPassword(ctx context.Context, w http.ResponseWriter, r *http.Request, user UserDB, password string) bool {
+	ip := utils.ClientIP(r)
+	accountKey := auth.AccountKey(user.ID)
+	if !checkLoginThrottle(ctx, w, accountKey, ip) {
+		return false
+	}
+
+	if err := auth.ValidatePassword(password, user.PasswordHash); err != nil {
+		recordLoginFailure(ctx, accountKey, ip)
+		utils.RespondWithError(w, http.StatusForbidden, "Current password is incorrect")
+		return false
+	}
+	return true
+}
+
+// newCurrentUserResponse returns the user as seen by themselves
+func newCurrentUserResponse(user UserDB) GetCurrentUserResponse {
+	return GetCurrentUserResponse{
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Role:          user.Role,
 		GetUserResponse: GetUserResponse{
 			ID:       user.ID,
 			UserBase: user.UserBase,
 		},
 	}
+}
+
+// @Summary List accounts
+// @Description Get a page of every account, including email, role and whether it is disabled. Requires the admin role.
+// @Tags Admin
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query int false "Maximum number of accounts to return (1-100, default 20)"
+// @Param sort query string false "Field to sort by" Enums(id, username, first_name, last_name)
+// @Param order query string false "Sort direction" Enums(asc, desc)
+// @Success 200 {object} utils.Page[AccountResponse]
+// @Failure 400 {object} utils.ErrorResponse "Invalid request parameters"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Forbidden"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /admin/users [get]
+func getAccounts(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+	if !auth.Can(principal, auth.ActionListAccounts, auth.Resource{}) {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have permission to list accounts")
+		return
+	}
+
+	page, err := utils.ParsePageRequest(r, userSortColumns, "id")
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s parameter", err.Error()))
+		return
+	}
 
-	utils.RespondWithJSON(w, http.StatusOK, currentUserResponse)
+	accounts, err := userService.GetAccounts(ctx, page)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve accounts")
+		return
+	}
+
+	log.Printf("User ID %d retrieved %d of %d accounts", principal.UserID, len(accounts.Items), accounts.Total)
+	utils.RespondWithJSON(w, http.StatusOK, accounts)
+}
+
+// @Summary Disable an account
+// @Description Disable an account and revoke all of its tokens, it can no longer log in until re-enabled. Requires the admin role.
+// @Tags Admin
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "ID of the user"
+// @Success 200 {object} map[string]string "Account disabled"
+// @Failure 400 {object} utils.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Forbidden"
+// @Failure 404 {object} utils.ErrorResponse "User not found"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /admin/users/{id}/disable [post]
+func disableAccount(w http.ResponseWriter, r *http.Request) {
+	setAccountDisabled(w, r, true)
+}
+
+// @Summary Enable an account
+// @Description Re-enable a disabled account. Requires the admin role.
+// @Tags Admin
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "ID of the user"
+// @Success 200 {object} map[string]string "Account enabled"
+// @Failure 400 {object} utils.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Forbidden"
+// @Failure 404 {object} utils.ErrorResponse "User not found"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /admin/users/{id}/enable [post]
+func enableAccount(w http.ResponseWriter, r *http.Request) {
+	setAccountDisabled(w, r, false)
 }
 
 /*
- * getAllUsers gets all users from the database and returns them as a response.
- * @param ctx: the request context
+ * setAccountDisabled disables or re-enables the account in the request path
  * @param w: the response writer
+ * @param r: the request
+ * @param disabled: whether to disable the account
  */
-func getAllUsers(ctx context.Context, w http.ResponseWriter) {
-	users, err := userService.GetUsers(ctx)
+func setAccountDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve users")
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
 
-	log.Printf("Retrieved %d users", len(users))
-	utils.RespondWithJSON(w, http.StatusOK, users)
+	principal, _ := auth.PrincipalFromContext(ctx)
+	if !auth.Can(principal, auth.ActionDisableAccount, auth.Resource{OwnerID: userId}) {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have permission to disable accounts")
+		return
+	}
+
+	if userId == principal.UserID {
+		utils.RespondWithError(w, http.StatusBadRequest, "You can't disable your own account")
+		return
+	}
+
+	if _, err := userService.GetUserById(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := userService.SetUserDisabled(ctx, userId, disabled); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update account")
+		return
+	}
+
+	if !disabled {
+		log.Printf("User ID %d enabled account of user ID %d", principal.UserID, userId)
+		utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Account enabled successfully"})
+		return
+	}
+
+	// Sign the user out everywhere, otherwise their access tokens stay valid until they expire
+	if err := refreshTokenService.RevokeAllRefreshTokens(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke account tokens")
+		return
+	}
+	if err := tokenService.RevokeAllTokens(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke account tokens")
+		return
+	}
+
+	log.Printf("User ID %d disabled account of user ID %d", principal.UserID, userId)
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Account disabled successfully"})
+}
+
+// @Summary Delete an account
+// @Description Permanently delete an account along with its learning items, categories, tags and tokens, straight away even if it is scheduled for deletion. Requires the admin role.
+// @Tags Admin
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "ID of the user"
+// @Success 204 "Account deleted"
+// @Failure 400 {object} utils.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized"
+// @Failure 403 {object} utils.ErrorResponse "Forbidden"
+// @Failure 404 {object} utils.ErrorResponse "User not found"
+// @Failure 500 {object} utils.ErrorResponse "Server error"
+// @Router /admin/users/{id} [delete]
+func deleteAccount(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+	if !auth.Can(principal, auth.ActionDeleteAccount, auth.Resource{OwnerID: userId}) {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have permission to delete accounts")
+		return
+	}
+
+	if userId == principal.UserID {
+		utils.RespondWithError(w, http.StatusBadRequest, "You can't delete your own account")
+		return
+	}
+
+	if _, err := userService.GetUserById(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := userService.DeleteUser(ctx, userId); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+
+	log.Printf("User ID %d deleted account of user ID %d", principal.UserID, userId)
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // InitUserRest initializes the user REST endpoints
-func InitUserRest(_userService UserService, _tokenService auth.TokenService) {
+func InitUserRest(_userService UserService, _tokenService auth.TokenService, _refreshTokenService auth.RefreshTokenService,
+	_personalAccessTokenService auth.PersonalAccessTokenService) {
 	userService = _userService
 	tokenService = _tokenService
+	refreshTokenService = _refreshTokenService
+	personalAccessTokenService = _personalAccessTokenService
 
-	http.HandleFunc("POST /user", createUser)
-	http.HandleFunc("GET /user", getUsers)
-	http.HandleFunc("POST /login", handleLogin)
+	auth.RegisterRoutes(tokenService, []auth.Route{
+		{Pattern: "POST /user", Access: auth.Public, Handler: createUser},
+		{Pattern: "GET /user", Access: auth.OptionalAuthentication, Scope: auth.ScopeReadUsers, Handler: getUsers},
+		{Pattern: "POST /login", Access: auth.Public, Handler: handleLogin},
+		{Pattern: "POST /token/refresh", Access: auth.Public, Handler: refreshToken},
+		{Pattern: "POST /logout", Access: auth.Authenticated, AllowUnverified: true, Handler: logout},
+		{Pattern: "POST /logout/all", Access: auth.Authenticated, AllowUnverified: true, Handler: logoutAll},
+		{Pattern: "PATCH /user/me", Access: auth.Authenticated, Handler: updateProfile},
+		{Pattern: "GET /user/me/privacy", Access: auth.Authenticated, Handler: getPrivacySettings},
+		{Pattern: "PATCH /user/me/privacy", Access: auth.Authenticated, Handler: updatePrivacySettings},
+		{Pattern: "POST /user/me/password", Access: auth.Authenticated, AllowUnverified: true, Handler: changePassword},
+		{Pattern: "POST /user/tokens", Access: auth.Authenticated, Handler: createPersonalAccessToken},
+		{Pattern: "GET /user/tokens", Access: auth.Authenticated, Handler: getPersonalAccessTokens},
+		{Pattern: "DELETE /user/tokens/{id}", Access: auth.Authenticated, Handler: revokePersonalAccessToken},
+		{Pattern: "GET /admin/users", Access: auth.Authenticated, Handler: getAccounts},
+		{Pattern: "POST /admin/users/{id}/disable", Access: auth.Authenticated, Handler: disableAccount},
+		{Pattern: "POST /admin/users/{id}/enable", Access: auth.Authenticated, Handler: enableAccount},
+		{Pattern: "DELETE /admin/users/{id}", Access: auth.Authenticated, Handler: deleteAccount},
+	})
 
 	log.Println("User REST endpoints initialized")
 }
@@ -2,35 +2,93 @@ package user
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
 
+	"software-slayer/auth"
 	"software-slayer/db"
+	"software-slayer/export"
+	"software-slayer/utils"
 )
 
 type UserService interface {
-	CreateUser(ctx context.Context, user *CreateUserRequest, passwordHash string) error
-	GetUsers(ctx context.Context) ([]GetUserResponse, error)
+	CreateUser(ctx context.Context, user *CreateUserRequest, passwordHash string) (int, error)
+	GetUsers(ctx context.Context, filter UserFilter, page utils.PageRequest) (utils.Page[GetUserResponse], error)
 	GetUserByIdentifier(ctx context.Context, identifier string) (UserDB, error)
 	GetUserById(ctx context.Context, id int) (UserDB, error)
+	UpdatePassword(ctx context.Context, id int, passwordHash string) error
+	UpdateProfile(ctx context.Context, id int, profile UserBase) error
+	RequestEmailChange(ctx context.Context, id int, email string) error
+	ConfirmEmailChange(ctx context.Context, id int, email string) (bool, error)
+	MarkEmailVerified(ctx context.Context, id int, email string) error
+	ClaimVerificationEmail(ctx context.Context, id int, interval time.Duration) (bool, error)
+	GetAccounts(ctx context.Context, page utils.PageRequest) (utils.Page[AccountResponse], error)
+	SetUserDisabled(ctx context.Context, id int, disabled bool) error
+	DeleteUser(ctx context.Context, id int) error
+	ScheduleDeletion(ctx context.Context, id int, deleteAfter time.Time) error
+	CancelDeletion(ctx context.Context, id int) (bool, error)
+	GetUsersDueForDeletion(ctx context.Context, now time.Time) ([]int, error)
+	DeleteUserIfDue(ctx context.Context, id int, now time.Time) (bool, error)
+	GetPrivacySettings(ctx context.Context, id int) (PrivacySettings, error)
+	UpdatePrivacySettings(ctx context.Context, id int, settings PrivacySettings) error
 }
 
+// UserServiceImpl stores users in the database, their data exports are deleted from exports along with them
 type UserServiceImpl struct {
-	db *db.Database
+	db      *db.Database
+	exports export.Store
 }
 
-func NewUserService(db *db.Database) *UserServiceImpl {
-	return &UserServiceImpl{db: db}
+func NewUserService(db *db.Database, exports export.Store) *UserServiceImpl {
+	return &UserServiceImpl{db: db, exports: exports}
 }
 
-func (s *UserServiceImpl) CreateUser(ctx context.Context, user *CreateUserRequest, passwordHash string) error {
-	_, err := s.db.ExecContext(ctx, "INSERT INTO users (email, username, password_hash, first_name, last_name) VALUES (?, ?, ?, ?, ?)",
+func (s *UserServiceImpl) CreateUser(ctx context.Context, user *CreateUserRequest, passwordHash string) (int, error) {
+	result, err := s.db.ExecContext(ctx, "INSERT INTO users (email, username, password_hash, first_name, last_name) VALUES (?, ?, ?, ?, ?)",
 		user.Email, user.Username, passwordHash, user.FirstName, user.LastName)
-	return err
+	if err != nil {
+		return -1, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+	return int(id), nil
 }
 
-func (s *UserServiceImpl) GetUsers(ctx context.Context) ([]GetUserResponse, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT id, username, first_name, last_name FROM users")
+func (s *UserServiceImpl) GetUsers(ctx context.Context, filter UserFilter, page utils.PageRequest) (utils.Page[GetUserResponse], error) {
+	conditions := []string{}
+	args := []any{}
+	if filter.Visibilities != nil {
+		// The viewer always sees their own profile
+		conditions = append(conditions, "(profile_visibility IN (?"+strings.Repeat(", ?", len(filter.Visibilities)-1)+") OR id = ?)")
+		for _, visibility := range filter.Visibilities {
+			args = append(args, visibility)
+		}
+		args = append(args, filter.ViewerID)
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+whereClause(conditions), args...).Scan(&total); err != nil {
+		return utils.Page[GetUserResponse]{}, err
+	}
+
+	query := "SELECT id, username, first_name, last_name FROM users"
+	if keyset, keysetArgs := page.KeysetClause("id"); keyset != "" {
+		conditions = append(conditions, keyset)
+		args = append(args, keysetArgs...)
+	}
+	order, orderArgs := page.OrderClause("id")
+	query += whereClause(conditions) + order
+	args = append(args, orderArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return utils.Page[GetUserResponse]{}, err
 	}
 	defer rows.Close()
 
@@ -39,23 +97,339 @@ func (s *UserServiceImpl) GetUsers(ctx context.Context) ([]GetUserResponse, erro
 		var user GetUserResponse
 		err := rows.Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName)
 		if err != nil {
-			return nil, err
+			return utils.Page[GetUserResponse]{}, err
 		}
 		users = append(users, user)
 	}
-	return users, nil
+
+	return utils.NewPage(users, total, page, func(user GetUserResponse) (string, int) {
+		return userCursor(user, page.Sort)
+	}), nil
 }
 
 func (s *UserServiceImpl) GetUserByIdentifier(ctx context.Context, identifier string) (UserDB, error) {
-	var user UserDB
-	err := s.db.QueryRowContext(ctx, "SELECT id, username, email, password_hash, first_name, last_name FROM users WHERE email = ? OR username = ?",
-		identifier, identifier).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName)
-	return user, err
+	return scanUser(s.db.QueryRowContext(ctx, "SELECT id, username, email, password_hash, first_name, last_name, role, disabled_at IS NOT NULL, email_verified_at IS NOT NULL FROM users WHERE email = ? OR username = ?",
+		identifier, identifier))
 }
 
 func (s *UserServiceImpl) GetUserById(ctx context.Context, id int) (UserDB, error) {
+	return scanUser(s.db.QueryRowContext(ctx, "SELECT id, username, email, password_hash, first_name, last_name, role, disabled_at IS NOT NULL, email_verified_at IS NOT NULL FROM users WHERE id = ?",
+		id))
+}
+
+func (s *UserServiceImpl) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET password_hash = ? WHERE id = ?", passwordHash, id)
+	return err
+}
+
+/*
+ * Update a user's username and name
+ * @param ctx: the context
+ * @param id: the id of the user
+ * @param profile: the new profile
+ * @return error: an error if the user could not be updated, including a duplicate entry error if the username is taken
+ */
+func (s *UserServiceImpl) UpdateProfile(ctx context.Context, id int, profile UserBase) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET username = ?, first_name = ?, last_name = ? WHERE id = ?",
+		profile.Username, profile.FirstName, profile.LastName, id)
+	return err
+}
+
+/*
+ * Record the email a user wants to change to, the email only changes once the new address is verified
+ * A later request replaces an earlier one, so only the latest verification link works
+ * @param ctx: the context
+ * @param id: the id of the user
+ * @param email: the new email
+ * @return error: an error if the user could not be updated
+ */
+func (s *UserServiceImpl) RequestEmailChange(ctx context.Context, id int, email string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET pending_email = ? WHERE id = ?", email, id)
+	return err
+}
+
+/*
+ * Change a user's email to the address they verified, if it is still the email they asked to change to
+ * @param ctx: the context
+ * @param id: the id of the user
+ * @param email: the email that was verified
+ * @return bool: whether the email was changed, false if the user has not asked to change to it
+ * @return error: an error if the user could not be updated, including a duplicate entry error if the email was taken since
+ */
+func (s *UserServiceImpl) ConfirmEmailChange(ctx context.Context, id int, email string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET email = pending_email, pending_email = NULL, email_verified_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND pending_email = ?`, id, email)
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return updated == 1, nil
+}
+
+/*
+ * Mark a user's email as verified, if it is still the email the verification was sent to
+ * @param ctx: the context
+ * @param id: the id of the user
+ * @param email: the email that was verified
+ * @return error: an error if the user could not be updated
+ */
+func (s *UserServiceImpl) MarkEmailVerified(ctx context.Context, id int, email string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP) WHERE id = ? AND email = ?",
+		id, email)
+	return err
+}
+
+/*
+ * Record that a verification email is being sent, unless one was sent within the interval
+ * The check and update are a single statement, so concurrent requests can't both send an email
+ * @param ctx: the context
+ * @param id: the id of the user
+ * @param interval: how long to wait between verification emails
+ * @return bool: whether the email may be sent, false if the user is verified or was sent an email too recently
+ * @return error: an error if the user could not be updated
+ */
+func (s *UserServiceImpl) ClaimVerificationEmail(ctx context.Context, id int, interval time.Duration) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET verification_sent_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND email_verified_at IS NULL AND (verification_sent_at IS NULL OR verification_sent_at < ?)`,
+		id, time.Now().Add(-interval).UTC())
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return updated == 1, nil
+}
+
+func (s *UserServiceImpl) GetAccounts(ctx context.Context, page utils.PageRequest) (utils.Page[AccountResponse], error) {
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&total); err != nil {
+		return utils.Page[AccountResponse]{}, err
+	}
+
+	query := "SELECT id, username, email, first_name, last_name, role, disabled_at IS NOT NULL, email_verified_at IS NOT NULL FROM users"
+	keyset, args := page.KeysetClause("id")
+	if keyset != "" {
+		query += " WHERE " + keyset
+	}
+	order, orderArgs := page.OrderClause("id")
+	query += order
+	args = append(args, orderArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return utils.Page[AccountResponse]{}, err
+	}
+	defer rows.Close()
+
+	accounts := make([]AccountResponse, 0)
+	for rows.Next() {
+		var account AccountResponse
+		var role string
+		err := rows.Scan(&account.ID, &account.Username, &account.Email, &account.FirstName, &account.LastName, &role, &account.Disabled, &account.EmailVerified)
+		if err != nil {
+			return utils.Page[AccountResponse]{}, err
+		}
+		account.Role = auth.ParseRole(role)
+		accounts = append(accounts, account)
+	}
+
+	return utils.NewPage(accounts, total, page, func(account AccountResponse) (string, int) {
+		return userCursor(account.GetUserResponse, page.Sort)
+	}), nil
+}
+
+/*
+ * Disable or re-enable a user's account, disabled users can't log in
+ * @param ctx: the context
+ * @param id: the id of the user
+ * @param disabled: whether the account is disabled
+ * @return error: an error if the account could not be updated
+ */
+func (s *UserServiceImpl) SetUserDisabled(ctx context.Context, id int, disabled bool) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET disabled_at = IF(?, COALESCE(disabled_at, CURRENT_TIMESTAMP), NULL) WHERE id = ?",
+		disabled, id)
+	return err
+}
+
+/*
+ * Delete a user and everything they own in a single transaction, then their data exports
+ * Tokens and revocations are deleted by their ON DELETE CASCADE foreign keys
+ * @param ctx: the context
+ * @param id: the id of the user
+ * @return error: an error if the user could not be deleted
+ */
+func (s *UserServiceImpl) DeleteUser(ctx context.Context, id int) error {
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		return deleteUser(ctx, tx, id)
+	})
+	if err != nil {
+		return err
+	}
+	s.deleteExports(ctx, id)
+	return nil
+}
+
+/*
+ * Schedule a user's account for deletion, replacing any earlier schedule
+ * @param ctx: the context
+ * @param id: the id of the user
+ * @param deleteAfter: when the account may be deleted
+ * @return error: an error if the user could not be updated
+ */
+func (s *UserServiceImpl) ScheduleDeletion(ctx context.Context, id int, deleteAfter time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET delete_after = ? WHERE id = ?", deleteAfter.UTC(), id)
+	return err
+}
+
+/*
+ * Cancel the scheduled deletion of a user's account
+ * @param ctx: the context
+ * @param id: the id of the user
+ * @return bool: whether a deletion was cancelled, false if none was scheduled
+ * @return error: an error if the user could not be updated
+ */
+func (s *UserServiceImpl) CancelDeletion(ctx context.Context, id int) (bool, error) {
+	result, err := s.db.ExecContext(ctx, "UPDATE users SET delete_after = NULL WHERE id = ? AND delete_after IS NOT NULL", id)
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return updated == 1, nil
+}
+
+/*
+ * Get the users whose grace period has ended
+ * @param ctx: the context
+ * @param now: the current time
+ * @return []int: the ids of the users to delete
+ * @return error: an error if the users could not be retrieved
+ */
+func (s *UserServiceImpl) GetUsersDueForDeletion(ctx context.Context, now time.Time) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id FROM users WHERE delete_after <= ?", now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+/*
+ * Delete a user and everything they own in a single transaction, if their deletion is still due
+ * The schedule is checked in the transaction, so a login that cancels the deletion can't race with it
+ * @param ctx: the context
+ * @param id: the id of the user
+ * @param now: the current time
+ * @return bool: whether the user was deleted, false if the deletion was cancelled
+ * @return error: an error if the user could not be deleted
+ */
+func (s *UserServiceImpl) DeleteUserIfDue(ctx context.Context, id int, now time.Time) (bool, error) {
+	deleted := false
+	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
+		var due bool
+		err := tx.QueryRowContext(ctx, "SELECT delete_after <= ? FROM users WHERE id = ? AND delete_after IS NOT NULL FOR UPDATE",
+			now.UTC(), id).Scan(&due)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !due) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		deleted = true
+		return deleteUser(ctx, tx, id)
+	})
+	if err != nil {
+		return false, err
+	}
+	if deleted {
+		s.deleteExports(ctx, id)
+	}
+	return deleted, nil
+}
+
+// deleteUser deletes a user and everything they own in a transaction
+func deleteUser(ctx context.Context, tx *sql.Tx, id int) error {
+	// Learning items reference the user's categories, and learning_tags rows cascade from both
+	for _, query := range []string{
+		"DELETE FROM user_learning_list WHERE user_id = ?",
+		"DELETE FROM tags WHERE user_id = ?",
+		"DELETE FROM categories WHERE user_id = ?",
+		"DELETE FROM users WHERE id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteExports deletes a deleted user's data exports, which are pruned with the others if they can't be
+func (s *UserServiceImpl) deleteExports(ctx context.Context, id int) {
+	if err := s.exports.DeleteJobsByUserId(ctx, id); err != nil {
+		log.Printf("Failed to delete data exports of deleted user ID %d: %v", id, err)
+	}
+}
+
+/*
+ * Get who may see a user's profile and learning list
+ * @param ctx: the context
+ * @param id: the id of the user
+ * @return PrivacySettings: the user's privacy settings
+ * @return error: an error if the user could not be retrieved
+ */
+func (s *UserServiceImpl) GetPrivacySettings(ctx context.Context, id int) (PrivacySettings, error) {
+	var settings PrivacySettings
+	err := s.db.QueryRowContext(ctx, "SELECT profile_visibility, learnings_visibility FROM users WHERE id = ?", id).
+		Scan(&settings.ProfileVisibility, &settings.LearningsVisibility)
+	return settings, err
+}
+
+/*
+ * Set who may see a user's profile and learning list
+ * @param ctx: the context
+ * @param id: the id of the user
+ * @param settings: the new privacy settings
+ * @return error: an error if the user could not be updated
+ */
+func (s *UserServiceImpl) UpdatePrivacySettings(ctx context.Context, id int, settings PrivacySettings) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET profile_visibility = ?, learnings_visibility = ? WHERE id = ?",
+		settings.ProfileVisibility, settings.LearningsVisibility, id)
+	return err
+}
+
+// whereClause joins conditions into a WHERE clause, which is empty without conditions
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// scanUser scans a row selected by GetUserByIdentifier or GetUserById
+func scanUser(row *sql.Row) (UserDB, error) {
 	var user UserDB
-	err := s.db.QueryRowContext(ctx, "SELECT id, username, email, password_hash, first_name, last_name FROM users WHERE id = ?",
-		id).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName)
+	var role string
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &role, &user.Disabled, &user.EmailVerified)
+	user.Role = auth.ParseRole(role)
 	return user, err
 }
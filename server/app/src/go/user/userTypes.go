@@ -2,13 +2,29 @@ package user
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
+	"strconv"
+	"time"
+
+	"software-slayer/auth"
 )
 
 var usernameValidator = regexp.MustCompile(`^[a-zA-Z0-9_ -]{1,30}$`)
 var emailValidator = regexp.MustCompile(`^[^@]+@[^@]+\.[^@]{2,}$`)
-var passwordValidator = regexp.MustCompile(`^.{8,64}$`)
 var nameValidator = regexp.MustCompile(`^[a-zA-Z -]{1,80}$`)
+var tokenNameValidator = regexp.MustCompile(`^[a-zA-Z0-9 _.-]{1,100}$`)
+
+// maxTokenLifetimeDays is the longest a personal access token can be created for, tokens without an expiry never expire
+const maxTokenLifetimeDays = 365
+
+// userSortColumns maps the sort parameter accepted by GET /user to the column it sorts by
+var userSortColumns = map[string]string{
+	"id":         "id",
+	"username":   "username",
+	"first_name": "first_name",
+	"last_name":  "last_name",
+}
 
 type UserBase struct {
 	Username  string `json:"username"`
@@ -20,6 +36,10 @@ type UserDB struct {
 	ID           int
 	Email        string
 	PasswordHash string
+	Role         auth.Role
+	Disabled     bool
+	// EmailVerified is whether the user has opened the link in their verification email
+	EmailVerified bool
 	UserBase
 }
 
@@ -28,11 +48,38 @@ type GetUserResponse struct {
 	UserBase
 }
 
+// UserFilter restricts the users listed to those a viewer may see
+type UserFilter struct {
+	ViewerID int
+	// Visibilities are the profile visibilities the viewer may see, nil for all of them
+	Visibilities []auth.Visibility
+}
+
+// PrivacySettings are who may see a user's profile and learning list
+type PrivacySettings struct {
+	ProfileVisibility   auth.Visibility `json:"profile_visibility"`
+	LearningsVisibility auth.Visibility `json:"learnings_visibility"`
+}
+
+// UpdatePrivacyRequest changes the privacy settings that are set, to public, organization or private
+type UpdatePrivacyRequest struct {
+	ProfileVisibility   *string `json:"profile_visibility,omitempty"`
+	LearningsVisibility *string `json:"learnings_visibility,omitempty"`
+}
+
 type GetCurrentUserResponse struct {
-	Email string `json:"email"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Role          auth.Role `json:"role"`
 	GetUserResponse
 }
 
+// AccountResponse is a user as seen by admins
+type AccountResponse struct {
+	Disabled bool `json:"disabled"`
+	GetCurrentUserResponse
+}
+
 type CreateUserRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -45,8 +92,89 @@ type Credentials struct {
 }
 
 type LoginResponse struct {
-	Token    string                 `json:"token"`
-	UserInfo GetCurrentUserResponse `json:"user_info"`
+	Token        string                 `json:"token"`
+	RefreshToken string                 `json:"refresh_token"`
+	UserInfo     GetCurrentUserResponse `json:"user_info"`
+}
+
+// TwoFactorChallengeResponse is returned by login instead of tokens when the user has enabled two-factor authentication
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	// Code is a code from the user's authenticator app, or one of their recovery codes
+	Code string `json:"code"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type CreatePersonalAccessTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresInDays is how long the token is valid for, it never expires if omitted
+	ExpiresInDays *int `json:"expires_in_days,omitempty"`
+}
+
+type CreatePersonalAccessTokenResponse struct {
+	// Token is only returned when the token is created
+	Token string `json:"token"`
+	auth.PersonalAccessToken
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// UpdateProfileRequest changes the fields of the current user's profile that are set
+type UpdateProfileRequest struct {
+	Username  *string `json:"username,omitempty"`
+	FirstName *string `json:"first_name,omitempty"`
+	LastName  *string `json:"last_name,omitempty"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ChangeEmailRequest struct {
+	Email string `json:"email"`
+	// Password is the current password, so a stolen session can't take over the account by changing its email
+	Password string `json:"password"`
+}
+
+type DeleteAccountRequest struct {
+	// Password is the current password, so a stolen session can't delete the account
+	Password string `json:"password"`
+}
+
+// DeleteAccountResponse tells the user when their account will be deleted, logging in before then cancels the deletion
+type DeleteAccountResponse struct {
+	Message     string    `json:"message"`
+	DeleteAfter time.Time `json:"delete_after"`
+}
+
+type RefreshTokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 /*
@@ -55,14 +183,27 @@ type LoginResponse struct {
  * @return error: an error if the CreateUserRequest is invalid
  */
 func validateCreateUserRequest(user CreateUserRequest) (error) {
-	if ok := usernameValidator.MatchString(user.Username); !ok {
-		return errors.New("username")
+	if err := validateUserBase(user.UserBase); err != nil {
+		return err
 	}
 	if ok := emailValidator.MatchString(user.Email); !ok {
 		return errors.New("email")
 	}
-	if ok := passwordValidator.MatchString(user.Password); !ok {
-		return errors.New("password")
+	if err := auth.CheckPassword(user.Password, user.Username, user.Email); err != nil {
+		return fmt.Errorf("password: %w", err)
+	}
+
+	return nil
+}
+
+/*
+ * Validate the profile fields shared by new and updated users
+ * @param user: the UserBase to validate
+ * @return error: an error naming the first invalid field
+ */
+func validateUserBase(user UserBase) error {
+	if ok := usernameValidator.MatchString(user.Username); !ok {
+		return errors.New("username")
 	}
 	if ok := nameValidator.MatchString(user.FirstName); !ok {
 		return errors.New("first_name")
@@ -73,3 +214,96 @@ func validateCreateUserRequest(user CreateUserRequest) (error) {
 
 	return nil
 }
+
+/*
+ * Apply a profile update to a user's current profile, fields left out of the update are kept
+ * @param profile: the current profile
+ * @param update: the UpdateProfileRequest
+ * @return UserBase: the updated profile
+ */
+func applyProfileUpdate(profile UserBase, update UpdateProfileRequest) UserBase {
+	if update.Username != nil {
+		profile.Username = *update.Username
+	}
+	if update.FirstName != nil {
+		profile.FirstName = *update.FirstName
+	}
+	if update.LastName != nil {
+		profile.LastName = *update.LastName
+	}
+	return profile
+}
+
+/*
+ * Apply a privacy update to a user's current settings, settings left out of the update are kept
+ * @param settings: the current privacy settings
+ * @param update: the UpdatePrivacyRequest
+ * @return PrivacySettings: the updated privacy settings
+ * @return error: an error naming the invalid setting
+ */
+func applyPrivacyUpdate(settings PrivacySettings, update UpdatePrivacyRequest) (PrivacySettings, error) {
+	if update.ProfileVisibility == nil && update.LearningsVisibility == nil {
+		return settings, errors.New("request: no settings to update")
+	}
+	if update.ProfileVisibility != nil {
+		visibility, err := auth.ParseVisibility(*update.ProfileVisibility)
+		if err != nil {
+			return settings, errors.New("profile_visibility")
+		}
+		settings.ProfileVisibility = visibility
+	}
+	if update.LearningsVisibility != nil {
+		visibility, err := auth.ParseVisibility(*update.LearningsVisibility)
+		if err != nil {
+			return settings, errors.New("learnings_visibility")
+		}
+		settings.LearningsVisibility = visibility
+	}
+	return settings, nil
+}
+
+/*
+ * Validate the CreatePersonalAccessTokenRequest
+ * @param request: the CreatePersonalAccessTokenRequest to validate
+ * @return error: an error if the CreatePersonalAccessTokenRequest is invalid
+ */
+func validateCreatePersonalAccessTokenRequest(request CreatePersonalAccessTokenRequest) error {
+	if ok := tokenNameValidator.MatchString(request.Name); !ok {
+		return errors.New("name")
+	}
+	if len(request.Scopes) == 0 {
+		return errors.New("scopes")
+	}
+	seen := make(map[string]bool)
+	for _, scope := range request.Scopes {
+		if !auth.IsScope(scope) || seen[scope] {
+			return errors.New("scopes")
+		}
+		seen[scope] = true
+	}
+	if request.ExpiresInDays != nil && (*request.ExpiresInDays < 1 || *request.ExpiresInDays > maxTokenLifetimeDays) {
+		return errors.New("expires_in_days")
+	}
+
+	return nil
+}
+
+/*
+ * Get the cursor position of a user in a list sorted by the given sort parameter
+ * @param user: the user
+ * @param sort: a key of userSortColumns
+ * @return string: the user's value for the sort
+ * @return int: the user's ID
+ */
+func userCursor(user GetUserResponse, sort string) (string, int) {
+	switch sort {
+	case "username":
+		return user.Username, user.ID
+	case "first_name":
+		return user.FirstName, user.ID
+	case "last_name":
+		return user.LastName, user.ID
+	default:
+		return strconv.Itoa(user.ID), user.ID
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 )
 
@@ -66,3 +67,17 @@ func RespondWithJSON(w http.ResponseWriter, status int, payload interface{}) {
 		}
 	}
 }
+
+/*
+ * ClientIP returns the address of the client that sent a request
+ * Forwarding headers are ignored, they can be set to anything by the client unless a trusted proxy overwrites them
+ * @param r: the request
+ * @return string: the client IP address
+ */
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
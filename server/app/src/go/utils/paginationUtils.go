@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// Page is the response envelope for paginated list endpoints
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+// Cursor marks the last row of a page. It is sent to clients as an opaque string.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// PageRequest describes which page of a keyset-paginated list to fetch
+type PageRequest struct {
+	Limit  int
+	Sort   string
+	Column string
+	Desc   bool
+	After  *Cursor
+}
+
+/*
+ * ParsePageRequest reads the cursor, limit, sort and order query parameters
+ * @param r: the request
+ * @param sortColumns: the allowed sort parameter values mapped to the SQL expressions they sort by
+ * @param defaultSort: the sort to use when none is given
+ * @return PageRequest: the parsed page request
+ * @return error: an error naming the invalid parameter
+ */
+func ParsePageRequest(r *http.Request, sortColumns map[string]string, defaultSort string) (PageRequest, error) {
+	query := r.URL.Query()
+	page := PageRequest{Limit: DefaultPageLimit, Sort: defaultSort}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		page.Limit, err = strconv.Atoi(limit)
+		if err != nil || page.Limit < 1 || page.Limit > MaxPageLimit {
+			return page, errors.New("limit")
+		}
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		page.Sort = sort
+	}
+	column, ok := sortColumns[page.Sort]
+	if !ok {
+		return page, errors.New("sort")
+	}
+	page.Column = column
+
+	switch query.Get("order") {
+	case "", "asc":
+		page.Desc = false
+	case "desc":
+		page.Desc = true
+	default:
+		return page, errors.New("order")
+	}
+
+	if encoded := query.Get("cursor"); encoded != "" {
+		cursor, err := decodeCursor(encoded)
+		// A cursor is only meaningful for the ordering it was issued for
+		if err != nil || cursor.Sort != page.Sort || cursor.Desc != page.Desc {
+			return page, errors.New("cursor")
+		}
+		page.After = &cursor
+	}
+
+	return page, nil
+}
+
+/*
+ * NextPage builds the request for the page after the one a cursor was returned with, to walk a whole list
+ * @param nextCursor: the NextCursor of the current page
+ * @return PageRequest: the request for the next page
+ * @return error: an error if the cursor is invalid or was issued for another ordering
+ */
+func (p PageRequest) NextPage(nextCursor string) (PageRequest, error) {
+	cursor, err := decodeCursor(nextCursor)
+	if err != nil || cursor.Sort != p.Sort || cursor.Desc != p.Desc {
+		return p, errors.New("cursor")
+	}
+	p.After = &cursor
+	return p, nil
+}
+
+/*
+ * KeysetClause builds the condition that skips every row up to and including the cursor
+ * @param idColumn: the unique column used to break ties between equal sort values
+ * @return string: the condition, or an empty string on the first page
+ * @return []any: the arguments for the condition
+ */
+func (p PageRequest) KeysetClause(idColumn string) (string, []any) {
+	if p.After == nil {
+		return "", nil
+	}
+
+	comparison := ">"
+	if p.Desc {
+		comparison = "<"
+	}
+	clause := fmt.Sprintf("(%[1]s %[3]s ? OR (%[1]s = ? AND %[2]s %[3]s ?))", p.Column, idColumn, comparison)
+	return clause, []any{p.After.Value, p.After.Value, p.After.ID}
+}
+
+/*
+ * OrderClause builds the ORDER BY and LIMIT for the page
+ * One more row than the limit is requested so that NewPage can tell whether another page exists
+ * @param idColumn: the unique column used to break ties between equal sort values
+ * @return string: the ORDER BY and LIMIT clauses
+ * @return []any: the arguments for the clauses
+ */
+func (p PageRequest) OrderClause(idColumn string) (string, []any) {
+	direction := "ASC"
+	if p.Desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %[1]s %[3]s, %[2]s %[3]s LIMIT ?", p.Column, idColumn, direction), []any{p.Limit + 1}
+}
+
+/*
+ * NewPage builds the response envelope from rows fetched with OrderClause
+ * @param items: the fetched rows, possibly including one row beyond the limit
+ * @param total: the number of rows across all pages
+ * @param p: the page request the rows were fetched for
+ * @param cursorFor: returns the sort value and ID of a row
+ * @return Page[T]: the page of items with the cursor for the next page, if any
+ */
+func NewPage[T any](items []T, total int, p PageRequest, cursorFor func(T) (string, int)) Page[T] {
+	page := Page[T]{Items: items, Total: total}
+	if len(items) > p.Limit {
+		page.Items = items[:p.Limit]
+		value, id := cursorFor(page.Items[p.Limit-1])
+		page.NextCursor = encodeCursor(Cursor{Sort: p.Sort, Desc: p.Desc, Value: value, ID: id})
+	}
+	return page
+}
+
+func encodeCursor(cursor Cursor) string {
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeCursor(encoded string) (Cursor, error) {
+	var cursor Cursor
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(decoded, &cursor)
+	return cursor, err
+}
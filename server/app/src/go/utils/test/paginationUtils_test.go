@@ -0,0 +1,122 @@
+package utils_test
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"software-slayer/utils"
+)
+
+var sortColumns = map[string]string{
+	"id":    "id",
+	"title": "title",
+}
+
+func parse(t *testing.T, query url.Values) (utils.PageRequest, error) {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/items?"+query.Encode(), nil)
+	return utils.ParsePageRequest(r, sortColumns, "id")
+}
+
+func TestParsePageRequest_Defaults(t *testing.T) {
+	page, err := parse(t, url.Values{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, utils.DefaultPageLimit, page.Limit)
+	assert.Equal(t, "id", page.Sort)
+	assert.Equal(t, "id", page.Column)
+	assert.False(t, page.Desc)
+	assert.Nil(t, page.After)
+}
+
+func TestParsePageRequest_InvalidParameters(t *testing.T) {
+	tests := map[string]url.Values{
+		"limit":  {"limit": {"abc"}},
+		"sort":   {"sort": {"password"}},
+		"order":  {"order": {"sideways"}},
+		"cursor": {"cursor": {"not-a-cursor"}},
+	}
+
+	for expected, query := range tests {
+		_, err := parse(t, query)
+		assert.EqualError(t, err, expected)
+	}
+
+	_, err := parse(t, url.Values{"limit": {"101"}})
+	assert.EqualError(t, err, "limit")
+}
+
+func TestNewPage_CursorRoundTrip(t *testing.T) {
+	page, err := parse(t, url.Values{"limit": {"2"}, "sort": {"title"}, "order": {"desc"}})
+	assert.NoError(t, err)
+
+	items := []string{"c", "b", "a"}
+	result := utils.NewPage(items, 5, page, func(item string) (string, int) {
+		return item, len(item)
+	})
+
+	assert.Equal(t, []string{"c", "b"}, result.Items)
+	assert.Equal(t, 5, result.Total)
+	assert.NotEmpty(t, result.NextCursor)
+
+	next, err := parse(t, url.Values{"limit": {"2"}, "sort": {"title"}, "order": {"desc"}, "cursor": {result.NextCursor}})
+	assert.NoError(t, err)
+	assert.Equal(t, &utils.Cursor{Sort: "title", Desc: true, Value: "b", ID: 1}, next.After)
+
+	clause, args := next.KeysetClause("id")
+	assert.Equal(t, "(title < ? OR (title = ? AND id < ?))", clause)
+	assert.Equal(t, []any{"b", "b", 1}, args)
+
+	order, orderArgs := next.OrderClause("id")
+	assert.Equal(t, " ORDER BY title DESC, id DESC LIMIT ?", order)
+	assert.Equal(t, []any{3}, orderArgs)
+}
+
+func TestNewPage_LastPage(t *testing.T) {
+	page, err := parse(t, url.Values{"limit": {"3"}})
+	assert.NoError(t, err)
+
+	result := utils.NewPage([]string{"a", "b"}, 2, page, func(item string) (string, int) {
+		return item, 0
+	})
+
+	assert.Equal(t, []string{"a", "b"}, result.Items)
+	assert.Empty(t, result.NextCursor)
+
+	clause, args := page.KeysetClause("id")
+	assert.Empty(t, clause)
+	assert.Nil(t, args)
+}
+
+func TestParsePageRequest_CursorForDifferentSort(t *testing.T) {
+	page, err := parse(t, url.Values{"limit": {"1"}, "sort": {"title"}})
+	assert.NoError(t, err)
+
+	result := utils.NewPage([]string{"a", "b"}, 2, page, func(item string) (string, int) {
+		return item, 0
+	})
+
+	// A cursor issued for one ordering is rejected for another
+	_, err = parse(t, url.Values{"sort": {"id"}, "cursor": {result.NextCursor}})
+	assert.EqualError(t, err, "cursor")
+}
+
+func TestPageRequest_NextPage(t *testing.T) {
+	page, err := parse(t, url.Values{"limit": {"1"}, "sort": {"title"}})
+	assert.NoError(t, err)
+
+	result := utils.NewPage([]string{"a", "b"}, 2, page, func(item string) (string, int) {
+		return item, 4
+	})
+
+	next, err := page.NextPage(result.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, &utils.Cursor{Sort: "title", Value: "a", ID: 4}, next.After)
+	assert.Equal(t, page.Column, next.Column)
+
+	_, err = page.NextPage("not-a-cursor")
+	assert.EqualError(t, err, "cursor")
+}
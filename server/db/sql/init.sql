@@ -4,14 +4,157 @@ CREATE TABLE users (
   email VARCHAR(255) NOT NULL UNIQUE CHECK (`email` regexp '^[^@]+@[^@]+\.[^@]{2,}$'),
   password_hash VARCHAR(255) NOT NULL,
   first_name VARCHAR(255) NOT NULL CHECK (`first_name` regexp '^[a-zA-Z -]{1,80}$'),
-  last_name VARCHAR(255) NOT NULL CHECK (`last_name` regexp '^[a-zA-Z -]{1,80}$')
+  last_name VARCHAR(255) NOT NULL CHECK (`last_name` regexp '^[a-zA-Z -]{1,80}$'),
+  role ENUM('user', 'moderator', 'admin') NOT NULL DEFAULT 'user',
+  disabled_at TIMESTAMP NULL,
+  email_verified_at TIMESTAMP NULL,
+  verification_sent_at TIMESTAMP NULL,
+  -- pending_email is the address the user is changing their email to, until they verify it
+  pending_email VARCHAR(255) NULL,
+  -- delete_after is when an account its user asked to delete is deleted, unless they log in before then
+  delete_after TIMESTAMP NULL,
+  -- who may see the profile and the learning list: everyone, signed in users or only the user
+  profile_visibility ENUM('public', 'organization', 'private') NOT NULL DEFAULT 'public',
+  learnings_visibility ENUM('public', 'organization', 'private') NOT NULL DEFAULT 'public',
+  FULLTEXT (username, first_name, last_name)
 );
 
+CREATE TABLE categories (
+  id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT UNSIGNED NULL,
+  name VARCHAR(50) NOT NULL CHECK (`name` regexp '^.{1,50}$'),
+  position INT NOT NULL DEFAULT 0,
+  UNIQUE (user_id, name),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Global default categories have no owner and are visible to every user
+INSERT INTO categories (user_id, name, position) VALUES
+  (NULL, 'Languages', 0),
+  (NULL, 'Technologies', 1),
+  (NULL, 'Concepts', 2),
+  (NULL, 'Projects', 3),
+  (NULL, 'Other', 4);
+
 CREATE TABLE user_learning_list (
   id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
   user_id BIGINT UNSIGNED NOT NULL,
   title VARCHAR(255) NOT NULL CHECK (`title` regexp '^.{1,100}$'),
-  category ENUM('Languages', 'Technologies', 'Concepts', 'Projects', 'Other') NOT NULL,
-  UNIQUE (user_id, title, category),
-  FOREIGN KEY (user_id) REFERENCES users(id)
-);
\ No newline at end of file
+  category_id BIGINT UNSIGNED NOT NULL,
+  status ENUM('planned', 'in_progress', 'completed', 'abandoned') NOT NULL DEFAULT 'planned',
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  started_at TIMESTAMP NULL,
+  completed_at TIMESTAMP NULL,
+  -- visibility overrides the owner's learnings_visibility for this item when it is set
+  visibility ENUM('public', 'organization', 'private') NULL,
+  UNIQUE (user_id, title, category_id),
+  FULLTEXT (title),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (category_id) REFERENCES categories(id)
+);
+
+CREATE TABLE tags (
+  id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT UNSIGNED NOT NULL,
+  name VARCHAR(30) NOT NULL CHECK (`name` regexp '^[a-z0-9 _+#.-]{1,30}$'),
+  UNIQUE (user_id, name),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE learning_tags (
+  learning_id BIGINT UNSIGNED NOT NULL,
+  tag_id BIGINT UNSIGNED NOT NULL,
+  PRIMARY KEY (learning_id, tag_id),
+  FOREIGN KEY (learning_id) REFERENCES user_learning_list(id) ON DELETE CASCADE,
+  FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE TABLE refresh_tokens (
+  id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT UNSIGNED NOT NULL,
+  family_id VARCHAR(64) NOT NULL,
+  token_hash CHAR(64) NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP NULL,
+  INDEX (family_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE revoked_tokens (
+  jti VARCHAR(64) PRIMARY KEY,
+  expires_at TIMESTAMP NOT NULL,
+  INDEX (expires_at)
+);
+
+CREATE TABLE user_token_revocations (
+  user_id BIGINT UNSIGNED PRIMARY KEY,
+  issued_before TIMESTAMP(6) NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  INDEX (expires_at),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE personal_access_tokens (
+  id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT UNSIGNED NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  token_hash CHAR(64) NOT NULL UNIQUE,
+  scopes VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NULL,
+  last_used_at TIMESTAMP NULL,
+  revoked_at TIMESTAMP NULL,
+  INDEX (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE password_reset_tokens (
+  id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT UNSIGNED NOT NULL,
+  token_hash CHAR(64) NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP NULL,
+  INDEX (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+-- TOTP secrets are encrypted by the server, confirmed_at is set once the user has entered a first code
+CREATE TABLE totp_secrets (
+  user_id BIGINT UNSIGNED PRIMARY KEY,
+  secret_ciphertext VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  confirmed_at TIMESTAMP NULL,
+  last_used_step BIGINT NOT NULL DEFAULT 0,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes (
+  id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT UNSIGNED NOT NULL,
+  code_hash CHAR(64) NOT NULL,
+  used_at TIMESTAMP NULL,
+  UNIQUE (user_id, code_hash),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Failed logins keyed by account ("user:<id>"), unknown identifier ("identifier:<name>") or client address ("ip:<address>")
+CREATE TABLE login_attempts (
+  attempt_key VARCHAR(255) PRIMARY KEY,
+  failures INT UNSIGNED NOT NULL,
+  last_failure_at TIMESTAMP NOT NULL,
+  locked_until TIMESTAMP NULL,
+  INDEX (last_failure_at)
+);
+
+-- Audit events outlive the users they are about, so subject is a plain key rather than a foreign key
+CREATE TABLE audit_events (
+  id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+  action VARCHAR(50) NOT NULL,
+  actor_id BIGINT UNSIGNED NULL,
+  subject VARCHAR(255) NOT NULL,
+  detail VARCHAR(255) NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX (subject),
+  FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL
+);